@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+var skipAssertionsBuildOption bool
+
+// checkRows returns an error if the number of rows isn't the one expected by
+// the assertion.
+func checkRows(assertion config.AssertionConfig, rows int) error {
+	minRows := 1
+	if assertion.MinRows != nil {
+		minRows = *assertion.MinRows
+	}
+	if rows < minRows {
+		return errors.New("Expected at least " + strconv.Itoa(minRows) + " rows, got " + strconv.Itoa(rows) + ".")
+	}
+	if assertion.MaxRows != nil && rows > *assertion.MaxRows {
+		return errors.New("Expected at most " + strconv.Itoa(*assertion.MaxRows) + " rows, got " + strconv.Itoa(rows) + ".")
+	}
+	return nil
+}
+
+// checkAssertion issues the query of the assertion and checks its results.
+func checkAssertion(assertion config.AssertionConfig) error {
+	repo, err := sparql.GetRepository(assertion.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	if sparql.QueryForm(repo.QueryIndex[assertion.Query]) == "ASK" {
+		if assertion.MinRows != nil || assertion.MaxRows != nil {
+			return errors.New("The query " + assertion.Query + " is an ASK query, which doesn't return rows.")
+		}
+		answer, err := repo.Ask(assertion.Query)
+		if err != nil {
+			return err
+		}
+		expected := assertion.Ask == nil || *assertion.Ask
+		if answer != expected {
+			return errors.New("Expected the query to answer " + strconv.FormatBool(expected) + ".")
+		}
+		return nil
+	}
+
+	if assertion.Ask != nil {
+		return errors.New("The query " + assertion.Query + " isn't an ASK query.")
+	}
+	results, err := repo.Query(assertion.Query)
+	if err != nil {
+		return err
+	}
+	return checkRows(assertion, len(results))
+}
+
+// checkAssertions checks every assertion of the configuration and stops at
+// the first that fails.
+func checkAssertions() error {
+	for _, assertion := range config.CurrentSiteConfig.Assertions {
+		if err := checkAssertion(assertion); err != nil {
+			return utils.ErrorExit("The assertion "+assertion.Name+" failed.", err)
+		}
+		logger.Debug("Passed the assertion " + assertion.Name + ".")
+	}
+	return nil
+}
@@ -1,29 +1,100 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/glaciers-in-archives/snowman/internal/archive"
+	"github.com/glaciers-in-archives/snowman/internal/basepath"
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/compress"
 	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/feed"
+	"github.com/glaciers-in-archives/snowman/internal/hooks"
+	"github.com/glaciers-in-archives/snowman/internal/images"
+	"github.com/glaciers-in-archives/snowman/internal/incremental"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/manifest"
+	"github.com/glaciers-in-archives/snowman/internal/meta"
+	"github.com/glaciers-in-archives/snowman/internal/minifier"
+	"github.com/glaciers-in-archives/snowman/internal/progress"
+	"github.com/glaciers-in-archives/snowman/internal/redirects"
+	"github.com/glaciers-in-archives/snowman/internal/report"
+	"github.com/glaciers-in-archives/snowman/internal/search"
+	"github.com/glaciers-in-archives/snowman/internal/sitemap"
 	"github.com/glaciers-in-archives/snowman/internal/sparql"
 	"github.com/glaciers-in-archives/snowman/internal/static"
 	"github.com/glaciers-in-archives/snowman/internal/utils"
 	"github.com/glaciers-in-archives/snowman/internal/views"
-	"github.com/knakk/rdf"
+	"github.com/glaciers-in-archives/snowman/internal/watcher"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // CLI FLAGS
 var cacheBuildOption string
 var staticBuildOption bool
-var configFileLocation string
+var jobsBuildOption int
+var offlineBuildOption bool
+var incrementalBuildOption bool
+var endpointBuildOption string
+var outputDirBuildOption string
+var staticDirBuildOption string
+var templatesDirBuildOption string
+var dryRunBuildOption bool
+var strictBuildOption bool
+var failFastBuildOption bool
+var minifyBuildOption bool
+var compressBuildOption bool
+var archiveBuildOption string
+var metaBuildOption bool
+var manifestBuildOption bool
+var forceBuildOption bool
+var outputBuildOption string
+var watchBuildOption bool
+var includeDraftsBuildOption bool
+var basePathBuildOption string
+var varsBuildOption []string
+var profileBuildOption string
+var onlyBuildOption []string
+var withDependenciesBuildOption bool
+var shareResultsBuildOption bool
+
+// configOverrides returns the configuration overrides given through flags or
+// environment variables. Flags take precedence over environment variables.
+func configOverrides() config.Overrides {
+	endpoint := endpointBuildOption
+	if endpoint == "" {
+		endpoint = os.Getenv("SNOWMAN_ENDPOINT")
+	}
+
+	profile := profileBuildOption
+	if profile == "" {
+		profile = os.Getenv("SNOWMAN_PROFILE")
+	}
+
+	return config.Overrides{
+		Endpoint:     endpoint,
+		Profile:      profile,
+		OutputDir:    outputDirBuildOption,
+		StaticDir:    staticDirBuildOption,
+		TemplatesDir: templatesDirBuildOption,
+		BasePath:     basePathBuildOption,
+		Vars:         varsBuildOption,
+	}
+}
 
 func DiscoverLayouts() ([]string, error) {
 	var paths []string
-	filepath.Walk("templates/layouts", func(path string, info os.FileInfo, err error) error {
+	filepath.Walk(config.CurrentSiteConfig.TemplatesDir+"/layouts", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -35,11 +106,50 @@ func DiscoverLayouts() ([]string, error) {
 	return paths, nil
 }
 
+// DiscoverIncludes returns the templates in the include directories, which are
+// parsed with every view like the layouts. Templates are named by their file
+// names, so two of them, or an include and a layout, can't have the same one.
+func DiscoverIncludes(layouts []string) ([]string, error) {
+	names := map[string]string{}
+	for _, layout := range layouts {
+		names[filepath.Base(layout)] = layout
+	}
+
+	var paths []string
+	for _, dir := range config.CurrentSiteConfig.IncludeDirs {
+		dir = filepath.Join(config.CurrentSiteConfig.TemplatesDir, filepath.FromSlash(dir))
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			logger.Debug("Failed to locate the include directory " + dir + ". Skipping...")
+			continue
+		}
+
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			name := filepath.Base(path)
+			if other, exists := names[name]; exists {
+				if other == path {
+					return nil
+				}
+				return errors.New("The templates " + other + " and " + path + " are both named " + name + ".")
+			}
+			names[name] = path
+			paths = append(paths, path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
 func DiscoverQueries() (map[string]string, error) {
 	var index = make(map[string]string)
 
 	if _, err := os.Stat("queries"); os.IsNotExist(err) {
-		printVerbose("Failed to locate query files. Skipping...")
+		logger.Debug("Failed to locate query files. Skipping...")
 		return index, nil
 	}
 
@@ -64,121 +174,546 @@ func DiscoverQueries() (map[string]string, error) {
 	return index, nil
 }
 
-// buildCmd represents the build command
-var buildCmd = &cobra.Command{
-	Use:   "build",
-	Short: "Builds a Snowman site in the current directory.",
-	Long:  `Tries to locate the Snowman configuration, views, queries, etc in the current directory. Then tries to build a Snowman site.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
+// sharedInputs returns a hash of the inputs that affect every view: the config
+// file, fingerprinted static files and all templates that aren't the main
+// template of a view, such as layouts and includes.
+func sharedInputs(discoveredViews []views.View) (string, error) {
+	viewTemplates := map[string]bool{}
+	for _, view := range discoveredViews {
+		viewTemplates[filepath.Join(config.CurrentSiteConfig.TemplatesDir, view.ViewConfig.TemplateFile)] = true
+	}
 
-		if staticBuildOption {
-			if err := static.ClearStatic(); err != nil {
-				utils.ErrorExit("Failed to clear old static files: ", err)
-			}
+	var templates []string
+	err := filepath.Walk(config.CurrentSiteConfig.TemplatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !viewTemplates[path] {
+			templates = append(templates, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
 
-			if err := static.CopyIn(); err != nil {
-				utils.ErrorExit("Failed to copy new static files: ", err)
+	templatesHash, err := incremental.HashFiles(templates)
+	if err != nil {
+		return "", err
+	}
+
+	configHash, err := incremental.HashFiles([]string{configFileLocation})
+	if err != nil {
+		return "", err
+	}
+
+	return incremental.Hash(templatesHash, configHash, fmt.Sprint(static.Assets), fmt.Sprint(config.CurrentSiteConfig.Variables), config.CurrentSiteConfig.Profile), nil
+}
+
+// build runs a build of the project in the current directory, collecting
+// metrics in the given report. Incremental builds skip unchanged views.
+func build(buildReport *report.Report, incrementalBuild bool) error {
+	if _, exists := archive.Formats[archiveBuildOption]; archiveBuildOption != "" && !exists {
+		return errors.New("The archive format must be either zip or targz.")
+	}
+	if jobsBuildOption < 1 {
+		return errors.New("The number of jobs must be at least 1.")
+	}
+
+	if sinceBuildOption != "" && (incrementalBuild || watchBuildOption) {
+		return errors.New("The since flag can't be combined with incremental builds or watching.")
+	}
+	since, err := parseSince(sinceBuildOption)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+
+	overrides := configOverrides()
+	overrides.Since = since
+	err = config.LoadConfig(configFileLocation, overrides)
+	if err != nil {
+		return err
+	}
+
+	layouts, err := DiscoverLayouts()
+	if err != nil {
+		return utils.ErrorExit("Failed to find any template files.", err)
+	}
+	includes, err := DiscoverIncludes(layouts)
+	if err != nil {
+		return utils.ErrorExit("Failed to discover the includes.", err)
+	}
+
+	queries, err := DiscoverQueries()
+	if err != nil {
+		return utils.ErrorExit("Failed to index query files.", err)
+	}
+
+	cacheStrategy := cacheBuildOption
+	if offlineBuildOption {
+		if cacheStrategy == "never" {
+			return errors.New("The offline flag can't be combined with the never cache strategy.")
+		}
+		cacheStrategy = "offline"
+	}
+
+	err = sparql.NewRepository(cacheStrategy, queries)
+	if err != nil {
+		return utils.ErrorExit("Failed to initiate SPARQL client.", err)
+	}
+	sparql.CurrentRepository.CacheManager.Session = watchSession
+	if incrementalBuild && watchSession == nil {
+		// the responses hashed to find the changed views are rendered from the
+		// cache, even with the never cache strategy
+		sparql.CurrentRepository.CacheManager.Session = cache.NewSession()
+	}
+	if shareResultsBuildOption {
+		sparql.ShareResults()
+	}
+
+	discoveredViews, err := views.DiscoverViews(append(layouts, includes...), queries, strictBuildOption)
+	if err != nil {
+		return utils.ErrorExit("Failed to discover views.", err)
+	}
+	if !includeDraftsBuildOption && !config.CurrentSiteConfig.IncludeDrafts {
+		discoveredViews = views.SkipDrafts(discoveredViews)
+	}
+	// the views left out by --only or --since keep their pages and incremental
+	// state
+	var skippedViews []views.View
+	if partialBuild() {
+		selectedViews := discoveredViews
+		if len(onlyBuildOption) > 0 {
+			selectedViews, err = views.Only(discoveredViews, onlyBuildOption, withDependenciesBuildOption)
+			if err != nil {
+				return utils.ErrorExit("Failed to select the views to build.", err)
+			}
+		}
+		if since != nil {
+			// only the views filtering by the since parameter have changes
+			selectedViews = sinceViews(selectedViews, queries)
+			logger.Info("Building the pages of resources changed since " + since.Format(time.RFC3339) + ".")
+		}
+		selected := map[string]bool{}
+		for _, view := range selectedViews {
+			selected[view.ViewConfig.Output] = true
+		}
+		for _, view := range discoveredViews {
+			if !selected[view.ViewConfig.Output] {
+				skippedViews = append(skippedViews, view)
 			}
+		}
+		logger.Info("Building " + strconv.Itoa(len(selectedViews)) + " of " + strconv.Itoa(len(discoveredViews)) + " views.")
+		discoveredViews = selectedViews
+	} else {
+		logger.Info("Building project with " + strconv.Itoa(len(discoveredViews)) + " views.")
+	}
 
-			printVerbose("Finished updating static files.")
-			return nil
+	// assertions are checked before the existing site is removed, so that a
+	// failing one leaves the last good build in place
+	if skipAssertionsBuildOption {
+		if len(config.CurrentSiteConfig.Assertions) > 0 {
+			logger.Debug("Skipping the assertions.")
+		}
+	} else if err := checkAssertions(); err != nil {
+		return err
+	}
+
+	if dryRunBuildOption {
+		logger.Debug("Dry run, nothing will be written to the site directory.")
+	} else if incrementalBuild || partialBuild() {
+		logger.Debug("Keeping existing files in the site directory.")
+	} else if err := cleanSite(); err != nil {
+		return utils.ErrorExit("Failed to remove the existing site directory.", err)
+	}
+
+	if dryRunBuildOption {
+		if len(config.CurrentSiteConfig.PreBuild) > 0 || len(config.CurrentSiteConfig.PostBuild) > 0 {
+			logger.Debug("Dry run, skipping the pre_build and post_build commands.")
 		}
+	} else if err := hooks.Run(config.CurrentSiteConfig.PreBuild); err != nil {
+		return utils.ErrorExit("Failed to run the pre_build commands.", err)
+	}
 
-		err := config.LoadConfig(configFileLocation)
+	images.Reset()
+	if _, err := os.Stat(config.CurrentSiteConfig.StaticDir); os.IsNotExist(err) {
+		logger.Debug("Failed to locate static files. Skipping...")
+	} else if !dryRunBuildOption {
+		files, bytes, err := static.CopyIn(jobsBuildOption)
 		if err != nil {
-			return err
+			return utils.ErrorExit("Failed to copy static files.", err)
 		}
+		buildReport.StaticFiles, buildReport.StaticBytes = files, bytes
+		logger.Debug("Finished copying static files.")
+	}
 
-		layouts, err := DiscoverLayouts()
+	options := renderOptions{
+		jobs:     jobsBuildOption,
+		dryRun:   dryRunBuildOption,
+		strict:   strictBuildOption,
+		failFast: failFastBuildOption,
+		progress: !dryRunBuildOption && logger.Enabled(logger.InfoLevel) && progress.IsTerminal(os.Stdout),
+		// only incremental builds skip views, watched builds record the state
+		// for the incremental rebuilds that follow
+		force:      forceBuildOption || !incrementalBuild,
+		since:      since != nil,
+		errorPages: developing,
+	}
+	for _, view := range discoveredViews {
+		if len(view.ViewConfig.Search) > 0 {
+			options.search = search.NewIndex()
+			break
+		}
+	}
+	// the first build while developing records the state for the rebuilds
+	if incrementalBuild || developing {
+		options.state = incremental.LoadState()
+		options.sharedInputs, err = sharedInputs(discoveredViews)
 		if err != nil {
-			return utils.ErrorExit("Failed to find any template files.", err)
+			return utils.ErrorExit("Failed to hash the templates.", err)
+		}
+	}
+
+	if interrupted.Err() != nil {
+		return interruptedBuild(incrementalBuild)
+	}
+	rendered, err := renderViews(discoveredViews, buildReport, options)
+	if interrupted.Err() != nil {
+		return interruptedBuild(incrementalBuild)
+	}
+	if err != nil {
+		return err
+	}
+	pages := rendered.list()
+	buildReport.PagePaths = pages
+	if developing {
+		for _, view := range discoveredViews {
+			developedPages[view.ViewConfig.Output] = rendered.of(view.ViewConfig.Output)
 		}
+	}
+	if shareResultsBuildOption {
+		buildReport.DeduplicatedQueries = sparql.Deduplicated()
+		logger.Info("Deduplicated " + strconv.Itoa(buildReport.DeduplicatedQueries) + " view queries.")
+	}
 
-		queries, err := DiscoverQueries()
+	if options.state != nil && !dryRunBuildOption {
+		for _, view := range skippedViews {
+			if previous, exists := options.state.Previous(view.ViewConfig.Output); exists {
+				options.state.Record(view.ViewConfig.Output, previous)
+			}
+		}
+		for _, page := range options.state.StalePages() {
+			logger.Debug("Removing stale page " + page)
+			if err := os.Remove(page); err != nil && !os.IsNotExist(err) {
+				return utils.ErrorExit("Failed to remove a stale page.", err)
+			}
+		}
+
+		if err := options.state.Save(); err != nil {
+			return utils.ErrorExit("Failed to save the build state.", err)
+		}
+	}
+
+	if dryRunBuildOption {
+		buildReport.Print(logger.Output())
+		logger.Info("Finished dry run.")
+		return nil
+	}
+
+	if config.CurrentSiteConfig.RewriteLinks && config.CurrentSiteConfig.BasePath != "" {
+		for _, page := range pages {
+			if extension := strings.ToLower(filepath.Ext(page)); extension != ".html" && extension != ".htm" {
+				continue
+			}
+			if err := basepath.RewriteFile(page, config.CurrentSiteConfig.BasePath); err != nil {
+				return utils.ErrorExit("Failed to rewrite the links of "+page+".", err)
+			}
+		}
+		logger.Debug("Finished rewriting links under the base path.")
+	}
+
+	// the site-wide files would only list the pages of the selected views
+	if partialBuild() {
+		logger.Debug("Only building some views, skipping the sitemap, build manifest and search index.")
+	}
+
+	if config.CurrentSiteConfig.BaseURL != "" && !partialBuild() {
+		exclude := config.CurrentSiteConfig.Sitemap.Exclude
+		if config.CurrentSiteConfig.NotFoundTemplate != "" {
+			exclude = append([]string{config.NotFoundPage}, exclude...)
+		}
+		if err := sitemap.Write(config.CurrentSiteConfig.OutputDir, config.CurrentSiteConfig.BaseURL, pages, exclude, config.CurrentSiteConfig.SourceDate); err != nil {
+			return utils.ErrorExit("Failed to write the sitemap.", err)
+		}
+		logger.Debug("Finished writing the sitemap.")
+	}
+
+	if redirectsConfig := config.CurrentSiteConfig.Redirects; len(redirectsConfig.Rules) > 0 {
+		if _, err := os.Stat(filepath.Join(config.CurrentSiteConfig.StaticDir, redirects.NetlifyFile)); err == nil && redirectsConfig.Format == "netlify" {
+			return errors.New("The static directory has a " + redirects.NetlifyFile + " file, which the redirects would replace.")
+		}
+		if err := redirects.Write(config.CurrentSiteConfig.OutputDir, config.CurrentSiteConfig.BasePath, redirectsConfig.Format, redirectsConfig.Rules, append(pages, buildReport.StaticFiles...)); err != nil {
+			return utils.ErrorExit("Failed to write the redirects.", err)
+		}
+		logger.Debug("Finished writing the redirects.")
+	}
+
+	for _, feedConfig := range config.CurrentSiteConfig.Feeds {
+		if err := writeFeed(feedConfig); err != nil {
+			return utils.ErrorExit("Failed to write the feed "+feedConfig.Output+".", err)
+		}
+		logger.Debug("Finished writing the feed " + feedConfig.Output + ".")
+	}
+
+	if manifestBuildOption && !partialBuild() {
+		if err := manifest.Write(config.CurrentSiteConfig.OutputDir, rendered.manifest()); err != nil {
+			return utils.ErrorExit("Failed to write the build manifest.", err)
+		}
+		logger.Debug("Finished writing the build manifest.")
+	}
+
+	if options.search != nil && !partialBuild() {
+		path := filepath.Join(config.CurrentSiteConfig.OutputDir, filepath.FromSlash(config.CurrentSiteConfig.Search.Output))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			return utils.ErrorExit("Failed to create the directory of the search index.", err)
+		}
+		if err := search.Write(path, options.search.Documents()); err != nil {
+			return utils.ErrorExit("Failed to write the search index.", err)
+		}
+		logger.Debug("Finished writing the search index.")
+	}
+
+	if minifyBuildOption {
+		result, err := minifier.Directory(config.CurrentSiteConfig.OutputDir, jobsBuildOption)
 		if err != nil {
-			return utils.ErrorExit("Failed to index query files.", err)
+			return utils.ErrorExit("Failed to minify the built site.", err)
 		}
+		buildReport.MinifiedFiles, buildReport.MinifySaved = result.Files, result.BytesSaved
+		logger.Info("Minified " + strconv.Itoa(result.Files) + " files, saving " + strconv.FormatInt(result.BytesSaved, 10) + " bytes.")
+	}
 
-		err = sparql.NewRepository(cacheBuildOption, queries, verbose)
+	if compressBuildOption {
+		compression := config.CurrentSiteConfig.Compression
+		result, err := compress.Directory(config.CurrentSiteConfig.OutputDir, compression.Extensions, *compression.MinSize, jobsBuildOption)
 		if err != nil {
-			return utils.ErrorExit("Failed to initiate SPARQL client.", err)
+			return utils.ErrorExit("Failed to compress the built site.", err)
 		}
+		buildReport.CompressedFiles, buildReport.CompressionSaved = result.Files, result.BytesSaved
+		logger.Info("Wrote " + strconv.Itoa(result.Files) + " compressed files, saving " + strconv.FormatInt(result.BytesSaved, 10) + " bytes.")
+	}
 
-		discoveredViews, err := views.DiscoverViews(layouts)
+	if metaBuildOption {
+		fingerprinted := map[string]bool{}
+		for _, hashed := range static.Assets {
+			fingerprinted[filepath.ToSlash(hashed)] = true
+		}
+		if err := meta.Write(config.CurrentSiteConfig.OutputDir, config.CurrentSiteConfig.Meta, fingerprinted); err != nil {
+			return utils.ErrorExit("Failed to write the meta manifest.", err)
+		}
+		logger.Debug("Finished writing the meta manifest.")
+	}
+
+	if archiveBuildOption != "" {
+		path, err := archive.Write(config.CurrentSiteConfig.OutputDir, archiveBuildOption, config.CurrentSiteConfig.SourceDate)
 		if err != nil {
-			return utils.ErrorExit("Failed to discover views.", err)
+			return utils.ErrorExit("Failed to archive the built site.", err)
 		}
+		logger.Info("Wrote the site to " + path + ".")
+	}
 
-		if err := os.RemoveAll("site"); err != nil {
-			return utils.ErrorExit("Failed to remove the existing site directory.", err)
+	if err := hooks.Run(config.CurrentSiteConfig.PostBuild); err != nil {
+		return utils.ErrorExit("Failed to run the post_build commands.", err)
+	}
+
+	if err := sparql.CurrentRepository.CacheManager.Teardown(); err != nil {
+		return utils.ErrorExit("Failed write used queries to cache memory.", err)
+	}
+
+	if logger.Enabled(logger.DebugLevel) {
+		buildReport.Print(logger.Output())
+	}
+
+	// a build of some views doesn't cover the changes to the others
+	if len(onlyBuildOption) == 0 {
+		if err := recordBuildTime(start); err != nil {
+			return utils.ErrorExit("Failed to save the time of the build.", err)
 		}
+	}
 
-		if _, err := os.Stat("static"); os.IsNotExist(err) {
-			printVerbose("Failed to locate static files. Skipping...")
+	logger.Info("Finished building project.")
+	return nil
+}
+
+// writeFeed queries the entries of a feed and writes it to the site directory.
+func writeFeed(feedConfig config.FeedConfig) error {
+	repo, err := sparql.GetRepository(feedConfig.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	results, err := repo.Query(feedConfig.Query)
+	if err != nil {
+		return utils.ErrorExit("SPARQL query failed.", err)
+	}
+
+	entries, err := feed.Entries(feedConfig, config.CurrentSiteConfig.BaseURL, results)
+	if err != nil {
+		return err
+	}
+
+	data, err := feed.Marshal(feedConfig, config.CurrentSiteConfig.BaseURL, entries)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(config.CurrentSiteConfig.OutputDir, feedConfig.Output)
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// rebuiltViews returns the outputs of the views which rendered pages.
+func rebuiltViews(buildReport *report.Report) []string {
+	var outputs []string
+	for _, view := range buildReport.Views {
+		if view.Pages > 0 {
+			outputs = append(outputs, view.Output)
+		}
+	}
+	return outputs
+}
+
+// watchBuild builds the site and rebuilds it whenever project files change.
+// Rebuilds are incremental and failing builds don't stop the watching.
+func watchBuild() error {
+	developing = true
+	reuseResults(nil)
+	if err := build(report.NewReport(), incrementalBuildOption); err != nil {
+		logger.Error(err.Error())
+	}
+
+	w, err := watcher.NewWatcher(watchedPaths(), 100*time.Millisecond)
+	if err != nil {
+		return utils.ErrorExit("Failed to watch project files.", err)
+	}
+	defer w.Close()
+
+	logger.Info("Watching for changes. Hold ctrl+c to exit.")
+	return w.Run(func(changed []string) {
+		for _, path := range changed {
+			logger.Debug("Changed: " + path)
+		}
+
+		logger.Info("Rebuilding site...")
+		reuseResults(changed)
+		buildReport := report.NewReport()
+		if err := build(buildReport, true); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+
+		if rebuilt := rebuiltViews(buildReport); len(rebuilt) > 0 {
+			logger.Info("Rebuilt views: " + strings.Join(rebuilt, ", "))
 		} else {
-			if err := static.CopyIn(); err != nil {
-				return utils.ErrorExit("Failed to copy static files.", err)
-			}
-			printVerbose("Finished copying static files.")
+			logger.Info("No views needed rebuilding.")
 		}
+	})
+}
 
-		var renderedPaths = make(map[string]bool)
-		for _, view := range discoveredViews {
-			results := make([]map[string]rdf.Term, 0)
-			if view.ViewConfig.QueryFile != "" {
-				printVerbose("Issuing query " + view.ViewConfig.QueryFile)
-				results, err = sparql.CurrentRepository.Query(view.ViewConfig.QueryFile)
-				if err != nil {
-					return utils.ErrorExit("SPARQL query failed.", err)
-				}
+// buildCmd represents the build command
+var buildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Builds a Snowman site in the current directory.",
+	Long:  `Tries to locate the Snowman configuration, views, queries, etc in the current directory. Then tries to build a Snowman site.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if staticBuildOption {
+			if jobsBuildOption < 1 {
+				return errors.New("The number of jobs must be at least 1.")
+			}
+			if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+				return err
 			}
 
-			// if the page is rendered based on SPARQL result rows
-			if view.MultipageVariableHook != nil {
-				for _, row := range results {
-					pathSection := row[*view.MultipageVariableHook].String()
-					if utils.ValidatePathSection(pathSection); err != nil {
-						return utils.ErrorExit("Failed to validate path section.", err)
-					}
-
-					outputPath := "site/" + strings.Replace(view.ViewConfig.Output, "{{"+*view.MultipageVariableHook+"}}", pathSection, 1)
-
-					if renderedPaths[outputPath] {
-						fmt.Println("Warning: Writing to " + outputPath + " for the second time.")
-					}
-
-					if err := view.RenderPage(outputPath, row); err != nil {
-						return utils.ErrorExit("Failed to render page at "+outputPath, err)
-					}
-					printVerbose("Rendered page at site/" + outputPath)
-					renderedPaths[outputPath] = true
-				}
-			} else {
-				if renderedPaths["site/"+view.ViewConfig.Output] {
-					fmt.Println("Warning: Writing to " + "site/" + view.ViewConfig.Output + " for the second time.")
-				}
+			if err := static.ClearStatic(); err != nil {
+				utils.ErrorExit("Failed to clear old static files: ", err)
+			}
 
-				if err := view.RenderPage("site/"+view.ViewConfig.Output, results); err != nil {
-					return utils.ErrorExit("Failed to render page at "+"site/"+view.ViewConfig.Output, err)
-				}
-				printVerbose("Rendered page at site/" + view.ViewConfig.Output)
-				renderedPaths["site/"+view.ViewConfig.Output] = true
+			if _, _, err := static.CopyIn(jobsBuildOption); err != nil {
+				utils.ErrorExit("Failed to copy new static files: ", err)
 			}
 
+			logger.Debug("Finished updating static files.")
+			return nil
 		}
 
-		if err := sparql.CurrentRepository.CacheManager.Teardown(); err != nil {
-			return utils.ErrorExit("Failed write used queries to cache memory.", err)
+		if watchBuildOption {
+			if dryRunBuildOption || outputBuildOption != "text" {
+				return errors.New("The watch flag can't be combined with dry runs or JSON output.")
+			}
+			return watchBuild()
 		}
+		defer trapInterrupts()()
 
-		fmt.Println("Finished building project.")
-		return nil
+		if outputBuildOption == "json" {
+			// the JSON report replaces the text otherwise printed during the build
+			buildReport := report.NewReport()
+			logger.SetOutput(io.Discard)
+			buildErr := build(buildReport, incrementalBuildOption)
+			logger.SetOutput(os.Stdout)
+
+			if err := buildReport.WriteJSON(os.Stdout, buildErr); err != nil {
+				return err
+			}
+			// the error is part of the report, but still sets the exit code
+			cmd.SilenceErrors = true
+			return buildErr
+		} else if outputBuildOption != "text" {
+			return errors.New("The output format must be either text or json.")
+		}
+
+		return build(report.NewReport(), incrementalBuildOption)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
-	buildCmd.Flags().StringVarP(&cacheBuildOption, "cache", "c", "available", "Sets the cache strategy. \"available\" will use cached SPARQL responses when available and fallback to making queries. \"never\" will ignore existing cache and will not update or set new cache.")
+	addBuildFlags(buildCmd.Flags())
 	buildCmd.Flags().BoolVarP(&staticBuildOption, "static", "s", false, "When set Snowman will only build static files.")
-	buildCmd.Flags().StringVarP(&configFileLocation, "config", "f", "snowman.yaml", "Sets the config file to use.")
+	buildCmd.Flags().BoolVar(&incrementalBuildOption, "incremental", false, "When set Snowman will keep existing files in the site directory and skip views whose templates and query results are unchanged since the last incremental build.")
+	buildCmd.Flags().BoolVar(&forceBuildOption, "force", false, "When set with --incremental Snowman will render all views, even unchanged ones.")
+	buildCmd.Flags().BoolVar(&dryRunBuildOption, "dry-run", false, "When set Snowman will run the queries and print the pages it would build without writing any files to the site directory.")
+	buildCmd.Flags().StringVar(&archiveBuildOption, "archive", "", "Packages the built site into an archive next to the site directory. Either \"zip\" or \"targz\".")
+	buildCmd.Flags().StringVar(&outputBuildOption, "output", "text", "Sets the output format. \"json\" replaces the text output with a report of the build for use in CI.")
+	buildCmd.Flags().BoolVar(&keepInterruptedBuildOption, "keep-interrupted", false, "When set Snowman will keep the pages written by an interrupted build instead of clearing the incomplete site directory.")
+	buildCmd.Flags().StringSliceVar(&onlyBuildOption, "only", nil, "Only builds the views whose output or template matches one of the given names or glob patterns, like \"items/*\". Existing files in the site directory are kept.")
+	buildCmd.Flags().StringVar(&sinceBuildOption, "since", "", "Only rebuilds the views whose queries use the {{since}} parameter, with the given date, time or \"last\" for the start of the last build. Existing files in the site directory are kept.")
+	buildCmd.Flags().BoolVar(&withDependenciesBuildOption, "with-dependencies", false, "When set with --only Snowman will also build the views the selected views depend on.")
+	buildCmd.Flags().BoolVarP(&watchBuildOption, "watch", "w", false, "When set Snowman will keep running and rebuild the views affected by changes to the project files.")
+}
+
+// addBuildFlags registers the flags changing how the site is built, which are
+// shared by the commands building it.
+func addBuildFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&cacheBuildOption, "cache", "c", "available", "Sets the cache strategy. \"available\" will use cached SPARQL responses when available and fallback to making queries. \"never\" will ignore existing cache and will not update or set new cache.")
+	flags.BoolVar(&offlineBuildOption, "offline", false, "When set Snowman will only use cached SPARQL responses and fail if a query has not been cached.")
+	flags.StringVar(&endpointBuildOption, "endpoint", "", "Overrides the endpoint of the default SPARQL client. Can also be set using the SNOWMAN_ENDPOINT environment variable.")
+	flags.StringVar(&outputDirBuildOption, "output-dir", "", "Sets the directory the site is built into. Defaults to output_dir in the config file or \"site\".")
+	flags.StringVar(&staticDirBuildOption, "static-dir", "", "Sets the directory static files are copied from. Defaults to static_dir in the config file or \"static\".")
+	flags.StringVar(&templatesDirBuildOption, "templates-dir", "", "Sets the directory templates are read from. Defaults to templates_dir in the config file or \"templates\".")
+	flags.StringVar(&basePathBuildOption, "base-path", "", "Sets the path the site is served from, like /project, which the url function prefixes onto internal links. Defaults to base_path in the config file.")
+	flags.StringVar(&profileBuildOption, "profile", "", "Selects a profile of the config file whose settings override the rest of it. Can also be set using the SNOWMAN_PROFILE environment variable.")
+	flags.StringArrayVar(&varsBuildOption, "var", nil, "Sets a variable as name=value for query parameters and the site.Vars of templates, overriding query_params and the params of views. Can be repeated.")
+	flags.BoolVar(&failFastBuildOption, "fail-fast", true, "Stop the build at the first page or view failing to render. Use --fail-fast=false to render everything else and report all failures at the end.")
+	flags.BoolVar(&strictBuildOption, "strict", false, "When set Snowman will fail the build on warnings, such as two pages written to the same output path, instead of printing them.")
+	flags.BoolVar(&compressBuildOption, "compress", false, "When set Snowman will write gzip and Brotli compressed copies of the built files next to them.")
+	flags.BoolVar(&metaBuildOption, "meta", false, "When set Snowman will write a manifest of the built files with suggested content types and cache headers to "+meta.FileName+".")
+	flags.BoolVar(&manifestBuildOption, "manifest", false, "When set Snowman will write a list of the rendered pages with the views, queries and bindings they were rendered from to "+manifest.FileName+".")
+	flags.BoolVar(&minifyBuildOption, "minify", false, "When set Snowman will minify the built HTML, CSS and JS files.")
+	flags.BoolVar(&shareResultsBuildOption, "share-results", false, "When set Snowman will issue identical view queries only once per build and share their results between the views, keeping them in memory.")
+	flags.BoolVar(&skipAssertionsBuildOption, "skip-assertions", false, "When set Snowman will build the site without checking the assertions of the config file.")
+	flags.BoolVar(&includeDraftsBuildOption, "include-drafts", false, "When set Snowman will also render the views marked as drafts.")
+	flags.IntVarP(&jobsBuildOption, "jobs", "j", runtime.NumCPU(), "Sets the number of views rendered and static files copied in parallel.")
 }
@@ -0,0 +1,335 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/report"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+)
+
+// hashDir returns a hash of the paths and contents of all files in dir.
+func hashDir(t *testing.T, dir string) string {
+	hash := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s %d %x\n", path, len(content), sha256.Sum256(content))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func TestReproducibleBuild(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/sparql-results+json")
+		fmt.Fprint(w, `{"head": {"vars": ["id", "date"]}, "results": {"bindings": [
+			{"id": {"type": "literal", "value": "b"}, "date": {"type": "literal", "value": "2021-01-02"}},
+			{"id": {"type": "literal", "value": "a"}, "date": {"type": "literal", "value": "2021-01-01"}}]}}`)
+	}))
+	defer server.Close()
+
+	files := map[string]string{
+		"snowman.yaml": "sparql_client:\n  endpoint: " + server.URL + "/sparql\n" +
+			"base_url: https://example.org\nfingerprint: [\"*.css\"]\n" +
+			"feeds:\n  - output: feed.xml\n    query: items.rq\n    title: Items\n    fields: {title: id, link: id, date: date}\n",
+		"views.yaml":             "views:\n  - output: index.html\n    query: items.rq\n    template: index.html\n    export: {output: items.csv}\n  - output: items/{{id}}.html\n    query: items.rq\n    template: item.html\n",
+		"queries/items.rq":       "SELECT ?id ?date WHERE {}",
+		"templates/index.html":   `<link href="{{ asset "main.css" }}">{{ range . }}{{ .id }}{{ end }} {{ now.Unix }} {{ site.BuildTime.Unix }}`,
+		"templates/item.html":    `{{ .id }} {{ now.Unix }}`,
+		"static/main.css":        "body { color: black; }",
+		"static/nested/data.txt": "data",
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	defer func(location, cache string, jobs int) {
+		configFileLocation, cacheBuildOption, jobsBuildOption = location, cache, jobs
+	}(configFileLocation, cacheBuildOption, jobsBuildOption)
+	configFileLocation, cacheBuildOption, jobsBuildOption = "snowman.yaml", "never", 4
+	logger.SetLevel(logger.ErrorLevel)
+	defer logger.SetLevel(logger.InfoLevel)
+	t.Setenv("SOURCE_DATE_EPOCH", "1600000000")
+
+	var hashes []string
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			// the files of the second build have other modification times
+			time.Sleep(1100 * time.Millisecond)
+		}
+		if err := build(report.NewReport(), false); err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hashDir(t, "site"))
+	}
+
+	if hashes[0] != hashes[1] {
+		t.Error("Expected two builds with the same inputs to have identical output")
+	}
+}
+
+func TestDiscoverIncludes(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"layouts/base.html", "includes/nav.html", "partials/card.html", "macros/base.html", "macros/nav.html"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(siteConfig config.SiteConfig) {
+		config.CurrentSiteConfig = siteConfig
+	}(config.CurrentSiteConfig)
+	config.CurrentSiteConfig.TemplatesDir = dir
+	layouts := []string{filepath.Join(dir, "layouts", "base.html")}
+
+	config.CurrentSiteConfig.IncludeDirs = []string{"includes", "partials", "missing"}
+	includes, err := DiscoverIncludes(layouts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprint([]string{filepath.Join(dir, "includes", "nav.html"), filepath.Join(dir, "partials", "card.html")})
+	if fmt.Sprint(includes) != expected {
+		t.Errorf("Expected the includes %s, got %s", expected, includes)
+	}
+
+	for _, dirs := range [][]string{{"macros"}, {"includes", "macros"}} {
+		config.CurrentSiteConfig.IncludeDirs = dirs
+		if _, err := DiscoverIncludes(layouts); err == nil {
+			t.Errorf("Expected an error for the templates with the same names in %s", dirs)
+		}
+	}
+}
+
+func TestUnusedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"page.html":            `{{ template "card" . }}{{ include "includes/footer.html" }}{{ range query "items.rq" }}{{ end }}`,
+		"includes/card.html":   `{{ define "card" }}{{ template "orphan.html" }}{{ end }}`,
+		"includes/footer.html": `<footer></footer>`,
+		"includes/orphan.html": `{{ define "unused" }}{{ end }}`,
+		"includes/lonely.html": `{{ define "lonely" }}{{ template "lonely" }}{{ end }}`,
+	}
+	var includes []string
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(name, "includes/") {
+			includes = append(includes, path)
+		}
+	}
+
+	defer func(siteConfig config.SiteConfig) {
+		config.CurrentSiteConfig = siteConfig
+	}(config.CurrentSiteConfig)
+	config.CurrentSiteConfig.TemplatesDir = dir
+	config.CurrentSiteConfig.Feeds = []config.FeedConfig{{Query: "feed.rq"}}
+
+	warnings, err := unusedFiles([]string{"items.rq", "feed.rq", "old.rq"}, includes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprint([]string{
+		"query old.rq: not used by any view, feed or template.",
+		"include " + filepath.Join(dir, "includes", "lonely.html") + ": not used by any view or template.",
+	})
+	if fmt.Sprint(warnings) != expected {
+		t.Errorf("Expected the warnings %s, got %s", expected, warnings)
+	}
+}
+
+func TestCheckRows(t *testing.T) {
+	zero, two := 0, 2
+	var tests = []struct {
+		assertion config.AssertionConfig
+		rows      int
+		passes    bool
+	}{
+		{config.AssertionConfig{}, 1, true},
+		{config.AssertionConfig{}, 0, false},
+		{config.AssertionConfig{MinRows: &zero, MaxRows: &zero}, 0, true},
+		{config.AssertionConfig{MaxRows: &two}, 3, false},
+		{config.AssertionConfig{MinRows: &two}, 2, true},
+	}
+	for _, test := range tests {
+		if err := checkRows(test.assertion, test.rows); (err == nil) != test.passes {
+			t.Errorf("Unexpected result %v for %d rows", err, test.rows)
+		}
+	}
+}
+
+func TestCacheWarm(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"head": {"vars": ["id"]}, "results": {"bindings": [{"id": {"type": "literal", "value": "a"}}]}}`)
+	}))
+	defer server.Close()
+
+	files := map[string]string{
+		"snowman.yaml":         "sparql_client:\n  endpoint: " + server.URL + "/sparql\n",
+		"views.yaml":           "views:\n  - output: index.html\n    query: index.rq\n    template: index.html\n  - output: items/{{id}}.html\n    query: items.rq\n    template: index.html\n",
+		"queries/index.rq":     "SELECT ?id WHERE {}",
+		"queries/items.rq":     "SELECT ?id WHERE { ?id ?p ?o }",
+		"templates/index.html": `{{ . }}`,
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	defer func(location string, offline bool, jobs int) {
+		configFileLocation, offlineBuildOption, jobsBuildOption = location, offline, jobs
+	}(configFileLocation, offlineBuildOption, jobsBuildOption)
+	configFileLocation, jobsBuildOption = "snowman.yaml", 2
+	logger.SetLevel(logger.ErrorLevel)
+	defer logger.SetLevel(logger.InfoLevel)
+
+	warmJobsOption = 1
+	if err := cacheWarmCmd.RunE(cacheWarmCmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests warming the cache, got %d", requests)
+	}
+
+	offlineBuildOption = true
+	if err := build(report.NewReport(), false); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the offline build to issue no requests, got %d", requests-2)
+	}
+	if _, err := os.Stat("site/items/a.html"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if _, err := parseSince("last"); err == nil {
+		t.Error("Expected an error without a last build")
+	}
+	start := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	if err := recordBuildTime(start); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		value    string
+		expected string
+	}{
+		{"last", "2024-05-01T12:30:00Z"},
+		{"2024-05-01", "2024-05-01T00:00:00Z"},
+		{"2024-05-01T14:30:00+02:00", "2024-05-01T12:30:00Z"},
+	}
+	for _, test := range tests {
+		since, err := parseSince(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := since.UTC().Format(time.RFC3339); actual != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.value, actual)
+		}
+	}
+
+	if _, err := parseSince("yesterday"); err == nil {
+		t.Error("Expected an error for an unknown time")
+	}
+}
+
+func TestSinceViews(t *testing.T) {
+	queries := map[string]string{
+		"changed.rq": "SELECT ?item { ?item schema:dateModified ?modified . FILTER (?modified > {{since}}) }",
+		"all.rq":     "SELECT ?item { ?item a schema:Book }",
+	}
+	var discovered []views.View
+	for _, output := range []string{"items/{{item}}.html", "index.html", "list/{{page}}.html", "pages/{{item}}.html", "changes.xml"} {
+		var view views.View
+		view.ViewConfig.Output = output
+		view.ViewConfig.QueryFile = "changed.rq"
+		if strings.Contains(output, "{{item}}") {
+			variable := "item"
+			view.MultipageVariableHook = &variable
+		}
+		discovered = append(discovered, view)
+	}
+	discovered[0].ViewConfig.DependsOn = []string{"index.html"}
+	discovered[1].ViewConfig.QueryFile = "all.rq"
+	discovered[2].ViewConfig.Paginate = 10
+	discovered[3].ViewConfig.Siblings = true
+
+	since := sinceViews(discovered, queries)
+	if len(since) != 1 || since[0].ViewConfig.Output != "items/{{item}}.html" || len(since[0].ViewConfig.DependsOn) != 0 {
+		t.Errorf("Expected only items/{{item}}.html without dependencies, got %v", since)
+	}
+}
@@ -1,19 +1,26 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
 	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/glaciers-in-archives/snowman/internal/views"
 	"github.com/spf13/cobra"
 )
 
 var invalidateCacheOption bool
 var unusedOption bool
+var warmJobsOption int
 
 func printFileContents(path string) error {
 	fmt.Println(path)
@@ -104,9 +111,20 @@ var cacheCmd = &cobra.Command{
 				return utils.ErrorExit("Failed to remove find query file.", err)
 			}
 
+			if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+				return err
+			}
+
 			queryString := strings.Replace(string(sparqlBytes), "{{.}}", args[1], 1)
+			queryString = sparql.PrependPrefixes(queryString, config.CurrentSiteConfig.Prefixes)
+
+			// the endpoint is keyed like when querying, including local data
+			if err := sparql.NewRepository("never", nil); err != nil {
+				return utils.ErrorExit("Failed to initiate SPARQL client.", err)
+			}
+			endpoint := sparql.CurrentRepository.EndpointKey()
 
-			filePath := cache.CacheLocation + cache.Hash(args[0]) + "/" + cache.Hash(queryString) + ".json"
+			filePath := cache.CacheLocation + cache.Hash(args[0]) + "/" + cache.ContentHash(endpoint, queryString) + ".json"
 			selectedCacheItems = append(selectedCacheItems, filePath)
 
 			printFileContents((filePath))
@@ -125,8 +143,153 @@ var cacheCmd = &cobra.Command{
 	},
 }
 
+// cacheClearCmd represents the cache clear command
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Removes all cached queries",
+	Long:  `This command removes every cached SPARQL response, so that the next build issues all queries again.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		totFiles, err := utils.CountFilesRecursive(cache.CacheLocation)
+		if err != nil && !os.IsNotExist(err) {
+			return utils.ErrorExit("Failed to retrive cache info.", err)
+		}
+
+		if err := os.RemoveAll(cache.CacheLocation); err != nil {
+			return utils.ErrorExit("Failed to remove the cache.", err)
+		}
+
+		fmt.Println("Removed " + fmt.Sprint(totFiles) + " cache items.")
+		return nil
+	},
+}
+
+// warmQuery is a query issued by a build, and what issues it.
+type warmQuery struct {
+	repo   *sparql.Repository
+	query  string
+	source string
+}
+
+// warmQueries returns the queries of the views, feeds and assertions.
+func warmQueries(discoveredViews []views.View) ([]warmQuery, error) {
+	var queries []warmQuery
+	for _, view := range discoveredViews {
+		repo, err := viewRepository(view)
+		if err != nil {
+			return nil, utils.ErrorExit("Failed to set up the query of view "+view.ViewConfig.Output+".", err)
+		}
+		if repo != nil {
+			queries = append(queries, warmQuery{repo, view.ViewConfig.QueryFile, "view " + view.ViewConfig.Output})
+		}
+	}
+	for _, feedConfig := range config.CurrentSiteConfig.Feeds {
+		repo, err := sparql.GetRepository(feedConfig.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, warmQuery{repo, feedConfig.Query, "feed " + feedConfig.Output})
+	}
+	for _, assertion := range config.CurrentSiteConfig.Assertions {
+		repo, err := sparql.GetRepository(assertion.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, warmQuery{repo, assertion.Query, "assertion " + assertion.Name})
+	}
+	return queries, nil
+}
+
+// warmCache issues the given queries in parallel, making sure their responses
+// are cached.
+func warmCache(queries []warmQuery, jobs int) error {
+	var mutex sync.Mutex
+	var firstErr error
+	queue := make(chan warmQuery)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for query := range queue {
+				_, err := query.repo.ResponseHash(query.query)
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = utils.ErrorExit("Failed to cache the query of "+query.source+".", err)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, query := range queries {
+		queue <- query
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+// cacheWarmCmd represents the cache warm command
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Caches the responses to all queries of a build",
+	Long:  `This command issues the queries of all views, feeds and assertions whose responses aren't cached yet, without rendering anything, so that a later build can run with --offline. Queries issued by templates are only cached by a build.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if warmJobsOption < 1 {
+			return errors.New("The number of jobs must be at least 1.")
+		}
+		if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+			return err
+		}
+
+		layouts, err := DiscoverLayouts()
+		if err != nil {
+			return utils.ErrorExit("Failed to find any template files.", err)
+		}
+		includes, err := DiscoverIncludes(layouts)
+		if err != nil {
+			return utils.ErrorExit("Failed to discover the includes.", err)
+		}
+		queries, err := DiscoverQueries()
+		if err != nil {
+			return utils.ErrorExit("Failed to index query files.", err)
+		}
+
+		if err := sparql.NewRepository("available", queries); err != nil {
+			return utils.ErrorExit("Failed to initiate SPARQL client.", err)
+		}
+
+		discoveredViews, err := views.DiscoverViews(append(layouts, includes...), queries, false)
+		if err != nil {
+			return utils.ErrorExit("Failed to discover views.", err)
+		}
+		if !config.CurrentSiteConfig.IncludeDrafts {
+			discoveredViews = views.SkipDrafts(discoveredViews)
+		}
+
+		warm, err := warmQueries(discoveredViews)
+		if err != nil {
+			return err
+		}
+		if err := warmCache(warm, warmJobsOption); err != nil {
+			return err
+		}
+
+		cm := sparql.CurrentRepository.CacheManager
+		fmt.Println("Warmed the cache for " + fmt.Sprint(len(warm)) + " queries: " + fmt.Sprint(cm.Hits) + " hits, " + fmt.Sprint(cm.Misses) + " misses, " + fmt.Sprint(cm.Writes) + " cache items written.")
+		return nil
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheWarmCmd)
+	cacheWarmCmd.Flags().IntVarP(&warmJobsOption, "jobs", "j", runtime.NumCPU(), "Sets the number of queries issued in parallel.")
 	cacheCmd.Flags().BoolVarP(&invalidateCacheOption, "invalidate", "i", false, "Removes/clears the specified parts of the query cache.")
 	cacheCmd.Flags().BoolVarP(&unusedOption, "unused", "u", false, "Returns cache items not used in the last build.")
 }
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/links"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+	"github.com/spf13/cobra"
+)
+
+var probeCheckOption bool
+var strictCheckOption bool
+var linksCheckOption bool
+var externalLinksCheckOption bool
+var unusedCheckOption bool
+
+var limitPattern = regexp.MustCompile(`(?i)\bLIMIT\s+\d+`)
+
+// limitQueries limits the given queries to a single result.
+func limitQueries(queries map[string]string) {
+	for name, query := range queries {
+		if !limitPattern.MatchString(query) {
+			queries[name] = sparql.AddModifiers(query, "LIMIT 1")
+		}
+	}
+}
+
+// checkProject validates the project without writing anything and returns
+// every problem found.
+func checkProject() ([]string, error) {
+	if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+		return nil, err
+	}
+
+	layouts, err := DiscoverLayouts()
+	if err != nil {
+		return nil, err
+	}
+	includes, err := DiscoverIncludes(layouts)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := DiscoverQueries()
+	if err != nil {
+		return nil, err
+	}
+
+	// inline queries of views are added to the queries by their discovery
+	var queryFiles []string
+	for name := range queries {
+		queryFiles = append(queryFiles, name)
+	}
+
+	if err := sparql.NewRepository("never", queries); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	discoveredViews, err := views.DiscoverViews(append(layouts, includes...), queries, strictCheckOption)
+	var validationErr *views.ValidationError
+	if errors.As(err, &validationErr) {
+		problems = append(problems, validationErr.Problems...)
+	} else if err != nil {
+		return nil, err
+	}
+
+	// templates that aren't used by views directly, like includes, are parsed too
+	err = filepath.Walk(config.CurrentSiteConfig.TemplatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if err := views.ParseTemplate(path); err != nil {
+				problems = append(problems, "template "+path+": "+err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// with problems in views.yaml not every view is known, so everything
+	// would seem unused
+	if unusedCheckOption && validationErr == nil {
+		unused, err := unusedFiles(queryFiles, includes, discoveredViews)
+		if err != nil {
+			return nil, err
+		}
+		if strictCheckOption {
+			problems = append(problems, unused...)
+		} else {
+			for _, warning := range unused {
+				logger.Warn(warning)
+			}
+		}
+	}
+
+	if linksCheckOption || externalLinksCheckOption {
+		linkProblems, err := checkLinks()
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, linkProblems...)
+	}
+
+	if !probeCheckOption {
+		return problems, nil
+	}
+	// the repositories share the queries, including the inline queries of views
+	limitQueries(queries)
+
+	probed := map[string]bool{}
+	for _, view := range discoveredViews {
+		// views sharing a query and its parameters only need one probe
+		key := view.ViewConfig.Endpoint + " " + view.ViewConfig.QueryFile + " " + fmt.Sprint(view.ViewConfig.Params)
+		if view.ViewConfig.QueryFile == "" || probed[key] {
+			continue
+		}
+		probed[key] = true
+
+		repo, err := sparql.GetRepository(view.ViewConfig.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		repo = repo.WithParams(view.ViewConfig.Params)
+
+		if sparql.IsGraphQuery(queries[view.ViewConfig.QueryFile]) {
+			_, err = repo.QueryGraph(view.ViewConfig.QueryFile)
+		} else {
+			_, err = repo.Query(view.ViewConfig.QueryFile)
+		}
+		if err != nil {
+			problems = append(problems, "view "+view.ViewConfig.Output+": "+err.Error())
+		}
+	}
+
+	return problems, nil
+}
+
+// checkLinks returns the broken links of the built site.
+func checkLinks() ([]string, error) {
+	siteDir := config.CurrentSiteConfig.OutputDir
+	if info, err := os.Stat(siteDir); err != nil || !info.IsDir() {
+		return nil, errors.New("The site directory " + siteDir + " doesn't exist. Build the site before checking its links.")
+	}
+
+	broken, err := links.Check(siteDir, config.CurrentSiteConfig.BaseURL, config.CurrentSiteConfig.BasePath, externalLinksCheckOption)
+	if err != nil {
+		return nil, utils.ErrorExit("Failed to check the links of the site.", err)
+	}
+
+	var problems []string
+	for _, link := range broken {
+		problems = append(problems, "page "+link.Page+": link to "+link.Target+" "+link.Reason)
+	}
+	return problems, nil
+}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Validate the project without building it",
+	Long:  `This command parses the configuration, views and templates of the project and reports every problem found, without writing anything. With --probe each query used by a view is also issued against its SPARQL endpoint, limited to a single result. With --links the links of the built site are checked too.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems, err := checkProject()
+		if err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			for _, problem := range problems {
+				fmt.Println(problem)
+			}
+			return errors.New("Found " + strconv.Itoa(len(problems)) + " problems in the project.")
+		}
+
+		fmt.Println("No problems found.")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+	checkCmd.Flags().BoolVar(&probeCheckOption, "probe", false, "Issue each query used by a view against its SPARQL endpoint, limited to a single result.")
+	checkCmd.Flags().BoolVar(&strictCheckOption, "strict", false, "Report warnings as problems.")
+	checkCmd.Flags().BoolVar(&unusedCheckOption, "unused", false, "Report the query files and includes that no view, feed or template refers to. They're warnings unless --strict is set.")
+	checkCmd.Flags().BoolVar(&linksCheckOption, "links", false, "Check that the internal links of the HTML pages in the site directory point to files in it.")
+	checkCmd.Flags().BoolVar(&externalLinksCheckOption, "external", false, "Also request the external links of the site and report those that fail. Implies --links.")
+}
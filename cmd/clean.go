@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// cleanSite removes the contents of the site directory. If the site directory
+// is a symlink its target is only cleared when it's located inside the project.
+func cleanSite() error {
+	siteDir := config.CurrentSiteConfig.OutputDir
+
+	project, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	absSiteDir, err := filepath.Abs(siteDir)
+	if err != nil {
+		return err
+	}
+
+	if absSiteDir == project || strings.HasPrefix(project, absSiteDir+string(filepath.Separator)) {
+		return errors.New("The site directory " + siteDir + " contains the project. Refusing to remove it.")
+	}
+
+	info, err := os.Lstat(siteDir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(siteDir)
+		if err != nil {
+			return err
+		}
+
+		target, err = filepath.Abs(target)
+		if err != nil {
+			return err
+		}
+
+		if !strings.HasPrefix(target, project+string(filepath.Separator)) {
+			return errors.New("The site directory is a symlink to " + target + " which is outside of the project. Refusing to remove it.")
+		}
+	}
+
+	entries, err := os.ReadDir(siteDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(siteDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanCmd represents the clean command
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Removes the built site.",
+	Long:  `Removes the contents of the site directory in the current directory.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+			return err
+		}
+
+		if err := cleanSite(); err != nil {
+			return utils.ErrorExit("Failed to remove the existing site directory.", err)
+		}
+
+		fmt.Println("Removed the built site.")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/overlay"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+)
+
+// developing is set while serving or watching the site, when pages and views
+// failing to render get an error page instead of failing the build.
+var developing bool
+
+// developedPages are the pages each view rendered in the last build while
+// developing, which get the error page of the view when it fails as a whole.
+var developedPages = map[string][]string{}
+
+// errorViews collects the views which got an error page during a build.
+type errorViews struct {
+	sync.Mutex
+	views map[string]bool
+}
+
+func (e *errorViews) add(view string) {
+	e.Lock()
+	defer e.Unlock()
+	if e.views == nil {
+		e.views = map[string]bool{}
+	}
+	e.views[view] = true
+}
+
+func (e *errorViews) has(view string) bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.views[view]
+}
+
+func (e *errorViews) count() int {
+	e.Lock()
+	defer e.Unlock()
+	return len(e.views)
+}
+
+// errorPaths returns the paths of the pages a failing view would have
+// rendered, which are those of the last build or the output of views
+// rendering a single page. Views rendering a page per result that haven't
+// rendered any yet have none.
+func errorPaths(view views.View) []string {
+	if pages := developedPages[view.ViewConfig.Output]; len(pages) > 0 {
+		return pages
+	}
+	switch {
+	case view.ViewConfig.Paginate > 0:
+		return []string{config.CurrentSiteConfig.OutputDir + "/" + view.PageOutput(1)}
+	case view.MultipageVariableHook == nil:
+		return []string{config.CurrentSiteConfig.OutputDir + "/" + view.OutputPath}
+	}
+	return nil
+}
+
+// templateFile returns the path of the template of the view with the given
+// name, or nothing if it's neither the view's template nor its layout.
+func templateFile(view views.View, name string) string {
+	candidates := []string{filepath.Join(config.CurrentSiteConfig.TemplatesDir, view.ViewConfig.TemplateFile)}
+	if view.ViewConfig.Layout != "" {
+		candidates = append(candidates, filepath.Join(config.CurrentSiteConfig.TemplatesDir, "layouts", view.ViewConfig.Layout))
+	}
+	for _, candidate := range candidates {
+		if filepath.Base(candidate) == name {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// showError reports the failure of a page or view while developing and writes
+// its error page to the given paths, so that the rest of the site is still
+// built and served.
+func (b *buildState) showError(view views.View, paths []string, source string, err error) error {
+	logger.Error(err.Error())
+	b.errorViews.add(view.ViewConfig.Output)
+
+	e := overlay.Error{View: view.ViewConfig.Output, Source: source, Messages: utils.ErrorMessages(err)}
+	if name, line := overlay.Position(e.Messages); name != "" {
+		if file := templateFile(view, name); file != "" {
+			e.File = file
+			e.Lines = overlay.Context(file, line)
+		}
+	}
+
+	for _, path := range paths {
+		if err := overlay.Write(path, e); err != nil {
+			return utils.ErrorExit("Failed to write the error page at "+path+".", err)
+		}
+		b.rendered.add(path, renderedPath{view: view.ViewConfig.Output, source: source, query: view.ViewConfig.QueryFile})
+		logger.Debug("Wrote the error page at " + path)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+// interrupted is cancelled when a build is interrupted, after which no new
+// views or pages are rendered.
+var interrupted = context.Background()
+
+var keepInterruptedBuildOption bool
+
+// trapInterrupts cancels interrupted, and the waits of queries to be retried,
+// on the first SIGINT or SIGTERM, and returns a function that stops trapping
+// them. A second signal exits immediately as usual.
+func trapInterrupts() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted = ctx
+	sparql.Interrupted = ctx
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, received := <-signals; !received {
+			return
+		}
+		signal.Stop(signals)
+		logger.Warn("Interrupted, finishing the pages being rendered. Interrupt again to exit immediately.")
+		cancel()
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel()
+		interrupted = context.Background()
+		sparql.Interrupted = context.Background()
+	}
+}
+
+// interruptedBuild returns the error of an interrupted build. The incomplete
+// site directory of a full build is cleared, unless it's to be kept, so that
+// it's not mistaken for a built site.
+func interruptedBuild(incrementalBuild bool) error {
+	if dryRunBuildOption || incrementalBuild || partialBuild() || keepInterruptedBuildOption {
+		return errors.New("Build interrupted.")
+	}
+	if err := cleanSite(); err != nil {
+		return utils.ErrorExit("Build interrupted. Failed to clear the incomplete site directory.", err)
+	}
+	return errors.New("Build interrupted. Cleared the incomplete site directory.")
+}
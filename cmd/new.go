@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -14,56 +15,63 @@ import (
 )
 
 var directory string
+var forceNewOption bool
 
 //go:embed scaffold/*
 var content embed.FS
 
-// serverCmd represents the server command
-var newCmd = &cobra.Command{
-	Use:   "new",
-	Short: "Generates a new project.",
-	Long:  `The new command can generate a basic Snowman project and all requried files and folders.`,
-	Args:  cobra.RangeArgs(0, 2),
-	RunE: func(cmd *cobra.Command, args []string) error {
+func copyScaffoldFile(path string, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0770); err != nil {
+		return utils.ErrorExit("Failed to generate directory: ", err)
+	}
 
-		if _, err := os.Stat(directory); !os.IsNotExist(err) {
-			fmt.Println("Directory already exists.")
-			return nil
-		}
+	out, err := os.Create(newPath)
+	if err != nil {
+		return utils.ErrorExit("Failed to create file: ", err)
+	}
+	defer out.Close()
 
-		fs.WalkDir(content, ".", func(path string, d fs.DirEntry, err error) error {
-			if d.IsDir() {
-				return nil
-			}
+	in, err := content.Open(path)
+	if err != nil {
+		return utils.ErrorExit("Failed to open embeded filesystem: ", err)
+	}
+	defer in.Close()
 
-			newPath := strings.Replace(path, "scaffold/", directory+"/", 1)
+	if _, err = io.Copy(out, in); err != nil {
+		return utils.ErrorExit("Failed to copy file: ", err)
+	}
 
-			if err := os.MkdirAll(filepath.Dir(newPath), 0770); err != nil {
-				utils.ErrorExit("Failed to generate directory: ", err)
-			}
+	return nil
+}
 
-			out, err := os.Create(newPath)
-			defer out.Close()
-			if err != nil {
-				utils.ErrorExit("Failed to create file: ", err)
+// newCmd represents the new command
+var newCmd = &cobra.Command{
+	Use:   "new [directory]",
+	Short: "Generates a new project.",
+	Long:  `The new command can generate a basic Snowman project and all requried files and folders. The project is created in the given directory, which must be empty unless the force flag is set.`,
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			directory = args[0]
+		}
 
-			}
+		if entries, err := os.ReadDir(directory); err == nil && len(entries) > 0 && !forceNewOption {
+			return errors.New("The directory " + directory + " is not empty. Use --force to generate the project anyway.")
+		}
 
-			in, err := embed.FS.Open(content, path)
-			defer in.Close()
+		err := fs.WalkDir(content, ".", func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
-				utils.ErrorExit("Failed to open embeded filesystem: ", err)
-
+				return err
 			}
-
-			_, err = io.Copy(out, in)
-			if err != nil {
-				utils.ErrorExit("Failed to copy file: ", err)
-
+			if d.IsDir() {
+				return nil
 			}
 
-			return nil
+			return copyScaffoldFile(path, strings.Replace(path, "scaffold/", directory+"/", 1))
 		})
+		if err != nil {
+			return err
+		}
 
 		fmt.Println("Your project has been created in:", directory)
 		fmt.Println("You can now run:")
@@ -78,4 +86,5 @@ var newCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(newCmd)
 	newCmd.Flags().StringVarP(&directory, "directory", "d", "my-new-project", "Directory in which to create the project.")
+	newCmd.Flags().BoolVar(&forceNewOption, "force", false, "Generate the project even if the directory isn't empty, overwriting existing files.")
 }
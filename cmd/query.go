@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/knakk/rdf"
+	"github.com/spf13/cobra"
+)
+
+var outputQueryOption string
+var clientQueryOption string
+
+// printTable prints the results as a table with a column per variable.
+func printTable(w io.Writer, results []map[string]rdf.Term) error {
+	names := sparql.Variables(results)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(names, "\t"))
+	for _, row := range results {
+		values := make([]string, len(names))
+		for i, name := range names {
+			if term, ok := row[name]; ok && term != nil {
+				values[i] = term.String()
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// loadQuery returns the name and queries to index for the given argument,
+// which is either -, for stdin, the name of a query in the queries
+// directory, or the path of a query file.
+func loadQuery(argument string) (string, map[string]string, error) {
+	if argument == "-" {
+		query, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", nil, utils.ErrorExit("Failed to read the query from stdin.", err)
+		}
+		return "stdin", map[string]string{"stdin": string(query)}, nil
+	}
+
+	queries, err := DiscoverQueries()
+	if err != nil {
+		return "", nil, utils.ErrorExit("Failed to index query files.", err)
+	}
+	if _, exists := queries[argument]; exists {
+		return argument, queries, nil
+	}
+
+	query, err := ioutil.ReadFile(argument)
+	if err != nil {
+		return "", nil, errors.New("No query named " + argument + " was found in the queries directory, and it can't be read as a file.")
+	}
+	queries[argument] = string(query)
+	return argument, queries, nil
+}
+
+// queryCmd represents the query command
+var queryCmd = &cobra.Command{
+	Use:   "query <name-or-file|-> [arguments...]",
+	Short: "Run a query against the configured endpoint",
+	Long:  `This command issues a query against the SPARQL endpoint configured in snowman.yaml and prints the results. The query is the name of a file in the queries directory, the path of a query file, or - to read it from stdin. Further arguments replace the {{.}} placeholders of parameterized queries. Graph queries print the resulting triples as N-Triples. Nothing is cached.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if outputQueryOption != "table" && outputQueryOption != "json" && outputQueryOption != "csv" {
+			return errors.New("The output must be either table, json or csv.")
+		}
+
+		if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+			return err
+		}
+
+		name, queries, err := loadQuery(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := sparql.NewRepository("never", queries); err != nil {
+			return utils.ErrorExit("Failed to initiate SPARQL client.", err)
+		}
+
+		repo, err := sparql.GetRepository(clientQueryOption)
+		if err != nil {
+			return err
+		}
+
+		var arguments []interface{}
+		for _, argument := range args[1:] {
+			arguments = append(arguments, argument)
+		}
+
+		if sparql.IsGraphQuery(queries[name]) {
+			if outputQueryOption != "table" {
+				return errors.New("The results of graph queries are always printed as N-Triples.")
+			}
+
+			graph, err := repo.QueryGraph(name, arguments...)
+			if err != nil {
+				return utils.ErrorExit("SPARQL query failed.", err)
+			}
+			for _, triple := range graph.Triples {
+				fmt.Print(triple.Serialize(rdf.NTriples))
+			}
+			return nil
+		}
+
+		results, err := repo.Query(name, arguments...)
+		if err != nil {
+			return utils.ErrorExit("SPARQL query failed.", err)
+		}
+
+		switch outputQueryOption {
+		case "json":
+			return sparql.WriteResultsJSON(os.Stdout, results)
+		case "csv":
+			return sparql.WriteResultsCSV(os.Stdout, results)
+		default:
+			return printTable(os.Stdout, results)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryCmd)
+	queryCmd.Flags().StringVarP(&outputQueryOption, "output", "o", "table", "Sets how results are printed: table, json or csv.")
+	queryCmd.Flags().StringVar(&clientQueryOption, "client", "", "Sets the name of the SPARQL client to query, instead of the default one.")
+}
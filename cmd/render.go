@@ -0,0 +1,784 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/incremental"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/manifest"
+	"github.com/glaciers-in-archives/snowman/internal/progress"
+	"github.com/glaciers-in-archives/snowman/internal/report"
+	"github.com/glaciers-in-archives/snowman/internal/search"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+	"github.com/knakk/rdf"
+)
+
+// renderedPaths keeps track of the output paths written during a build, and
+// what produced them, so that overwrites can be reported.
+type renderedPaths struct {
+	sync.Mutex
+	paths map[string]renderedPath
+}
+
+type renderedPath struct {
+	view   string
+	source string
+	query  string
+	// bindings are the values of the output variables of multipage views
+	bindings map[string]string
+}
+
+// add records the given path and returns what previously wrote to it, if
+// anything.
+func (rp *renderedPaths) add(path string, rendered renderedPath) (string, bool) {
+	rp.Lock()
+	defer rp.Unlock()
+	previous, seen := rp.paths[path]
+	if !seen {
+		rp.paths[path] = rendered
+	}
+	return previous.source, seen
+}
+
+// bindingsOf returns the bindings of the paths written by the given view.
+func (rp *renderedPaths) bindingsOf(view string) map[string]map[string]string {
+	rp.Lock()
+	defer rp.Unlock()
+	bindings := make(map[string]map[string]string)
+	for path, rendered := range rp.paths {
+		if rendered.view == view && rendered.bindings != nil {
+			bindings[path] = rendered.bindings
+		}
+	}
+	return bindings
+}
+
+// manifest returns the recorded paths as the pages of a build manifest.
+func (rp *renderedPaths) manifest() []manifest.Page {
+	rp.Lock()
+	defer rp.Unlock()
+	pages := make([]manifest.Page, 0, len(rp.paths))
+	for path, rendered := range rp.paths {
+		pages = append(pages, manifest.Page{Path: path, View: rendered.view, Query: rendered.query, Bindings: rendered.bindings})
+	}
+	return pages
+}
+
+// of returns the paths written by the given view.
+func (rp *renderedPaths) of(view string) []string {
+	rp.Lock()
+	defer rp.Unlock()
+	var paths []string
+	for path, rendered := range rp.paths {
+		if rendered.view == view {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// list returns the recorded paths in sorted order.
+func (rp *renderedPaths) list() []string {
+	rp.Lock()
+	defer rp.Unlock()
+	paths := make([]string, 0, len(rp.paths))
+	for path := range rp.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// renderOptions control how views are rendered.
+type renderOptions struct {
+	jobs int
+	// dryRun makes renderPage report the pages it would write instead
+	dryRun bool
+	// strict turns duplicate output paths into errors
+	strict bool
+	// state, when set, is used to skip views whose inputs are unchanged since
+	// the last build, unless force is set
+	state *incremental.State
+	force bool
+	// sharedInputs is a hash of the inputs used by all views
+	sharedInputs string
+	// failFast stops the build at the first failing page or view, otherwise
+	// failures are collected and reported when all views are rendered
+	failFast bool
+	// progress shows the number of rendered views on the terminal
+	progress bool
+	// search, when set, collects the documents of the pages of views mapping
+	// variables to search fields
+	search *search.Index
+	// since is set when the queries only return the resources changed since
+	// a time, so exports would be incomplete and empty results are expected
+	since bool
+	// errorPages writes an error page in place of the pages of failing pages
+	// and views, instead of failing the build
+	errorPages bool
+}
+
+// failures collects the errors of pages and views when not failing fast.
+type failures struct {
+	sync.Mutex
+	errors []error
+}
+
+func (f *failures) add(err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.errors = append(f.errors, err)
+}
+
+// err returns an error summarising the collected failures, if any.
+func (f *failures) err() error {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.errors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(f.errors))
+	for i, err := range f.errors {
+		messages[i] = err.Error()
+	}
+	return errors.New("Failed to build " + strconv.Itoa(len(f.errors)) + " pages or views:\n  " + strings.Join(messages, "\n  "))
+}
+
+// buildState holds the state shared by all render workers during a build.
+type buildState struct {
+	renderOptions
+	ctx      context.Context
+	rendered *renderedPaths
+	report   *report.Report
+	failures *failures
+	progress *progress.Progress
+	// errorViews are the views which got an error page
+	errorViews *errorViews
+}
+
+// pageFailed returns the error of a page when failing fast, otherwise it
+// reports and collects it so that the other pages are still rendered.
+func (b *buildState) pageFailed(err error) error {
+	if b.failFast {
+		return err
+	}
+	logger.Error(err.Error())
+	b.failures.add(err)
+	return nil
+}
+
+// record records a path written by the view and reports when something
+// else already wrote to it.
+func (b *buildState) record(view views.View, outputPath string, source string, bindings map[string]string) error {
+	rendered := renderedPath{view: view.ViewConfig.Output, source: source, query: view.ViewConfig.QueryFile, bindings: bindings}
+	if previous, seen := b.rendered.add(outputPath, rendered); seen {
+		message := "Both " + previous + " and " + source + " write to " + outputPath + "."
+		if b.strict {
+			return errors.New(message)
+		}
+		logger.Warn(message)
+	}
+	return nil
+}
+
+// noResults reports a view whose query returned no results, which usually
+// means that the query has a mistake. It's an error when strict.
+func (b *buildState) noResults(view views.View) error {
+	message := "The query " + view.ViewConfig.QueryFile + " of view " + view.ViewConfig.Output + " returned no results."
+	if b.since {
+		logger.Debug(message)
+		return nil
+	}
+	if b.strict {
+		return errors.New(message)
+	}
+	logger.Warn(message)
+	return nil
+}
+
+// writeExport writes the results of a view to its export file.
+func (b *buildState) writeExport(view views.View, rows []map[string]rdf.Term) error {
+	exportPath := config.CurrentSiteConfig.OutputDir + "/" + view.ViewConfig.Export.Output
+	if b.since {
+		logger.Debug("Keeping the export of view " + view.ViewConfig.Output + ", as its query only returns changed resources.")
+		return nil
+	}
+	if err := b.record(view, exportPath, "the export of view "+view.ViewConfig.Output, nil); err != nil {
+		return err
+	}
+
+	if b.dryRun {
+		fmt.Fprintln(logger.Output(), "Would write "+exportPath)
+		return nil
+	}
+
+	if err := view.WriteExport(exportPath, rows); err != nil {
+		return b.pageFailed(utils.ErrorExit("Failed to write the export at "+exportPath+".", err))
+	}
+	logger.Debug("Wrote export at " + exportPath)
+	return nil
+}
+
+// renderPage renders a single page of a view and records it in the metrics.
+// The source describes what produced the page and is used to report
+// duplicate output paths. The bindings of multipage views go in the manifest.
+func (b *buildState) renderPage(view views.View, metrics *report.View, outputPath string, source string, bindings map[string]string, data interface{}) error {
+	if err := b.record(view, outputPath, source, bindings); err != nil {
+		return err
+	}
+
+	if b.dryRun {
+		metrics.Pages++
+		fmt.Fprintln(logger.Output(), "Would write "+outputPath)
+		return nil
+	}
+
+	start := time.Now()
+	if err := view.RenderPage(outputPath, data); err != nil {
+		err = utils.ErrorExit("Failed to render page at "+outputPath, err)
+		if b.errorPages {
+			return b.showError(view, []string{outputPath}, source, err)
+		}
+		return b.pageFailed(err)
+	}
+	if view.ViewConfig.Sidecar != "" {
+		if err := view.WriteSidecar(outputPath, data); err != nil {
+			return b.pageFailed(utils.ErrorExit("Failed to write the sidecar of "+outputPath, err))
+		}
+	}
+	metrics.RenderDuration += time.Since(start)
+	metrics.Pages++
+
+	logger.Debug("Rendered page at " + outputPath)
+	return nil
+}
+
+// renderMultipageRow renders the page of a result row, or of the data given
+// such as a group of rows, to the output path of the row.
+func (b *buildState) renderMultipageRow(view views.View, metrics *report.View, row map[string]rdf.Term, data interface{}) error {
+	output, err := view.MultipageOutput(row)
+	var unbound views.UnboundError
+	if errors.As(err, &unbound) && view.ViewConfig.SkipUnbound {
+		logger.Warn("Skipping a result row of view " + view.ViewConfig.Output + " which doesn't bind " + unbound.Variable + ".")
+		return nil
+	}
+	if err != nil {
+		return b.pageFailed(utils.ErrorExit("Failed to build a page of view "+view.ViewConfig.Output+".", err))
+	}
+
+	var described []string
+	bindings := make(map[string]string, len(view.MultipageVariables))
+	for _, variable := range view.MultipageVariables {
+		// output templates can leave out unbound variables
+		if row[variable] == nil {
+			continue
+		}
+		described = append(described, variable+" \""+row[variable].String()+"\"")
+		bindings[variable] = row[variable].String()
+	}
+
+	outputPath := config.CurrentSiteConfig.OutputDir + "/" + output
+	source := "view " + view.ViewConfig.Output + " with " + strings.Join(described, ", ")
+	if data == nil {
+		data = row
+	}
+	if err := b.renderPage(view, metrics, outputPath, source, bindings, data); err != nil {
+		return err
+	}
+	if b.search != nil && len(view.ViewConfig.Search) > 0 && !b.dryRun {
+		b.search.Add(view.ViewConfig.Output, searchDocument(view, output, row))
+	}
+	return nil
+}
+
+// searchDocument returns the document of a page in the search index, with the
+// values the row binds to the variables of the search fields.
+func searchDocument(view views.View, output string, row map[string]rdf.Term) search.Document {
+	document := search.Document{search.URLField: search.URL(output, config.CurrentSiteConfig.BasePath)}
+	for field, variable := range view.ViewConfig.Search {
+		if term := row[variable]; term != nil {
+			document[field] = term.String()
+		}
+	}
+	return document
+}
+
+// renderGroups renders a page for every group of rows, to the output path of
+// the first row of the group.
+func (b *buildState) renderGroups(view views.View, metrics *report.View, rows []map[string]rdf.Term) error {
+	transformed, err := view.Transform(rows)
+	if err != nil {
+		return b.pageFailed(utils.ErrorExit("Failed to transform the result rows of view "+view.ViewConfig.Output+".", err))
+	}
+
+	var firsts []map[string]rdf.Term
+	for _, item := range transformed.Items {
+		firsts = append(firsts, item.(map[string]interface{})["Rows"].([]map[string]rdf.Term)[0])
+	}
+	return b.renderPages(view, metrics, firsts, transformed.Items)
+}
+
+// renderRows renders the page of every row once all of them are in, so that
+// each page can be given its siblings.
+func (b *buildState) renderRows(view views.View, metrics *report.View, rows []map[string]rdf.Term) error {
+	extended := make([]map[string]rdf.Term, 0, len(rows))
+	pages := make([]interface{}, 0, len(rows))
+	for _, row := range rows {
+		row, err := view.AddFields(row)
+		if err != nil {
+			return b.pageFailed(utils.ErrorExit("Failed to transform a result row of view "+view.ViewConfig.Output+".", err))
+		}
+		extended = append(extended, row)
+		pages = append(pages, row)
+	}
+	return b.renderPages(view, metrics, extended, pages)
+}
+
+// renderPages renders the data of every page to the output path of its row.
+// With siblings, rows which are skipped for not binding a variable of the
+// output don't count as pages.
+func (b *buildState) renderPages(view views.View, metrics *report.View, rows []map[string]rdf.Term, pages []interface{}) error {
+	if view.ViewConfig.Siblings {
+		var kept []map[string]rdf.Term
+		var keptPages []interface{}
+		for i, row := range rows {
+			_, err := view.MultipageOutput(row)
+			var unbound views.UnboundError
+			if errors.As(err, &unbound) && view.ViewConfig.SkipUnbound {
+				// rendered on its own to warn that it's skipped
+				if err := b.renderMultipageRow(view, metrics, row, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			kept = append(kept, row)
+			keptPages = append(keptPages, pages[i])
+		}
+		rows = kept
+		pages = nil
+		for _, page := range views.Siblings(keptPages) {
+			pages = append(pages, page)
+		}
+	}
+
+	for i, row := range rows {
+		if err := b.renderMultipageRow(view, metrics, row, pages[i]); err != nil {
+			return err
+		}
+		if err := b.ctx.Err(); err != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// viewInputs returns a hash of everything the pages of a view are rendered
+// from: its configuration, template, query response and the shared inputs.
+func (b *buildState) viewInputs(view views.View, repo *sparql.Repository) (string, error) {
+	templatePath := config.CurrentSiteConfig.TemplatesDir + "/" + view.ViewConfig.TemplateFile
+	if view.ViewConfig.JSONLDFrame != "" {
+		templatePath = view.ViewConfig.JSONLDFrame
+	}
+	template, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return "", err
+	}
+
+	response := ""
+	if repo != nil {
+		response, err = repo.ResponseHash(view.ViewConfig.QueryFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	viewConfig, err := json.Marshal(view.ViewConfig)
+	if err != nil {
+		return "", err
+	}
+
+	return incremental.Hash(b.sharedInputs, string(viewConfig), string(template), response), nil
+}
+
+// unchanged reports whether the view can be skipped, which is when its inputs
+// match those of the last build and all of its pages are still in place.
+func (b *buildState) unchanged(view views.View, inputs string) (incremental.ViewState, bool) {
+	previous, exists := b.state.Previous(view.ViewConfig.Output)
+	if !exists || previous.Inputs != inputs || b.force {
+		return previous, false
+	}
+
+	for _, page := range previous.Pages {
+		if _, err := os.Stat(page); err != nil {
+			return previous, false
+		}
+	}
+	return previous, true
+}
+
+// viewRepository returns the repository issuing the query of a view with its
+// params, cache TTL and page size, or nil if the view has no query.
+func viewRepository(view views.View) (*sparql.Repository, error) {
+	if view.ViewConfig.QueryFile == "" {
+		return nil, nil
+	}
+	repo, err := sparql.GetRepository(view.ViewConfig.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	repo = repo.WithParams(view.ViewConfig.Params)
+	if view.ViewConfig.CacheTTL != nil {
+		repo = repo.WithCacheTTL(*view.ViewConfig.CacheTTL)
+	}
+	if view.ViewConfig.FetchPageSize > 0 {
+		repo = repo.WithPageSize(view.ViewConfig.FetchPageSize)
+	}
+	return repo, nil
+}
+
+func (b *buildState) renderView(view views.View) error {
+	metrics := b.report.NewView(view.ViewConfig.Output, view.ViewConfig.QueryFile)
+
+	repo, err := viewRepository(view)
+	if err != nil {
+		return err
+	}
+
+	if b.state == nil {
+		return b.render(view, metrics, repo)
+	}
+
+	inputs, err := b.viewInputs(view, repo)
+	if err != nil {
+		return err
+	}
+
+	if previous, skip := b.unchanged(view, inputs); skip {
+		for _, page := range previous.Pages {
+			b.rendered.add(page, renderedPath{view: view.ViewConfig.Output, source: "view " + view.ViewConfig.Output, query: view.ViewConfig.QueryFile, bindings: previous.Bindings[page]})
+		}
+		if b.search != nil {
+			for _, document := range previous.Search {
+				b.search.Add(view.ViewConfig.Output, document)
+			}
+		}
+		b.state.Record(view.ViewConfig.Output, previous)
+		logger.Debug("Skipping unchanged view " + view.ViewConfig.Output)
+		return nil
+	}
+
+	if err := b.render(view, metrics, repo); err != nil {
+		return err
+	}
+	// views with error pages are rendered again by the next build
+	if b.errorViews.has(view.ViewConfig.Output) {
+		inputs = ""
+	}
+	viewState := incremental.ViewState{Inputs: inputs, Pages: b.rendered.of(view.ViewConfig.Output), Bindings: b.rendered.bindingsOf(view.ViewConfig.Output)}
+	if b.search != nil {
+		for _, document := range b.search.Of(view.ViewConfig.Output) {
+			viewState.Search = append(viewState.Search, document)
+		}
+	}
+	b.state.Record(view.ViewConfig.Output, viewState)
+	return nil
+}
+
+// queryShared calls handle for each result row of a query issued through
+// SharedQuery. The rows are kept in memory for the views issuing the same
+// query.
+func queryShared(repo *sparql.Repository, queryLocation string, handle func(map[string]rdf.Term) error) error {
+	rows, err := repo.SharedQuery(queryLocation)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := handle(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// render renders all pages of a view.
+func (b *buildState) render(view views.View, metrics *report.View, repo *sparql.Repository) error {
+	if repo != nil {
+		logger.Debug("Issuing query " + view.ViewConfig.QueryFile)
+	}
+
+	// if the page is rendered based on SPARQL result rows, the rows are
+	// rendered as they are parsed to avoid keeping all of them in memory
+	if view.MultipageVariableHook != nil {
+		if repo == nil {
+			return nil
+		}
+
+		if sparql.IsGraphQuery(repo.QueryIndex[view.ViewConfig.QueryFile]) {
+			return errors.New("Views rendering a page per result can't use CONSTRUCT or DESCRIBE queries.")
+		}
+
+		// grouped rows, and rows with siblings, are rendered once all of them
+		// are in
+		grouped := view.ViewConfig.Transform != nil && view.ViewConfig.Transform.GroupBy != ""
+		deferred := grouped || view.ViewConfig.Siblings
+
+		// only views with an export, grouping or siblings keep the rows
+		var rows []map[string]rdf.Term
+		results := 0
+		start := time.Now()
+		handle := func(row map[string]rdf.Term) error {
+			if err := b.ctx.Err(); err != nil {
+				return err
+			}
+			results++
+			if view.ViewConfig.Export != nil || deferred {
+				rows = append(rows, row)
+			}
+			if deferred {
+				return nil
+			}
+			row, err := view.AddFields(row)
+			if err != nil {
+				return b.pageFailed(utils.ErrorExit("Failed to transform a result row of view "+view.ViewConfig.Output+".", err))
+			}
+			return b.renderMultipageRow(view, metrics, row, nil)
+		}
+		var err error
+		if shareResultsBuildOption {
+			err = queryShared(repo, view.ViewConfig.QueryFile, handle)
+		} else {
+			err = repo.QueryStream(view.ViewConfig.QueryFile, handle)
+		}
+		// as rows are rendered while the response is read, rendering is excluded
+		metrics.QueryDuration = time.Since(start) - metrics.RenderDuration
+		if err != nil {
+			if b.ctx.Err() == nil {
+				return err
+			}
+			return nil
+		}
+
+		if results == 0 {
+			if err := b.noResults(view); err != nil {
+				return err
+			}
+		}
+
+		if grouped {
+			if err := b.renderGroups(view, metrics, rows); err != nil {
+				return err
+			}
+		} else if deferred {
+			if err := b.renderRows(view, metrics, rows); err != nil {
+				return err
+			}
+		}
+
+		if view.ViewConfig.Export != nil {
+			return b.writeExport(view, rows)
+		}
+		return nil
+	}
+
+	outputPath := config.CurrentSiteConfig.OutputDir + "/" + view.OutputPath
+	source := "view " + view.ViewConfig.Output
+
+	if repo != nil && sparql.IsGraphQuery(repo.QueryIndex[view.ViewConfig.QueryFile]) {
+		if view.ViewConfig.Paginate > 0 {
+			return errors.New("Paginated views can't use CONSTRUCT or DESCRIBE queries.")
+		}
+		if view.ViewConfig.Export != nil {
+			return errors.New("Views with an export can't use CONSTRUCT or DESCRIBE queries.")
+		}
+		if view.ViewConfig.Transform != nil {
+			return errors.New("Views with a transform can't use CONSTRUCT or DESCRIBE queries.")
+		}
+
+		start := time.Now()
+		graph, err := repo.QueryGraph(view.ViewConfig.QueryFile)
+		metrics.QueryDuration = time.Since(start)
+		if err != nil {
+			return utils.ErrorExit("SPARQL query failed.", err)
+		}
+		if len(graph.Triples) == 0 {
+			if err := b.noResults(view); err != nil {
+				return err
+			}
+		}
+		return b.renderPage(view, metrics, outputPath, source, nil, graph)
+	}
+
+	results := make([]map[string]rdf.Term, 0)
+	if repo != nil {
+		start := time.Now()
+		var err error
+		results, err = repo.SharedQuery(view.ViewConfig.QueryFile)
+		metrics.QueryDuration = time.Since(start)
+		if err != nil {
+			return utils.ErrorExit("SPARQL query failed.", err)
+		}
+		if len(results) == 0 && sparql.QueryForm(repo.QueryIndex[view.ViewConfig.QueryFile]) != "ASK" {
+			if err := b.noResults(view); err != nil {
+				return err
+			}
+		}
+	}
+
+	if view.ViewConfig.Export != nil {
+		if err := b.writeExport(view, results); err != nil {
+			return err
+		}
+	}
+
+	if view.ViewConfig.Transform != nil && view.ViewConfig.Paginate == 0 {
+		transformed, err := view.Transform(results)
+		if err != nil {
+			return err
+		}
+		return b.renderPage(view, metrics, outputPath, source, nil, transformed)
+	}
+
+	if view.ViewConfig.Paginate > 0 {
+		// paginated views can't group, their items are the rows with fields
+		for i, row := range results {
+			extended, err := view.AddFields(row)
+			if err != nil {
+				return err
+			}
+			results[i] = extended
+		}
+		for _, page := range view.Paginate(results) {
+			pagePath := config.CurrentSiteConfig.OutputDir + "/" + view.PageOutput(page.PageNumber)
+			pageSource := source + " page " + strconv.Itoa(page.PageNumber)
+			if err := b.renderPage(view, metrics, pagePath, pageSource, nil, page); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return b.renderPage(view, metrics, outputPath, source, nil, results)
+}
+
+// renderLevel renders the given views using a pool of workers. When failing
+// fast the first error returned by a worker is sent to failure and stops the
+// remaining workers from picking up new work.
+func (b *buildState) renderLevel(level []views.View, failure chan error, cancel context.CancelFunc) {
+	queue := make(chan views.View)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for view := range queue {
+				b.progress.Begin(view.ViewConfig.Output)
+				err := b.renderView(view)
+				b.progress.Done(view.ViewConfig.Output)
+				if err != nil {
+					err = utils.ErrorExit("Failed to build view "+view.ViewConfig.Output+".", err)
+					if b.errorPages && b.ctx.Err() == nil {
+						paths := errorPaths(view)
+						if showErr := b.showError(view, paths, "view "+view.ViewConfig.Output, err); showErr != nil {
+							err = showErr
+						} else {
+							// the error pages are kept until the view is rendered again
+							if b.state != nil {
+								b.state.Record(view.ViewConfig.Output, incremental.ViewState{Pages: paths})
+							}
+							continue
+						}
+					}
+					if !b.failFast {
+						logger.Error(err.Error())
+						b.failures.add(err)
+						continue
+					}
+
+					select {
+					case failure <- err:
+					default:
+					}
+					cancel()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for _, view := range level {
+		select {
+		case <-b.ctx.Done():
+			break dispatch
+		case queue <- view:
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// renderViews renders the given views, ordered by their dependencies, and
+// returns the paths of the rendered pages. Views that don't depend on each
+// other are rendered in parallel.
+func renderViews(discoveredViews []views.View, buildReport *report.Report, options renderOptions) (*renderedPaths, error) {
+	levels, err := views.Levels(discoveredViews)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(interrupted)
+	defer cancel()
+
+	state := buildState{
+		renderOptions: options,
+		ctx:           ctx,
+		rendered:      &renderedPaths{paths: make(map[string]renderedPath)},
+		report:        buildReport,
+		failures:      &failures{},
+		errorViews:    &errorViews{},
+	}
+	failure := make(chan error, 1)
+
+	if options.progress {
+		state.progress = progress.Start(len(discoveredViews))
+		defer state.progress.Stop()
+	}
+
+	for _, level := range levels {
+		state.renderLevel(level, failure, cancel)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	select {
+	case err := <-failure:
+		return nil, err
+	default:
+	}
+
+	if err := state.failures.err(); err != nil {
+		return nil, err
+	}
+	if count := state.errorViews.count(); count == 1 {
+		logger.Warn("1 view failed to build and shows an error page instead.")
+	} else if count > 1 {
+		logger.Warn(strconv.Itoa(count) + " views failed to build and show an error page instead.")
+	}
+	return state.rendered, nil
+}
@@ -1,20 +1,65 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/glaciers-in-archives/snowman/internal/logger"
 	"github.com/spf13/cobra"
 )
 
 var timeit bool
 var verbose bool
+var quiet bool
+var logLevel string
+var configFileLocation string
+var projectDir string
 
-func printVerbose(message string) {
-	if verbose {
-		fmt.Println(message)
+// setLogLevel applies the logging flags. --quiet and --verbose take
+// precedence over --log-level.
+func setLogLevel() error {
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		return err
 	}
+
+	if quiet && verbose {
+		return errors.New("The quiet and verbose flags can't be combined.")
+	} else if quiet {
+		level = logger.ErrorLevel
+	} else if verbose {
+		level = logger.DebugLevel
+	}
+
+	logger.SetLevel(level)
+	return nil
+}
+
+// enterProjectDir changes to the project directory, if one is given, so that
+// all paths resolve against it. Unless the command creates a new project, the
+// directory must contain the config file.
+func enterProjectDir(cmd *cobra.Command) error {
+	if projectDir == "" {
+		return nil
+	}
+
+	if info, err := os.Stat(projectDir); err != nil || !info.IsDir() {
+		return errors.New("The project directory " + projectDir + " doesn't exist.")
+	}
+	if err := os.Chdir(projectDir); err != nil {
+		return err
+	}
+	logger.Debug("Using the project in " + projectDir)
+
+	if cmd.Name() == "new" || cmd.Name() == "version" {
+		return nil
+	}
+	if _, err := os.Stat(configFileLocation); err != nil {
+		return errors.New("The project directory " + projectDir + " doesn't contain " + configFileLocation + ".")
+	}
+	return nil
 }
 
 func elapsed() func() {
@@ -31,6 +76,12 @@ var rootCmd = &cobra.Command{
 	Use:   "snowman <command> [flags]",
 	Short: "A static site generator for SPARQL backends. ",
 	Long:  `Snowman is a CLI tool for creating websites from SPARQL queries.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setLogLevel(); err != nil {
+			return err
+		}
+		return enterProjectDir(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -47,4 +98,9 @@ func init() {
 	rootCmd.SilenceUsage = true
 	rootCmd.PersistentFlags().BoolVarP(&timeit, "timeit", "t", false, "")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Activate verbose output.")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors.")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Sets the lowest level of messages printed: debug, info, warn or error.")
+	// -c is taken by the cache strategy of the build command
+	rootCmd.PersistentFlags().StringVarP(&configFileLocation, "config", "f", "snowman.yaml", "Sets the config file to use, relative to the project directory.")
+	rootCmd.PersistentFlags().StringVarP(&projectDir, "project-dir", "C", "", "Runs the command in the given project directory instead of the current directory.")
 }
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/livereload"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/report"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/glaciers-in-archives/snowman/internal/watcher"
+)
+
+// watchedPaths are the project files and directories which trigger a rebuild.
+func watchedPaths() []string {
+	return append([]string{config.CurrentSiteConfig.TemplatesDir, config.CurrentSiteConfig.StaticDir}, dataPaths()...)
+}
+
+// watchSession reuses the query responses of earlier builds while watching for
+// changes, until a query or the configuration changes.
+var watchSession *cache.Session
+
+// dataPaths are the project files which change the results of queries.
+func dataPaths() []string {
+	paths := []string{"queries", "views.yaml", configFileLocation}
+	// the RDF files of local endpoints are data the site is built from
+	for _, client := range config.CurrentSiteConfig.Clients {
+		if path, isFile := config.LocalFile(client.Endpoint); isFile {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// reuseResults starts reusing the query responses of earlier builds, unless
+// the changed paths include queries or configuration, after which all queries
+// are issued again.
+func reuseResults(changed []string) {
+	if watchSession == nil {
+		watchSession = cache.NewSession()
+		return
+	}
+	for _, path := range changed {
+		path = filepath.Clean(path)
+		for _, dataPath := range dataPaths() {
+			dataPath = filepath.Clean(dataPath)
+			if path == dataPath || strings.HasPrefix(path, dataPath+string(filepath.Separator)) {
+				logger.Debug("Queries or configuration changed, issuing the queries again.")
+				watchSession.Reset()
+				return
+			}
+		}
+	}
+	logger.Debug("Reusing the query results of the last build.")
+}
+
+// serveWatching builds the site, serves it with live reload and rebuilds the
+// views affected whenever project files change, like build --watch. Failing
+// builds don't stop the server.
+func serveWatching() error {
+	developing = true
+	reuseResults(nil)
+	if err := build(report.NewReport(), false); err != nil {
+		logger.Error(err.Error())
+	}
+
+	w, err := watcher.NewWatcher(watchedPaths(), 100*time.Millisecond)
+	if err != nil {
+		return utils.ErrorExit("Failed to watch project files.", err)
+	}
+	defer w.Close()
+
+	broker := livereload.NewBroker()
+	go func() {
+		err := w.Run(func(changed []string) {
+			for _, path := range changed {
+				logger.Debug("Changed: " + path)
+			}
+
+			logger.Info("Rebuilding site...")
+			reuseResults(changed)
+			if err := build(report.NewReport(), true); err != nil {
+				logger.Error(err.Error())
+				return
+			}
+			broker.Reload()
+		})
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle(livereload.EventsPath, broker)
+	siteDir := config.CurrentSiteConfig.OutputDir
+	mux.Handle("/", basePathHandler(notFoundHandler(livereload.Inject(http.FileServer(http.Dir(siteDir)), siteDir), siteDir)))
+
+	address := serverInterface + ":" + strconv.Itoa(port)
+	logger.Info("Serving site at http://" + address + config.CurrentSiteConfig.BasePath + "/ with live reload. Hold ctrl+c to exit.")
+	return http.ListenAndServe(address, loggingHandler(mux))
+}
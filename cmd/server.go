@@ -1,17 +1,22 @@
 package cmd
 
 import (
-	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
 	"github.com/spf13/cobra"
 )
 
 var port int
 var serverInterface string
+var watchServerOption bool
 
 func loggingHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -20,21 +25,71 @@ func loggingHandler(h http.Handler) http.Handler {
 	})
 }
 
+// notFoundHandler serves the 404.html page of the site, if there is one, for
+// paths that don't exist in the site directory.
+func notFoundHandler(h http.Handler, siteDir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filePath := filepath.Join(siteDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			if content, err := os.ReadFile(filepath.Join(siteDir, config.NotFoundPage)); err == nil {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write(content)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// basePathHandler serves the site under the base_path it's built for and
+// redirects the root of the server there.
+func basePathHandler(h http.Handler) http.Handler {
+	basePath := config.CurrentSiteConfig.BasePath
+	if basePath == "" {
+		return h
+	}
+	site := http.StripPrefix(basePath, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			site.ServeHTTP(w, r)
+		case r.URL.Path == "/" || r.URL.Path == basePath:
+			http.Redirect(w, r, basePath+"/", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
 // serverCmd represents the server command
 var serverCmd = &cobra.Command{
-	Use:   "server",
-	Short: "Serves your site through an HTTP server.",
-	Long:  `This command will serve your site through Snowman's built-in webserver. It's intended only for usage during development.`,
-	Args:  cobra.RangeArgs(0, 2),
+	Use:     "server",
+	Aliases: []string{"serve"},
+	Short:   "Serves your site through an HTTP server.",
+	Long:    `This command will serve your site through Snowman's built-in webserver. With --watch, or when called as serve, it builds the site first, rebuilds it whenever templates, queries, static files or the configuration change and reloads open pages in the browser after each rebuild. It's intended only for usage during development.`,
+	Args:    cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if _, err := os.Stat("site"); err != nil {
-			fmt.Println("No site found. Did your run snowman build?")
+		if watchServerOption || cmd.CalledAs() == "serve" {
+			return serveWatching()
+		}
+
+		// the site directory might be configured, but a config isn't required
+		if _, err := os.Stat(configFileLocation); err == nil {
+			if err := config.LoadConfig(configFileLocation, configOverrides()); err != nil {
+				return err
+			}
+		}
+		siteDir := config.CurrentSiteConfig.OutputDir
+
+		if _, err := os.Stat(siteDir); err != nil {
+			logger.Warn("No site found. Did your run snowman build?")
 		}
 
-		fs := http.FileServer(http.Dir("site/"))
+		fs := http.FileServer(http.Dir(siteDir))
 		address := serverInterface + ":" + strconv.Itoa(port)
-		fmt.Println("Serving site at http://" + address + ". Hold ctrl+c to exit.")
-		if err := http.ListenAndServe(address, loggingHandler(fs)); err != nil {
+		logger.Info("Serving site at http://" + address + config.CurrentSiteConfig.BasePath + "/. Hold ctrl+c to exit.")
+		if err := http.ListenAndServe(address, loggingHandler(basePathHandler(notFoundHandler(fs, siteDir)))); err != nil {
 			// utils.ErrorExit() wont work here has
 			log.Println(err) // #TODO shutdown gracefully
 		}
@@ -48,4 +103,6 @@ func init() {
 	rootCmd.AddCommand(serverCmd)
 	serverCmd.Flags().IntVarP(&port, "port", "p", 8000, "Port on which the server will listen.")
 	serverCmd.Flags().StringVarP(&serverInterface, "address", "a", "127.0.0.1", "Address to which the server will bind.")
+	serverCmd.Flags().BoolVarP(&watchServerOption, "watch", "w", false, "When set Snowman will build the site, rebuild it on changes and reload open pages. It's the default when called as serve.")
+	addBuildFlags(serverCmd.Flags())
 }
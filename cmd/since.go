@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+)
+
+var sinceBuildOption string
+
+// lastBuildFile holds the start time of the last finished build.
+const lastBuildFile = ".snowman/last_build_time"
+
+// parseSince parses the time given with --since, which is a date, a RFC 3339
+// time or last for the start of the last finished build.
+func parseSince(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if value == "last" {
+		data, err := os.ReadFile(lastBuildFile)
+		if os.IsNotExist(err) {
+			return nil, errors.New("No build has finished yet, so there is no last build to rebuild the changes since.")
+		}
+		if err != nil {
+			return nil, err
+		}
+		value = strings.TrimSpace(string(data))
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if since, err := time.Parse(layout, value); err == nil {
+			return &since, nil
+		}
+	}
+	return nil, errors.New("The since flag must be a date like 2024-05-01, a time like 2024-05-01T12:00:00Z or last.")
+}
+
+// recordBuildTime saves the start time of a finished build for --since last.
+func recordBuildTime(start time.Time) error {
+	if err := os.MkdirAll(".snowman", 0770); err != nil {
+		return err
+	}
+	return os.WriteFile(lastBuildFile, []byte(start.UTC().Format(time.RFC3339)+"\n"), 0666)
+}
+
+// partialResults returns why the pages of a view can't be rendered from the
+// changed resources alone, or nothing if they can.
+func partialResults(view views.View) string {
+	switch {
+	case view.ViewConfig.Paginate > 0:
+		return "paginates its results"
+	case view.MultipageVariableHook == nil:
+		return "renders a single page of all its results"
+	case view.ViewConfig.Siblings:
+		return "links its pages to their siblings"
+	case view.ViewConfig.Transform != nil && view.ViewConfig.Transform.GroupBy != "":
+		return "groups its results"
+	}
+	return ""
+}
+
+// sinceViews returns the views whose queries use the since parameter, which
+// are the only ones rebuilt with --since. Views whose pages depend on the
+// other results too are skipped with a warning, as they'd be rendered from the
+// changed resources only. Their dependencies on the other views are dropped.
+func sinceViews(discoveredViews []views.View, queries map[string]string) []views.View {
+	selected := map[string]bool{}
+	for _, view := range discoveredViews {
+		if view.ViewConfig.QueryFile == "" || !sparql.UsesParam(queries[view.ViewConfig.QueryFile], sparql.SinceParam) {
+			continue
+		}
+		if reason := partialResults(view); reason != "" {
+			logger.Warn("The view " + view.ViewConfig.Output + " " + reason + ", so it isn't rebuilt with --since, which would only include the changed resources.")
+			continue
+		}
+		selected[view.ViewConfig.Output] = true
+	}
+
+	var since []views.View
+	for _, view := range discoveredViews {
+		if !selected[view.ViewConfig.Output] {
+			continue
+		}
+		var kept []string
+		for _, dependency := range view.ViewConfig.DependsOn {
+			if selected[dependency] {
+				kept = append(kept, dependency)
+			}
+		}
+		view.ViewConfig.DependsOn = kept
+		since = append(since, view)
+	}
+	return since
+}
+
+// partialBuild reports whether only some views or pages are built, in which
+// case the existing site is kept and no site-wide files are written.
+func partialBuild() bool {
+	return len(onlyBuildOption) > 0 || sinceBuildOption != ""
+}
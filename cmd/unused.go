@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/views"
+)
+
+var stringPattern = regexp.MustCompile("\"((?:[^\"\\\\\\n]|\\\\.)*)\"|`([^`]*)`")
+var definitionPattern = regexp.MustCompile(`{{-?\s*(?:define|block)\s+"([^"]+)"`)
+
+// unusedFiles returns a warning for every query file and include that isn't
+// used. Nothing is removed.
+func unusedFiles(queryFiles []string, includes []string, discoveredViews []views.View) ([]string, error) {
+	literals, err := templateStrings()
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	for _, query := range unusedQueries(queryFiles, discoveredViews, literals) {
+		warnings = append(warnings, "query "+query+": not used by any view, feed or template.")
+	}
+	unused, err := unusedIncludes(includes, discoveredViews, literals)
+	if err != nil {
+		return nil, err
+	}
+	for _, include := range unused {
+		warnings = append(warnings, "include "+include+": not used by any view or template.")
+	}
+	return warnings, nil
+}
+
+// templateStrings returns the quoted strings of every template file, by path.
+// They include the names passed to the query, include and template functions
+// and statements.
+func templateStrings() (map[string]map[string]bool, error) {
+	found := map[string]map[string]bool{}
+	err := filepath.Walk(config.CurrentSiteConfig.TemplatesDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found[path] = map[string]bool{}
+		for _, match := range stringPattern.FindAllStringSubmatch(string(data), -1) {
+			found[path][match[1]+match[2]] = true
+		}
+		return nil
+	})
+	return found, err
+}
+
+// unusedQueries returns the query files that no view, feed or template
+// refers to.
+func unusedQueries(queryFiles []string, discoveredViews []views.View, literals map[string]map[string]bool) []string {
+	used := map[string]bool{}
+	for _, view := range discoveredViews {
+		used[view.ViewConfig.QueryFile] = true
+	}
+	for _, feed := range config.CurrentSiteConfig.Feeds {
+		used[feed.Query] = true
+	}
+	for _, fileLiterals := range literals {
+		for literal := range fileLiterals {
+			used[literal] = true
+		}
+	}
+
+	var unused []string
+	for _, query := range queryFiles {
+		if !used[query] {
+			unused = append(unused, query)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// unusedIncludes returns the includes that no view uses as its template and
+// no other template refers to by their file name, their path or the templates
+// they define.
+func unusedIncludes(includes []string, discoveredViews []views.View, literals map[string]map[string]bool) ([]string, error) {
+	templates := map[string]bool{}
+	for _, view := range discoveredViews {
+		templates[filepath.Join(config.CurrentSiteConfig.TemplatesDir, view.ViewConfig.TemplateFile)] = true
+	}
+
+	var unused []string
+	for _, include := range includes {
+		if templates[filepath.Clean(include)] {
+			continue
+		}
+		data, err := os.ReadFile(include)
+		if err != nil {
+			return nil, err
+		}
+		names := []string{filepath.Base(include)}
+		for _, match := range definitionPattern.FindAllStringSubmatch(string(data), -1) {
+			names = append(names, match[1])
+		}
+
+		if !referenced(include, names, literals) {
+			unused = append(unused, include)
+		}
+	}
+	sort.Strings(unused)
+	return unused, nil
+}
+
+// referenced reports whether a template other than the given file contains
+// one of the names, or a path ending in one.
+func referenced(file string, names []string, literals map[string]map[string]bool) bool {
+	for path, fileLiterals := range literals {
+		if filepath.Clean(path) == filepath.Clean(file) {
+			continue
+		}
+		for literal := range fileLiterals {
+			for _, name := range names {
+				if literal == name || strings.HasSuffix(literal, "/"+name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -15,11 +15,21 @@ var versionCmd = &cobra.Command{
 	Long:  `Prints the Snowman version and additional build information.`,
 	Args:  cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		fmt.Println("Snowman " + version.CurrentVersion.String() + " " + runtime.GOOS + "/" + runtime.GOARCH)
+		fmt.Println(versionLine())
 		return nil
 	},
 }
 
+// versionLine describes the executable, like "Snowman 0.6.0 (commit
+// 1a2b3c4d5e6f, built 2024-01-02T15:04:05Z) linux/amd64".
+func versionLine() string {
+	return "Snowman " + version.Build().String() + " " + runtime.GOOS + "/" + runtime.GOARCH
+}
+
 func init() {
 	rootCmd.AddCommand(versionCmd)
+
+	// also print the version with --version
+	rootCmd.Version = version.Build().Version
+	rootCmd.SetVersionTemplate(versionLine() + "\n")
 }
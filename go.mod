@@ -3,13 +3,22 @@ module github.com/glaciers-in-archives/snowman
 go 1.19
 
 require (
+	github.com/andybalholm/brotli v1.0.5
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/knakk/rdf v0.0.0-20190304171630-8521bf4c5042
+	github.com/piprate/json-gold v0.7.0
 	github.com/spf13/cast v1.4.1
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
+	github.com/tdewolff/minify/v2 v2.20.37
+	github.com/tdewolff/parse/v2 v2.7.15
+	github.com/yuin/goldmark v1.5.6
+	golang.org/x/image v0.18.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/pquerna/cachecontrol v0.0.0-20180517163645-1555304b9b35 // indirect
+	golang.org/x/sys v0.16.0 // indirect
 )
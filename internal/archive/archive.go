@@ -0,0 +1,165 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Formats maps the supported archive formats to the extension of their files.
+var Formats = map[string]string{
+	"zip":   ".zip",
+	"targz": ".tar.gz",
+}
+
+// Path returns the path of the archive of dir in the given format, which is
+// next to the directory.
+func Path(dir string, format string) string {
+	return filepath.Clean(dir) + Formats[format]
+}
+
+// Write packages the contents of dir into an archive of the given format at
+// Path. Files are streamed into the archive, which replaces an existing one
+// once it's complete. If modTime is set it replaces the modification times and
+// owners of the files, making the archive reproducible.
+func Write(dir string, format string, modTime *time.Time) (string, error) {
+	if _, exists := Formats[format]; !exists {
+		return "", errors.New("Unknown archive format " + format + ". Use zip or targz.")
+	}
+
+	path := Path(dir, format)
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if format == "zip" {
+		err = writeZip(f, dir, modTime)
+	} else {
+		err = writeTarGz(f, dir, modTime)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(f.Name(), path)
+}
+
+// walk calls add for everything in dir with its path relative to dir, using
+// forward slashes.
+func walk(dir string, add func(path string, name string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		return add(path, filepath.ToSlash(name), info)
+	})
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func writeZip(w io.Writer, dir string, modTime *time.Time) error {
+	zw := zip.NewWriter(w)
+	err := walk(dir, func(path string, name string, info os.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		if modTime != nil {
+			header.Modified = *modTime
+		}
+
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			// like zip tools, the target of a symlink is stored as its content
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(entry, target)
+			return err
+		case info.Mode().IsRegular():
+			return copyFile(entry, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, dir string, modTime *time.Time) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	err := walk(dir, func(path string, name string, info os.FileInfo) error {
+		target := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			var err error
+			if target, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, target)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if modTime != nil {
+			header.ModTime = *modTime
+			header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			return copyFile(tw, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
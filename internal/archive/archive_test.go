@@ -0,0 +1,100 @@
+//go:build !windows
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSite(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "site")
+	if err := os.MkdirAll(filepath.Join(dir, "items"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), []byte("<p>index</p>"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "items", "run.sh"), []byte("echo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestWriteZip(t *testing.T) {
+	dir := writeSite(t)
+	path, err := Write(dir, "zip", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != dir+".zip" {
+		t.Errorf("Expected the archive next to the site directory, got %s", path)
+	}
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(content)
+	}
+
+	if len(files) != 2 || files["index.html"] != "<p>index</p>" || files["items/run.sh"] != "echo" {
+		t.Errorf("Expected the files of the site, got %v", files)
+	}
+}
+
+func TestWriteTarGz(t *testing.T) {
+	dir := writeSite(t)
+	path, err := Write(dir, "targz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modes := map[string]os.FileMode{}
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		modes[header.Name] = header.FileInfo().Mode().Perm()
+	}
+
+	if _, exists := modes["items/"]; !exists || len(modes) != 3 {
+		t.Errorf("Expected the directories and files of the site, got %v", modes)
+	}
+	if modes["items/run.sh"] != 0755 {
+		t.Errorf("Expected the mode of run.sh to be preserved, got %v", modes["items/run.sh"])
+	}
+}
@@ -0,0 +1,147 @@
+// Package basepath prefixes internal links with the path a site is served
+// from, for sites hosted in a subdirectory rather than at the root of a host.
+package basepath
+
+import (
+	"bytes"
+	"html"
+	"os"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	html_lexer "github.com/tdewolff/parse/v2/html"
+)
+
+// Normalize returns the base path with a leading and without a trailing
+// slash, like /project, or an empty string for the root.
+func Normalize(basePath string) string {
+	basePath = strings.Trim(strings.TrimSpace(basePath), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// Prefix returns the target under the base path. Paths relative to the site
+// root, with or without a leading slash, are prefixed unless they're already
+// under the base path. URLs with a scheme or host, fragments and queries are
+// returned unchanged.
+func Prefix(basePath string, target string) string {
+	if target == "" || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "?") || hasScheme(target) {
+		return target
+	}
+	if !strings.HasPrefix(target, "/") {
+		target = "/" + target
+	}
+	if basePath == "" || target == basePath || strings.HasPrefix(target, basePath+"/") || strings.HasPrefix(target, basePath+"?") || strings.HasPrefix(target, basePath+"#") {
+		return target
+	}
+	return basePath + target
+}
+
+// hasScheme reports whether the target starts with a URL scheme like https:
+// or mailto:.
+func hasScheme(target string) bool {
+	for i, c := range target {
+		switch {
+		case c == ':':
+			return i > 0
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// Rewrite prefixes the root-relative href, src, action, poster and srcset
+// attributes of an HTML page with the base path. The rest of the page is
+// copied as is.
+func Rewrite(data []byte, basePath string) []byte {
+	if basePath == "" {
+		return data
+	}
+
+	var rewritten bytes.Buffer
+	rewritten.Grow(len(data))
+	lexer := html_lexer.NewLexer(parse.NewInputBytes(data))
+	for {
+		tokenType, token := lexer.Next()
+		if tokenType == html_lexer.ErrorToken {
+			return rewritten.Bytes()
+		}
+		if tokenType != html_lexer.AttributeToken {
+			rewritten.Write(token)
+			continue
+		}
+
+		value := lexer.AttrVal()
+		var replaced string
+		switch strings.ToLower(string(lexer.AttrKey())) {
+		case "href", "src", "action", "poster":
+			replaced = rewriteValue(value, func(target string) string {
+				return prefixRootRelative(basePath, target)
+			})
+		case "srcset":
+			replaced = rewriteValue(value, func(srcset string) string {
+				candidates := strings.Split(srcset, ",")
+				for i, candidate := range candidates {
+					trimmed := strings.TrimLeft(candidate, " \t\n\r\f")
+					target := strings.Fields(trimmed)
+					if len(target) > 0 {
+						candidates[i] = candidate[:len(candidate)-len(trimmed)] + prefixRootRelative(basePath, target[0]) + trimmed[len(target[0]):]
+					}
+				}
+				return strings.Join(candidates, ",")
+			})
+		}
+		if replaced == "" {
+			rewritten.Write(token)
+			continue
+		}
+		// the value always ends the attribute token
+		rewritten.Write(token[:len(token)-len(value)])
+		rewritten.WriteString(replaced)
+	}
+}
+
+// prefixRootRelative prefixes targets with a single leading slash only.
+func prefixRootRelative(basePath string, target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return target
+	}
+	return Prefix(basePath, target)
+}
+
+// rewriteValue returns the attribute value, quoted like the original, with
+// its unescaped content replaced, or an empty string if nothing changed.
+func rewriteValue(value []byte, replace func(string) string) string {
+	quote := ""
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		quote = string(value[0])
+	}
+	content := html.UnescapeString(strings.Trim(string(value), `"'`))
+	replaced := replace(content)
+	if replaced == content {
+		return ""
+	}
+	escaped := html.EscapeString(replaced)
+	if quote == "" {
+		quote = `"`
+	}
+	return quote + escaped + quote
+}
+
+// RewriteFile rewrites the links of the HTML page at the path in place.
+func RewriteFile(file string, basePath string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	rewritten := Rewrite(data, basePath)
+	if bytes.Equal(data, rewritten) {
+		return nil
+	}
+	return os.WriteFile(file, rewritten, 0644)
+}
@@ -0,0 +1,35 @@
+package basepath
+
+import "testing"
+
+func TestPrefix(t *testing.T) {
+	tests := map[string]string{
+		"/":                    "/project/",
+		"/items/1.html":        "/project/items/1.html",
+		"items/1.html":         "/project/items/1.html",
+		"/project":             "/project",
+		"/project/items/":      "/project/items/",
+		"/projects/":           "/project/projects/",
+		"https://example.org/": "https://example.org/",
+		"//cdn.example.org/a":  "//cdn.example.org/a",
+		"mailto:a@example.org": "mailto:a@example.org",
+		"#top":                 "#top",
+	}
+	for target, expected := range tests {
+		if prefixed := Prefix("/project", target); prefixed != expected {
+			t.Errorf("Prefix(%q) = %q, expected %q", target, prefixed, expected)
+		}
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	page := `<a href="/items/1.html?a=1&amp;b=2">1</a><a HREF='items/2.html'>2</a><img src=/logo.png srcset="/a.png 1x, /b.png 2x"><a href="/project/">home</a><script>var a = '<a href="/x">'</script>`
+	expected := `<a href="/project/items/1.html?a=1&amp;b=2">1</a><a href='items/2.html'>2</a><img src="/project/logo.png" srcset="/project/a.png 1x, /project/b.png 2x"><a href="/project/">home</a><script>var a = '<a href="/x">'</script>`
+
+	if rewritten := string(Rewrite([]byte(page), "/project")); rewritten != expected {
+		t.Errorf("Unexpected rewritten page:\n%s\nexpected:\n%s", rewritten, expected)
+	}
+	if rewritten := string(Rewrite([]byte(expected), "/project")); rewritten != expected {
+		t.Errorf("Rewriting a rewritten page changed it:\n%s", rewritten)
+	}
+}
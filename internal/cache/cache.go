@@ -3,10 +3,13 @@ package cache
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/glaciers-in-archives/snowman/internal/utils"
 )
@@ -18,15 +21,77 @@ func Hash(value string) string {
 	return hex.EncodeToString(hash[:])
 }
 
+// ContentHash returns the hash identifying the response to the given query
+// issued against the given endpoint.
+func ContentHash(endpoint string, query string) string {
+	query = strings.TrimSpace(strings.ReplaceAll(query, "\r\n", "\n"))
+	return Hash(endpoint + "\n" + query)
+}
+
 type CacheManager struct {
-	CacheStrategy          string // "available", "never"
+	mutex                  sync.Mutex
+	CacheStrategy          string // "available", "never", "offline"
+	TTL                    time.Duration
 	StoredCacheHashes      map[string]bool
 	CacheHashesUsedInBuild []string
+	// Session is set while watching for changes, see Session
+	Session *Session
+	// Hits and Misses count the lookups of responses, Writes the responses
+	// written to the cache
+	Hits, Misses, Writes int
+}
+
+// Session holds the cached responses used by the builds of a session, like
+// the rebuilds while watching for changes. They are reused regardless of the
+// cache strategy and TTL until the session is reset.
+type Session struct {
+	mutex  sync.Mutex
+	hashes map[string]bool
+}
+
+func NewSession() *Session {
+	return &Session{hashes: make(map[string]bool)}
+}
+
+// Reset makes the following builds query again.
+func (s *Session) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hashes = make(map[string]bool)
 }
 
-func NewCacheManager(strategy string) (*CacheManager, error) {
+func (s *Session) add(hash string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hashes[hash] = true
+}
+
+func (s *Session) has(hash string) bool {
+	if s == nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.hashes[hash]
+}
+
+// writes reports whether responses are written to the cache. With the never
+// strategy they are only written to be reused within a session.
+func (cm *CacheManager) writes() bool {
+	return cm.CacheStrategy != "never" || cm.Session != nil
+}
+
+func NewCacheManager(strategy string, ttl time.Duration) (*CacheManager, error) {
+	if strategy != "available" && strategy != "never" && strategy != "offline" {
+		return nil, errors.New("Unknown cache strategy " + strategy + ".")
+	}
+
 	cm := CacheManager{
 		CacheStrategy: strategy,
+		TTL:           ttl,
 	}
 	cm.StoredCacheHashes = make(map[string]bool)
 
@@ -56,6 +121,10 @@ func (cm *CacheManager) readStoredHashes() error {
 			return err
 		}
 		for _, contentFileInfo := range contentDirInfo {
+			// skip responses which are still being written or were never committed
+			if strings.HasPrefix(contentFileInfo.Name(), ".") {
+				continue
+			}
 			fullCacheHash := locationDirInfo.Name() + "/" + strings.Replace(contentFileInfo.Name(), ".json", "", 1)
 			cm.StoredCacheHashes[fullCacheHash] = true
 		}
@@ -64,25 +133,75 @@ func (cm *CacheManager) readStoredHashes() error {
 	return nil
 }
 
-func (cm *CacheManager) GetCache(location string, query string) (*os.File, error) {
-	fullQueryHash := Hash(location) + "/" + Hash(query)
+func (cm *CacheManager) GetCache(location string, endpoint string, query string) (*os.File, error) {
+	return cm.GetCacheWithTTL(location, endpoint, query, cm.TTL)
+}
+
+// GetCacheWithTTL works like GetCache but considers cached responses older
+// than the given TTL stale instead of using the TTL of the cache manager.
+func (cm *CacheManager) GetCacheWithTTL(location string, endpoint string, query string, ttl time.Duration) (*os.File, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	file, err := cm.lookup(location, endpoint, query, ttl)
+	if file != nil {
+		cm.Hits++
+	} else {
+		cm.Misses++
+	}
+	return file, err
+}
+
+func (cm *CacheManager) lookup(location string, endpoint string, query string, ttl time.Duration) (*os.File, error) {
+	fullQueryHash := Hash(location) + "/" + ContentHash(endpoint, query)
 	cm.CacheHashesUsedInBuild = append(cm.CacheHashesUsedInBuild, fullQueryHash)
 
-	if !cm.StoredCacheHashes[fullQueryHash] || cm.CacheStrategy == "never" {
+	queryCacheLocation := CacheLocation + fullQueryHash + ".json"
+	if cm.Session.has(fullQueryHash) {
+		if file, err := os.Open(queryCacheLocation); err == nil {
+			return file, nil
+		}
+	}
+
+	if cm.CacheStrategy == "never" {
 		return nil, nil
 	}
 
-	queryCacheLocation := CacheLocation + fullQueryHash + ".json"
+	if !cm.StoredCacheHashes[fullQueryHash] {
+		if cm.CacheStrategy == "offline" {
+			return nil, errors.New("No cached response available for " + location + " while offline.")
+		}
+		return nil, nil
+	}
+
+	// stale items are still used when offline as there is no way of refreshing them
+	if ttl > 0 && cm.CacheStrategy != "offline" {
+		info, err := os.Stat(queryCacheLocation)
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(info.ModTime()) > ttl {
+			return nil, nil
+		}
+	}
 
-	return os.Open(queryCacheLocation)
+	file, err := os.Open(queryCacheLocation)
+	if err != nil {
+		return nil, err
+	}
+	cm.Session.add(fullQueryHash)
+	return file, nil
 }
 
-func (cm *CacheManager) SetCache(location string, query string, content string) error {
-	if cm.CacheStrategy == "never" {
+func (cm *CacheManager) SetCache(location string, endpoint string, query string, content string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	if !cm.writes() {
 		return nil
 	}
 
-	fullQueryHash := Hash(location) + "/" + Hash(query)
+	fullQueryHash := Hash(location) + "/" + ContentHash(endpoint, query)
 	queryCacheLocation := CacheLocation + fullQueryHash + ".json"
 
 	if err := os.MkdirAll(filepath.Dir(queryCacheLocation), 0770); err != nil {
@@ -103,10 +222,71 @@ func (cm *CacheManager) SetCache(location string, query string, content string)
 	f.Sync()
 
 	cm.StoredCacheHashes[fullQueryHash] = true
+	cm.Session.add(fullQueryHash)
+	cm.Writes++
 
 	return nil
 }
 
+// CacheWriter writes a response to the cache while it's being read. The
+// response only becomes available in the cache once it has been committed.
+type CacheWriter struct {
+	file     *os.File
+	cm       *CacheManager
+	hash     string
+	location string
+}
+
+// NewCacheWriter returns a writer for the response to the given query, or nil
+// if responses shouldn't be cached.
+func (cm *CacheManager) NewCacheWriter(location string, endpoint string, query string) (*CacheWriter, error) {
+	if !cm.writes() {
+		return nil, nil
+	}
+
+	fullQueryHash := Hash(location) + "/" + ContentHash(endpoint, query)
+	queryCacheLocation := CacheLocation + fullQueryHash + ".json"
+
+	if err := os.MkdirAll(filepath.Dir(queryCacheLocation), 0770); err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(queryCacheLocation), ".partial-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheWriter{file: f, cm: cm, hash: fullQueryHash, location: queryCacheLocation}, nil
+}
+
+func (w *CacheWriter) Write(p []byte) (int, error) {
+	return w.file.Write(p)
+}
+
+// Commit moves the written response into the cache.
+func (w *CacheWriter) Commit() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(w.file.Name(), w.location); err != nil {
+		return err
+	}
+
+	w.cm.mutex.Lock()
+	defer w.cm.mutex.Unlock()
+	w.cm.StoredCacheHashes[w.hash] = true
+	w.cm.Session.add(w.hash)
+	w.cm.Writes++
+	return nil
+}
+
+// Abort discards the written response.
+func (w *CacheWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
 func (cm *CacheManager) Teardown() error {
 	if err := utils.WriteLineSeperatedFile(cm.CacheHashesUsedInBuild, ".snowman/last_build_queries.txt"); err != nil {
 		return err
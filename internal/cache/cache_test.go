@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"io"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	location := CacheLocation
+	CacheLocation = t.TempDir() + "/"
+	defer func() { CacheLocation = location }()
+
+	cm, err := NewCacheManager("never", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.SetCache("index.rq", "https://example.org/sparql", "SELECT * {}", "response"); err != nil {
+		t.Fatal(err)
+	}
+	if file, _ := cm.GetCache("index.rq", "https://example.org/sparql", "SELECT * {}"); file != nil {
+		file.Close()
+		t.Fatal("Expected no cached response with the never strategy")
+	}
+
+	cm.Session = NewSession()
+	if err := cm.SetCache("index.rq", "https://example.org/sparql", "SELECT * {}", "response"); err != nil {
+		t.Fatal(err)
+	}
+	file, err := cm.GetCache("index.rq", "https://example.org/sparql", "SELECT * {}")
+	if err != nil || file == nil {
+		t.Fatalf("Expected the response of the session, got %v", err)
+	}
+	content, _ := io.ReadAll(file)
+	file.Close()
+	if string(content) != "response" {
+		t.Errorf("Unexpected response %q", content)
+	}
+
+	cm.Session.Reset()
+	if file, _ := cm.GetCache("index.rq", "https://example.org/sparql", "SELECT * {}"); file != nil {
+		file.Close()
+		t.Error("Expected no cached response after resetting the session")
+	}
+}
@@ -0,0 +1,127 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Result summarises a compression run.
+type Result struct {
+	Files      int
+	BytesSaved int64
+}
+
+type encoder struct {
+	extension string
+	writer    func(io.Writer) io.WriteCloser
+}
+
+var encoders = []encoder{
+	{".gz", func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	}},
+	{".br", func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	}},
+}
+
+// Directory writes .gz and .br siblings for every file in dir with one of the
+// given extensions and at least minSize bytes. Compressed files that aren't
+// smaller than the original are not written.
+func Directory(dir string, extensions []string, minSize int64, jobs int) (Result, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && info.Size() >= minSize && hasExtension(path, extensions) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	var mutex sync.Mutex
+	var result Result
+	var firstErr error
+	queue := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				files, saved, err := compressFile(path)
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				result.Files += files
+				result.BytesSaved += saved
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+	wg.Wait()
+
+	return result, firstErr
+}
+
+// compressFile writes the compressed siblings of a file and returns the number
+// of files written and the bytes they saved.
+func compressFile(path string) (int, int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	files := 0
+	var saved int64
+	for _, enc := range encoders {
+		var buf bytes.Buffer
+		w := enc.writer(&buf)
+		if _, err := w.Write(content); err != nil {
+			return files, saved, err
+		}
+		if err := w.Close(); err != nil {
+			return files, saved, err
+		}
+
+		if buf.Len() >= len(content) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(path+enc.extension, buf.Bytes(), 0666); err != nil {
+			return files, saved, err
+		}
+		files++
+		saved += int64(len(content) - buf.Len())
+	}
+	return files, saved, nil
+}
+
+func hasExtension(path string, extensions []string) bool {
+	for _, extension := range extensions {
+		if strings.EqualFold(filepath.Ext(path), extension) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package compress
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html": strings.Repeat("<p>snowman</p>", 200),
+		"tiny.html":  "<p>hi</p>",
+		"data.json":  strings.Repeat("{}", 1000),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Directory(dir, []string{".html"}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Files != 2 || result.BytesSaved <= 0 {
+		t.Errorf("Expected 2 compressed files saving bytes, got %+v", result)
+	}
+
+	for _, name := range []string{"index.html.gz", "index.html.br"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be written", name)
+		}
+	}
+
+	// tiny.html doesn't shrink and data.json has another extension
+	for _, name := range []string{"tiny.html.gz", "tiny.html.br", "data.json.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("Expected %s not to be written", name)
+		}
+	}
+}
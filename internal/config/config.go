@@ -1,39 +1,735 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/glaciers-in-archives/snowman/internal/basepath"
 	"github.com/glaciers-in-archives/snowman/internal/utils"
 	"gopkg.in/yaml.v2"
 )
 
-var CurrentSiteConfig SiteConfig
+var CurrentSiteConfig = SiteConfig{
+	OutputDir:    "site",
+	StaticDir:    "static",
+	TemplatesDir: "templates",
+}
 
 type ClientConfig struct {
 	Endpoint string            `yaml:"endpoint"`
 	Headers  map[string]string `yaml:"http_headers"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	Token    string            `yaml:"bearer_token"`
+	Timeout  time.Duration     `yaml:"timeout"`
+	Retries  int               `yaml:"retries"`
+	// Method is post, the default, or get. GET requests whose URL would be
+	// longer than MaxGetLength are sent as POST requests instead
+	Method       string `yaml:"method"`
+	MaxGetLength int    `yaml:"max_get_length"`
+	// ResultsFormat is the format SELECT results are requested in, json, the
+	// default, xml, csv or tsv
+	ResultsFormat string `yaml:"results_format"`
+}
+
+// DefaultMaxGetLength is the length of the longest URL sent as a GET request,
+// which most servers and proxies accept.
+const DefaultMaxGetLength = 2000
+
+// resolveCredentials checks that a client uses a single kind of
+// authentication.
+func (c *ClientConfig) resolveCredentials() error {
+	if c.Token != "" && (c.Username != "" || c.Password != "") {
+		return errors.New("A SPARQL client can't use both basic authentication and a bearer token.")
+	}
+	return nil
+}
+
+// StaticCopyConfig controls how the static directory is copied.
+type StaticCopyConfig struct {
+	// FollowSymlinks copies the targets of symlinks instead of the symlinks
+	FollowSymlinks bool `yaml:"follow_symlinks"`
+	// EmptyDirs creates empty directories in the site directory
+	EmptyDirs bool `yaml:"empty_dirs"`
+}
+
+// MarkdownConfig controls the markdown template function.
+type MarkdownConfig struct {
+	// Unsafe passes raw HTML in markdown through to the output
+	Unsafe bool `yaml:"unsafe"`
+	// Linkify turns bare URLs into links
+	Linkify bool `yaml:"linkify"`
+}
+
+// FeedFields maps the fields of feed entries to variables of the feed's query.
+type FeedFields struct {
+	Title   string `yaml:"title"`
+	Link    string `yaml:"link"`
+	Date    string `yaml:"date"`
+	Summary string `yaml:"summary"`
+}
+
+// FeedConfig describes an Atom or RSS feed built from the results of a query.
+type FeedConfig struct {
+	Output string `yaml:"output"`
+	// Format is either atom, the default, or rss
+	Format string `yaml:"format"`
+	Query  string `yaml:"query"`
+	// Endpoint names the SPARQL client to query, like the option of views
+	Endpoint    string     `yaml:"endpoint"`
+	Title       string     `yaml:"title"`
+	Description string     `yaml:"description"`
+	Author      string     `yaml:"author"`
+	Limit       int        `yaml:"limit"`
+	Fields      FeedFields `yaml:"fields"`
+}
+
+func (f *FeedConfig) validate() error {
+	if f.Format == "" {
+		f.Format = "atom"
+	}
+	if f.Format != "atom" && f.Format != "rss" {
+		return errors.New("The format of the feed " + f.Output + " must be either atom or rss.")
+	}
+	if f.Output == "" || f.Query == "" || f.Title == "" {
+		return errors.New("Every feed needs an output, a query and a title.")
+	}
+	if f.Fields.Title == "" || f.Fields.Link == "" || f.Fields.Date == "" {
+		return errors.New("The feed " + f.Output + " must map the title, link and date fields to query variables.")
+	}
+	if f.Limit < 0 {
+		return errors.New("The limit of the feed " + f.Output + " can't be negative.")
+	}
+	return nil
+}
+
+// AssertionConfig describes a query whose results are checked before the
+// views are rendered. ASK queries are expected to answer Ask, true by default,
+// and SELECT queries to return between MinRows, 1 by default, and MaxRows.
+type AssertionConfig struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+	// Endpoint names the SPARQL client to query, like the option of views
+	Endpoint string `yaml:"endpoint"`
+	Ask      *bool  `yaml:"ask"`
+	MinRows  *int   `yaml:"min_rows"`
+	MaxRows  *int   `yaml:"max_rows"`
+}
+
+func (a *AssertionConfig) validate() error {
+	if a.Name == "" || a.Query == "" {
+		return errors.New("Every assertion needs a name and a query.")
+	}
+	if a.Ask != nil && (a.MinRows != nil || a.MaxRows != nil) {
+		return errors.New("The assertion " + a.Name + " can expect either an answer to an ASK query or a number of rows, not both.")
+	}
+	if (a.MinRows != nil && *a.MinRows < 0) || (a.MaxRows != nil && *a.MaxRows < 0) {
+		return errors.New("The number of rows expected by the assertion " + a.Name + " can't be negative.")
+	}
+	if a.MinRows != nil && a.MaxRows != nil && *a.MinRows > *a.MaxRows {
+		return errors.New("The min_rows of the assertion " + a.Name + " is larger than its max_rows.")
+	}
+	return nil
+}
+
+// DefaultInlineMaxSize is the default of InlineMaxSize, 10 KB.
+const DefaultInlineMaxSize = 10 * 1024
+
+// ImagesConfig controls the resized images created by the srcset function.
+type ImagesConfig struct {
+	Widths []int `yaml:"widths"`
+	// Format is original, jpeg or png
+	Format string `yaml:"format"`
+	// Quality is the quality of JPEG images between 1 and 100
+	Quality int `yaml:"quality"`
+}
+
+// SearchConfig controls the search index written from the views mapping their
+// variables to search fields.
+type SearchConfig struct {
+	// Output is the path of the index within the site directory
+	Output string `yaml:"output"`
+}
+
+// NotFoundPage is the page static hosts serve for missing paths.
+const NotFoundPage = "404.html"
+
+// RootFilesConfig selects a directory of templates which are each rendered
+// once, without a query, into the site directory.
+type RootFilesConfig struct {
+	// Dir is relative to the templates directory
+	Dir string `yaml:"dir"`
+	// Output is the directory within the site directory the files are
+	// written to, by default the site directory itself
+	Output string `yaml:"output"`
+}
+
+// MetaRule sets the headers of the files matching its glob pattern.
+type MetaRule struct {
+	Pattern      string `yaml:"pattern"`
+	CacheControl string `yaml:"cache_control"`
+	ContentType  string `yaml:"content_type"`
+}
+
+// MetaConfig controls the headers suggested in the manifest written when
+// building with --meta.
+type MetaConfig struct {
+	// Rules are tried in order and the first matching one applies
+	Rules []MetaRule `yaml:"rules"`
+	// Fingerprinted is the cache control of fingerprinted static files
+	Fingerprinted string `yaml:"fingerprinted"`
+}
+
+// ConnectionsConfig tunes the HTTP connections shared by all SPARQL clients.
+type ConnectionsConfig struct {
+	// MaxIdlePerHost is the number of idle connections kept open to each
+	// endpoint, by default sparql_max_concurrent or 16
+	MaxIdlePerHost int `yaml:"max_idle_per_host"`
+	// IdleTimeout closes idle connections after this long, by default 90s
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// DisableKeepAlives opens a new connection for every query
+	DisableKeepAlives bool `yaml:"disable_keep_alives"`
+}
+
+// ServiceConfig describes a remote endpoint queries call using SERVICE
+// clauses. Snowman never contacts it, the endpoint running the query does.
+type ServiceConfig struct {
+	// Timeout is added to the timeout of the queries calling the service
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Languages is a fallback chain of language tags, most preferred first. It's
+// written as either a single tag or a list of them.
+type Languages []string
+
+func (l *Languages) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tag string
+	if err := unmarshal(&tag); err == nil {
+		*l = Languages{tag}
+		return nil
+	}
+	var tags []string
+	if err := unmarshal(&tags); err != nil {
+		return errors.New("The language must be a language tag or a list of them.")
+	}
+	*l = tags
+	return nil
+}
+
+type SitemapConfig struct {
+	Exclude []string `yaml:"exclude"`
+}
+
+// Redirect sends visitors of the From path of the site to To, which is a path
+// of the site or a URL.
+type Redirect struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+	// Status is the HTTP status of redirects files, 301 by default
+	Status int `yaml:"status"`
+}
+
+// RedirectsConfig lists the redirects of the site and how they're written,
+// either as html pages or as a netlify _redirects file.
+type RedirectsConfig struct {
+	Format string     `yaml:"format"`
+	Rules  []Redirect `yaml:"rules"`
+}
+
+func (r *RedirectsConfig) validate() error {
+	if r.Format == "" {
+		r.Format = "html"
+	}
+	if r.Format != "html" && r.Format != "netlify" {
+		return errors.New("The format of the redirects must be either html or netlify.")
+	}
+
+	sources := map[string]bool{}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if !strings.HasPrefix(rule.From, "/") || rule.To == "" {
+			return errors.New("Every redirect needs a from path starting with / and a to path or URL.")
+		}
+		if sources[rule.From] {
+			return errors.New("There are two redirects from " + rule.From + ".")
+		}
+		sources[rule.From] = true
+
+		// html redirects are written as pages at their from paths
+		if from := strings.Trim(rule.From, "/"); r.Format == "html" && from != "" {
+			for _, section := range strings.Split(from, "/") {
+				if err := utils.ValidatePathSection(section); err != nil {
+					return utils.ErrorExit("The redirect from "+rule.From+" leads outside of the site directory or has an invalid section.", err)
+				}
+			}
+		}
+
+		if rule.Status == 0 {
+			rule.Status = 301
+		}
+		if rule.Status != 301 && rule.Status != 302 && rule.Status != 307 && rule.Status != 308 {
+			return errors.New("The status of the redirect from " + rule.From + " must be 301, 302, 307 or 308.")
+		}
+	}
+	return nil
+}
+
+// CompressionConfig selects the output files that are pre-compressed when
+// building with --compress.
+type CompressionConfig struct {
+	Extensions []string `yaml:"extensions"`
+	// MinSize is the size in bytes of the smallest file compressed, 1024 if
+	// unset. 0 compresses files of any size
+	MinSize *int64 `yaml:"min_size"`
 }
 
 type SiteConfig struct {
-	Client   ClientConfig `yaml:"sparql_client"`
-	Metadata map[string]interface{}
+	Client   ClientConfig            `yaml:"sparql_client"`
+	Clients  map[string]ClientConfig `yaml:"sparql_clients"`
+	CacheTTL time.Duration           `yaml:"cache_ttl"`
+	Prefixes map[string]string       `yaml:"prefixes"`
+	// QueryParams are substituted into {{name}} placeholders in queries.
+	QueryParams map[string]interface{} `yaml:"query_params"`
+	// Variables are given with --var and take precedence over the
+	// QueryParams and the params of views.
+	Variables map[string]interface{} `yaml:"-"`
+	// OutputDir, StaticDir and TemplatesDir are the directories of the built
+	// site, static files and templates relative to the project.
+	OutputDir    string `yaml:"output_dir"`
+	StaticDir    string `yaml:"static_dir"`
+	TemplatesDir string `yaml:"templates_dir"`
+	// IncludeDirs are the directories, relative to the TemplatesDir, of the
+	// templates parsed with every view, by default just includes
+	IncludeDirs []string          `yaml:"include_dirs"`
+	Compression CompressionConfig `yaml:"compression"`
+	Meta        MetaConfig        `yaml:"meta"`
+	// Fingerprint lists glob patterns, relative to the static directory, of
+	// the static files whose names get a content hash.
+	Fingerprint []string `yaml:"fingerprint"`
+	Title       string   `yaml:"title"`
+	// BaseURL is the public URL of the site, required for the sitemap.
+	BaseURL string `yaml:"base_url"`
+	// BasePath is the path the site is served from, like /project, which the
+	// url function prefixes onto internal links.
+	BasePath string `yaml:"base_path"`
+	// RewriteLinks prefixes the root-relative links of rendered pages with
+	// the BasePath
+	RewriteLinks bool            `yaml:"rewrite_links"`
+	Sitemap      SitemapConfig   `yaml:"sitemap"`
+	Search       SearchConfig    `yaml:"search"`
+	RootFiles    RootFilesConfig `yaml:"root_files"`
+	Feeds        []FeedConfig    `yaml:"feeds"`
+	Redirects    RedirectsConfig `yaml:"redirects"`
+	// Assertions are checked before the views are rendered
+	Assertions []AssertionConfig `yaml:"assertions"`
+	Images     ImagesConfig      `yaml:"images"`
+	// InlineMaxSize is the size in bytes of the largest static file the
+	// inline function embeds into pages
+	InlineMaxSize int64            `yaml:"inline_max_size"`
+	Markdown      MarkdownConfig   `yaml:"markdown"`
+	StaticCopy    StaticCopyConfig `yaml:"static_copy"`
+	// CleanURLs writes .html outputs as name/index.html
+	CleanURLs bool `yaml:"clean_urls"`
+	// NotFoundTemplate is rendered without a query to NotFoundPage
+	NotFoundTemplate string `yaml:"not_found_template"`
+	// Language is the fallback chain of languages the lang_pick function
+	// picks literals in, unless views set their own
+	Language Languages `yaml:"language"`
+	// IncludeDrafts renders the views marked as drafts, like --include-drafts
+	IncludeDrafts bool `yaml:"include_drafts"`
+	// PathEncoding is how values are written into the output paths of views,
+	// either raw, slugify or urlencode
+	PathEncoding string `yaml:"path_encoding"`
+	// MaxConcurrent limits the queries awaiting a response from the SPARQL
+	// endpoints at the same time, 0 for no limit
+	MaxConcurrent int               `yaml:"sparql_max_concurrent"`
+	Connections   ConnectionsConfig `yaml:"sparql_connections"`
+	// Services maps the hosts of the endpoints called through SERVICE
+	// clauses to their settings
+	Services map[string]ServiceConfig `yaml:"sparql_services"`
+	// Headers are sent with the queries of every SPARQL client, unless the
+	// http_headers of a client set them too
+	Headers map[string]string `yaml:"sparql_headers"`
+	// PreBuild and PostBuild are shell commands run before static files are
+	// copied and after all views rendered.
+	PreBuild  []string `yaml:"pre_build"`
+	PostBuild []string `yaml:"post_build"`
+	Metadata  map[string]interface{}
+	// Profile is the name of the profile the configuration was loaded with.
+	Profile string `yaml:"-"`
+	// DefaultClient is the name of the client used by views not selecting one.
+	DefaultClient string `yaml:"-"`
+	// BuildTime is the time the configuration was loaded for the build, or the
+	// SourceDate if set.
+	BuildTime time.Time `yaml:"-"`
+	// SourceDate is set by the SOURCE_DATE_EPOCH environment variable and
+	// replaces the timestamps in the output of reproducible builds.
+	SourceDate *time.Time `yaml:"-"`
+	// Since is set by --since and substituted for the since query parameter
+	Since *time.Time `yaml:"-"`
 }
 
-func (c *SiteConfig) Parse(data []byte) error {
+// defaultClientName returns "main" if such a client is defined, otherwise the
+// name of the first client defined in the configuration.
+func defaultClientName(data []byte, clients map[string]ClientConfig) (string, error) {
+	if _, exists := clients["main"]; exists {
+		return "main", nil
+	}
+
+	var ordered struct {
+		Clients yaml.MapSlice `yaml:"sparql_clients"`
+	}
+	if err := yaml.Unmarshal(data, &ordered); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprint(ordered.Clients[0].Key), nil
+}
+
+// LocalFile returns the path of the RDF file a file: endpoint refers to, like
+// file:data.ttl relative to the project or file:///srv/data.ttl.
+func LocalFile(endpoint string) (string, bool) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	if u.Opaque != "" {
+		return filepath.FromSlash(u.Opaque), true
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// ValidateEndpoint returns an error if the given endpoint isn't an HTTP(S) URL
+// with a host that is either localhost, an IP address or a domain name with
+// at least one dot. This catches typos like https://example early. Endpoints
+// can also refer to a local RDF file with the file: scheme.
+func ValidateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return errors.New("No SPARQL endpoint has been configured.")
+	}
+
+	if path, isFile := LocalFile(endpoint); isFile {
+		if path == "" {
+			return errors.New("Invalid SPARQL endpoint " + endpoint + ". The endpoint must name an RDF file.")
+		}
+		return nil
+	}
+
+	u, err := url.ParseRequestURI(endpoint)
+	if err != nil {
+		return errors.New("Invalid SPARQL endpoint " + endpoint + ". Error: " + err.Error())
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("Invalid SPARQL endpoint " + endpoint + ". The endpoint must use http or https.")
+	}
+
+	host := u.Hostname()
+	if host != "localhost" && net.ParseIP(host) == nil && (!strings.Contains(host, ".") || strings.HasSuffix(host, ".")) {
+		return errors.New("Invalid SPARQL endpoint " + endpoint + ". The host must be localhost, an IP address or a full domain name.")
+	}
+
+	return nil
+}
+
+// Overrides holds settings given on the command line or through environment
+// variables which take precedence over the configuration file.
+type Overrides struct {
+	// Endpoint replaces the endpoint of the default client.
+	Endpoint     string
+	OutputDir    string
+	StaticDir    string
+	TemplatesDir string
+	BasePath     string
+	// Vars are name=value pairs given with --var.
+	Vars []string
+	// Profile names the profile merged over the configuration.
+	Profile string
+	// Since is the time given with --since.
+	Since *time.Time
+}
+
+var varNamePattern = regexp.MustCompile(`^[A-Za-z_]\w*$`)
+
+// integerPattern and decimalPattern match numbers in their plain form, leaving
+// out values like 007, +1, 1e3 or inf, which are more likely meant as strings.
+var integerPattern = regexp.MustCompile(`^-?(0|[1-9]\d*)$`)
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9]\d*)\.\d+$`)
+
+// parseVars parses name=value pairs into variables. Values that are plain
+// integers, decimals or booleans are typed as such, others are strings.
+func parseVars(pairs []string) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(pairs))
+	for _, pair := range pairs {
+		name, value, found := strings.Cut(pair, "=")
+		if !found || !varNamePattern.MatchString(name) {
+			return nil, errors.New("The variable " + pair + " must be given as name=value, with a name of letters, digits and underscores.")
+		}
+		if integer, err := strconv.Atoi(value); err == nil && integerPattern.MatchString(value) {
+			variables[name] = integer
+		} else if decimal, err := strconv.ParseFloat(value, 64); err == nil && decimalPattern.MatchString(value) {
+			variables[name] = decimal
+		} else if value == "true" || value == "false" {
+			variables[name] = value == "true"
+		} else {
+			variables[name] = value
+		}
+	}
+	return variables, nil
+}
+
+// choose returns the first non-empty value.
+func choose(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (c *SiteConfig) Parse(data []byte, overrides Overrides) error {
+	data, err := applyProfile(data, overrides.Profile)
+	if err != nil {
+		return err
+	}
+	c.Profile = overrides.Profile
+
+	// environment variables are expanded after merging the profile, so that
+	// the other profiles can reference ones that aren't set
+	if data, err = expandDocument(data); err != nil {
+		return err
+	}
+
 	if err := yaml.Unmarshal(data, c); err != nil {
 		return err
 	}
 
-	_, err := url.ParseRequestURI(c.Client.Endpoint) // #TODO why is https://example valid?
+	if len(c.Clients) == 0 {
+		// the single client form is treated as a client named "main"
+		c.Clients = map[string]ClientConfig{"main": c.Client}
+	} else if c.Client.Endpoint != "" {
+		return errors.New("Use either sparql_client or sparql_clients, not both.")
+	}
+
+	defaultClient, err := defaultClientName(data, c.Clients)
 	if err != nil {
 		return err
 	}
+	c.DefaultClient = defaultClient
+
+	c.OutputDir = filepath.Clean(choose(overrides.OutputDir, c.OutputDir, "site"))
+	c.StaticDir = filepath.Clean(choose(overrides.StaticDir, c.StaticDir, "static"))
+	c.TemplatesDir = filepath.Clean(choose(overrides.TemplatesDir, c.TemplatesDir, "templates"))
+
+	variables, err := parseVars(overrides.Vars)
+	if err != nil {
+		return err
+	}
+	c.Variables = variables
+
+	if c.IncludeDirs == nil {
+		c.IncludeDirs = []string{"includes"}
+	}
+	for i, dir := range c.IncludeDirs {
+		c.IncludeDirs[i] = filepath.ToSlash(filepath.Clean(dir))
+		if strings.HasPrefix(c.IncludeDirs[i], "..") || filepath.IsAbs(dir) {
+			return errors.New("The include_dirs must be directories within the templates directory.")
+		}
+	}
+
+	c.BasePath = basepath.Normalize(choose(overrides.BasePath, c.BasePath))
+	if c.BasePath != "" && c.BaseURL != "" {
+		baseURL, err := url.Parse(c.BaseURL)
+		if err != nil {
+			return errors.New("The base_url is malformed. Error: " + err.Error())
+		}
+		switch path := basepath.Normalize(baseURL.Path); {
+		case path == "":
+			// the sitemap and feeds link to pages relative to the base_url
+			c.BaseURL = strings.TrimSuffix(c.BaseURL, "/") + c.BasePath
+		case !strings.HasSuffix(path, c.BasePath):
+			return errors.New("The path of the base_url must end with the base_path " + c.BasePath + ".")
+		}
+	}
+
+	if c.RootFiles.Dir != "" {
+		c.RootFiles.Dir = filepath.ToSlash(filepath.Clean(c.RootFiles.Dir))
+		c.RootFiles.Output = filepath.ToSlash(filepath.Clean(c.RootFiles.Output))
+		if strings.HasPrefix(c.RootFiles.Output, "..") || filepath.IsAbs(c.RootFiles.Output) {
+			return errors.New("The output of root_files must be a directory within the site directory.")
+		}
+	}
+
+	if c.Search.Output == "" {
+		c.Search.Output = "search.json"
+	}
+	c.Search.Output = filepath.ToSlash(filepath.Clean(c.Search.Output))
+	if strings.HasPrefix(c.Search.Output, "..") || filepath.IsAbs(c.Search.Output) {
+		return errors.New("The output of the search index must be within the site directory.")
+	}
+
+	if c.Compression.Extensions == nil {
+		c.Compression.Extensions = []string{".html", ".css", ".js"}
+	}
+	if c.Compression.MinSize == nil {
+		minSize := int64(1024)
+		c.Compression.MinSize = &minSize
+	} else if *c.Compression.MinSize < 0 {
+		return errors.New("The min_size of the compression can't be negative.")
+	}
+
+	if c.Meta.Rules == nil {
+		c.Meta.Rules = []MetaRule{
+			{Pattern: "*.html", CacheControl: "public, max-age=300"},
+			{Pattern: "*", CacheControl: "public, max-age=3600"},
+		}
+	}
+	if c.Meta.Fingerprinted == "" {
+		c.Meta.Fingerprinted = "public, max-age=31536000, immutable"
+	}
+	for _, rule := range c.Meta.Rules {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return errors.New("The meta rule pattern " + rule.Pattern + " is malformed.")
+		}
+	}
+
+	if c.Images.Widths == nil {
+		c.Images.Widths = []int{400, 800, 1200}
+	}
+	if c.Images.Format == "" {
+		c.Images.Format = "original"
+	}
+	if c.Images.Format != "original" && c.Images.Format != "jpeg" && c.Images.Format != "png" {
+		return errors.New("The image format must be original, jpeg or png.")
+	}
+	if c.Images.Quality == 0 {
+		c.Images.Quality = 85
+	}
+
+	if c.InlineMaxSize < 0 {
+		return errors.New("The inline_max_size can't be negative.")
+	}
+	if c.InlineMaxSize == 0 {
+		c.InlineMaxSize = DefaultInlineMaxSize
+	}
+
+	if c.MaxConcurrent < 0 || c.Connections.MaxIdlePerHost < 0 || c.Connections.IdleTimeout < 0 {
+		return errors.New("sparql_max_concurrent and sparql_connections can't be negative.")
+	}
+
+	for host, service := range c.Services {
+		if host == "" || strings.ContainsAny(host, "/?#") {
+			return errors.New("The sparql_services must be given by their host, like query.wikidata.org, not " + host + ".")
+		}
+		if service.Timeout < 0 {
+			return errors.New("The timeout of the SPARQL service " + host + " can't be negative.")
+		}
+	}
+
+	if len(c.Feeds) > 0 && c.BaseURL == "" {
+		return errors.New("Feeds require a base_url.")
+	}
+	for i := range c.Feeds {
+		if err := c.Feeds[i].validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Redirects.validate(); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for i := range c.Assertions {
+		if err := c.Assertions[i].validate(); err != nil {
+			return err
+		}
+		if names[c.Assertions[i].Name] {
+			return errors.New("There are two assertions named " + c.Assertions[i].Name + ".")
+		}
+		names[c.Assertions[i].Name] = true
+	}
+
+	if overrides.Endpoint != "" {
+		client := c.Clients[defaultClient]
+		client.Endpoint = overrides.Endpoint
+		c.Clients[defaultClient] = client
+	}
+
+	for name, client := range c.Clients {
+		if err := ValidateEndpoint(client.Endpoint); err != nil {
+			return err
+		}
+
+		if err := client.resolveCredentials(); err != nil {
+			return err
+		}
+
+		if len(c.Headers) > 0 {
+			headers := make(map[string]string, len(c.Headers)+len(client.Headers))
+			for header, content := range c.Headers {
+				headers[header] = content
+			}
+			for header, content := range client.Headers {
+				headers[header] = content
+			}
+			client.Headers = headers
+		}
+
+		if client.Method == "" {
+			client.Method = "post"
+		}
+		if client.Method != "get" && client.Method != "post" {
+			return errors.New("The method of a SPARQL client must be either get or post.")
+		}
+		if client.MaxGetLength < 0 {
+			return errors.New("The max_get_length of a SPARQL client can't be negative.")
+		}
+		if client.MaxGetLength == 0 {
+			client.MaxGetLength = DefaultMaxGetLength
+		}
+		if client.ResultsFormat == "" {
+			client.ResultsFormat = "json"
+		}
+		if client.ResultsFormat != "json" && client.ResultsFormat != "xml" && client.ResultsFormat != "csv" && client.ResultsFormat != "tsv" {
+			return errors.New("The results_format of a SPARQL client must be json, xml, csv or tsv.")
+		}
+		if _, isFile := LocalFile(client.Endpoint); isFile && client.ResultsFormat != "json" {
+			return errors.New("SPARQL clients querying a local RDF file can only use the json results_format.")
+		}
+		c.Clients[name] = client
+	}
+	c.Client = c.Clients[defaultClient]
+
 	return nil
 }
 
-func LoadConfig(fileLocation string) error {
+// sourceDateEpoch returns the time set in seconds since the Unix epoch by the
+// SOURCE_DATE_EPOCH environment variable, or nil if it isn't set.
+func sourceDateEpoch() (*time.Time, error) {
+	value := os.Getenv("SOURCE_DATE_EPOCH")
+	if value == "" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, errors.New("SOURCE_DATE_EPOCH must be a number of seconds since the Unix epoch, got " + value + ".")
+	}
+	sourceDate := time.Unix(seconds, 0).UTC()
+	return &sourceDate, nil
+}
+
+func LoadConfig(fileLocation string, overrides Overrides) error {
 	if _, err := os.Stat(fileLocation); err != nil {
 		if fileLocation == "snowman.yaml" {
 			return utils.ErrorExit("Unable to locate snowman.yaml in the current working directory.", err)
@@ -47,9 +743,21 @@ func LoadConfig(fileLocation string) error {
 		return utils.ErrorExit("Failed to read "+fileLocation+".", err)
 	}
 
-	if err := CurrentSiteConfig.Parse(data); err != nil {
+	var siteConfig SiteConfig
+	if err := siteConfig.Parse(data, overrides); err != nil {
 		return utils.ErrorExit("Failed to parse "+fileLocation+".", err)
 	}
+	sourceDate, err := sourceDateEpoch()
+	if err != nil {
+		return err
+	}
+	siteConfig.SourceDate = sourceDate
+	siteConfig.Since = overrides.Since
+	siteConfig.BuildTime = time.Now()
+	if sourceDate != nil {
+		siteConfig.BuildTime = *sourceDate
+	}
+	CurrentSiteConfig = siteConfig
 
 	return nil
 }
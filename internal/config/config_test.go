@@ -0,0 +1,300 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var validateEndpointTests = []struct {
+	endpoint        string
+	expectedIsValid bool
+}{
+	{"https://query.wikidata.org/sparql", true},
+	{"http://localhost:7200/repositories/test", true},
+	{"http://127.0.0.1:8890/sparql", true},
+	{"http://[::1]:3030/ds/sparql", true},
+	{"https://example.org", true},
+
+	// the endpoint can't be empty
+	{"", false},
+
+	// the host must be a full domain name
+	{"https://example", false},
+	{"https://example./sparql", false},
+	{"https:///sparql", false},
+
+	// only http and https are supported
+	{"ftp://foo", false},
+	{"ftp://example.org/sparql", false},
+
+	// the endpoint must be an absolute URL
+	{"example.org/sparql", false},
+
+	// local RDF files
+	{"file:data.ttl", true},
+	{"file:///srv/data/graph.nt", true},
+	{"file:", false},
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	for _, test := range validateEndpointTests {
+		if test.expectedIsValid {
+			if err := ValidateEndpoint(test.endpoint); err != nil {
+				t.Errorf("Expected endpoint \"%s\" to be valid, but got error: %v", test.endpoint, err)
+			}
+		} else {
+			if err := ValidateEndpoint(test.endpoint); err == nil {
+				t.Errorf("Expected endpoint \"%s\" to be invalid, but got nil", test.endpoint)
+			}
+		}
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("SNOWMAN_TEST_ENDPOINT", "https://example.org/sparql")
+	t.Setenv("SNOWMAN_TEST_EMPTY", "")
+
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"${SNOWMAN_TEST_ENDPOINT}", "https://example.org/sparql"},
+		{"$SNOWMAN_TEST_MISSING", "$SNOWMAN_TEST_MISSING"},
+		{"pa$$word$", "pa$$word$"},
+		{"${SNOWMAN_TEST_EMPTY}", ""},
+		{"${SNOWMAN_TEST_MISSING:-30s}", "30s"},
+		{"${SNOWMAN_TEST_MISSING:-}", ""},
+		{"${SNOWMAN_TEST_ENDPOINT:-https://example.com}", "https://example.org/sparql"},
+		{"$${SNOWMAN_TEST_MISSING}", "${SNOWMAN_TEST_MISSING}"},
+		{"Bearer ${SNOWMAN_TEST_MISSING:-token}", "Bearer token"},
+	}
+
+	for _, test := range tests {
+		result, err := expandEnv(test.input)
+		if err != nil {
+			t.Errorf("Expected %q to expand, but got error: %v", test.input, err)
+		} else if result != test.expected {
+			t.Errorf("Expected %q to expand to %q, got %q", test.input, test.expected, result)
+		}
+	}
+
+	for _, input := range []string{"${SNOWMAN_TEST_MISSING}", "a ${SNOWMAN_TEST_MISSING} b"} {
+		if _, err := expandEnv(input); err == nil {
+			t.Errorf("Expected the missing variable in %q to be an error", input)
+		}
+	}
+}
+
+func TestParseExpandsEnv(t *testing.T) {
+	t.Setenv("SNOWMAN_TEST_ENDPOINT", "https://example.org/sparql")
+	// values can't add to the configuration
+	t.Setenv("SNOWMAN_TEST_TOKEN", "secret\"\n  username: \"admin")
+
+	var c SiteConfig
+	data := []byte("# endpoint: \"${SNOWMAN_TEST_MISSING}\"\nsparql_client:\n  endpoint: \"${SNOWMAN_TEST_ENDPOINT}\"\n  bearer_token: \"${SNOWMAN_TEST_TOKEN}\"\n  http_headers:\n    X-Stage: \"${SNOWMAN_TEST_STAGE:-dev}\"\n")
+	if err := c.Parse(data, Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := c.Clients[c.DefaultClient]
+	if client.Endpoint != "https://example.org/sparql" || client.Token != "secret\"\n  username: \"admin" || client.Username != "" || client.Headers["X-Stage"] != "dev" {
+		t.Errorf("Expected the endpoint, token and header to be expanded, got %+v", client)
+	}
+
+	t.Setenv("SNOWMAN_TEST_DRAFTS", "true")
+	t.Setenv("SNOWMAN_TEST_PASSWORD", "007")
+	data = []byte("base_url: \"${SNOWMAN_TEST_BASE_URL:-https://example.org}\"\ninclude_drafts: ${SNOWMAN_TEST_DRAFTS:-false}\nsparql_client:\n  endpoint: https://example.org/sparql?key=$KEY\n  username: user\n  password: ${SNOWMAN_TEST_PASSWORD}\n  timeout: ${SNOWMAN_TEST_TIMEOUT:-30s}\n  retries: ${SNOWMAN_TEST_RETRIES:-}\n")
+	c = SiteConfig{}
+	if err := c.Parse(data, Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+	client = c.Clients[c.DefaultClient]
+	if c.BaseURL != "https://example.org" || !c.IncludeDrafts || client.Endpoint != "https://example.org/sparql?key=$KEY" || client.Password != "007" || client.Timeout != 30*time.Second || client.Retries != 0 {
+		t.Errorf("Expected every value to be expanded, got %+v and %+v", c, client)
+	}
+
+	data = []byte("sparql_client:\n  endpoint: https://example.org/sparql\n  password: \"${SNOWMAN_TEST_MISSING}\"\n")
+	if err := c.Parse(data, Overrides{}); err == nil {
+		t.Error("Expected a missing variable in the credentials to be an error")
+	}
+}
+
+func TestParseBasePath(t *testing.T) {
+	tests := []struct {
+		yaml     string
+		override string
+		basePath string
+		baseURL  string
+	}{
+		{"base_path: project/\n", "", "/project", ""},
+		{"base_path: /project\nbase_url: https://example.org\n", "", "/project", "https://example.org/project"},
+		{"base_path: /project\nbase_url: https://example.org/project/\n", "", "/project", "https://example.org/project/"},
+		{"base_path: /project\n", "/other/", "/other", ""},
+	}
+	for _, test := range tests {
+		var c SiteConfig
+		data := []byte("sparql_client:\n  endpoint: https://example.org/sparql\n" + test.yaml)
+		if err := c.Parse(data, Overrides{BasePath: test.override}); err != nil {
+			t.Fatal(err)
+		}
+		if c.BasePath != test.basePath || c.BaseURL != test.baseURL {
+			t.Errorf("Expected %q and %q for %q, got %q and %q", test.basePath, test.baseURL, test.yaml, c.BasePath, c.BaseURL)
+		}
+	}
+
+	var c SiteConfig
+	data := []byte("sparql_client:\n  endpoint: https://example.org/sparql\nbase_path: /project\nbase_url: https://example.org/other/\n")
+	if err := c.Parse(data, Overrides{}); err == nil {
+		t.Error("Expected an error for a base_url not ending with the base_path")
+	}
+}
+
+func TestParseMethod(t *testing.T) {
+	var c SiteConfig
+	if err := c.Parse([]byte("sparql_client:\n  endpoint: https://example.org/sparql\n"), Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Client.Method != "post" || c.Client.MaxGetLength != DefaultMaxGetLength {
+		t.Errorf("Unexpected defaults %q and %d", c.Client.Method, c.Client.MaxGetLength)
+	}
+
+	if err := c.Parse([]byte("sparql_client:\n  endpoint: https://example.org/sparql\n  method: put\n"), Overrides{}); err == nil {
+		t.Error("Expected an error for an unknown method")
+	}
+}
+
+func TestRedirectsValidate(t *testing.T) {
+	var tests = []struct {
+		format string
+		from   string
+		ok     bool
+	}{
+		{"html", "/old/", true},
+		{"html", "/", true},
+		{"html", "/../escaped.html", false},
+		{"html", "/old/../page", false},
+		{"netlify", "/news/:year/*", true},
+	}
+	for _, test := range tests {
+		r := RedirectsConfig{Format: test.format, Rules: []Redirect{{From: test.from, To: "/new/"}}}
+		if err := r.validate(); (err == nil) != test.ok {
+			t.Errorf("Unexpected result for the %s redirect from %s: %v", test.format, test.from, err)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	t.Setenv("SNOWMAN_TEST_KEY", "secret")
+	data := []byte(`sparql_headers:
+  X-Api-Key: "${SNOWMAN_TEST_KEY}"
+  X-Tenant: shared
+sparql_clients:
+  main:
+    endpoint: https://example.org/sparql
+    http_headers:
+      X-Tenant: main
+  other:
+    endpoint: https://example.com/sparql
+`)
+	var c SiteConfig
+	if err := c.Parse(data, Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+	if headers := fmt.Sprint(c.Clients["main"].Headers); headers != "map[X-Api-Key:secret X-Tenant:main]" {
+		t.Errorf("Unexpected headers of main %s", headers)
+	}
+	if headers := fmt.Sprint(c.Clients["other"].Headers); headers != "map[X-Api-Key:secret X-Tenant:shared]" {
+		t.Errorf("Unexpected headers of other %s", headers)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	variables, err := parseVars([]string{"year=2024", "ratio=0.5", "draft=true", "class=<http://schema.org/Book>", "title=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "map[class:<http://schema.org/Book> draft:true empty: ratio:0.5 title:a=b year:2024]"
+	if actual := fmt.Sprint(variables); actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+	if _, ok := variables["year"].(int); !ok {
+		t.Errorf("Expected the year to be an integer, got %T", variables["year"])
+	}
+
+	for _, value := range []string{"007", "+1", "1e3", "1.", ".5", "nan", "NaN", "inf", "-Infinity", "0x1F", "1_000", "99999999999999999999"} {
+		variables, err := parseVars([]string{"code=" + value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if variables["code"] != value {
+			t.Errorf("Expected %s to be kept as a string, got %T", value, variables["code"])
+		}
+	}
+
+	for _, pair := range []string{"year", "=2024", "my-year=2024"} {
+		if _, err := parseVars([]string{pair}); err == nil {
+			t.Errorf("Expected an error for %q", pair)
+		}
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	data := []byte(`sparql_client:
+  endpoint: https://example.org/sparql
+  timeout: 30s
+base_url: https://dev.example.org
+profiles:
+  prod:
+    sparql_client:
+      endpoint: https://data.example.org/sparql
+      bearer_token: secret
+    base_url: https://example.org
+    include_drafts: false
+  dev:
+    include_drafts: true
+`)
+
+	var c SiteConfig
+	if err := c.Parse(data, Overrides{Profile: "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	client := c.Clients[c.DefaultClient]
+	if client.Endpoint != "https://data.example.org/sparql" || client.Token != "secret" || client.Timeout.Seconds() != 30 || c.BaseURL != "https://example.org" || c.Profile != "prod" {
+		t.Errorf("Expected the prod profile to be merged over the configuration, got %+v and %s", client, c.BaseURL)
+	}
+
+	c = SiteConfig{}
+	if err := c.Parse(data, Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Clients[c.DefaultClient].Endpoint != "https://example.org/sparql" || c.IncludeDrafts {
+		t.Errorf("Expected no profile to be applied, got %+v", c)
+	}
+
+	c = SiteConfig{}
+	err := c.Parse(data, Overrides{Profile: "staging"})
+	if err == nil || err.Error() != "Unknown profile staging. The available profiles are dev, prod." {
+		t.Errorf("Expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestCompressionMinSize(t *testing.T) {
+	var tests = []struct {
+		data     string
+		expected int64
+	}{
+		{"sparql_client:\n  endpoint: https://example.org/sparql\n", 1024},
+		{"sparql_client:\n  endpoint: https://example.org/sparql\ncompression:\n  min_size: 0\n", 0},
+		{"sparql_client:\n  endpoint: https://example.org/sparql\ncompression:\n  min_size: 512\n", 512},
+	}
+	for _, test := range tests {
+		var c SiteConfig
+		if err := c.Parse([]byte(test.data), Overrides{}); err != nil {
+			t.Fatal(err)
+		}
+		if *c.Compression.MinSize != test.expected {
+			t.Errorf("Expected a min_size of %d, got %d", test.expected, *c.Compression.MinSize)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// envReference matches a reference to an environment variable, ${VAR} or
+// ${VAR:-default}, and the same reference escaped with another $.
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_]\w*)(:-[^}]*)?\}`)
+
+// expandEnv replaces references to environment variables in a value of the
+// configuration with their values. References are ${VAR} and ${VAR:-default},
+// and $${VAR} is a literal ${VAR}. Other uses of $ are kept as they are.
+func expandEnv(value string) (string, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(value, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+
+		match := envReference.FindStringSubmatch(reference)
+		if value, exists := os.LookupEnv(match[1]); exists {
+			return value
+		}
+		if match[2] == "" {
+			missing = append(missing, match[1])
+		}
+		return strings.TrimPrefix(match[2], ":-")
+	})
+
+	if len(missing) > 0 {
+		return "", errors.New("The environment variable " + strings.Join(missing, ", ") + " referenced in the configuration is not set. Set it or use ${" + missing[0] + ":-default}.")
+	}
+	return expanded, nil
+}
+
+// expandDocument expands the environment variables in every value of the
+// configuration. The values are expanded after the document is parsed, so
+// that the contents of a variable can't change its structure, and references
+// in comments are ignored.
+func expandDocument(data []byte) ([]byte, error) {
+	if !envReference.Match(data) {
+		return data, nil
+	}
+
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	expanded, err := expandValue(document)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(expanded)
+}
+
+// expandValue expands the environment variables in the strings of a parsed
+// value.
+func expandValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		for i := range value {
+			expanded, err := expandValue(value[i].Value)
+			if err != nil {
+				return nil, err
+			}
+			value[i].Value = expanded
+		}
+	case []interface{}:
+		for i := range value {
+			expanded, err := expandValue(value[i])
+			if err != nil {
+				return nil, err
+			}
+			value[i] = expanded
+		}
+	case string:
+		if !envReference.MatchString(value) {
+			return value, nil
+		}
+		expanded, err := expandEnv(value)
+		if err != nil {
+			return nil, err
+		}
+		return scalar(expanded), nil
+	}
+	return value, nil
+}
+
+// scalar returns an expanded value as a boolean or number when it's written
+// as one, like false for include_drafts, and as nothing when it's empty, like
+// an unset timeout. Other values, including numbers written in other forms
+// like 007, stay strings.
+func scalar(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+
+	var typed interface{}
+	if err := yaml.Unmarshal([]byte(value), &typed); err != nil {
+		return value
+	}
+	switch typed.(type) {
+	case bool, int, float64:
+		if written, err := yaml.Marshal(typed); err == nil && strings.TrimSpace(string(written)) == value {
+			return typed
+		}
+	}
+	return value
+}
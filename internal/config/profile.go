@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// applyProfile merges the settings of the named profile under profiles over
+// the rest of the configuration. Maps are merged key by key, while all other
+// values, including lists, are replaced. The profiles themselves are removed.
+func applyProfile(data []byte, profile string) ([]byte, error) {
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	var profiles yaml.MapSlice
+	var base yaml.MapSlice
+	for _, item := range document {
+		if fmt.Sprint(item.Key) == "profiles" {
+			switch value := item.Value.(type) {
+			case yaml.MapSlice:
+				profiles = value
+			case nil:
+			default:
+				return nil, errors.New("The profiles must map names to settings.")
+			}
+			continue
+		}
+		base = append(base, item)
+	}
+
+	if profile == "" {
+		if profiles == nil {
+			return data, nil
+		}
+		return yaml.Marshal(base)
+	}
+
+	var names []string
+	for _, item := range profiles {
+		name := fmt.Sprint(item.Key)
+		if name != profile {
+			names = append(names, name)
+			continue
+		}
+		settings, ok := item.Value.(yaml.MapSlice)
+		if !ok && item.Value != nil {
+			return nil, errors.New("The profile " + profile + " must map settings to their values.")
+		}
+		return yaml.Marshal(merge(base, settings))
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("Unknown profile " + profile + ". No profiles are defined.")
+	}
+	sort.Strings(names)
+	return nil, errors.New("Unknown profile " + profile + ". The available profiles are " + strings.Join(names, ", ") + ".")
+}
+
+// merge returns the base with the values of the override, merging maps
+// recursively.
+func merge(base yaml.MapSlice, override yaml.MapSlice) yaml.MapSlice {
+	merged := append(yaml.MapSlice{}, base...)
+	for _, item := range override {
+		found := false
+		for i, existing := range merged {
+			if fmt.Sprint(existing.Key) != fmt.Sprint(item.Key) {
+				continue
+			}
+			found = true
+			baseMap, baseIsMap := existing.Value.(yaml.MapSlice)
+			overrideMap, overrideIsMap := item.Value.(yaml.MapSlice)
+			if baseIsMap && overrideIsMap {
+				merged[i].Value = merge(baseMap, overrideMap)
+			} else {
+				merged[i].Value = item.Value
+			}
+			break
+		}
+		if !found {
+			merged = append(merged, item)
+		}
+	}
+	return merged
+}
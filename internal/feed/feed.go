@@ -0,0 +1,199 @@
+package feed
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+// Entry is an item of a feed.
+type Entry struct {
+	Title   string
+	Link    string
+	Date    time.Time
+	Summary string
+}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New("Unable to parse the date " + value + ". Use xsd:dateTime or xsd:date values.")
+}
+
+func value(row map[string]rdf.Term, variable string, field string) (string, error) {
+	term, ok := row[variable]
+	if !ok || term == nil {
+		return "", errors.New("The variable " + variable + " used as the " + field + " of feed entries is not bound in a result row.")
+	}
+	return term.String(), nil
+}
+
+// Entries maps the results of the feed's query to entries, newest first.
+// Relative links are resolved against baseURL.
+func Entries(feedConfig config.FeedConfig, baseURL string, results []map[string]rdf.Term) ([]Entry, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(results))
+	for _, row := range results {
+		var entry Entry
+		if entry.Title, err = value(row, feedConfig.Fields.Title, "title"); err != nil {
+			return nil, err
+		}
+
+		link, err := value(row, feedConfig.Fields.Link, "link")
+		if err != nil {
+			return nil, err
+		}
+		linkURL, err := url.Parse(link)
+		if err != nil {
+			return nil, err
+		}
+		entry.Link = base.ResolveReference(linkURL).String()
+
+		date, err := value(row, feedConfig.Fields.Date, "date")
+		if err != nil {
+			return nil, err
+		}
+		if entry.Date, err = parseDate(date); err != nil {
+			return nil, err
+		}
+
+		if feedConfig.Fields.Summary != "" {
+			if term, ok := row[feedConfig.Fields.Summary]; ok && term != nil {
+				entry.Summary = term.String()
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Date.After(entries[j].Date)
+	})
+	if feedConfig.Limit > 0 && len(entries) > feedConfig.Limit {
+		entries = entries[:feedConfig.Limit]
+	}
+	return entries, nil
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	Link    atomLink `xml:"link"`
+	ID      string   `xml:"id"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary,omitempty"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// Marshal returns the feed with the given entries in the configured format.
+// The feed links to baseURL and to itself at its output path.
+func Marshal(feedConfig config.FeedConfig, baseURL string, entries []Entry) ([]byte, error) {
+	siteURL := strings.TrimSuffix(baseURL, "/") + "/"
+	feedURL := siteURL + strings.TrimPrefix(feedConfig.Output, "/")
+
+	var feed interface{}
+	if feedConfig.Format == "rss" {
+		description := feedConfig.Description
+		if description == "" {
+			description = feedConfig.Title
+		}
+
+		channel := rssChannel{Title: feedConfig.Title, Link: siteURL, Description: description}
+		for _, entry := range entries {
+			channel.Items = append(channel.Items, rssItem{
+				Title:       entry.Title,
+				Link:        entry.Link,
+				GUID:        entry.Link,
+				PubDate:     entry.Date.Format(time.RFC1123Z),
+				Description: entry.Summary,
+			})
+		}
+		feed = rssFeed{Version: "2.0", Channel: channel}
+	} else {
+		// the feed was updated when its newest entry was
+		updated := time.Unix(0, 0).UTC()
+		if len(entries) > 0 {
+			updated = entries[0].Date
+		}
+
+		atom := atomFeed{
+			Xmlns:   "http://www.w3.org/2005/Atom",
+			Title:   feedConfig.Title,
+			ID:      feedURL,
+			Links:   []atomLink{{Href: feedURL, Rel: "self"}, {Href: siteURL}},
+			Updated: updated.Format(time.RFC3339),
+		}
+		if feedConfig.Author != "" {
+			atom.Author = &atomAuthor{Name: feedConfig.Author}
+		}
+		for _, entry := range entries {
+			atom.Entries = append(atom.Entries, atomEntry{
+				Title:   entry.Title,
+				Link:    atomLink{Href: entry.Link},
+				ID:      entry.Link,
+				Updated: entry.Date.Format(time.RFC3339),
+				Summary: entry.Summary,
+			})
+		}
+		feed = atom
+	}
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
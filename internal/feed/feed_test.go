@@ -0,0 +1,68 @@
+package feed
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+func literal(value string) rdf.Term {
+	term, _ := rdf.NewLiteral(value)
+	return term
+}
+
+func TestEntries(t *testing.T) {
+	feedConfig := config.FeedConfig{
+		Format: "atom",
+		Limit:  2,
+		Fields: config.FeedFields{Title: "title", Link: "link", Date: "date"},
+	}
+	results := []map[string]rdf.Term{
+		{"title": literal("Old"), "link": literal("posts/old.html"), "date": literal("2020-01-01")},
+		{"title": literal("New"), "link": literal("https://example.org/new"), "date": literal("2022-03-04T05:06:07Z")},
+		{"title": literal("Middle"), "link": literal("/posts/middle.html"), "date": literal("2021-06-01")},
+	}
+
+	entries, err := Entries(feedConfig, "https://example.org/blog/", results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Title != "New" || entries[1].Title != "Middle" {
+		t.Fatalf("Expected the two newest entries, got %+v", entries)
+	}
+	if entries[1].Link != "https://example.org/posts/middle.html" {
+		t.Errorf("Expected the link to be resolved against the base URL, got %s", entries[1].Link)
+	}
+
+	delete(results[0], "date")
+	if _, err := Entries(feedConfig, "https://example.org/", results); err == nil {
+		t.Error("Expected an unbound date to be an error")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	entries, err := Entries(config.FeedConfig{Fields: config.FeedFields{Title: "title", Link: "link", Date: "date"}}, "https://example.org/", []map[string]rdf.Term{
+		{"title": literal("Post"), "link": literal("post.html"), "date": literal("2022-03-04")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for format, expected := range map[string][]string{
+		"atom": {`<feed xmlns="http://www.w3.org/2005/Atom">`, `<link href="https://example.org/atom.xml" rel="self"></link>`, `<updated>2022-03-04T00:00:00Z</updated>`},
+		"rss":  {`<rss version="2.0">`, `<link>https://example.org/post.html</link>`, `<pubDate>Fri, 04 Mar 2022 00:00:00 +0000</pubDate>`},
+	} {
+		data, err := Marshal(config.FeedConfig{Output: "atom.xml", Format: format, Title: "Blog"}, "https://example.org", entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, part := range expected {
+			if !strings.Contains(string(data), part) {
+				t.Errorf("Expected the %s feed to contain %s, got %s", format, part, data)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package hooks
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+// command returns a command running the given line through the shell of the
+// platform.
+func command(line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", line)
+	}
+	return exec.Command("sh", "-c", line)
+}
+
+// Run runs the given commands in order from the current directory. Commands
+// share stdin and stderr with Snowman and print to the output of the logger,
+// and the first command that fails stops the rest from running.
+func Run(commands []string) error {
+	for _, line := range commands {
+		logger.Info("Running " + line)
+
+		cmd := command(line)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = logger.Output()
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return utils.ErrorExit("The command \""+line+"\" failed.", err)
+		}
+	}
+	return nil
+}
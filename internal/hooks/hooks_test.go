@@ -0,0 +1,26 @@
+//go:build !windows
+
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first")
+	last := filepath.Join(dir, "last")
+
+	if err := Run([]string{"touch " + first, "exit 3", "touch " + last}); err == nil {
+		t.Error("Expected a failing command to be an error")
+	}
+
+	if _, err := os.Stat(first); err != nil {
+		t.Error("Expected the commands before the failing one to run")
+	}
+	if _, err := os.Stat(last); err == nil {
+		t.Error("Expected the commands after the failing one not to run")
+	}
+}
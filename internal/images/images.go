@@ -0,0 +1,183 @@
+package images
+
+import (
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/static"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// extensions holds the image formats that can be decoded.
+var extensions = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+	".gif":  "gif",
+	".webp": "webp",
+}
+
+// candidate is an image in a srcset.
+type candidate struct {
+	path  string
+	width int
+}
+
+type srcset struct {
+	once       sync.Once
+	candidates []candidate
+	err        error
+}
+
+var mutex sync.Mutex
+var srcsets = map[string]*srcset{}
+
+// Reset forgets the resized images, which have to be written again by every
+// build as the site directory might have been cleared.
+func Reset() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	srcsets = map[string]*srcset{}
+}
+
+// Srcset writes resized copies of the static image at the given path, which
+// is relative to the static directory, to the site directory and returns a
+// srcset listing them together with the original image. Images are never
+// enlarged. A leading "/" of the path is kept in the srcset.
+func Srcset(imagePath string) (string, error) {
+	relativePath := strings.TrimPrefix(imagePath, "/")
+	sourcePath := filepath.Join(config.CurrentSiteConfig.StaticDir, filepath.FromSlash(relativePath))
+
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	// changed images get another key so that they are resized again, even by
+	// builds which aren't reset
+	key := sourcePath + " " + info.ModTime().String()
+	mutex.Lock()
+	entry, exists := srcsets[key]
+	if !exists {
+		entry = &srcset{}
+		srcsets[key] = entry
+	}
+	mutex.Unlock()
+
+	entry.once.Do(func() {
+		entry.candidates, entry.err = resize(sourcePath, relativePath)
+	})
+	if entry.err != nil {
+		return "", entry.err
+	}
+
+	prefix := ""
+	if strings.HasPrefix(imagePath, "/") {
+		prefix = "/"
+	}
+	parts := make([]string, len(entry.candidates))
+	for i, c := range entry.candidates {
+		parts[i] = prefix + c.path + " " + strconv.Itoa(c.width) + "w"
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func resize(sourcePath string, relativePath string) ([]candidate, error) {
+	sourceFormat, ok := extensions[strings.ToLower(path.Ext(relativePath))]
+	if !ok {
+		return nil, errors.New("The file " + relativePath + " is not a JPEG, PNG, GIF or WebP image.")
+	}
+
+	file, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	source, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	format := config.CurrentSiteConfig.Images.Format
+	if format == "original" {
+		format = sourceFormat
+		// WebP images can only be decoded
+		if format == "webp" {
+			return nil, errors.New("The WebP image " + relativePath + " can't be resized to WebP. Set the format of the images to jpeg or png.")
+		}
+	}
+
+	widths := append([]int{}, config.CurrentSiteConfig.Images.Widths...)
+	sort.Ints(widths)
+
+	bounds := source.Bounds()
+	var candidates []candidate
+	for _, width := range widths {
+		if width <= 0 || width >= bounds.Dx() {
+			continue
+		}
+
+		variantPath := variantPath(relativePath, width, format)
+		height := bounds.Dy() * width / bounds.Dx()
+		if height < 1 {
+			height = 1
+		}
+
+		resized := image.NewRGBA(image.Rect(0, 0, width, height))
+		draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Over, nil)
+		if err := write(resized, filepath.Join(config.CurrentSiteConfig.OutputDir, filepath.FromSlash(variantPath)), format); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, candidate{variantPath, width})
+	}
+
+	original := relativePath
+	if hashed, ok := static.Assets[relativePath]; ok {
+		original = hashed
+	}
+	return append(candidates, candidate{original, bounds.Dx()}), nil
+}
+
+// variantPath returns the path of the image resized to the given width, like
+// img/photo-400w.jpg.
+func variantPath(relativePath string, width int, format string) string {
+	extension := path.Ext(relativePath)
+	if extensions[strings.ToLower(extension)] != format {
+		extension = map[string]string{"jpeg": ".jpg", "png": ".png", "gif": ".gif"}[format]
+	}
+	return strings.TrimSuffix(relativePath, path.Ext(relativePath)) + "-" + strconv.Itoa(width) + "w" + extension
+}
+
+func write(img image.Image, outputPath string, format string) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0770); err != nil {
+		return err
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch format {
+	case "jpeg":
+		return jpeg.Encode(file, img, &jpeg.Options{Quality: config.CurrentSiteConfig.Images.Quality})
+	case "gif":
+		return gif.Encode(file, img, nil)
+	default:
+		return png.Encode(file, img)
+	}
+}
@@ -0,0 +1,75 @@
+package images
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+func TestSrcset(t *testing.T) {
+	dir := t.TempDir()
+	previous := config.CurrentSiteConfig
+	defer func() { config.CurrentSiteConfig = previous }()
+	config.CurrentSiteConfig.StaticDir = filepath.Join(dir, "static")
+	config.CurrentSiteConfig.OutputDir = filepath.Join(dir, "site")
+	config.CurrentSiteConfig.Images = config.ImagesConfig{Widths: []int{800, 400, 2000}, Format: "jpeg", Quality: 80}
+
+	if err := os.MkdirAll(filepath.Join(dir, "static", "img"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.Join(dir, "static", "img", "photo.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewRGBA(image.Rect(0, 0, 1000, 500))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	srcset, err := Srcset("/img/photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2000 is wider than the original and skipped
+	expected := "/img/photo-400w.jpg 400w, /img/photo-800w.jpg 800w, /img/photo.png 1000w"
+	if srcset != expected {
+		t.Errorf("Expected %s, got %s", expected, srcset)
+	}
+
+	variant, err := os.Open(filepath.Join(dir, "site", "img", "photo-400w.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer variant.Close()
+	resized, format, err := image.Decode(variant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || resized.Bounds().Dx() != 400 || resized.Bounds().Dy() != 200 {
+		t.Errorf("Expected a 400 by 200 JPEG, got a %d by %d %s", resized.Bounds().Dx(), resized.Bounds().Dy(), format)
+	}
+
+	// a cleared site directory gets the resized images again after a reset
+	if err := os.RemoveAll(filepath.Join(dir, "site")); err != nil {
+		t.Fatal(err)
+	}
+	Reset()
+	if _, err := Srcset("/img/photo.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site", "img", "photo-400w.jpg")); err != nil {
+		t.Errorf("Expected the resized image to be written again, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "static", "notes.txt"), []byte("notes"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Srcset("notes.txt"); err == nil {
+		t.Error("Expected an error for a file that isn't an image")
+	}
+}
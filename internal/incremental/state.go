@@ -0,0 +1,124 @@
+package incremental
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var StateLocation string = ".snowman/build_state.json"
+
+// ViewState is what a previous build recorded about a view.
+type ViewState struct {
+	Inputs string   `json:"inputs"`
+	Pages  []string `json:"pages"`
+	// Bindings are the values of the output variables of each page of
+	// multipage views
+	Bindings map[string]map[string]string `json:"bindings,omitempty"`
+	// Search are the documents of the pages in the search index
+	Search []map[string]string `json:"search,omitempty"`
+}
+
+// State holds the inputs and outputs of each view from the last build so that
+// views with unchanged inputs can be skipped.
+type State struct {
+	mutex    sync.Mutex
+	previous map[string]ViewState
+	current  map[string]ViewState
+}
+
+// LoadState reads the state of the last build. A missing or unreadable state
+// results in an empty state, which makes every view render.
+func LoadState() *State {
+	state := State{previous: map[string]ViewState{}, current: map[string]ViewState{}}
+
+	data, err := ioutil.ReadFile(StateLocation)
+	if err == nil {
+		json.Unmarshal(data, &state.previous)
+	}
+	return &state
+}
+
+// Previous returns what the last build recorded for the given view.
+func (s *State) Previous(view string) (ViewState, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	viewState, exists := s.previous[view]
+	return viewState, exists
+}
+
+// Record stores the inputs and pages of a view for the next build.
+func (s *State) Record(view string, viewState ViewState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.current[view] = viewState
+}
+
+// StalePages returns the pages written by the last build that the current
+// build didn't write.
+func (s *State) StalePages() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := map[string]bool{}
+	for _, viewState := range s.current {
+		for _, page := range viewState.Pages {
+			current[page] = true
+		}
+	}
+
+	var stale []string
+	for _, viewState := range s.previous {
+		for _, page := range viewState.Pages {
+			if !current[page] {
+				stale = append(stale, page)
+				current[page] = true
+			}
+		}
+	}
+	return stale
+}
+
+// Save writes the recorded state, replacing that of the last build.
+func (s *State) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.Marshal(s.current)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(StateLocation), 0770); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(StateLocation, data, 0666)
+}
+
+// Hash returns a hex encoded hash of the given parts.
+func Hash(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		// the length prefix keeps ("ab", "c") and ("a", "bc") apart
+		hash.Write([]byte(strconv.Itoa(len(part)) + ":" + part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// HashFiles returns a hash of the paths and contents of the given files.
+func HashFiles(paths []string) (string, error) {
+	parts := make([]string, 0, len(paths)*2)
+	for _, path := range paths {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, path, string(content))
+	}
+	return Hash(parts...), nil
+}
@@ -0,0 +1,61 @@
+// Package jsonld serializes RDF graphs as JSON-LD shaped by a frame.
+package jsonld
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/knakk/rdf"
+	"github.com/piprate/json-gold/ld"
+)
+
+// loader fetches the remote contexts of frames once per run.
+var loader = ld.NewCachingDocumentLoader(ld.NewDefaultDocumentLoader(nil))
+
+// options returns the options of the JSON-LD processor. The defaults of
+// framing follow JSON-LD 1.1, and the matches of a frame are always written
+// in @graph so that the shape of the document doesn't depend on their number.
+func options() *ld.JsonLdOptions {
+	opts := ld.NewJsonLdOptions("")
+	opts.DocumentLoader = loader
+	opts.RequireAll = false
+	opts.OmitGraph = false
+	return opts
+}
+
+// ParseFrame parses a JSON-LD frame, which must be a JSON object with a valid
+// context.
+func ParseFrame(data []byte) (map[string]interface{}, error) {
+	var frame map[string]interface{}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, errors.New("The JSON-LD frame must be a JSON object. Error: " + err.Error())
+	}
+	if _, err := ld.NewContext(nil, options()).Parse(frame["@context"]); err != nil {
+		return nil, errors.New("The context of the JSON-LD frame is invalid. Error: " + err.Error())
+	}
+	return frame, nil
+}
+
+// Frame returns the framed and compacted JSON-LD document of the triples,
+// following the JSON-LD 1.1 framing algorithm.
+func Frame(triples []rdf.Triple, frame map[string]interface{}) (map[string]interface{}, error) {
+	var nquads strings.Builder
+	for _, triple := range triples {
+		nquads.WriteString(triple.Serialize(rdf.NTriples))
+	}
+
+	processor := ld.NewJsonLdProcessor()
+	opts := options()
+	opts.Format = "application/n-quads"
+	expanded, err := processor.FromRDF(nquads.String(), opts)
+	if err != nil {
+		return nil, errors.New("Failed to read the graph as JSON-LD. Error: " + err.Error())
+	}
+
+	document, err := processor.Frame(expanded, frame, opts)
+	if err != nil {
+		return nil, errors.New("Failed to frame the graph. Error: " + err.Error())
+	}
+	return document, nil
+}
@@ -0,0 +1,119 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/knakk/rdf"
+)
+
+const testGraph = `@prefix ex: <http://example.org/> .
+@prefix schema: <http://schema.org/> .
+@prefix xsd: <http://www.w3.org/2001/XMLSchema#> .
+
+ex:alice a schema:Person ;
+	schema:name "Alice" ;
+	schema:description "Eine Person"@de ;
+	schema:birthDate "1990-01-01"^^xsd:date ;
+	schema:knows ex:bob ;
+	schema:address _:home .
+
+_:home schema:addressLocality "Stockholm" .
+
+ex:bob a schema:Person ;
+	schema:name "Bob" ;
+	schema:knows ex:alice ;
+	schema:url <http://bob.example.org/> .
+
+ex:book a schema:Book ;
+	schema:name "A book" .
+`
+
+func decodeTriples(t *testing.T, turtle string) []rdf.Triple {
+	var triples []rdf.Triple
+	decoder := rdf.NewTripleDecoder(strings.NewReader(turtle), rdf.Turtle)
+	for {
+		triple, err := decoder.Decode()
+		if err == io.EOF {
+			return triples
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		triples = append(triples, triple)
+	}
+}
+
+func frameJSON(t *testing.T, frame string) string {
+	parsed, err := ParseFrame([]byte(frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := Frame(decodeTriples(t, testGraph), parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFrame(t *testing.T) {
+	var tests = []struct {
+		name     string
+		frame    string
+		expected string
+	}{
+		{
+			"embeds nodes in each top-level node and refers to ancestors",
+			`{"@context": {"@vocab": "http://schema.org/", "ex": "http://example.org/"}, "@type": "Person"}`,
+			`{"@context":{"@vocab":"http://schema.org/","ex":"http://example.org/"},"@graph":[` +
+				`{"@id":"ex:alice","@type":"Person","address":{"@id":"_:b0","addressLocality":"Stockholm"},"birthDate":{"@type":"http://www.w3.org/2001/XMLSchema#date","@value":"1990-01-01"},"description":{"@language":"de","@value":"Eine Person"},"knows":{"@id":"ex:bob","@type":"Person","knows":{"@id":"ex:alice"},"name":"Bob","url":{"@id":"http://bob.example.org/"}},"name":"Alice"},` +
+				`{"@id":"ex:bob","@type":"Person","knows":{"@id":"ex:alice","@type":"Person","address":{"@id":"_:b0","addressLocality":"Stockholm"},"birthDate":{"@type":"http://www.w3.org/2001/XMLSchema#date","@value":"1990-01-01"},"description":{"@language":"de","@value":"Eine Person"},"knows":{"@id":"ex:bob"},"name":"Alice"},"name":"Bob","url":{"@id":"http://bob.example.org/"}}]}`,
+		},
+		{
+			"coerces values and applies explicit",
+			`{"@context": {"schema": "http://schema.org/", "name": "schema:name", "knows": {"@id": "schema:knows", "@type": "@id"}}, "@type": "schema:Person", "@explicit": true, "name": {}, "knows": {"@embed": "@never"}}`,
+			`{"@context":{"knows":{"@id":"schema:knows","@type":"@id"},"name":"schema:name","schema":"http://schema.org/"},"@graph":[` +
+				`{"@id":"http://example.org/alice","@type":"schema:Person","knows":"http://example.org/bob","name":"Alice"},` +
+				`{"@id":"http://example.org/bob","@type":"schema:Person","knows":"http://example.org/alice","name":"Bob"}]}`,
+		},
+		{
+			"refers to top-level nodes which aren't embedded",
+			`{"@context": {"@vocab": "http://schema.org/"}, "@type": "Person", "@embed": "@never"}`,
+			`{"@context":{"@vocab":"http://schema.org/"},"@graph":[{"@id":"http://example.org/alice"},{"@id":"http://example.org/bob"}]}`,
+		},
+		{
+			"writes a single match in @graph",
+			`{"@context": {"@vocab": "http://schema.org/"}, "@type": "Book", "author": {"@default": "unknown"}}`,
+			`{"@context":{"@vocab":"http://schema.org/"},"@graph":[{"@id":"http://example.org/book","@type":"Book","author":"unknown","name":"A book"}]}`,
+		},
+		{
+			"matches nodes by their properties",
+			`{"@context": {"@vocab": "http://schema.org/"}, "addressLocality": {}}`,
+			`{"@context":{"@vocab":"http://schema.org/"},"@graph":[{"addressLocality":"Stockholm"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		if actual := frameJSON(t, test.frame); actual != test.expected {
+			t.Errorf("Expected the frame that %s to give\n%s\ngot\n%s", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestParseFrame(t *testing.T) {
+	for _, frame := range []string{
+		`[]`,
+		`{"@context": 3}`,
+		`{"@context": {"name": 3}}`,
+	} {
+		if _, err := ParseFrame([]byte(frame)); err == nil {
+			t.Errorf("Expected the frame %s to be invalid", frame)
+		}
+	}
+}
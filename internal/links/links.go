@@ -0,0 +1,231 @@
+// Package links finds broken links in the HTML pages of a built site.
+package links
+
+import (
+	"bytes"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdewolff/parse/v2"
+	html_lexer "github.com/tdewolff/parse/v2/html"
+)
+
+// externalWorkers is the number of external links checked at the same time.
+const externalWorkers = 8
+
+// Broken is a link from a page of the site which doesn't resolve.
+type Broken struct {
+	// Page is the path of the linking page relative to the site directory
+	Page   string
+	Target string
+	Reason string
+}
+
+// link is a link found in a page.
+type link struct {
+	page   string
+	target string
+}
+
+// Check returns the broken links of the HTML pages in the site directory,
+// sorted by page and target. Internal links, including absolute ones starting
+// with the base URL, must point to a file in the site directory, or to a
+// directory with an index.html. Root-relative links of sites served from a
+// base path must start with it. External links are only requested when
+// external is set.
+func Check(siteDir string, baseURL string, basePath string, external bool) ([]Broken, error) {
+	var found []link
+	err := filepath.Walk(siteDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		extension := strings.ToLower(filepath.Ext(file))
+		if extension != ".html" && extension != ".htm" {
+			return nil
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		page, err := filepath.Rel(siteDir, file)
+		if err != nil {
+			return err
+		}
+		for _, target := range targets(data) {
+			found = append(found, link{page: filepath.ToSlash(page), target: target})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var broken []Broken
+	externals := map[string][]string{}
+	for _, l := range found {
+		target := l.target
+		if basePath != "" && strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+			if target != basePath && !strings.HasPrefix(target, basePath+"/") {
+				broken = append(broken, Broken{Page: l.page, Target: l.target, Reason: "outside the base path " + basePath})
+				continue
+			}
+			target = "/" + strings.TrimPrefix(strings.TrimPrefix(target, basePath), "/")
+		}
+
+		target, internal := resolve(l.page, target, baseURL)
+		if !internal {
+			if target != "" {
+				externals[target] = append(externals[target], l.page)
+			}
+			continue
+		}
+		if !exists(siteDir, target) {
+			broken = append(broken, Broken{Page: l.page, Target: l.target, Reason: "not found in the site"})
+		}
+	}
+
+	if external {
+		broken = append(broken, checkExternal(externals)...)
+	}
+
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].Page != broken[j].Page {
+			return broken[i].Page < broken[j].Page
+		}
+		return broken[i].Target < broken[j].Target
+	})
+	return broken, nil
+}
+
+// targets returns the values of the href, src and srcset attributes of a page.
+func targets(data []byte) []string {
+	var targets []string
+	lexer := html_lexer.NewLexer(parse.NewInputBytes(data))
+	for {
+		tokenType, _ := lexer.Next()
+		if tokenType == html_lexer.ErrorToken {
+			return targets
+		}
+		if tokenType != html_lexer.AttributeToken {
+			continue
+		}
+
+		key := strings.ToLower(string(lexer.AttrKey()))
+		value := html.UnescapeString(string(bytes.Trim(lexer.AttrVal(), `"'`)))
+		switch key {
+		case "href", "src":
+			targets = append(targets, strings.TrimSpace(value))
+		case "srcset":
+			for _, candidate := range strings.Split(value, ",") {
+				if fields := strings.Fields(candidate); len(fields) > 0 {
+					targets = append(targets, fields[0])
+				}
+			}
+		}
+	}
+}
+
+// resolve returns the path within the site directory an internal link points
+// to, or the URL of an external link. Links to fragments of the same page and
+// with other schemes, like mailto:, resolve to neither.
+func resolve(page string, target string, baseURL string) (string, bool) {
+	if baseURL != "" && strings.HasPrefix(target, strings.TrimSuffix(baseURL, "/")+"/") {
+		target = strings.TrimPrefix(target, strings.TrimSuffix(baseURL, "/"))
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return target, true
+	}
+	if parsed.Scheme == "http" || parsed.Scheme == "https" || (parsed.Scheme == "" && parsed.Host != "") {
+		if parsed.Scheme == "" {
+			parsed.Scheme = "https"
+		}
+		parsed.Fragment = ""
+		return parsed.String(), false
+	}
+	if parsed.Scheme != "" || parsed.Path == "" {
+		return "", false
+	}
+
+	if strings.HasPrefix(parsed.Path, "/") {
+		return path.Clean(parsed.Path), true
+	}
+	return path.Join("/", path.Dir(page), parsed.Path), true
+}
+
+// exists reports whether the path is a file in the site directory, or a
+// directory with an index.html.
+func exists(siteDir string, target string) bool {
+	file := filepath.Join(siteDir, filepath.FromSlash(target))
+	info, err := os.Stat(file)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		_, err := os.Stat(filepath.Join(file, "index.html"))
+		return err == nil
+	}
+	return true
+}
+
+// checkExternal requests each external URL once and returns a broken link for
+// every page linking to one that fails.
+func checkExternal(externals map[string][]string) []Broken {
+	client := &http.Client{Timeout: 10 * time.Second}
+	urls := make(chan string)
+	var mutex sync.Mutex
+	var broken []Broken
+
+	var workers sync.WaitGroup
+	for i := 0; i < externalWorkers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for target := range urls {
+				if reason := request(client, target); reason != "" {
+					mutex.Lock()
+					for _, page := range externals[target] {
+						broken = append(broken, Broken{Page: page, Target: target, Reason: reason})
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for target := range externals {
+		urls <- target
+	}
+	close(urls)
+	workers.Wait()
+	return broken
+}
+
+// request returns why the URL can't be retrieved, or an empty string if it
+// can. Servers not allowing HEAD requests are asked with GET.
+func request(client *http.Client, target string) string {
+	resp, err := client.Head(target)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = client.Get(target)
+	}
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "responded with HTTP " + strconv.Itoa(resp.StatusCode)
+	}
+	return ""
+}
@@ -0,0 +1,72 @@
+package links
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ok" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html": `<a href="about/">About</a> <a href="/missing.html#top">Missing</a> <a href="#top">Top</a>
+<a href="mailto:info@example.org">Mail</a> <img src="img/logo.png" srcset="img/logo.png 1x, img/logo@2x.png 2x">
+<a href="https://example.org/works/1.html?page=2">Work</a> <a href="https://example.org/works/2.html">Work</a>
+<a href="` + server.URL + `/ok">OK</a> <a href="` + server.URL + `/gone">Gone</a>`,
+		"about/index.html": `<a href="../index.html">Home</a> <a href="team.html">Team</a> <a href="&#x2F;works">Works</a>`,
+		"img/logo.png":     "",
+		"works/1.html":     "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broken, err := Check(dir, "https://example.org/", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[{about/index.html /works not found in the site} {about/index.html team.html not found in the site} " +
+		"{index.html /missing.html#top not found in the site} {index.html " + server.URL + "/gone responded with HTTP 404} " +
+		"{index.html https://example.org/works/2.html not found in the site} {index.html img/logo@2x.png not found in the site}]"
+	if actual := fmt.Sprint(broken); actual != expected {
+		t.Errorf("Expected the broken links\n%s\ngot\n%s", expected, actual)
+	}
+}
+
+func TestCheckBasePath(t *testing.T) {
+	dir := t.TempDir()
+	content := `<a href="/project/">Home</a> <a href="/project/works/1.html">Work</a> <a href="/works/1.html">Work</a> <a href="https://example.org/project/works/1.html">Work</a>`
+	if err := os.MkdirAll(filepath.Join(dir, "works"), 0770); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"index.html": content, "works/1.html": ""} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	broken, err := Check(dir, "https://example.org/project", "/project", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "[{index.html /works/1.html outside the base path /project}]"
+	if actual := fmt.Sprint(broken); actual != expected {
+		t.Errorf("Expected the broken links\n%s\ngot\n%s", expected, actual)
+	}
+}
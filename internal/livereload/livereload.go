@@ -0,0 +1,117 @@
+package livereload
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EventsPath is the path at which browsers subscribe to reload events.
+const EventsPath = "/_snowman/livereload"
+
+var script = []byte(`<script>new EventSource("` + EventsPath + `").onmessage = function() { location.reload(); };</script>`)
+
+// Broker keeps track of connected browsers and notifies them when the site has
+// been rebuilt using server-sent events.
+type Broker struct {
+	mutex   sync.Mutex
+	clients map[chan bool]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{clients: make(map[chan bool]bool)}
+}
+
+// Reload tells all connected browsers to reload the current page.
+func (b *Broker) Reload() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for client := range b.clients {
+		select {
+		case client <- true:
+		default:
+		}
+	}
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	client := make(chan bool, 1)
+	b.mutex.Lock()
+	b.clients[client] = true
+	b.mutex.Unlock()
+
+	defer func() {
+		b.mutex.Lock()
+		delete(b.clients, client)
+		b.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Inject wraps a handler serving the given directory and injects the live
+// reload script into every HTML page it serves.
+func Inject(h http.Handler, dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filePath := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if info, err := os.Stat(filePath); err == nil && info.IsDir() {
+			// let the file server redirect directories to their canonical path
+			if !strings.HasSuffix(r.URL.Path, "/") {
+				h.ServeHTTP(w, r)
+				return
+			}
+			filePath = filepath.Join(filePath, "index.html")
+		}
+
+		if !strings.HasSuffix(filePath, ".html") {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write(injectScript(content))
+	})
+}
+
+func injectScript(content []byte) []byte {
+	i := bytes.LastIndex(bytes.ToLower(content), []byte("</body>"))
+	if i == -1 {
+		return append(content, script...)
+	}
+
+	injected := make([]byte, 0, len(content)+len(script))
+	injected = append(injected, content[:i]...)
+	injected = append(injected, script...)
+	return append(injected, content[i:]...)
+}
@@ -0,0 +1,505 @@
+package localstore
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// evaluateGroup extends each of the input solutions with the matches of the
+// group. FILTERs apply to the whole group, wherever they appear in it.
+func (s *Store) evaluateGroup(g *group, input []solution) []solution {
+	results := input
+	var filters []expression
+
+	for _, el := range g.elements {
+		var next []solution
+		switch el := el.(type) {
+		case triplesElement:
+			for _, patterns := range el.patterns {
+				next = nil
+				for _, sol := range results {
+					next = append(next, s.match(patterns, sol)...)
+				}
+				results = next
+			}
+			continue
+		case *group:
+			next = s.evaluateGroup(el, results)
+		case optionalElement:
+			for _, sol := range results {
+				extended := s.evaluateGroup(el.group, []solution{sol})
+				if len(extended) == 0 {
+					next = append(next, sol)
+				}
+				next = append(next, extended...)
+			}
+		case unionElement:
+			for _, sol := range results {
+				for _, branch := range el.groups {
+					next = append(next, s.evaluateGroup(branch, []solution{sol})...)
+				}
+			}
+		case filterElement:
+			filters = append(filters, el.expression)
+			continue
+		case bindElement:
+			for _, sol := range results {
+				extended := sol
+				if value, err := el.expression.evaluate(sol, nil); err == nil {
+					extended = sol.with(el.variable, value)
+				}
+				next = append(next, extended)
+			}
+		case valuesElement:
+			for _, sol := range results {
+				for _, row := range el.rows {
+					if merged, ok := sol.merge(el.variables, row); ok {
+						next = append(next, merged)
+					}
+				}
+			}
+		}
+		results = next
+	}
+
+	if len(filters) == 0 {
+		return results
+	}
+	var filtered []solution
+	for _, sol := range results {
+		if passes(filters, sol, nil) {
+			filtered = append(filtered, sol)
+		}
+	}
+	return filtered
+}
+
+// passes reports whether the effective boolean value of each of the
+// expressions is true.
+func passes(expressions []expression, sol solution, group []solution) bool {
+	for _, expr := range expressions {
+		term, err := expr.evaluate(sol, group)
+		if err != nil {
+			return false
+		}
+		if value, err := effectiveBoolean(term); err != nil || !value {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns the extensions of the solution that match the triple pattern.
+func (s *Store) match(pattern triplePattern, sol solution) []solution {
+	subject, subjectBound := sol.resolve(pattern.subject)
+	predicate, predicateBound := sol.resolve(pattern.predicate)
+	object, objectBound := sol.resolve(pattern.object)
+
+	// start from the smallest index that applies
+	var candidates []int
+	all := true
+	for _, index := range []struct {
+		bound bool
+		terms map[string][]int
+		term  rdf.Term
+	}{
+		{subjectBound, s.bySubject, subject},
+		{objectBound, s.byObject, object},
+		{predicateBound, s.byPredicate, predicate},
+	} {
+		if !index.bound {
+			continue
+		}
+		indexed := index.terms[termKey(index.term)]
+		if all || len(indexed) < len(candidates) {
+			candidates, all = indexed, false
+		}
+	}
+
+	if all {
+		candidates = s.all
+	}
+
+	var matches []solution
+	for _, i := range candidates {
+		triple := s.triples[i]
+
+		extended := sol
+		ok := true
+		for _, position := range []struct {
+			node  node
+			term  rdf.Term
+			bound bool
+			value rdf.Term
+		}{
+			{pattern.subject, subject, subjectBound, triple.Subj},
+			{pattern.predicate, predicate, predicateBound, triple.Pred},
+			{pattern.object, object, objectBound, triple.Obj},
+		} {
+			if position.bound {
+				if termKey(position.term) != termKey(position.value) {
+					ok = false
+					break
+				}
+				continue
+			}
+			// a variable used twice in a pattern must match the same term
+			if previous, exists := extended[position.node.variable]; exists && previous != nil {
+				if termKey(previous) != termKey(position.value) {
+					ok = false
+					break
+				}
+				continue
+			}
+			extended = extended.with(position.node.variable, position.value)
+		}
+		if ok {
+			matches = append(matches, extended)
+		}
+	}
+	return matches
+}
+
+// resolve returns the term of a pattern node, and whether it's known.
+func (sol solution) resolve(n node) (rdf.Term, bool) {
+	if n.term != nil {
+		return n.term, true
+	}
+	term, exists := sol[n.variable]
+	return term, exists && term != nil
+}
+
+// with returns a copy of the solution with the variable bound to the term.
+func (sol solution) with(variable string, term rdf.Term) solution {
+	extended := make(solution, len(sol)+1)
+	for key, value := range sol {
+		extended[key] = value
+	}
+	extended[variable] = term
+	return extended
+}
+
+// merge returns the solution extended with the given bindings, unless they
+// conflict with it. Nil terms are undefined and bind nothing.
+func (sol solution) merge(variables []string, terms []rdf.Term) (solution, bool) {
+	merged := sol
+	for i, variable := range variables {
+		if terms[i] == nil {
+			continue
+		}
+		if previous, exists := merged[variable]; exists && previous != nil {
+			if termKey(previous) != termKey(terms[i]) {
+				return nil, false
+			}
+			continue
+		}
+		merged = merged.with(variable, terms[i])
+	}
+	return merged, true
+}
+
+// solutionKey returns a key which is equal for solutions with equal bindings.
+func solutionKey(sol solution) string {
+	keys := make([]string, 0, len(sol))
+	for variable, term := range sol {
+		if term != nil {
+			keys = append(keys, variable+"="+termKey(term))
+		}
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n")
+}
+
+// row is a solution of a query along with the group it was aggregated from.
+type row struct {
+	solution solution
+	group    []solution
+}
+
+// evaluateSelect returns the projected variables and the rows of a SELECT
+// query.
+func (s *Store) evaluateSelect(q *query) ([]string, []solution) {
+	solutions := s.evaluateGroup(q.where, []solution{{}})
+
+	var rows []row
+	if q.groupBy != nil || q.having != nil || projectsAggregate(q.projected) {
+		rows = aggregate(q, solutions)
+	} else {
+		rows = make([]row, len(solutions))
+		for i, sol := range solutions {
+			for _, p := range q.projected {
+				if p.expression == nil {
+					continue
+				}
+				if value, err := p.expression.evaluate(sol, nil); err == nil {
+					sol = sol.with(p.variable, value)
+				}
+			}
+			rows[i] = row{solution: sol}
+		}
+	}
+
+	sortRows(rows, q.orderBy)
+
+	variables := make([]string, 0, len(q.projected))
+	for _, p := range q.projected {
+		variables = append(variables, p.variable)
+	}
+	if q.selectAll {
+		variables = patternVariables(q.where, nil, map[string]bool{})
+	}
+
+	seen := map[string]bool{}
+	var results []solution
+	for _, r := range rows {
+		projected := solution{}
+		for _, variable := range variables {
+			if term, bound := r.solution[variable]; bound && term != nil {
+				projected[variable] = term
+			}
+		}
+		if q.distinct {
+			key := solutionKey(projected)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		results = append(results, projected)
+	}
+	return variables, slice(results, q.offset, q.limit)
+}
+
+// aggregate groups the solutions and computes the projections of each group.
+func aggregate(q *query, solutions []solution) []row {
+	var keys []string
+	groups := map[string][]solution{}
+	for _, sol := range solutions {
+		var parts []string
+		for _, expr := range q.groupBy {
+			value, err := expr.evaluate(sol, nil)
+			if err != nil {
+				parts = append(parts, "")
+				continue
+			}
+			parts = append(parts, termKey(value))
+		}
+		key := strings.Join(parts, "\n")
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], sol)
+	}
+	// without GROUP BY, aggregates apply to all solutions, even if there are none
+	if q.groupBy == nil && len(keys) == 0 {
+		keys = []string{""}
+		groups[""] = nil
+	}
+
+	var rows []row
+	for _, key := range keys {
+		members := groups[key]
+		sol := solution{}
+		for _, expr := range q.groupBy {
+			if variable, ok := expr.(variableExpression); ok && len(members) > 0 {
+				if term, bound := members[0][variable.name]; bound {
+					sol[variable.name] = term
+				}
+			}
+		}
+		for _, p := range q.projected {
+			if p.expression == nil {
+				continue
+			}
+			if value, err := p.expression.evaluate(sol, members); err == nil {
+				sol = sol.with(p.variable, value)
+			}
+		}
+		if passes(q.having, sol, members) {
+			rows = append(rows, row{solution: sol, group: members})
+		}
+	}
+	return rows
+}
+
+func projectsAggregate(projected []projection) bool {
+	for _, p := range projected {
+		if p.expression != nil && hasAggregate(p.expression) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAggregate(expr expression) bool {
+	switch expr := expr.(type) {
+	case aggregateExpression:
+		return true
+	case unaryExpression:
+		return hasAggregate(expr.operand)
+	case binaryExpression:
+		return hasAggregate(expr.left) || hasAggregate(expr.right)
+	case callExpression:
+		for _, argument := range expr.arguments {
+			if hasAggregate(argument) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortRows(rows []row, conditions []orderCondition) {
+	if len(conditions) == 0 {
+		return
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, condition := range conditions {
+			a, _ := condition.expression.evaluate(rows[i].solution, rows[i].group)
+			b, _ := condition.expression.evaluate(rows[j].solution, rows[j].group)
+			order := orderTerms(a, b)
+			if condition.descending {
+				order = -order
+			}
+			if order != 0 {
+				return order < 0
+			}
+		}
+		return false
+	})
+}
+
+func slice(solutions []solution, offset int, limit int) []solution {
+	if offset >= len(solutions) {
+		return nil
+	}
+	solutions = solutions[offset:]
+	if limit >= 0 && limit < len(solutions) {
+		solutions = solutions[:limit]
+	}
+	return solutions
+}
+
+// patternVariables returns the variables of a group in order of appearance,
+// which is what SELECT * projects. Blank nodes aren't included.
+func patternVariables(g *group, variables []string, seen map[string]bool) []string {
+	add := func(variable string) {
+		if variable != "" && !strings.HasPrefix(variable, "_:") && !seen[variable] {
+			seen[variable] = true
+			variables = append(variables, variable)
+		}
+	}
+
+	for _, el := range g.elements {
+		switch el := el.(type) {
+		case triplesElement:
+			for _, pattern := range el.patterns {
+				add(pattern.subject.variable)
+				add(pattern.predicate.variable)
+				add(pattern.object.variable)
+			}
+		case *group:
+			variables = patternVariables(el, variables, seen)
+		case optionalElement:
+			variables = patternVariables(el.group, variables, seen)
+		case unionElement:
+			for _, branch := range el.groups {
+				variables = patternVariables(branch, variables, seen)
+			}
+		case bindElement:
+			add(el.variable)
+		case valuesElement:
+			for _, variable := range el.variables {
+				add(variable)
+			}
+		}
+	}
+	return variables
+}
+
+// evaluateGraph returns the triples built by a CONSTRUCT or DESCRIBE query.
+func (s *Store) evaluateGraph(q *query) []rdf.Triple {
+	var solutions []solution
+	if q.where != nil {
+		solutions = s.evaluateGroup(q.where, []solution{{}})
+		solutions = slice(solutions, q.offset, q.limit)
+	}
+
+	seen := map[string]bool{}
+	var triples []rdf.Triple
+	add := func(triple rdf.Triple) {
+		key := triple.Serialize(rdf.NTriples)
+		if !seen[key] {
+			seen[key] = true
+			triples = append(triples, triple)
+		}
+	}
+
+	if q.form == "CONSTRUCT" {
+		for i, sol := range solutions {
+			for _, pattern := range q.template {
+				if triple, ok := instantiate(pattern, sol, i); ok {
+					add(triple)
+				}
+			}
+		}
+		return triples
+	}
+
+	// DESCRIBE returns the triples about each of the resources
+	var resources []rdf.Term
+	for _, n := range q.describe {
+		if n.term != nil {
+			resources = append(resources, n.term)
+			continue
+		}
+		for _, sol := range solutions {
+			if term, bound := sol[n.variable]; bound && term != nil {
+				resources = append(resources, term)
+			}
+		}
+	}
+	if q.selectAll {
+		for _, sol := range solutions {
+			for _, variable := range patternVariables(q.where, nil, map[string]bool{}) {
+				if term, bound := sol[variable]; bound && term != nil {
+					resources = append(resources, term)
+				}
+			}
+		}
+	}
+	for _, resource := range resources {
+		for _, i := range s.bySubject[termKey(resource)] {
+			add(s.triples[i])
+		}
+	}
+	return triples
+}
+
+// instantiate builds a triple from a template pattern. Blank nodes in the
+// template are new for each solution.
+func instantiate(pattern triplePattern, sol solution, index int) (rdf.Triple, bool) {
+	var terms []rdf.Term
+	for _, n := range []node{pattern.subject, pattern.predicate, pattern.object} {
+		term, bound := sol.resolve(n)
+		if strings.HasPrefix(n.variable, "_:") {
+			blank, err := rdf.NewBlank(strings.TrimPrefix(n.variable, "_:") + "_" + strconv.Itoa(index))
+			if err != nil {
+				return rdf.Triple{}, false
+			}
+			term, bound = blank, true
+		}
+		if !bound {
+			return rdf.Triple{}, false
+		}
+		terms = append(terms, term)
+	}
+
+	if terms[0].Type() == rdf.TermLiteral || terms[1].Type() != rdf.TermIRI {
+		return rdf.Triple{}, false
+	}
+	return rdf.Triple{Subj: terms[0].(rdf.Subject), Pred: terms[1].(rdf.Predicate), Obj: terms[2].(rdf.Object)}, true
+}
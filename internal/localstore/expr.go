@@ -0,0 +1,992 @@
+package localstore
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// solution maps variable names to the terms they're bound to.
+type solution map[string]rdf.Term
+
+// errType is the error of an expression applied to terms of the wrong type.
+// Like in SPARQL, it makes a FILTER fail and leaves a BIND unbound.
+var errType = errors.New("The expression can't be evaluated for these terms.")
+
+// expression is a SPARQL expression. Aggregates are evaluated over the group
+// of solutions, other expressions over a single solution.
+type expression interface {
+	evaluate(s solution, group []solution) (rdf.Term, error)
+}
+
+type variableExpression struct {
+	name string
+}
+
+type termExpression struct {
+	term rdf.Term
+}
+
+type unaryExpression struct {
+	operator string
+	operand  expression
+}
+
+type binaryExpression struct {
+	operator    string
+	left, right expression
+}
+
+type inExpression struct {
+	operand expression
+	list    []expression
+	negated bool
+}
+
+type callExpression struct {
+	function  string
+	arguments []expression
+	pattern   *regexp.Regexp
+}
+
+type existsExpression struct {
+	group   *group
+	negated bool
+	store   *Store
+}
+
+type aggregateExpression struct {
+	function  string
+	argument  expression
+	distinct  bool
+	separator string
+}
+
+// functionArity holds the number of arguments of each supported function, -1
+// for a variable number.
+var functionArity = map[string]int{
+	"BOUND": 1, "STR": 1, "LANG": 1, "LANGMATCHES": 2, "DATATYPE": 1,
+	"REGEX": -1, "CONTAINS": 2, "STRSTARTS": 2, "STRENDS": 2, "LCASE": 1,
+	"UCASE": 1, "STRLEN": 1, "ISIRI": 1, "ISURI": 1, "ISLITERAL": 1,
+	"ISBLANK": 1, "ISNUMERIC": 1, "SAMETERM": 2, "IF": 3, "COALESCE": -1,
+	"CONCAT": -1, "REPLACE": -1, "SUBSTR": -1, "ABS": 1, "ROUND": 1,
+	"CEIL": 1, "FLOOR": 1, "IRI": 1, "URI": 1, "STRDT": 2, "STRLANG": 2,
+	"STRBEFORE": 2, "STRAFTER": 2, "ENCODE_FOR_URI": 1,
+}
+
+var aggregates = map[string]bool{
+	"COUNT": true, "SUM": true, "MIN": true, "MAX": true, "AVG": true,
+	"SAMPLE": true, "GROUP_CONCAT": true,
+}
+
+func (p *parser) parseConstraint() (expression, error) {
+	if p.isPunctuation("(") || p.peek().kind == tokenKeyword {
+		return p.parsePrimary()
+	}
+	return nil, p.unexpected("expected a constraint")
+}
+
+func (p *parser) parseExpression() (expression, error) {
+	return p.parseBinary(0)
+}
+
+// binaryOperators lists the binary operators by increasing precedence.
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"=", "!=", "<", ">", "<=", ">="},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *parser) parseBinary(precedence int) (expression, error) {
+	if precedence == len(binaryOperators) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(precedence + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if precedence == 2 && (p.isKeyword("IN") || p.isKeyword("NOT")) {
+			return p.parseIn(left)
+		}
+
+		operator := ""
+		for _, candidate := range binaryOperators[precedence] {
+			if p.isPunctuation(candidate) {
+				operator = candidate
+			}
+		}
+		if operator == "" {
+			return left, nil
+		}
+		p.next()
+
+		right, err := p.parseBinary(precedence + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpression{operator: operator, left: left, right: right}
+	}
+}
+
+func (p *parser) parseIn(operand expression) (expression, error) {
+	in := inExpression{operand: operand, negated: p.accept("NOT")}
+	if err := p.expect("IN"); err != nil {
+		return nil, err
+	}
+	arguments, err := p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+	in.list = arguments
+	return in, nil
+}
+
+func (p *parser) parseUnary() (expression, error) {
+	for _, operator := range []string{"!", "-", "+"} {
+		if p.accept(operator) {
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return unaryExpression{operator: operator, operand: operand}, nil
+		}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expression, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenPunctuation:
+		if t.value == "(" {
+			p.next()
+			expr, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		}
+	case tokenVariable:
+		p.next()
+		return variableExpression{name: t.value[1:]}, nil
+	case tokenIRI, tokenPrefixedName, tokenString, tokenNumber:
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		if p.isPunctuation("(") {
+			return p.parseCast(term)
+		}
+		return termExpression{term: term}, nil
+	case tokenKeyword:
+		name := strings.ToUpper(t.value)
+		if name == "TRUE" || name == "FALSE" {
+			p.next()
+			return termExpression{term: boolean(name == "TRUE")}, nil
+		}
+		if aggregates[name] {
+			p.next()
+			return p.parseAggregate(name)
+		}
+		if name == "EXISTS" || name == "NOT" {
+			p.next()
+			if name == "NOT" {
+				if err := p.expect("EXISTS"); err != nil {
+					return nil, err
+				}
+			}
+			g, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			return &existsExpression{group: g, negated: name == "NOT", store: p.store}, nil
+		}
+
+		arity, exists := functionArity[name]
+		if !exists {
+			return nil, errors.New("The function " + t.value + " isn't supported by local endpoints.")
+		}
+		p.next()
+		if name == "BOUND" {
+			// BOUND takes a variable rather than a value
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			variable := p.next()
+			if variable.kind != tokenVariable {
+				return nil, errors.New("BOUND expects a variable.")
+			}
+			return callExpression{function: name, arguments: []expression{variableExpression{name: variable.value[1:]}}}, p.expect(")")
+		}
+
+		arguments, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		if arity >= 0 && len(arguments) != arity {
+			plural := "s"
+			if arity == 1 {
+				plural = ""
+			}
+			return nil, errors.New("The function " + name + " expects " + strconv.Itoa(arity) + " argument" + plural + ".")
+		}
+		call := callExpression{function: name, arguments: arguments}
+		if name == "REGEX" {
+			if len(arguments) != 2 && len(arguments) != 3 {
+				return nil, errors.New("The function REGEX expects 2 or 3 arguments.")
+			}
+			if call.pattern, err = constantPattern(arguments[1:]); err != nil {
+				return nil, err
+			}
+		}
+		return call, nil
+	}
+	return nil, p.unexpected("expected an expression")
+}
+
+// parseCast parses a call to an XSD datatype as a cast, like xsd:integer(?x).
+func (p *parser) parseCast(term rdf.Term) (expression, error) {
+	datatype, ok := term.(rdf.IRI)
+	if !ok || !strings.HasPrefix(datatype.String(), "http://www.w3.org/2001/XMLSchema#") {
+		return nil, errors.New("Only casts to XSD datatypes are supported by local endpoints.")
+	}
+	arguments, err := p.parseArguments()
+	if err != nil {
+		return nil, err
+	}
+	if len(arguments) != 1 {
+		return nil, errors.New("A cast expects a single argument.")
+	}
+	return callExpression{function: "CAST", arguments: []expression{arguments[0], termExpression{term: datatype}}}, nil
+}
+
+func (p *parser) parseArguments() ([]expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var arguments []expression
+	for !p.accept(")") {
+		if len(arguments) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		argument, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		arguments = append(arguments, argument)
+	}
+	return arguments, nil
+}
+
+func (p *parser) parseAggregate(name string) (expression, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	aggregate := aggregateExpression{function: name, distinct: p.accept("DISTINCT"), separator: " "}
+	if name == "COUNT" && p.accept("*") {
+		return aggregate, p.expect(")")
+	}
+
+	argument, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	aggregate.argument = argument
+
+	if name == "GROUP_CONCAT" && p.accept(";") {
+		if err := p.expect("SEPARATOR"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		separator := p.next()
+		if separator.kind != tokenString {
+			return nil, errors.New("The SEPARATOR of GROUP_CONCAT must be a string.")
+		}
+		aggregate.separator = unescape(separator.value)
+	}
+	return aggregate, p.expect(")")
+}
+
+// constantPattern compiles the pattern of a REGEX call when it's a literal,
+// so that it isn't compiled again for each solution.
+func constantPattern(arguments []expression) (*regexp.Regexp, error) {
+	flags := ""
+	for i, argument := range arguments {
+		constant, ok := argument.(termExpression)
+		if !ok {
+			return nil, nil
+		}
+		if i == 1 {
+			flags = constant.term.String()
+		}
+	}
+	return compilePattern(arguments[0].(termExpression).term.String(), flags)
+}
+
+func compilePattern(pattern string, flags string) (*regexp.Regexp, error) {
+	goFlags := ""
+	for _, flag := range flags {
+		switch flag {
+		case 'i', 's', 'm':
+			goFlags += string(flag)
+		default:
+			return nil, errors.New("The REGEX flag " + string(flag) + " isn't supported by local endpoints.")
+		}
+	}
+	if goFlags != "" {
+		pattern = "(?" + goFlags + ")" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+func (e variableExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	term, bound := s[e.name]
+	if !bound || term == nil {
+		return nil, errType
+	}
+	return term, nil
+}
+
+func (e termExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	return e.term, nil
+}
+
+func (e unaryExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	operand, err := e.operand.evaluate(s, group)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.operator == "!" {
+		value, err := effectiveBoolean(operand)
+		if err != nil {
+			return nil, err
+		}
+		return boolean(!value), nil
+	}
+
+	n, datatype, ok := numeric(operand)
+	if !ok {
+		return nil, errType
+	}
+	if e.operator == "-" {
+		n = -n
+	}
+	return number(n, datatype), nil
+}
+
+func (e binaryExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	left, leftErr := e.left.evaluate(s, group)
+
+	// the logical operators tolerate an error on one side
+	switch e.operator {
+	case "||", "&&":
+		var leftValue, rightValue bool
+		if leftErr == nil {
+			leftValue, leftErr = effectiveBoolean(left)
+		}
+		if leftErr == nil && leftValue == (e.operator == "||") {
+			return boolean(leftValue), nil
+		}
+		right, rightErr := e.right.evaluate(s, group)
+		if rightErr == nil {
+			rightValue, rightErr = effectiveBoolean(right)
+		}
+		if rightErr == nil && rightValue == (e.operator == "||") {
+			return boolean(rightValue), nil
+		}
+		if leftErr != nil {
+			return nil, leftErr
+		}
+		if rightErr != nil {
+			return nil, rightErr
+		}
+		return boolean(rightValue), nil
+	}
+
+	if leftErr != nil {
+		return nil, leftErr
+	}
+	right, err := e.right.evaluate(s, group)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.operator {
+	case "=", "!=":
+		equal, err := equalTerms(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return boolean(equal == (e.operator == "=")), nil
+	case "<", ">", "<=", ">=":
+		order, err := compareTerms(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch e.operator {
+		case "<":
+			return boolean(order < 0), nil
+		case ">":
+			return boolean(order > 0), nil
+		case "<=":
+			return boolean(order <= 0), nil
+		default:
+			return boolean(order >= 0), nil
+		}
+	}
+
+	a, leftType, leftOk := numeric(left)
+	b, rightType, rightOk := numeric(right)
+	if !leftOk || !rightOk {
+		return nil, errType
+	}
+	datatype := widest(leftType, rightType)
+	switch e.operator {
+	case "+":
+		return number(a+b, datatype), nil
+	case "-":
+		return number(a-b, datatype), nil
+	case "*":
+		return number(a*b, datatype), nil
+	default:
+		if b == 0 && datatype != xsdDouble {
+			return nil, errType
+		}
+		if datatype == xsdInteger {
+			datatype = xsdDecimal
+		}
+		return number(a/b, datatype), nil
+	}
+}
+
+func (e inExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	operand, err := e.operand.evaluate(s, group)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range e.list {
+		term, err := item.evaluate(s, group)
+		if err != nil {
+			continue
+		}
+		if equal, err := equalTerms(operand, term); err == nil && equal {
+			return boolean(!e.negated), nil
+		}
+	}
+	return boolean(e.negated), nil
+}
+
+func (e *existsExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	matches := e.store.evaluateGroup(e.group, []solution{s})
+	return boolean((len(matches) > 0) != e.negated), nil
+}
+
+func (e aggregateExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	var values []rdf.Term
+	seen := map[string]bool{}
+	for _, member := range group {
+		var value rdf.Term
+		if e.argument == nil {
+			// COUNT(*) counts solutions
+			value = boolean(true)
+			if e.distinct {
+				value = rdf.NewTypedLiteral(solutionKey(member), mustIRI(xsdString))
+			}
+		} else {
+			var err error
+			if value, err = e.argument.evaluate(member, group); err != nil {
+				continue
+			}
+		}
+		if e.distinct {
+			key := value.Serialize(rdf.NTriples)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		values = append(values, value)
+	}
+
+	switch e.function {
+	case "COUNT":
+		return number(float64(len(values)), xsdInteger), nil
+	case "SAMPLE":
+		if len(values) == 0 {
+			return nil, errType
+		}
+		return values[0], nil
+	case "GROUP_CONCAT":
+		parts := make([]string, len(values))
+		for i, value := range values {
+			parts[i] = value.String()
+		}
+		return rdf.NewTypedLiteral(strings.Join(parts, e.separator), mustIRI(xsdString)), nil
+	case "MIN", "MAX":
+		if len(values) == 0 {
+			return nil, errType
+		}
+		extreme := values[0]
+		for _, value := range values[1:] {
+			order := orderTerms(value, extreme)
+			if (e.function == "MIN" && order < 0) || (e.function == "MAX" && order > 0) {
+				extreme = value
+			}
+		}
+		return extreme, nil
+	}
+
+	// SUM and AVG
+	sum, datatype := 0.0, xsdInteger
+	for _, value := range values {
+		n, valueType, ok := numeric(value)
+		if !ok {
+			return nil, errType
+		}
+		sum += n
+		datatype = widest(datatype, valueType)
+	}
+	if e.function == "AVG" {
+		if len(values) == 0 {
+			return number(0, xsdInteger), nil
+		}
+		if datatype == xsdInteger {
+			datatype = xsdDecimal
+		}
+		return number(sum/float64(len(values)), datatype), nil
+	}
+	return number(sum, datatype), nil
+}
+
+func (e callExpression) evaluate(s solution, group []solution) (rdf.Term, error) {
+	switch e.function {
+	case "BOUND":
+		_, err := e.arguments[0].evaluate(s, group)
+		return boolean(err == nil), nil
+	case "IF":
+		condition, err := e.arguments[0].evaluate(s, group)
+		if err != nil {
+			return nil, err
+		}
+		value, err := effectiveBoolean(condition)
+		if err != nil {
+			return nil, err
+		}
+		if value {
+			return e.arguments[1].evaluate(s, group)
+		}
+		return e.arguments[2].evaluate(s, group)
+	case "COALESCE":
+		for _, argument := range e.arguments {
+			if term, err := argument.evaluate(s, group); err == nil {
+				return term, nil
+			}
+		}
+		return nil, errType
+	}
+
+	arguments := make([]rdf.Term, len(e.arguments))
+	for i, argument := range e.arguments {
+		term, err := argument.evaluate(s, group)
+		if err != nil {
+			return nil, err
+		}
+		arguments[i] = term
+	}
+
+	switch e.function {
+	case "STR":
+		if arguments[0].Type() == rdf.TermBlank {
+			return nil, errType
+		}
+		return plain(arguments[0].String()), nil
+	case "LANG":
+		literal, ok := arguments[0].(rdf.Literal)
+		if !ok {
+			return nil, errType
+		}
+		return plain(literal.Lang()), nil
+	case "LANGMATCHES":
+		tag, ranges := strings.ToLower(arguments[0].String()), strings.ToLower(arguments[1].String())
+		if ranges == "*" {
+			return boolean(tag != ""), nil
+		}
+		return boolean(tag == ranges || strings.HasPrefix(tag, ranges+"-")), nil
+	case "DATATYPE":
+		literal, ok := arguments[0].(rdf.Literal)
+		if !ok {
+			return nil, errType
+		}
+		return literal.DataType, nil
+	case "ISIRI", "ISURI":
+		return boolean(arguments[0].Type() == rdf.TermIRI), nil
+	case "ISLITERAL":
+		return boolean(arguments[0].Type() == rdf.TermLiteral), nil
+	case "ISBLANK":
+		return boolean(arguments[0].Type() == rdf.TermBlank), nil
+	case "ISNUMERIC":
+		_, _, ok := numeric(arguments[0])
+		return boolean(ok), nil
+	case "SAMETERM":
+		return boolean(termKey(arguments[0]) == termKey(arguments[1])), nil
+	case "IRI", "URI":
+		if arguments[0].Type() == rdf.TermIRI {
+			return arguments[0], nil
+		}
+		iri, err := rdf.NewIRI(arguments[0].String())
+		if err != nil {
+			return nil, errType
+		}
+		return iri, nil
+	case "STRDT":
+		datatype, ok := arguments[1].(rdf.IRI)
+		if !ok {
+			return nil, errType
+		}
+		return rdf.NewTypedLiteral(arguments[0].String(), datatype), nil
+	case "STRLANG":
+		literal, err := rdf.NewLangLiteral(arguments[0].String(), arguments[1].String())
+		if err != nil {
+			return nil, errType
+		}
+		return literal, nil
+	case "CAST":
+		return cast(arguments[0], arguments[1].(rdf.IRI))
+	case "ABS", "ROUND", "CEIL", "FLOOR":
+		n, datatype, ok := numeric(arguments[0])
+		if !ok {
+			return nil, errType
+		}
+		switch e.function {
+		case "ABS":
+			n = math.Abs(n)
+		case "ROUND":
+			n = math.Floor(n + 0.5)
+		case "CEIL":
+			n = math.Ceil(n)
+		default:
+			n = math.Floor(n)
+		}
+		return number(n, datatype), nil
+	}
+
+	// the remaining functions operate on strings
+	for _, argument := range arguments {
+		if argument.Type() != rdf.TermLiteral {
+			return nil, errType
+		}
+	}
+	value := arguments[0].String()
+
+	switch e.function {
+	case "REGEX":
+		pattern := e.pattern
+		if pattern == nil {
+			flags := ""
+			if len(arguments) == 3 {
+				flags = arguments[2].String()
+			}
+			var err error
+			if pattern, err = compilePattern(arguments[1].String(), flags); err != nil {
+				return nil, errType
+			}
+		}
+		return boolean(pattern.MatchString(value)), nil
+	case "REPLACE":
+		if len(arguments) != 3 && len(arguments) != 4 {
+			return nil, errType
+		}
+		flags := ""
+		if len(arguments) == 4 {
+			flags = arguments[3].String()
+		}
+		pattern, err := compilePattern(arguments[1].String(), flags)
+		if err != nil {
+			return nil, errType
+		}
+		replacement := regexp.MustCompile(`\$(\d)`).ReplaceAllString(arguments[2].String(), "$${$1}")
+		return like(arguments[0], pattern.ReplaceAllString(value, replacement)), nil
+	case "CONTAINS":
+		return boolean(strings.Contains(value, arguments[1].String())), nil
+	case "STRSTARTS":
+		return boolean(strings.HasPrefix(value, arguments[1].String())), nil
+	case "STRENDS":
+		return boolean(strings.HasSuffix(value, arguments[1].String())), nil
+	case "STRBEFORE":
+		if i := strings.Index(value, arguments[1].String()); i >= 0 {
+			return like(arguments[0], value[:i]), nil
+		}
+		return plain(""), nil
+	case "STRAFTER":
+		if i := strings.Index(value, arguments[1].String()); i >= 0 {
+			return like(arguments[0], value[i+len(arguments[1].String()):]), nil
+		}
+		return plain(""), nil
+	case "ENCODE_FOR_URI":
+		return plain(strings.ReplaceAll(url.QueryEscape(value), "+", "%20")), nil
+	case "LCASE":
+		return like(arguments[0], strings.ToLower(value)), nil
+	case "UCASE":
+		return like(arguments[0], strings.ToUpper(value)), nil
+	case "STRLEN":
+		return number(float64(len([]rune(value))), xsdInteger), nil
+	case "CONCAT":
+		var builder strings.Builder
+		for _, argument := range arguments {
+			builder.WriteString(argument.String())
+		}
+		return plain(builder.String()), nil
+	case "SUBSTR":
+		if len(arguments) != 2 && len(arguments) != 3 {
+			return nil, errType
+		}
+		runes := []rune(value)
+		start, _, ok := numeric(arguments[1])
+		if !ok {
+			return nil, errType
+		}
+		end := float64(len(runes)) + 1
+		if len(arguments) == 3 {
+			length, _, ok := numeric(arguments[2])
+			if !ok {
+				return nil, errType
+			}
+			end = math.Min(end, start+length)
+		}
+		from := int(math.Max(math.Round(start), 1)) - 1
+		to := int(math.Round(end)) - 1
+		if from >= to || from >= len(runes) {
+			return like(arguments[0], ""), nil
+		}
+		return like(arguments[0], string(runes[from:to])), nil
+	}
+	return nil, errType
+}
+
+// like returns a literal with the given value and the language of the literal.
+func like(original rdf.Term, value string) rdf.Term {
+	if literal, ok := original.(rdf.Literal); ok && literal.Lang() != "" {
+		if tagged, err := rdf.NewLangLiteral(value, literal.Lang()); err == nil {
+			return tagged
+		}
+	}
+	return plain(value)
+}
+
+func plain(value string) rdf.Literal {
+	return rdf.NewTypedLiteral(value, mustIRI(xsdString))
+}
+
+func boolean(value bool) rdf.Literal {
+	return rdf.NewTypedLiteral(strconv.FormatBool(value), mustIRI(xsdBoolean))
+}
+
+// numericTypes maps the numeric XSD datatypes to the type used in arithmetic.
+var numericTypes = map[string]string{
+	xsdInteger:                                            xsdInteger,
+	xsdDecimal:                                            xsdDecimal,
+	xsdDouble:                                             xsdDouble,
+	"http://www.w3.org/2001/XMLSchema#float":              xsdDouble,
+	"http://www.w3.org/2001/XMLSchema#int":                xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#long":               xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#short":              xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#byte":               xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#nonNegativeInteger": xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#positiveInteger":    xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#nonPositiveInteger": xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#negativeInteger":    xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#unsignedInt":        xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#unsignedLong":       xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#unsignedShort":      xsdInteger,
+	"http://www.w3.org/2001/XMLSchema#unsignedByte":       xsdInteger,
+}
+
+// numeric returns the value of a numeric literal and its arithmetic type.
+func numeric(term rdf.Term) (float64, string, bool) {
+	literal, ok := term.(rdf.Literal)
+	if !ok {
+		return 0, "", false
+	}
+	datatype, ok := numericTypes[literal.DataType.String()]
+	if !ok {
+		return 0, "", false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(literal.String()), 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return n, datatype, true
+}
+
+// widest returns the arithmetic type of an operation on the given types.
+func widest(a, b string) string {
+	if a == xsdDouble || b == xsdDouble {
+		return xsdDouble
+	}
+	if a == xsdDecimal || b == xsdDecimal {
+		return xsdDecimal
+	}
+	return xsdInteger
+}
+
+func number(n float64, datatype string) rdf.Literal {
+	var value string
+	switch datatype {
+	case xsdInteger:
+		value = strconv.FormatFloat(math.Trunc(n), 'f', -1, 64)
+	case xsdDecimal:
+		value = strconv.FormatFloat(n, 'f', -1, 64)
+		if !strings.Contains(value, ".") {
+			value += ".0"
+		}
+	default:
+		value = strconv.FormatFloat(n, 'E', -1, 64)
+	}
+	return rdf.NewTypedLiteral(value, mustIRI(datatype))
+}
+
+func cast(term rdf.Term, datatype rdf.IRI) (rdf.Term, error) {
+	value := strings.TrimSpace(term.String())
+	switch arithmetic := numericTypes[datatype.String()]; {
+	case arithmetic != "":
+		if n, _, ok := numeric(term); ok {
+			return number(n, arithmetic), nil
+		}
+		if b, err := strconv.ParseBool(value); err == nil && isBoolean(term) {
+			if b {
+				return number(1, arithmetic), nil
+			}
+			return number(0, arithmetic), nil
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil || (arithmetic == xsdInteger && n != math.Trunc(n)) {
+			return nil, errType
+		}
+		return number(n, arithmetic), nil
+	case datatype.String() == xsdBoolean:
+		if n, _, ok := numeric(term); ok {
+			return boolean(n != 0), nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, errType
+		}
+		return boolean(b), nil
+	case datatype.String() == xsdString:
+		return plain(term.String()), nil
+	}
+	if term.Type() != rdf.TermLiteral {
+		return nil, errType
+	}
+	return rdf.NewTypedLiteral(term.String(), datatype), nil
+}
+
+func isBoolean(term rdf.Term) bool {
+	literal, ok := term.(rdf.Literal)
+	return ok && literal.DataType.String() == xsdBoolean
+}
+
+// isString reports whether the term is a simple literal or an xsd:string.
+func isString(term rdf.Term) bool {
+	literal, ok := term.(rdf.Literal)
+	return ok && literal.DataType.String() == xsdString
+}
+
+// effectiveBoolean returns the effective boolean value of a term.
+func effectiveBoolean(term rdf.Term) (bool, error) {
+	literal, ok := term.(rdf.Literal)
+	if !ok {
+		return false, errType
+	}
+	if isBoolean(literal) {
+		return literal.String() == "true" || literal.String() == "1", nil
+	}
+	if n, _, ok := numeric(literal); ok {
+		return n != 0 && !math.IsNaN(n), nil
+	}
+	if isString(literal) || literal.Lang() != "" {
+		return literal.String() != "", nil
+	}
+	return false, errType
+}
+
+func equalTerms(a, b rdf.Term) (bool, error) {
+	if x, _, ok := numeric(a); ok {
+		if y, _, ok := numeric(b); ok {
+			return x == y, nil
+		}
+	}
+	if isBoolean(a) && isBoolean(b) {
+		x, _ := effectiveBoolean(a)
+		y, _ := effectiveBoolean(b)
+		return x == y, nil
+	}
+	return termKey(a) == termKey(b), nil
+}
+
+// compareTerms orders two literals of the same kind.
+func compareTerms(a, b rdf.Term) (int, error) {
+	if x, _, ok := numeric(a); ok {
+		if y, _, ok := numeric(b); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+
+	left, leftOk := a.(rdf.Literal)
+	right, rightOk := b.(rdf.Literal)
+	if !leftOk || !rightOk || left.DataType != right.DataType || left.Lang() != right.Lang() {
+		return 0, errType
+	}
+	if _, isNumeric := numericTypes[left.DataType.String()]; isNumeric {
+		return 0, errType
+	}
+	// strings, booleans and dates in the same time zone order lexically
+	return strings.Compare(left.String(), right.String()), nil
+}
+
+// orderTerms orders any two terms for ORDER BY, MIN and MAX: unbound values
+// first, then blank nodes, IRIs and literals.
+func orderTerms(a, b rdf.Term) int {
+	rank := func(term rdf.Term) int {
+		if term == nil {
+			return 0
+		}
+		return int(term.Type()) + 1
+	}
+	if rank(a) != rank(b) {
+		return rank(a) - rank(b)
+	}
+	if a == nil {
+		return 0
+	}
+	if order, err := compareTerms(a, b); err == nil {
+		return order
+	}
+	return strings.Compare(a.String(), b.String())
+}
+
+// termKey returns a key that is equal for equal terms.
+func termKey(term rdf.Term) string {
+	return term.Serialize(rdf.NTriples)
+}
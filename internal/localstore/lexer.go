@@ -0,0 +1,124 @@
+package localstore
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIRI
+	tokenPrefixedName
+	tokenVariable
+	tokenBlank
+	tokenString
+	tokenLangTag
+	tokenNumber
+	tokenKeyword
+	tokenPunctuation
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+var tokenPatterns = []struct {
+	kind    tokenKind
+	pattern *regexp.Regexp
+}{
+	{tokenIRI, regexp.MustCompile(`^<[^<>"{}|^` + "`" + `\\\x00-\x20]*>`)},
+	{tokenVariable, regexp.MustCompile(`^[?$][A-Za-z0-9_]+`)},
+	{tokenBlank, regexp.MustCompile(`^_:[A-Za-z0-9_\-.]*[A-Za-z0-9_\-]`)},
+	{tokenString, regexp.MustCompile(`^("""(?s:.*?)"""|'''(?s:.*?)'''|"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*')`)},
+	{tokenLangTag, regexp.MustCompile(`^@[A-Za-z]+(-[A-Za-z0-9]+)*`)},
+	{tokenNumber, regexp.MustCompile(`^[0-9]*\.?[0-9]+([eE][+-]?[0-9]+)?`)},
+	{tokenPrefixedName, regexp.MustCompile(`^([A-Za-z]([\w\-.]*[\w\-])?)?:([\w\-%]([\w\-.%]*[\w\-%])?)?`)},
+	{tokenKeyword, regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)},
+	{tokenPunctuation, regexp.MustCompile(`^(\^\^|&&|\|\||!=|<=|>=|[{}()\[\].;,*=<>!+\-/])`)},
+}
+
+var whitespaceOrComment = regexp.MustCompile(`^(\s+|#[^\n]*)+`)
+
+// expressionInIRI matches what only expressions contain, like the variables
+// and operators of FILTER(?a<?b&&?c>3), which would otherwise be lexed as
+// the IRI <?b&&?c>.
+var expressionInIRI = regexp.MustCompile(`^<[?$\d(]|&&|\|\|`)
+
+// endsOperand reports whether the token can end the left operand of a
+// comparison.
+func endsOperand(t token) bool {
+	switch t.kind {
+	case tokenVariable, tokenNumber, tokenString, tokenLangTag, tokenIRI, tokenPrefixedName:
+		return true
+	case tokenPunctuation:
+		return t.value == ")"
+	}
+	return false
+}
+
+// tokenize splits a query into tokens. Inside parentheses a < following an
+// operand is a comparison rather than the start of an IRI if what follows it
+// looks like an expression.
+func tokenize(query string) ([]token, error) {
+	var tokens []token
+	// the open parentheses and braces, innermost last
+	var groups []string
+	rest := query
+	for {
+		rest = whitespaceOrComment.ReplaceAllString(rest, "")
+		if rest == "" {
+			return append(tokens, token{kind: tokenEOF}), nil
+		}
+
+		inExpression := len(groups) > 0 && groups[len(groups)-1] == "(" && len(tokens) > 0 && endsOperand(tokens[len(tokens)-1])
+		matched := false
+		for _, tp := range tokenPatterns {
+			match := tp.pattern.FindString(rest)
+			if match == "" {
+				continue
+			}
+			if tp.kind == tokenIRI && inExpression && expressionInIRI.MatchString(match) {
+				continue
+			}
+			tokens = append(tokens, token{kind: tp.kind, value: match})
+			rest = rest[len(match):]
+			matched = true
+
+			if tp.kind == tokenPunctuation {
+				switch match {
+				case "(", "{":
+					groups = append(groups, match)
+				case ")", "}":
+					if len(groups) > 0 {
+						groups = groups[:len(groups)-1]
+					}
+				}
+			}
+			break
+		}
+		if !matched {
+			end := len(rest)
+			if end > 20 {
+				end = 20
+			}
+			return nil, errors.New("Unexpected input in query near \"" + rest[:end] + "\".")
+		}
+	}
+}
+
+// unescape resolves the escape sequences of a quoted string token.
+func unescape(quoted string) string {
+	var body string
+	if strings.HasPrefix(quoted, `"""`) || strings.HasPrefix(quoted, `'''`) {
+		body = quoted[3 : len(quoted)-3]
+	} else {
+		body = quoted[1 : len(quoted)-1]
+	}
+
+	replacer := strings.NewReplacer(`\t`, "\t", `\n`, "\n", `\r`, "\r", `\b`, "\b", `\f`, "\f", `\"`, `"`, `\'`, "'", `\\`, `\`)
+	return replacer.Replace(body)
+}
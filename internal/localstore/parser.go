@@ -0,0 +1,774 @@
+package localstore
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+const rdfType = "http://www.w3.org/1999/02/22-rdf-syntax-ns#type"
+
+const (
+	xsdString  = "http://www.w3.org/2001/XMLSchema#string"
+	xsdInteger = "http://www.w3.org/2001/XMLSchema#integer"
+	xsdDecimal = "http://www.w3.org/2001/XMLSchema#decimal"
+	xsdDouble  = "http://www.w3.org/2001/XMLSchema#double"
+	xsdBoolean = "http://www.w3.org/2001/XMLSchema#boolean"
+)
+
+// node is a position in a triple pattern, either a variable or an RDF term.
+// Blank nodes in patterns act as variables which aren't projected.
+type node struct {
+	variable string
+	term     rdf.Term
+}
+
+type triplePattern struct {
+	subject, predicate, object node
+}
+
+// element is a part of a group graph pattern.
+type element interface{}
+
+type triplesElement struct {
+	patterns []triplePattern
+}
+
+type optionalElement struct {
+	group *group
+}
+
+type unionElement struct {
+	groups []*group
+}
+
+type filterElement struct {
+	expression expression
+}
+
+type bindElement struct {
+	expression expression
+	variable   string
+}
+
+type valuesElement struct {
+	variables []string
+	rows      [][]rdf.Term
+}
+
+type group struct {
+	elements []element
+}
+
+// projection is a variable selected by a query, optionally computed by an
+// expression.
+type projection struct {
+	variable   string
+	expression expression
+}
+
+type orderCondition struct {
+	expression expression
+	descending bool
+}
+
+type query struct {
+	form      string
+	distinct  bool
+	selectAll bool
+	projected []projection
+	template  []triplePattern
+	describe  []node
+	where     *group
+	groupBy   []expression
+	having    []expression
+	orderBy   []orderCondition
+	limit     int
+	offset    int
+}
+
+type parser struct {
+	tokens   []token
+	position int
+	prefixes map[string]string
+	base     string
+	blanks   int
+	store    *Store
+}
+
+// parse parses the supported subset of SPARQL 1.1 queries to be evaluated
+// against the store.
+func parse(source string, store *Store) (*query, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, prefixes: map[string]string{}, store: store}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != tokenEOF {
+		p.position++
+	}
+	return t
+}
+
+// isKeyword reports whether the next token is the given keyword, ignoring case.
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenKeyword && strings.EqualFold(t.value, keyword)
+}
+
+func (p *parser) isPunctuation(punctuation string) bool {
+	t := p.peek()
+	return t.kind == tokenPunctuation && t.value == punctuation
+}
+
+// accept consumes the next token if it's the given keyword or punctuation.
+func (p *parser) accept(value string) bool {
+	if p.isKeyword(value) || p.isPunctuation(value) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(value string) error {
+	if !p.accept(value) {
+		return p.unexpected("expected " + value)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(context string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return errors.New("Unexpected end of query, " + context + ".")
+	}
+	return errors.New("Unexpected \"" + t.value + "\" in query, " + context + ".")
+}
+
+func (p *parser) parseQuery() (*query, error) {
+	for {
+		if p.accept("PREFIX") {
+			name := p.next()
+			iri := p.next()
+			if name.kind != tokenPrefixedName || iri.kind != tokenIRI {
+				return nil, errors.New("Malformed PREFIX declaration in query.")
+			}
+			p.prefixes[strings.TrimSuffix(name.value, ":")] = p.resolve(iri.value[1 : len(iri.value)-1])
+		} else if p.accept("BASE") {
+			iri := p.next()
+			if iri.kind != tokenIRI {
+				return nil, errors.New("Malformed BASE declaration in query.")
+			}
+			p.base = iri.value[1 : len(iri.value)-1]
+		} else {
+			break
+		}
+	}
+
+	q := &query{limit: -1}
+	var err error
+	switch {
+	case p.accept("SELECT"):
+		q.form = "SELECT"
+		err = p.parseSelectClause(q)
+	case p.accept("ASK"):
+		q.form = "ASK"
+	case p.accept("CONSTRUCT"):
+		q.form = "CONSTRUCT"
+		if p.isPunctuation("{") {
+			q.template, err = p.parseTemplate()
+		}
+	case p.accept("DESCRIBE"):
+		q.form = "DESCRIBE"
+		err = p.parseDescribeClause(q)
+	default:
+		return nil, p.unexpected("expected SELECT, ASK, CONSTRUCT or DESCRIBE")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("FROM") {
+		return nil, errors.New("FROM clauses aren't supported by local endpoints.")
+	}
+
+	if p.accept("WHERE") || p.isPunctuation("{") {
+		if q.where, err = p.parseGroup(); err != nil {
+			return nil, err
+		}
+	} else if q.form != "DESCRIBE" {
+		return nil, p.unexpected("expected WHERE")
+	}
+
+	if q.form == "CONSTRUCT" && q.template == nil {
+		// the short form uses the pattern as the template
+		if len(q.where.elements) != 1 {
+			return nil, errors.New("The WHERE clause of a short CONSTRUCT query can only contain triples.")
+		}
+		triples, ok := q.where.elements[0].(triplesElement)
+		if !ok {
+			return nil, errors.New("The WHERE clause of a short CONSTRUCT query can only contain triples.")
+		}
+		q.template = triples.patterns
+	}
+
+	if err := p.parseSolutionModifiers(q); err != nil {
+		return nil, err
+	}
+	if err := checkGrouping(q); err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected("expected the end of the query")
+	}
+	return q, nil
+}
+
+func (p *parser) parseSelectClause(q *query) error {
+	if p.accept("DISTINCT") {
+		q.distinct = true
+	} else {
+		p.accept("REDUCED")
+	}
+
+	if p.accept("*") {
+		q.selectAll = true
+		return nil
+	}
+
+	for {
+		t := p.peek()
+		if t.kind == tokenVariable {
+			p.next()
+			q.projected = append(q.projected, projection{variable: t.value[1:]})
+		} else if p.accept("(") {
+			expr, err := p.parseExpression()
+			if err != nil {
+				return err
+			}
+			if err := p.expect("AS"); err != nil {
+				return err
+			}
+			variable := p.next()
+			if variable.kind != tokenVariable {
+				return errors.New("Expected a variable after AS in the SELECT clause.")
+			}
+			if err := p.expect(")"); err != nil {
+				return err
+			}
+			q.projected = append(q.projected, projection{variable: variable.value[1:], expression: expr})
+		} else {
+			break
+		}
+	}
+
+	if len(q.projected) == 0 {
+		return p.unexpected("expected the variables to select")
+	}
+	return nil
+}
+
+func (p *parser) parseDescribeClause(q *query) error {
+	if p.accept("*") {
+		q.selectAll = true
+		return nil
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenVariable && t.kind != tokenIRI && t.kind != tokenPrefixedName {
+			break
+		}
+		n, err := p.parseNode()
+		if err != nil {
+			return err
+		}
+		q.describe = append(q.describe, n)
+	}
+	if len(q.describe) == 0 {
+		return p.unexpected("expected the resources to describe")
+	}
+	return nil
+}
+
+// checkGrouping checks that aggregated SELECT queries only project variables
+// they group by, aggregates, and expressions of those, rather than silently
+// leaving the other variables unbound.
+func checkGrouping(q *query) error {
+	if q.form != "SELECT" || (q.groupBy == nil && q.having == nil && !projectsAggregate(q.projected)) {
+		return nil
+	}
+	if q.selectAll {
+		return errors.New("SELECT * can't be combined with GROUP BY or aggregates.")
+	}
+
+	grouped := map[string]bool{}
+	for _, expr := range q.groupBy {
+		if variable, ok := expr.(variableExpression); ok {
+			grouped[variable.name] = true
+		}
+	}
+	for _, p := range q.projected {
+		variable := p.variable
+		if p.expression != nil {
+			variable = ungroupedVariable(p.expression, grouped)
+		} else if grouped[variable] {
+			variable = ""
+		}
+		if variable != "" {
+			return errors.New("The variable ?" + variable + " is selected but not grouped by. Add it to the GROUP BY or use an aggregate like SAMPLE(?" + variable + ").")
+		}
+		// later projections can use the earlier ones
+		grouped[p.variable] = true
+	}
+	return nil
+}
+
+// ungroupedVariable returns a variable used by the expression outside of an
+// aggregate which isn't grouped, or nothing if there is none.
+func ungroupedVariable(expr expression, grouped map[string]bool) string {
+	var children []expression
+	switch expr := expr.(type) {
+	case variableExpression:
+		if !grouped[expr.name] {
+			return expr.name
+		}
+	case unaryExpression:
+		children = []expression{expr.operand}
+	case binaryExpression:
+		children = []expression{expr.left, expr.right}
+	case inExpression:
+		children = append([]expression{expr.operand}, expr.list...)
+	case callExpression:
+		children = expr.arguments
+	}
+	for _, child := range children {
+		if variable := ungroupedVariable(child, grouped); variable != "" {
+			return variable
+		}
+	}
+	return ""
+}
+
+func (p *parser) parseSolutionModifiers(q *query) error {
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return err
+		}
+		for {
+			t := p.peek()
+			if t.kind == tokenVariable {
+				p.next()
+				q.groupBy = append(q.groupBy, variableExpression{name: t.value[1:]})
+			} else if p.isPunctuation("(") {
+				expr, err := p.parsePrimary()
+				if err != nil {
+					return err
+				}
+				q.groupBy = append(q.groupBy, expr)
+			} else {
+				break
+			}
+		}
+		if len(q.groupBy) == 0 {
+			return p.unexpected("expected the variables to group by")
+		}
+	}
+
+	if p.accept("HAVING") {
+		for p.isPunctuation("(") {
+			expr, err := p.parsePrimary()
+			if err != nil {
+				return err
+			}
+			q.having = append(q.having, expr)
+		}
+	}
+
+	if p.accept("ORDER") {
+		if err := p.expect("BY"); err != nil {
+			return err
+		}
+		for {
+			var condition orderCondition
+			t := p.peek()
+			switch {
+			case p.isKeyword("ASC") || p.isKeyword("DESC"):
+				condition.descending = strings.EqualFold(p.next().value, "DESC")
+				expr, err := p.parsePrimary()
+				if err != nil {
+					return err
+				}
+				condition.expression = expr
+			case t.kind == tokenVariable:
+				p.next()
+				condition.expression = variableExpression{name: t.value[1:]}
+			case p.isPunctuation("("):
+				expr, err := p.parsePrimary()
+				if err != nil {
+					return err
+				}
+				condition.expression = expr
+			default:
+				if len(q.orderBy) == 0 {
+					return p.unexpected("expected the variables to order by")
+				}
+				return p.parseLimitOffset(q)
+			}
+			q.orderBy = append(q.orderBy, condition)
+		}
+	}
+	return p.parseLimitOffset(q)
+}
+
+func (p *parser) parseLimitOffset(q *query) error {
+	for {
+		if p.accept("LIMIT") {
+			n, err := p.parseInteger()
+			if err != nil {
+				return err
+			}
+			q.limit = n
+		} else if p.accept("OFFSET") {
+			n, err := p.parseInteger()
+			if err != nil {
+				return err
+			}
+			q.offset = n
+		} else {
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseInteger() (int, error) {
+	t := p.next()
+	n := 0
+	for _, r := range t.value {
+		if r < '0' || r > '9' {
+			return 0, errors.New("Expected a number instead of \"" + t.value + "\".")
+		}
+		n = n*10 + int(r-'0')
+	}
+	if t.kind != tokenNumber {
+		return 0, errors.New("Expected a number instead of \"" + t.value + "\".")
+	}
+	return n, nil
+}
+
+func (p *parser) parseTemplate() ([]triplePattern, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var patterns []triplePattern
+	for !p.accept("}") {
+		triples, err := p.parseTriples()
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, triples...)
+		if !p.accept(".") && !p.isPunctuation("}") {
+			return nil, p.unexpected("expected . or }")
+		}
+	}
+	return patterns, nil
+}
+
+func (p *parser) parseGroup() (*group, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	g := &group{}
+	for !p.accept("}") {
+		switch {
+		case p.isPunctuation("{"):
+			first, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			groups := []*group{first}
+			for p.accept("UNION") {
+				next, err := p.parseGroup()
+				if err != nil {
+					return nil, err
+				}
+				groups = append(groups, next)
+			}
+			if len(groups) == 1 {
+				g.elements = append(g.elements, first)
+			} else {
+				g.elements = append(g.elements, unionElement{groups: groups})
+			}
+		case p.accept("OPTIONAL"):
+			optional, err := p.parseGroup()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, optionalElement{group: optional})
+		case p.accept("FILTER"):
+			expr, err := p.parseConstraint()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, filterElement{expression: expr})
+		case p.accept("BIND"):
+			if err := p.expect("("); err != nil {
+				return nil, err
+			}
+			expr, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("AS"); err != nil {
+				return nil, err
+			}
+			variable := p.next()
+			if variable.kind != tokenVariable {
+				return nil, errors.New("Expected a variable after AS in BIND.")
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, bindElement{expression: expr, variable: variable.value[1:]})
+		case p.accept("VALUES"):
+			values, err := p.parseValues()
+			if err != nil {
+				return nil, err
+			}
+			g.elements = append(g.elements, values)
+		case p.isKeyword("SELECT"):
+			return nil, errors.New("Subqueries aren't supported by local endpoints.")
+		case p.isKeyword("MINUS") || p.isKeyword("SERVICE") || p.isKeyword("GRAPH"):
+			return nil, errors.New(strings.ToUpper(p.peek().value) + " patterns aren't supported by local endpoints.")
+		case p.isPunctuation("."):
+			p.next()
+		default:
+			triples, err := p.parseTriples()
+			if err != nil {
+				return nil, err
+			}
+			// consecutive triples form a single basic graph pattern
+			if last := len(g.elements) - 1; last >= 0 {
+				if previous, ok := g.elements[last].(triplesElement); ok {
+					previous.patterns = append(previous.patterns, triples...)
+					g.elements[last] = previous
+					continue
+				}
+			}
+			g.elements = append(g.elements, triplesElement{patterns: triples})
+		}
+	}
+	return g, nil
+}
+
+func (p *parser) parseValues() (valuesElement, error) {
+	var values valuesElement
+	single := p.peek().kind == tokenVariable
+	if single {
+		values.variables = []string{p.next().value[1:]}
+	} else {
+		if err := p.expect("("); err != nil {
+			return values, err
+		}
+		for p.peek().kind == tokenVariable {
+			values.variables = append(values.variables, p.next().value[1:])
+		}
+		if err := p.expect(")"); err != nil {
+			return values, err
+		}
+	}
+
+	if err := p.expect("{"); err != nil {
+		return values, err
+	}
+	for !p.accept("}") {
+		var row []rdf.Term
+		if !single {
+			if err := p.expect("("); err != nil {
+				return values, err
+			}
+		}
+		for len(row) < len(values.variables) {
+			if p.accept("UNDEF") {
+				row = append(row, nil)
+				continue
+			}
+			n, err := p.parseNode()
+			if err != nil {
+				return values, err
+			}
+			if n.term == nil {
+				return values, errors.New("VALUES can't contain variables.")
+			}
+			row = append(row, n.term)
+		}
+		if !single {
+			if err := p.expect(")"); err != nil {
+				return values, err
+			}
+		}
+		values.rows = append(values.rows, row)
+	}
+	return values, nil
+}
+
+// parseTriples parses the triples sharing a subject, including the
+// predicate-object lists separated by ; and the objects separated by ,.
+func (p *parser) parseTriples() ([]triplePattern, error) {
+	subject, err := p.parseNode()
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []triplePattern
+	for {
+		var predicate node
+		if p.accept("a") {
+			predicate = node{term: mustIRI(rdfType)}
+		} else if predicate, err = p.parseNode(); err != nil {
+			return nil, err
+		}
+
+		for {
+			object, err := p.parseNode()
+			if err != nil {
+				return nil, err
+			}
+			patterns = append(patterns, triplePattern{subject: subject, predicate: predicate, object: object})
+			if !p.accept(",") {
+				break
+			}
+		}
+
+		if !p.accept(";") {
+			return patterns, nil
+		}
+		// a trailing ; may end the list
+		if p.isPunctuation(".") || p.isPunctuation("}") {
+			return patterns, nil
+		}
+	}
+}
+
+// parseNode parses a variable or an RDF term.
+func (p *parser) parseNode() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokenVariable:
+		p.next()
+		return node{variable: t.value[1:]}, nil
+	case tokenBlank:
+		p.next()
+		return node{variable: t.value}, nil
+	case tokenPunctuation:
+		if t.value == "[" {
+			p.next()
+			if err := p.expect("]"); err != nil {
+				return node{}, errors.New("Blank node property lists aren't supported by local endpoints.")
+			}
+			p.blanks++
+			return node{variable: "_:anonymous" + strconv.Itoa(p.blanks)}, nil
+		}
+		if t.value == "-" || t.value == "+" {
+			p.next()
+			number := p.next()
+			if number.kind != tokenNumber {
+				return node{}, p.unexpected("expected a number")
+			}
+			return node{term: numberLiteral(t.value + number.value)}, nil
+		}
+	case tokenIRI, tokenPrefixedName, tokenString, tokenNumber:
+		term, err := p.parseTerm()
+		return node{term: term}, err
+	case tokenKeyword:
+		if strings.EqualFold(t.value, "true") || strings.EqualFold(t.value, "false") {
+			p.next()
+			return node{term: rdf.NewTypedLiteral(strings.ToLower(t.value), mustIRI(xsdBoolean))}, nil
+		}
+	}
+	return node{}, p.unexpected("expected a variable or an RDF term")
+}
+
+// parseTerm parses an IRI or a literal.
+func (p *parser) parseTerm() (rdf.Term, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenIRI:
+		return rdf.NewIRI(p.resolve(t.value[1 : len(t.value)-1]))
+	case tokenPrefixedName:
+		return p.expand(t.value)
+	case tokenNumber:
+		return numberLiteral(t.value), nil
+	case tokenString:
+		value := unescape(t.value)
+		if p.peek().kind == tokenLangTag {
+			return rdf.NewLangLiteral(value, p.next().value[1:])
+		}
+		if p.accept("^^") {
+			datatype, err := p.parseTerm()
+			if err != nil {
+				return nil, err
+			}
+			iri, ok := datatype.(rdf.IRI)
+			if !ok {
+				return nil, errors.New("The datatype of a literal must be an IRI.")
+			}
+			return rdf.NewTypedLiteral(value, iri), nil
+		}
+		return rdf.NewTypedLiteral(value, mustIRI(xsdString)), nil
+	}
+	return nil, errors.New("Expected an IRI or a literal instead of \"" + t.value + "\".")
+}
+
+// expand resolves a prefixed name using the declared prefixes.
+func (p *parser) expand(name string) (rdf.IRI, error) {
+	i := strings.Index(name, ":")
+	namespace, exists := p.prefixes[name[:i]]
+	if !exists {
+		return rdf.IRI{}, errors.New("The prefix " + name[:i] + ": is not declared in the query.")
+	}
+	return rdf.NewIRI(namespace + name[i+1:])
+}
+
+// resolve resolves a relative IRI against the base of the query.
+func (p *parser) resolve(iri string) string {
+	if p.base == "" || strings.Contains(iri, ":") {
+		return iri
+	}
+	return p.base + iri
+}
+
+func numberLiteral(value string) rdf.Literal {
+	datatype := xsdInteger
+	if strings.ContainsAny(value, "eE") {
+		datatype = xsdDouble
+	} else if strings.Contains(value, ".") {
+		datatype = xsdDecimal
+	}
+	return rdf.NewTypedLiteral(strings.TrimPrefix(value, "+"), mustIRI(datatype))
+}
+
+func mustIRI(iri string) rdf.IRI {
+	term, err := rdf.NewIRI(iri)
+	if err != nil {
+		panic(err)
+	}
+	return term
+}
@@ -0,0 +1,213 @@
+// Package localstore answers SPARQL queries from RDF files loaded into memory,
+// so that a site can be built without a SPARQL endpoint.
+package localstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// formats maps the supported file extensions to their RDF format.
+var formats = map[string]rdf.Format{
+	".ttl": rdf.Turtle,
+	".nt":  rdf.NTriples,
+	".rdf": rdf.RDFXML,
+	".xml": rdf.RDFXML,
+	".owl": rdf.RDFXML,
+}
+
+// Store is an in-memory RDF graph which supports a subset of SPARQL 1.1.
+type Store struct {
+	triples     []rdf.Triple
+	all         []int
+	bySubject   map[string][]int
+	byPredicate map[string][]int
+	byObject    map[string][]int
+	// Hash identifies the loaded data, so that cached responses can be told
+	// apart from those of another version of the file.
+	Hash string
+}
+
+// Load reads the RDF file at the given path into a new store. The format is
+// determined by the file extension.
+func Load(path string) (*Store, error) {
+	format, supported := formats[strings.ToLower(filepath.Ext(path))]
+	if !supported {
+		return nil, errors.New("Unsupported RDF file " + path + ". Use Turtle (.ttl), N-Triples (.nt) or RDF/XML (.rdf).")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		bySubject:   map[string][]int{},
+		byPredicate: map[string][]int{},
+		byObject:    map[string][]int{},
+	}
+	hash := sha256.Sum256(data)
+	store.Hash = hex.EncodeToString(hash[:])
+
+	seen := map[string]bool{}
+	decoder := rdf.NewTripleDecoder(bytes.NewReader(data), format)
+	for {
+		triple, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Failed to parse " + path + ". Error: " + err.Error())
+		}
+
+		// a graph is a set of triples
+		key := triple.Serialize(rdf.NTriples)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		i := len(store.triples)
+		store.triples = append(store.triples, triple)
+		store.all = append(store.all, i)
+		store.bySubject[termKey(triple.Subj)] = append(store.bySubject[termKey(triple.Subj)], i)
+		store.byPredicate[termKey(triple.Pred)] = append(store.byPredicate[termKey(triple.Pred)], i)
+		store.byObject[termKey(triple.Obj)] = append(store.byObject[termKey(triple.Obj)], i)
+	}
+	return store, nil
+}
+
+// Len returns the number of triples in the store.
+func (s *Store) Len() int {
+	return len(s.triples)
+}
+
+type resultBinding struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Lang     string `json:"xml:lang,omitempty"`
+	DataType string `json:"datatype,omitempty"`
+}
+
+type selectResults struct {
+	Head struct {
+		Vars []string `json:"vars"`
+	} `json:"head"`
+	Results struct {
+		Bindings []map[string]resultBinding `json:"bindings"`
+	} `json:"results"`
+}
+
+type askResults struct {
+	Head    struct{} `json:"head"`
+	Boolean bool     `json:"boolean"`
+}
+
+// Query evaluates the query and returns the response a SPARQL endpoint would
+// give along with its content type: SPARQL JSON results for SELECT and ASK
+// queries and N-Triples for CONSTRUCT and DESCRIBE queries.
+func (s *Store) Query(source string) ([]byte, string, error) {
+	q, err := parse(source, s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch q.form {
+	case "ASK":
+		var results askResults
+		results.Boolean = len(slice(s.evaluateGroup(q.where, []solution{{}}), q.offset, 1)) > 0
+		data, err := json.Marshal(results)
+		return data, "application/sparql-results+json", err
+	case "CONSTRUCT", "DESCRIBE":
+		var buffer bytes.Buffer
+		for _, triple := range s.evaluateGraph(q) {
+			buffer.WriteString(triple.Serialize(rdf.NTriples))
+		}
+		return buffer.Bytes(), "application/n-triples", nil
+	}
+
+	variables, solutions := s.evaluateSelect(q)
+	var results selectResults
+	results.Head.Vars = variables
+	results.Results.Bindings = make([]map[string]resultBinding, len(solutions))
+	for i, sol := range solutions {
+		bindings := map[string]resultBinding{}
+		for variable, term := range sol {
+			bindings[variable] = toBinding(term)
+		}
+		results.Results.Bindings[i] = bindings
+	}
+	data, err := json.Marshal(results)
+	return data, "application/sparql-results+json", err
+}
+
+func toBinding(term rdf.Term) resultBinding {
+	switch term := term.(type) {
+	case rdf.IRI:
+		return resultBinding{Type: "uri", Value: term.String()}
+	case rdf.Blank:
+		return resultBinding{Type: "bnode", Value: term.String()}
+	case rdf.Literal:
+		b := resultBinding{Type: "literal", Value: term.String(), Lang: term.Lang()}
+		if b.Lang == "" && term.DataType.String() != xsdString {
+			b.DataType = term.DataType.String()
+		}
+		return b
+	}
+	return resultBinding{}
+}
+
+// Transport answers SPARQL protocol requests from a store, which lets an HTTP
+// client query the store as if it were a remote endpoint.
+type Transport struct {
+	Store *Store
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var query string
+	if req.Method == "GET" {
+		query = req.URL.Query().Get("query")
+	} else if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		query = values.Get("query")
+	}
+
+	status := http.StatusOK
+	data, contentType, err := t.Store.Query(query)
+	if err != nil {
+		status, contentType = http.StatusBadRequest, "text/plain"
+		data = []byte(err.Error())
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package localstore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testData = `@prefix ex: <http://example.org/> .
+@prefix rdfs: <http://www.w3.org/2000/01/rdf-schema#> .
+
+ex:alice a ex:Person ;
+	rdfs:label "Alice"@en, "Alicia"@es ;
+	ex:age 34 ;
+	ex:knows ex:bob, ex:carol .
+
+ex:bob a ex:Person ;
+	rdfs:label "Bob"@en ;
+	ex:age 27 .
+
+ex:carol a ex:Person ;
+	ex:age 41 ;
+	ex:knows ex:alice .
+
+ex:book a ex:Book ;
+	rdfs:label "A book" .
+`
+
+func loadTestStore(t *testing.T) *Store {
+	path := filepath.Join(t.TempDir(), "data.ttl")
+	if err := os.WriteFile(path, []byte(testData), 0666); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// selectValues runs a SELECT query and returns the values of the variable in
+// each result.
+func selectValues(t *testing.T, store *Store, query string, variable string) []string {
+	data, _, err := store.Query(query)
+	if err != nil {
+		t.Fatalf("Failed to evaluate %q: %v", query, err)
+	}
+
+	var results selectResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	values := []string{}
+	for _, binding := range results.Results.Bindings {
+		values = append(values, binding[variable].Value)
+	}
+	return values
+}
+
+const prefixes = "PREFIX ex: <http://example.org/> PREFIX rdfs: <http://www.w3.org/2000/01/rdf-schema#> "
+
+func TestSelect(t *testing.T) {
+	store := loadTestStore(t)
+
+	var tests = []struct {
+		query    string
+		variable string
+		expected []string
+	}{
+		{"SELECT ?p WHERE { ?p a ex:Person } ORDER BY ?p", "p", []string{"http://example.org/alice", "http://example.org/bob", "http://example.org/carol"}},
+		{"SELECT ?label WHERE { ex:alice rdfs:label ?label FILTER(LANG(?label) = 'es') }", "label", []string{"Alicia"}},
+		{"SELECT ?p WHERE { ?p ex:age ?age FILTER(?age > 30) } ORDER BY DESC(?age)", "p", []string{"http://example.org/carol", "http://example.org/alice"}},
+		{"SELECT ?p ?label WHERE { ?p a ex:Person OPTIONAL { ?p rdfs:label ?label FILTER(LANGMATCHES(LANG(?label), 'en')) } } ORDER BY ?p", "label", []string{"Alice", "Bob", ""}},
+		{"SELECT ?x WHERE { { ex:alice ex:knows ?x } UNION { ?x ex:knows ex:alice } } ORDER BY ?x", "x", []string{"http://example.org/bob", "http://example.org/carol", "http://example.org/carol"}},
+		{"SELECT DISTINCT ?x WHERE { { ex:alice ex:knows ?x } UNION { ?x ex:knows ex:alice } } ORDER BY ?x", "x", []string{"http://example.org/bob", "http://example.org/carol"}},
+		{"SELECT ?p WHERE { ?p a ex:Person } ORDER BY ?p LIMIT 1 OFFSET 1", "p", []string{"http://example.org/bob"}},
+		{"SELECT (COUNT(?p) AS ?count) WHERE { ?p a ex:Person }", "count", []string{"3"}},
+		{"SELECT ?type (COUNT(*) AS ?count) WHERE { ?s a ?type } GROUP BY ?type ORDER BY DESC(?count)", "count", []string{"3", "1"}},
+		{"SELECT (SUM(?age) AS ?total) WHERE { ?p ex:age ?age }", "total", []string{"102"}},
+		{"SELECT ?next WHERE { ex:bob ex:age ?age BIND(?age + 1 AS ?next) }", "next", []string{"28"}},
+		{"SELECT ?p WHERE { ?p a ex:Person FILTER NOT EXISTS { ?p ex:knows ?someone } }", "p", []string{"http://example.org/bob"}},
+		{"SELECT ?p WHERE { VALUES ?p { ex:bob ex:book } ?p a ex:Person }", "p", []string{"http://example.org/bob"}},
+		{"SELECT ?p WHERE { ?p rdfs:label ?label FILTER(REGEX(?label, '^ali', 'i')) } ORDER BY ?label", "p", []string{"http://example.org/alice", "http://example.org/alice"}},
+		{"SELECT ?p WHERE { ?p ex:knows [] ; ex:age ?age FILTER(?age IN (27, 41)) }", "p", []string{"http://example.org/carol"}},
+		{"SELECT DISTINCT ?a WHERE { ?a ex:age ?x . ?b ex:age ?y . ?c ex:age ?z FILTER(?x<?y&&?z>40) } ORDER BY ?a", "a", []string{"http://example.org/alice", "http://example.org/bob"}},
+		{"SELECT ?p WHERE { ?p ex:age ?age FILTER(?age<30||?age>40) } ORDER BY ?p", "p", []string{"http://example.org/bob", "http://example.org/carol"}},
+		{"SELECT ?p WHERE { ex:alice ex:knows ?p FILTER(?p != <http://example.org/bob>) }", "p", []string{"http://example.org/carol"}},
+		{"SELECT ?type (COUNT(*) * 2 AS ?double) WHERE { ?s a ?type } GROUP BY ?type ORDER BY ?double", "double", []string{"2", "6"}},
+	}
+
+	for _, test := range tests {
+		values := selectValues(t, store, prefixes+test.query, test.variable)
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("Expected %q to return %v, got %v", test.query, test.expected, values)
+		}
+	}
+}
+
+func TestSelectBindingTypes(t *testing.T) {
+	store := loadTestStore(t)
+
+	data, _, err := store.Query(prefixes + "SELECT * WHERE { ex:bob ?p ?o } ORDER BY ?p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results selectResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results.Head.Vars, []string{"p", "o"}) {
+		t.Errorf("Expected SELECT * to project p and o, got %v", results.Head.Vars)
+	}
+
+	expected := []resultBinding{
+		{Type: "literal", Value: "27", DataType: "http://www.w3.org/2001/XMLSchema#integer"},
+		{Type: "uri", Value: "http://example.org/Person"},
+		{Type: "literal", Value: "Bob", Lang: "en"},
+	}
+	for i, binding := range results.Results.Bindings {
+		if binding["o"] != expected[i] {
+			t.Errorf("Expected binding %d to be %v, got %v", i, expected[i], binding["o"])
+		}
+	}
+}
+
+func TestAskAndConstruct(t *testing.T) {
+	store := loadTestStore(t)
+
+	data, _, err := store.Query(prefixes + "ASK { ex:bob ex:knows ?x }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != `{"head":{},"boolean":false}` {
+		t.Errorf("Expected ASK to be false, got %s", data)
+	}
+
+	data, contentType, err := store.Query(prefixes + "CONSTRUCT { ?x ex:knownBy ?p } WHERE { ?p ex:knows ?x }")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/n-triples" {
+		t.Errorf("Expected CONSTRUCT to return N-Triples, got %s", contentType)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 3 {
+		t.Errorf("Expected CONSTRUCT to return 3 triples, got %d:\n%s", lines, data)
+	}
+	if !strings.Contains(string(data), "<http://example.org/bob> <http://example.org/knownBy> <http://example.org/alice> .") {
+		t.Errorf("Expected CONSTRUCT to return the inverse triples, got:\n%s", data)
+	}
+}
+
+func TestUnsupportedQueries(t *testing.T) {
+	store := loadTestStore(t)
+
+	for _, query := range []string{
+		"SELECT * WHERE { ?s ?p ?o",
+		"SELECT * WHERE { ?s undeclared:p ?o }",
+		"SELECT * WHERE { SERVICE <http://example.org/sparql> { ?s ?p ?o } }",
+		"SELECT * WHERE { { SELECT ?s WHERE { ?s ?p ?o } } }",
+		"SELECT * WHERE { ?s ?p ?o FILTER(UNKNOWN(?o)) }",
+		"SELECT ?p (COUNT(?x) AS ?count) WHERE { ?p ex:knows ?x }",
+		"SELECT ?type ?s WHERE { ?s a ?type } GROUP BY ?type",
+		"SELECT (?age + COUNT(?p) AS ?n) WHERE { ?p ex:age ?age }",
+		"SELECT * WHERE { ?s a ?type } GROUP BY ?type",
+	} {
+		if _, _, err := store.Query(query); err == nil {
+			t.Errorf("Expected %q to fail", query)
+		}
+	}
+}
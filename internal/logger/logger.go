@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+var levelNames = map[string]Level{
+	"debug": DebugLevel,
+	"info":  InfoLevel,
+	"warn":  WarnLevel,
+	"error": ErrorLevel,
+}
+
+var mutex sync.Mutex
+var currentLevel = InfoLevel
+
+// status is the line shown below the messages, see SetStatus
+var status string
+
+// output receives all messages but errors, which go to stderr
+var output io.Writer = os.Stdout
+
+// ParseLevel returns the level of the given name.
+func ParseLevel(name string) (Level, error) {
+	level, exists := levelNames[strings.ToLower(name)]
+	if !exists {
+		return InfoLevel, errors.New("Unknown log level " + name + ". Use debug, info, warn or error.")
+	}
+	return level, nil
+}
+
+// SetLevel sets the lowest level of messages that are printed.
+func SetLevel(level Level) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	currentLevel = level
+}
+
+// Enabled reports whether messages of the given level are printed.
+func Enabled(level Level) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return level >= currentLevel
+}
+
+// SetOutput sets where messages other than errors are printed, which is
+// stdout by default.
+func SetOutput(w io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	output = w
+}
+
+// Output returns where messages other than errors are printed, for output
+// that should follow the messages.
+func Output() io.Writer {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return output
+}
+
+// SetStatus shows a line below the printed messages, which is replaced by the
+// next status. It's meant for terminals and an empty status clears the line.
+func SetStatus(line string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if status != "" || line != "" {
+		fmt.Fprint(output, "\r\033[K"+line)
+	}
+	status = line
+}
+
+func print(level Level, prefix string, message string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if level < currentLevel {
+		return
+	}
+
+	// the status line is cleared and shown again below the message
+	if status != "" {
+		fmt.Fprint(output, "\r\033[K")
+	}
+
+	// errors go to stderr so that they remain visible when the output is
+	// discarded
+	out := output
+	if level == ErrorLevel {
+		out = os.Stderr
+	}
+	fmt.Fprintln(out, prefix+message)
+
+	if status != "" {
+		fmt.Fprint(output, status)
+	}
+}
+
+func Debug(message string) {
+	print(DebugLevel, "", message)
+}
+
+func Info(message string) {
+	print(InfoLevel, "", message)
+}
+
+func Warn(message string) {
+	print(WarnLevel, "Warning: ", message)
+}
+
+func Error(message string) {
+	print(ErrorLevel, "Error: ", message)
+}
@@ -0,0 +1,50 @@
+// Package manifest writes a list of the pages of a build and the views that
+// rendered them, for auditing builds and for downstream tools.
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const FileName = ".snowman-manifest.json"
+
+// Page is a file written by a view.
+type Page struct {
+	// Path is relative to the site directory and uses forward slashes
+	Path string `json:"path"`
+	// View is the output of the view as configured in views.yaml
+	View  string `json:"view"`
+	Query string `json:"query,omitempty"`
+	// Bindings are the values of the variables in the output of multipage
+	// views
+	Bindings map[string]string `json:"bindings,omitempty"`
+}
+
+type manifest struct {
+	Pages []Page `json:"pages"`
+}
+
+// Write writes the manifest of the given pages, sorted by path, to FileName in
+// the site directory. The paths of the pages start with the site directory.
+func Write(siteDir string, pages []Page) error {
+	normalized := make([]Page, 0, len(pages))
+	for _, page := range pages {
+		if relativePath, err := filepath.Rel(siteDir, page.Path); err == nil {
+			page.Path = relativePath
+		}
+		page.Path = filepath.ToSlash(filepath.Clean(page.Path))
+		normalized = append(normalized, page)
+	}
+	sort.SliceStable(normalized, func(i, j int) bool {
+		return normalized[i].Path < normalized[j].Path
+	})
+
+	data, err := json.MarshalIndent(manifest{Pages: normalized}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(siteDir, FileName), append(data, '\n'), 0644)
+}
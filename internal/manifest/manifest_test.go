@@ -0,0 +1,56 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	siteDir := filepath.Join(t.TempDir(), "site")
+	if err := os.Mkdir(siteDir, 0770); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := []Page{
+		{Path: filepath.Join(siteDir, "works", "2.html"), View: "works/{{id}}.html", Query: "works.rq", Bindings: map[string]string{"id": "2"}},
+		{Path: siteDir + "/index.html", View: "index.html"},
+		{Path: filepath.Join(siteDir, "works", "1.html"), View: "works/{{id}}.html", Query: "works.rq", Bindings: map[string]string{"id": "1"}},
+	}
+	if err := Write(siteDir, pages); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(siteDir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "pages": [
+    {
+      "path": "index.html",
+      "view": "index.html"
+    },
+    {
+      "path": "works/1.html",
+      "view": "works/{{id}}.html",
+      "query": "works.rq",
+      "bindings": {
+        "id": "1"
+      }
+    },
+    {
+      "path": "works/2.html",
+      "view": "works/{{id}}.html",
+      "query": "works.rq",
+      "bindings": {
+        "id": "2"
+      }
+    }
+  ]
+}
+`
+	if string(data) != expected {
+		t.Errorf("Unexpected manifest:\n%s", data)
+	}
+}
@@ -0,0 +1,149 @@
+package meta
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+const FileName = ".snowman-meta.json"
+
+// File holds the headers suggested for serving a file of the site.
+type File struct {
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	CacheControl    string `json:"cache_control,omitempty"`
+}
+
+// contentTypes maps the extensions of the files of a site to their media
+// type. The table is fixed, rather than the one of the system, so that builds
+// describe files the same on every machine.
+var contentTypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".htm":         "text/html; charset=utf-8",
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".jsonld":      "application/ld+json",
+	".webmanifest": "application/manifest+json",
+	".map":         "application/json",
+	".xml":         "application/xml",
+	".rss":         "application/rss+xml",
+	".atom":        "application/atom+xml",
+	".rdf":         "application/rdf+xml",
+	".ttl":         "text/turtle; charset=utf-8",
+	".nt":          "application/n-triples",
+	".nq":          "application/n-quads",
+	".trig":        "application/trig",
+	".txt":         "text/plain; charset=utf-8",
+	".md":          "text/markdown; charset=utf-8",
+	".csv":         "text/csv; charset=utf-8",
+	".tsv":         "text/tab-separated-values; charset=utf-8",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".gif":         "image/gif",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".ico":         "image/x-icon",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".pdf":         "application/pdf",
+	".zip":         "application/zip",
+	".wasm":        "application/wasm",
+	".mp3":         "audio/mpeg",
+	".mp4":         "video/mp4",
+	".webm":        "video/webm",
+}
+
+// encodings maps the extensions of pre-compressed files to their encoding.
+var encodings = map[string]string{
+	".gz": "gzip",
+	".br": "br",
+}
+
+// matches reports whether the pattern matches the path. Patterns without a
+// slash match the file name in any directory.
+func matches(pattern string, relativePath string) (bool, error) {
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(relativePath))
+	}
+	return path.Match(pattern, relativePath)
+}
+
+// describe returns the headers of the file at the given path relative to the
+// site directory. Fingerprinted files get the fingerprinted cache control,
+// others that of the first matching rule.
+func describe(metaConfig config.MetaConfig, relativePath string, fingerprinted map[string]bool) (File, error) {
+	var file File
+
+	// pre-compressed copies are described like the original file
+	original := relativePath
+	if encoding, exists := encodings[path.Ext(relativePath)]; exists {
+		file.ContentEncoding = encoding
+		original = strings.TrimSuffix(relativePath, path.Ext(relativePath))
+	}
+	file.ContentType = contentTypes[strings.ToLower(path.Ext(original))]
+
+	if fingerprinted[original] {
+		file.CacheControl = metaConfig.Fingerprinted
+	}
+	for _, rule := range metaConfig.Rules {
+		matched, err := matches(rule.Pattern, original)
+		if err != nil {
+			return file, err
+		}
+		if !matched {
+			continue
+		}
+		if file.CacheControl == "" {
+			file.CacheControl = rule.CacheControl
+		}
+		if rule.ContentType != "" {
+			file.ContentType = rule.ContentType
+		}
+		break
+	}
+	return file, nil
+}
+
+// Write writes a manifest of every file in the site directory with the
+// headers suggested for serving it. Fingerprinted holds the paths of
+// fingerprinted files relative to the site directory.
+func Write(siteDir string, metaConfig config.MetaConfig, fingerprinted map[string]bool) error {
+	files := map[string]File{}
+	err := filepath.Walk(siteDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(siteDir, file)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if relativePath == FileName {
+			return nil
+		}
+
+		files[relativePath], err = describe(metaConfig, relativePath, fingerprinted)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(siteDir, FileName), data, 0666)
+}
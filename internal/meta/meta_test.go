@@ -0,0 +1,43 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+func TestDescribe(t *testing.T) {
+	metaConfig := config.MetaConfig{
+		Rules: []config.MetaRule{
+			{Pattern: "*.html", CacheControl: "max-age=300"},
+			{Pattern: "data/*", CacheControl: "no-cache", ContentType: "text/turtle"},
+			{Pattern: "*", CacheControl: "max-age=3600"},
+		},
+		Fingerprinted: "immutable",
+	}
+	fingerprinted := map[string]bool{"css/main.1a2b3c.css": true}
+
+	var tests = []struct {
+		path     string
+		expected File
+	}{
+		{"works/Q1.html", File{ContentType: "text/html; charset=utf-8", CacheControl: "max-age=300"}},
+		{"works/Q1.html.gz", File{ContentType: "text/html; charset=utf-8", ContentEncoding: "gzip", CacheControl: "max-age=300"}},
+		{"css/main.1a2b3c.css", File{ContentType: "text/css; charset=utf-8", CacheControl: "immutable"}},
+		{"css/main.1a2b3c.css.br", File{ContentType: "text/css; charset=utf-8", ContentEncoding: "br", CacheControl: "immutable"}},
+		{"data/works", File{ContentType: "text/turtle", CacheControl: "no-cache"}},
+		{"robots.txt", File{ContentType: "text/plain; charset=utf-8", CacheControl: "max-age=3600"}},
+		{"img/Photo.JPG", File{ContentType: "image/jpeg", CacheControl: "max-age=3600"}},
+		{"works/Q1.unknown", File{CacheControl: "max-age=3600"}},
+	}
+
+	for _, test := range tests {
+		file, err := describe(metaConfig, test.path, fingerprinted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file != test.expected {
+			t.Errorf("Expected %s to be described as %+v, got %+v", test.path, test.expected, file)
+		}
+	}
+}
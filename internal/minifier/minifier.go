@@ -0,0 +1,116 @@
+package minifier
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+)
+
+// Result summarises a minification run.
+type Result struct {
+	Files      int
+	BytesSaved int64
+}
+
+var mediaTypes = map[string]string{
+	".html": "text/html",
+	".htm":  "text/html",
+	".css":  "text/css",
+	".js":   "application/javascript",
+}
+
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepQuotes: true})
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	return m
+}
+
+// Directory minifies the HTML, CSS and JS files in dir in place. Files that
+// fail to parse are reported as warnings and left untouched.
+func Directory(dir string, jobs int) (Result, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && mediaTypes[strings.ToLower(filepath.Ext(path))] != "" {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	m := newMinifier()
+	var mutex sync.Mutex
+	var result Result
+	var firstErr error
+	queue := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				saved, err := minifyFile(m, path)
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if saved > 0 {
+					result.Files++
+					result.BytesSaved += saved
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- path
+	}
+	close(queue)
+	wg.Wait()
+
+	return result, firstErr
+}
+
+// minifyFile minifies a file in place and returns the bytes saved.
+func minifyFile(m *minify.M, path string) (int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	if err := m.Minify(mediaTypes[strings.ToLower(filepath.Ext(path))], &buf, bytes.NewReader(content)); err != nil {
+		logger.Warn("Failed to minify " + path + ", leaving it as is. " + err.Error())
+		return 0, nil
+	}
+
+	if buf.Len() >= len(content) {
+		return 0, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	return int64(len(content) - buf.Len()), nil
+}
@@ -0,0 +1,47 @@
+package minifier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html": "<html>\n  <body>\n    <p>  Snowman   </p>\n  </body>\n</html>\n",
+		"app.css":    "body {\n  color: red;\n}\n",
+		"broken.js":  "function ( {\n",
+		"data.json":  "{\n  \"a\": 1\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Directory(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 2 || result.BytesSaved <= 0 {
+		t.Errorf("Expected 2 minified files saving bytes, got %+v", result)
+	}
+
+	expected := map[string]string{
+		"index.html": "<html><body><p>Snowman</p></body></html>",
+		"app.css":    "body{color:red}",
+		// files failing to parse and other types are left untouched
+		"broken.js": files["broken.js"],
+		"data.json": files["data.json"],
+	}
+	for name, content := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, got)
+		}
+	}
+}
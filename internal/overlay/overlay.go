@@ -0,0 +1,110 @@
+// Package overlay writes the error pages shown in place of pages failing to
+// render while developing a site.
+package overlay
+
+import (
+	"bufio"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Error is a failure shown on an error page.
+type Error struct {
+	// View is the output of the failing view
+	View string
+	// Source describes what failed, like the row a page was rendered for
+	Source string
+	// Messages are the messages of the error and the errors causing it,
+	// outermost first
+	Messages []string
+	// File is the template the error points at, and Lines are the lines
+	// around the failing one
+	File  string
+	Lines []Line
+}
+
+// Line is a line of the template an error points at.
+type Line struct {
+	Number  int
+	Text    string
+	Failing bool
+}
+
+var templatePosition = regexp.MustCompile(`template: ([^:\s]+):(\d+)`)
+
+// Position returns the name of the template and the line the messages of a
+// template error point at, or no name if they don't point at any.
+func Position(messages []string) (string, int) {
+	for _, message := range messages {
+		if match := templatePosition.FindStringSubmatch(message); match != nil {
+			line, _ := strconv.Atoi(match[2])
+			return match[1], line
+		}
+	}
+	return "", 0
+}
+
+// Context returns the lines of the file around the given line.
+func Context(file string, line int) []Line {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []Line
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		if number >= line-3 && number <= line+3 {
+			lines = append(lines, Line{Number: number, Text: scanner.Text(), Failing: number == line})
+		}
+	}
+	return lines
+}
+
+var page = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Error in view {{ .View }}</title>
+<style>
+body { margin: 0; background: #1e1e1e; color: #eee; font: 15px/1.5 system-ui, sans-serif; }
+main { max-width: 60rem; margin: 3rem auto; padding: 0 1.5rem; }
+h1 { color: #ff6b6b; font-size: 1.4rem; }
+pre { background: #111; padding: 1rem; overflow-x: auto; border-left: 3px solid #ff6b6b; }
+.failing { color: #ff6b6b; font-weight: bold; }
+</style>
+</head>
+<body>
+<main>
+<h1>Failed to build view {{ .View }}</h1>
+<p>{{ .Source }}</p>
+<pre>{{ range .Messages }}{{ . }}
+{{ end }}</pre>
+{{ if .Lines }}<p>{{ .File }}</p>
+<pre>{{ range .Lines }}<span{{ if .Failing }} class="failing"{{ end }}>{{ printf "%4d" .Number }} | {{ .Text }}</span>
+{{ end }}</pre>{{ end }}
+<p>The page is rebuilt when you change the project.</p>
+</main>
+</body>
+</html>
+`))
+
+// Write writes the error page of the error to path.
+func Write(path string, e Error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := page.Execute(f, e); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,40 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "item.html")
+	if err := os.WriteFile(template, []byte("<h1>\n{{ .label }}\n{{ broken .label }}\n</h1>\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := []string{"Failed to render page at site/items/1.html", `template: item.html:3:3: executing "item.html" at <broken .label>: error calling broken`}
+	name, line := Position(messages)
+	if name != "item.html" || line != 3 {
+		t.Fatalf("Expected item.html line 3, got %s line %d", name, line)
+	}
+	lines := Context(template, line)
+	if len(lines) != 4 || !lines[2].Failing || lines[2].Text != "{{ broken .label }}" {
+		t.Errorf("Unexpected context %v", lines)
+	}
+
+	path := filepath.Join(dir, "site", "items", "1.html")
+	if err := Write(path, Error{View: "items/{{id}}.html", Source: `view items/{{id}}.html with id "1"`, Messages: messages, File: name, Lines: lines}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"Failed to build view items/{{id}}.html", "at &lt;broken .label&gt;", `<span class="failing">   3 | {{ broken .label }}</span>`, "</body>"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected the error page to contain %s, got %s", expected, content)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package progress shows how far a build has come on the status line of the
+// terminal.
+package progress
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+)
+
+// refreshInterval is how often the elapsed time is updated.
+const refreshInterval = 200 * time.Millisecond
+
+// Progress tracks the views rendered out of the total. Its methods are safe
+// for concurrent use by render workers and do nothing on a nil Progress, so
+// that callers don't have to check whether progress is shown.
+type Progress struct {
+	mutex   sync.Mutex
+	total   int
+	done    int
+	current []string
+	start   time.Time
+	stop    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// IsTerminal reports whether the file is a terminal rather than, for example,
+// a pipe or a regular file.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0 && f.Name() != os.DevNull
+}
+
+// Start shows the progress of rendering the given number of views until Stop
+// is called.
+func Start(total int) *Progress {
+	p := &Progress{total: total, start: time.Now(), stop: make(chan struct{})}
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.show()
+			}
+		}
+	}()
+	p.show()
+	return p
+}
+
+// Begin marks the view as being rendered.
+func (p *Progress) Begin(view string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	p.current = append(p.current, view)
+	p.mutex.Unlock()
+	p.show()
+}
+
+// Done marks the view as rendered.
+func (p *Progress) Done(view string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	for i, current := range p.current {
+		if current == view {
+			p.current = append(p.current[:i], p.current[i+1:]...)
+			break
+		}
+	}
+	p.done++
+	p.mutex.Unlock()
+	p.show()
+}
+
+// Stop removes the progress from the terminal.
+func (p *Progress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	p.stopped.Wait()
+	logger.SetStatus("")
+}
+
+func (p *Progress) show() {
+	p.mutex.Lock()
+	line := p.line()
+	p.mutex.Unlock()
+	logger.SetStatus(line)
+}
+
+// line formats the progress, like "[12/340] 3.2s items/{{id}}.html". The
+// view shown is the one which has been rendering the longest.
+func (p *Progress) line() string {
+	elapsed := time.Since(p.start).Truncate(100 * time.Millisecond)
+	line := "[" + strconv.Itoa(p.done) + "/" + strconv.Itoa(p.total) + "] " + elapsed.String()
+	if len(p.current) > 0 {
+		line += " " + p.current[0]
+		if len(p.current) > 1 {
+			line += " (+" + strconv.Itoa(len(p.current)-1) + ")"
+		}
+	}
+	return truncate(line, width()-1)
+}
+
+// width returns the width of the terminal as set in COLUMNS, or 80. Lines
+// wider than the terminal would wrap and leave the cleared status behind.
+func width() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 1 {
+		return columns
+	}
+	return 80
+}
+
+func truncate(line string, length int) string {
+	runes := []rune(line)
+	if len(runes) <= length {
+		return line
+	}
+	return strings.TrimSpace(string(runes[:length-1])) + "…"
+}
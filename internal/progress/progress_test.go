@@ -0,0 +1,72 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+)
+
+func TestLine(t *testing.T) {
+	t.Setenv("COLUMNS", "40")
+
+	var tests = []struct {
+		done     int
+		current  []string
+		expected string
+	}{
+		{0, nil, "[0/3] 0s"},
+		{1, []string{"index.html"}, "[1/3] 0s index.html"},
+		{1, []string{"items/{{id}}.html", "index.html"}, "[1/3] 0s items/{{id}}.html (+1)"},
+		{2, []string{"a/very/long/path/of/a/view/{{id}}.html"}, "[2/3] 0s a/very/long/path/of/a/view/{{…"},
+	}
+
+	for _, test := range tests {
+		p := &Progress{total: 3, done: test.done, current: test.current, start: time.Now()}
+		if line := p.line(); line != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, line)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var output bytes.Buffer
+	logger.SetOutput(&output)
+	defer logger.SetOutput(os.Stdout)
+
+	p := Start(2)
+	p.Begin("index.html")
+	p.Done("index.html")
+	p.Begin("items/{{id}}.html")
+	p.Done("items/{{id}}.html")
+	p.Stop()
+
+	if !strings.Contains(output.String(), "[1/2]") || !strings.Contains(output.String(), "[2/2]") {
+		t.Errorf("Expected the status to show each view rendered, got %q", output.String())
+	}
+	if !strings.HasSuffix(output.String(), "\r\033[K") {
+		t.Errorf("Expected the status to be cleared when stopping, got %q", output.String())
+	}
+
+	// a nil progress shows nothing
+	var none *Progress
+	none.Begin("index.html")
+	none.Done("index.html")
+	none.Stop()
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "log.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if IsTerminal(f) {
+		t.Error("Expected a regular file not to be a terminal")
+	}
+}
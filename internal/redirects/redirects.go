@@ -0,0 +1,99 @@
+// Package redirects writes the redirects of a site as pages sending visitors
+// on to their destination, or as a redirects file for hosts like Netlify.
+package redirects
+
+import (
+	"errors"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/basepath"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+// NetlifyFile is the name of the redirects file of the netlify format.
+const NetlifyFile = "_redirects"
+
+// pagePath returns the path of the page redirecting from the given path of
+// the site, relative to the site directory. Paths without an extension are
+// directories. Paths leading outside of the site directory or having invalid
+// sections are rejected.
+func pagePath(from string) (string, error) {
+	from = strings.TrimPrefix(from, "/")
+	if sections := strings.TrimSuffix(from, "/"); sections != "" {
+		for _, section := range strings.Split(sections, "/") {
+			if err := utils.ValidatePathSection(section); err != nil {
+				return "", utils.ErrorExit("The redirect from /"+from+" leads outside of the site directory or has an invalid section.", err)
+			}
+		}
+	}
+
+	if from == "" || strings.HasSuffix(from, "/") || path.Ext(from) == "" {
+		return path.Join(from, "index.html"), nil
+	}
+	return from, nil
+}
+
+// page returns a page sending visitors on to the destination.
+func page(destination string) []byte {
+	escaped := html.EscapeString(destination)
+	return []byte(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Redirecting to ` + escaped + `</title>
+<link rel="canonical" href="` + escaped + `">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url=` + escaped + `">
+</head>
+<body>
+<p>This page has moved to <a href="` + escaped + `">` + escaped + `</a>.</p>
+</body>
+</html>
+`)
+}
+
+// Write writes the redirects to the site directory in the given format, html
+// or netlify. The paths of the site are taken to be under the base path. A
+// redirect can't replace one of the given files, the pages and static files
+// within the site directory.
+func Write(siteDir string, basePath string, format string, rules []config.Redirect, files []string) error {
+	if format == "netlify" {
+		var lines []string
+		for _, rule := range rules {
+			lines = append(lines, basepath.Prefix(basePath, rule.From)+" "+basepath.Prefix(basePath, rule.To)+" "+strconv.Itoa(rule.Status))
+		}
+		return os.WriteFile(filepath.Join(siteDir, NetlifyFile), []byte(strings.Join(lines, "\n")+"\n"), 0666)
+	}
+
+	written := map[string]bool{}
+	for _, file := range files {
+		if relativePath, err := filepath.Rel(siteDir, file); err == nil {
+			written[filepath.ToSlash(relativePath)] = true
+		}
+	}
+
+	for _, rule := range rules {
+		relativePath, err := pagePath(rule.From)
+		if err != nil {
+			return err
+		}
+		if written[relativePath] {
+			return errors.New("The redirect from " + rule.From + " would replace the file " + relativePath + ".")
+		}
+
+		file := filepath.Join(siteDir, filepath.FromSlash(relativePath))
+		if err := os.MkdirAll(filepath.Dir(file), 0770); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, page(basepath.Prefix(basePath, rule.To)), 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package redirects
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+func TestPagePath(t *testing.T) {
+	var tests = []struct {
+		from     string
+		expected string
+	}{
+		{"/old.html", "old.html"},
+		{"/old/", "old/index.html"},
+		{"/old", "old/index.html"},
+		{"/", "index.html"},
+		{"/works/q1.html", "works/q1.html"},
+	}
+	for _, test := range tests {
+		if relativePath, err := pagePath(test.from); err != nil || relativePath != test.expected {
+			t.Errorf("Expected %s for %s, got %s (%v)", test.expected, test.from, relativePath, err)
+		}
+	}
+
+	for _, from := range []string{"/../escaped.html", "/old/../../escaped", "/a//b.html", "/./index.html", "/.."} {
+		if _, err := pagePath(from); err == nil {
+			t.Errorf("Expected an error for the redirect from %s", from)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	rules := []config.Redirect{
+		{From: "/old/", To: "/new/", Status: 301},
+		{From: "/elsewhere.html", To: "https://example.org/?a=1&b=2", Status: 302},
+	}
+
+	if err := Write(dir, "/project", "html", rules, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "old", "index.html"))
+	if !strings.Contains(string(content), `content="0; url=/project/new/"`) {
+		t.Errorf("Unexpected redirect page %s", content)
+	}
+	content, _ = os.ReadFile(filepath.Join(dir, "elsewhere.html"))
+	if !strings.Contains(string(content), `href="https://example.org/?a=1&amp;b=2"`) {
+		t.Errorf("Expected the escaped URL in %s", content)
+	}
+
+	if err := Write(dir, "", "html", rules, []string{filepath.Join(dir, "old", "index.html")}); err == nil {
+		t.Error("Expected an error for a redirect replacing a page")
+	}
+	if err := Write(dir, "", "html", rules, []string{filepath.Join(dir, "elsewhere.html")}); err == nil {
+		t.Error("Expected an error for a redirect replacing a static file")
+	}
+
+	if err := Write(dir, "/project", "netlify", rules, nil); err != nil {
+		t.Fatal(err)
+	}
+	content, _ = os.ReadFile(filepath.Join(dir, NetlifyFile))
+	if string(content) != "/project/old/ /project/new/ 301\n/project/elsewhere.html https://example.org/?a=1&b=2 302\n" {
+		t.Errorf("Unexpected redirects file %q", content)
+	}
+}
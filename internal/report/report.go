@@ -0,0 +1,192 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+// View holds the metrics of a single view. A view is only ever rendered by
+// one worker so its fields can be updated without locking.
+type View struct {
+	Output         string
+	Query          string
+	QueryDuration  time.Duration
+	RenderDuration time.Duration
+	Pages          int
+}
+
+func (v *View) Total() time.Duration {
+	return v.QueryDuration + v.RenderDuration
+}
+
+// Report collects metrics during a build.
+type Report struct {
+	mutex       sync.Mutex
+	Start       time.Time
+	Views       []*View
+	StaticFiles []string
+	StaticBytes int64
+	// CompressedFiles and CompressionSaved are only set when compressing
+	CompressedFiles  int
+	CompressionSaved int64
+	// MinifiedFiles and MinifySaved are only set when minifying
+	MinifiedFiles int
+	MinifySaved   int64
+	// DeduplicatedQueries is only set when sharing query results
+	DeduplicatedQueries int
+	// PagePaths holds the paths of all pages once rendering has finished
+	PagePaths []string
+}
+
+func NewReport() *Report {
+	return &Report{Start: time.Now()}
+}
+
+// NewView adds and returns the metrics of a view about to be rendered.
+func (r *Report) NewView(output string, query string) *View {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	view := View{Output: output, Query: query}
+	r.Views = append(r.Views, &view)
+	return &view
+}
+
+func (r *Report) Pages() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pages := 0
+	for _, view := range r.Views {
+		pages += view.Pages
+	}
+	return pages
+}
+
+// Print writes a summary of the build, with the slowest views first.
+func (r *Report) Print(w io.Writer) {
+	r.mutex.Lock()
+	views := append([]*View(nil), r.Views...)
+	r.mutex.Unlock()
+
+	sort.SliceStable(views, func(i, j int) bool {
+		return views[i].Total() > views[j].Total()
+	})
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VIEW\tQUERY\tPAGES\tQUERYING\tRENDERING\tTOTAL")
+	for _, view := range views {
+		fmt.Fprintln(table, view.Output+"\t"+view.Query+"\t"+strconv.Itoa(view.Pages)+"\t"+round(view.QueryDuration)+"\t"+round(view.RenderDuration)+"\t"+round(view.Total()))
+	}
+	table.Flush()
+
+	fmt.Fprintln(w, "Views: "+strconv.Itoa(len(views))+", pages written: "+strconv.Itoa(r.Pages())+", static files copied: "+strconv.Itoa(len(r.StaticFiles))+" ("+strconv.FormatInt(r.StaticBytes, 10)+" bytes)"+minified(r)+compressed(r)+deduplicated(r)+", wall time: "+round(time.Since(r.Start))+".")
+}
+
+type jsonView struct {
+	Output        string  `json:"output"`
+	Query         string  `json:"query,omitempty"`
+	Pages         int     `json:"pages"`
+	QuerySeconds  float64 `json:"query_seconds"`
+	RenderSeconds float64 `json:"render_seconds"`
+}
+
+type jsonError struct {
+	Message string   `json:"message"`
+	Causes  []string `json:"causes"`
+}
+
+type jsonReport struct {
+	Success          bool        `json:"success"`
+	Views            []jsonView  `json:"views"`
+	Pages            []string    `json:"pages"`
+	StaticFiles      []string    `json:"static_files"`
+	StaticBytes      int64       `json:"static_bytes"`
+	CompressedFiles  int         `json:"compressed_files"`
+	CompressionSaved int64       `json:"compression_saved_bytes"`
+	MinifiedFiles    int         `json:"minified_files"`
+	MinifySaved      int64       `json:"minify_saved_bytes"`
+	Deduplicated     int         `json:"deduplicated_queries"`
+	Seconds          float64     `json:"seconds"`
+	Errors           []jsonError `json:"errors"`
+}
+
+// WriteJSON writes the report, and the error that ended the build if any, as
+// JSON.
+func (r *Report) WriteJSON(w io.Writer, buildErr error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	out := jsonReport{
+		Success:          buildErr == nil,
+		Views:            []jsonView{},
+		Pages:            r.PagePaths,
+		StaticFiles:      r.StaticFiles,
+		StaticBytes:      r.StaticBytes,
+		CompressedFiles:  r.CompressedFiles,
+		CompressionSaved: r.CompressionSaved,
+		MinifiedFiles:    r.MinifiedFiles,
+		MinifySaved:      r.MinifySaved,
+		Deduplicated:     r.DeduplicatedQueries,
+		Seconds:          time.Since(r.Start).Seconds(),
+		Errors:           []jsonError{},
+	}
+	if out.Pages == nil {
+		out.Pages = []string{}
+	}
+	if out.StaticFiles == nil {
+		out.StaticFiles = []string{}
+	}
+
+	for _, view := range r.Views {
+		out.Views = append(out.Views, jsonView{
+			Output:        view.Output,
+			Query:         view.Query,
+			Pages:         view.Pages,
+			QuerySeconds:  view.QueryDuration.Seconds(),
+			RenderSeconds: view.RenderDuration.Seconds(),
+		})
+	}
+
+	if buildErr != nil {
+		messages := utils.ErrorMessages(buildErr)
+		out.Errors = append(out.Errors, jsonError{Message: buildErr.Error(), Causes: messages})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+func minified(r *Report) string {
+	if r.MinifiedFiles == 0 {
+		return ""
+	}
+	return ", files minified: " + strconv.Itoa(r.MinifiedFiles) + " (" + strconv.FormatInt(r.MinifySaved, 10) + " bytes saved)"
+}
+
+func deduplicated(r *Report) string {
+	if r.DeduplicatedQueries == 0 {
+		return ""
+	}
+	return ", queries deduplicated: " + strconv.Itoa(r.DeduplicatedQueries)
+}
+
+func compressed(r *Report) string {
+	if r.CompressedFiles == 0 {
+		return ""
+	}
+	return ", compressed files written: " + strconv.Itoa(r.CompressedFiles) + " (" + strconv.FormatInt(r.CompressionSaved, 10) + " bytes saved)"
+}
+
+func round(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,78 @@
+// Package search writes an index of the pages of a site for client-side
+// search libraries like Lunr, MiniSearch or Fuse.js.
+package search
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/glaciers-in-archives/snowman/internal/basepath"
+)
+
+// URLField is the field of every document holding the URL of its page.
+const URLField = "url"
+
+// Document is a page in the search index, mapping the fields of the index to
+// their values.
+type Document map[string]string
+
+// Index collects the documents of the pages of each view as they're rendered.
+type Index struct {
+	mutex     sync.Mutex
+	documents map[string][]Document
+}
+
+func NewIndex() *Index {
+	return &Index{documents: make(map[string][]Document)}
+}
+
+// Add adds the document of a page of the view.
+func (i *Index) Add(view string, document Document) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.documents[view] = append(i.documents[view], document)
+}
+
+// Of returns the documents of the pages of the view.
+func (i *Index) Of(view string) []Document {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]Document{}, i.documents[view]...)
+}
+
+// Documents returns the documents of all views sorted by their URLs.
+func (i *Index) Documents() []Document {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	documents := []Document{}
+	for _, viewDocuments := range i.documents {
+		documents = append(documents, viewDocuments...)
+	}
+	sort.SliceStable(documents, func(a, b int) bool {
+		return documents[a][URLField] < documents[b][URLField]
+	})
+	return documents
+}
+
+// URL returns the URL of the page at the path relative to the site directory,
+// under the base path. Directory indices are linked as the directory.
+func URL(relativePath string, basePath string) string {
+	relativePath = path.Clean("/" + relativePath)
+	if path.Base(relativePath) == "index.html" {
+		relativePath = strings.TrimSuffix(relativePath, "index.html")
+	}
+	return basepath.Prefix(basePath, relativePath)
+}
+
+// Write writes the documents as a JSON array to the file.
+func Write(file string, documents []Document) error {
+	data, err := json.Marshal(documents)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
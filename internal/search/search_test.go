@@ -0,0 +1,37 @@
+package search
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestURL(t *testing.T) {
+	tests := map[string]string{
+		"works/1.html":       "/works/1.html",
+		"works/1/index.html": "/works/1/",
+		"index.html":         "/",
+	}
+	for relativePath, expected := range tests {
+		if url := URL(relativePath, ""); url != expected {
+			t.Errorf("Expected %s for %s, got %s", expected, relativePath, url)
+		}
+	}
+	if url := URL("works/1.html", "/project"); url != "/project/works/1.html" {
+		t.Errorf("Expected the URL under the base path, got %s", url)
+	}
+}
+
+func TestIndexDocuments(t *testing.T) {
+	index := NewIndex()
+	index.Add("works/{{id}}.html", Document{URLField: "/works/2.html", "title": "Two"})
+	index.Add("people/{{id}}.html", Document{URLField: "/people/1.html", "title": "One"})
+	index.Add("works/{{id}}.html", Document{URLField: "/works/1.html", "title": "One"})
+
+	expected := "[map[title:One url:/people/1.html] map[title:One url:/works/1.html] map[title:Two url:/works/2.html]]"
+	if documents := fmt.Sprint(index.Documents()); documents != expected {
+		t.Errorf("Expected %s, got %s", expected, documents)
+	}
+	if documents := index.Of("works/{{id}}.html"); len(documents) != 2 {
+		t.Errorf("Expected the two documents of the view, got %v", documents)
+	}
+}
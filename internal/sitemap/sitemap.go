@@ -0,0 +1,96 @@
+package sitemap
+
+import (
+	"encoding/xml"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const FileName = "sitemap.xml"
+
+type urlEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	URLs    []urlEntry `xml:"url"`
+}
+
+// excluded reports whether the path, or any of its parent directories,
+// matches one of the glob patterns.
+func excluded(relativePath string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		for p := relativePath; p != "." && p != "/"; p = path.Dir(p) {
+			matched, err := path.Match(pattern, p)
+			if err != nil {
+				return false, err
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Write writes a sitemap listing the HTML pages among the given pages to the
+// site directory. Pages are paths within the site directory and are linked
+// relative to baseURL. The
+// pages were last modified at lastMod if it's set, otherwise at the
+// modification time of their files.
+func Write(siteDir string, baseURL string, pages []string, exclude []string, lastMod *time.Time) error {
+	sitemap := urlSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+
+	for _, page := range pages {
+		relativePath, err := filepath.Rel(siteDir, page)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if extension := strings.ToLower(path.Ext(relativePath)); extension != ".html" && extension != ".htm" {
+			continue
+		}
+
+		skip, err := excluded(relativePath, exclude)
+		if err != nil {
+			return err
+		}
+		if skip {
+			continue
+		}
+
+		modified := lastMod
+		if modified == nil {
+			info, err := os.Stat(page)
+			if err != nil {
+				return err
+			}
+			modTime := info.ModTime()
+			modified = &modTime
+		}
+
+		segments := strings.Split(relativePath, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+
+		sitemap.URLs = append(sitemap.URLs, urlEntry{
+			Loc:     strings.TrimSuffix(baseURL, "/") + "/" + strings.Join(segments, "/"),
+			LastMod: modified.UTC().Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	data, err := xml.MarshalIndent(sitemap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(siteDir, FileName), append([]byte(xml.Header), data...), 0666)
+}
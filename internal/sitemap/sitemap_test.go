@@ -0,0 +1,37 @@
+package sitemap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	var pages []string
+	for _, page := range []string{"index.html", "works/Mona Lisa.html", "works/data.json", "feed.xml", "drafts/a.html", "legacy.HTM"} {
+		pages = append(pages, filepath.Join(dir, filepath.FromSlash(page)))
+	}
+	lastMod := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := Write(dir, "https://example.org/", pages, []string{"drafts"}, &lastMod); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"<loc>https://example.org/index.html</loc>", "<loc>https://example.org/works/Mona%20Lisa.html</loc>", "<loc>https://example.org/legacy.HTM</loc>", "<lastmod>2024-05-01T12:00:00Z</lastmod>"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected the sitemap to contain %s, got %s", expected, content)
+		}
+	}
+	for _, unexpected := range []string{"data.json", "feed.xml", "drafts"} {
+		if strings.Contains(string(content), unexpected) {
+			t.Errorf("Expected the sitemap to leave out %s, got %s", unexpected, content)
+		}
+	}
+}
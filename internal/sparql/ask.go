@@ -0,0 +1,56 @@
+package sparql
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// parseAskResult returns the answer of a SPARQL JSON response to an ASK query.
+func parseAskResult(r io.Reader) (bool, error) {
+	var response struct {
+		Boolean *bool `json:"boolean"`
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return false, err
+	}
+	if response.Boolean == nil {
+		return false, errors.New("The response has no boolean result.")
+	}
+	return *response.Boolean, nil
+}
+
+// Ask issues the given ASK query, or reads its response from the cache, and
+// returns its answer.
+func (r *Repository) Ask(queryLocation string) (bool, error) {
+	query, err := r.prepareQuery(queryLocation)
+	if err != nil {
+		return false, err
+	}
+
+	file, err := r.getCache(queryLocation, query)
+	if err != nil {
+		return false, err
+	}
+	if file != nil {
+		defer file.Close()
+		return parseAskResult(file)
+	}
+
+	start := time.Now()
+	jsonString, err := r.QueryCall(query)
+	if err != nil {
+		return false, queryFailed(queryLocation, query, start, err)
+	}
+	answer, err := parseAskResult(strings.NewReader(*jsonString))
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.CacheManager.SetCache(queryLocation, r.EndpointKey(), query, *jsonString); err != nil {
+		return false, err
+	}
+	return answer, nil
+}
@@ -0,0 +1,78 @@
+package sparql
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+var serviceClause = regexp.MustCompile(`(?i)\bSERVICE\s+(?:SILENT\s+)?<([^<>\s]*)>`)
+
+// Services returns the IRIs of the endpoints the SERVICE clauses of a query
+// call, in the order they appear. Services given by a variable are left out.
+func Services(query string) []string {
+	var services []string
+	seen := map[string]bool{}
+	for _, match := range serviceClause.FindAllStringSubmatch(query, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			services = append(services, match[1])
+		}
+	}
+	return services
+}
+
+// serviceHost returns the host of a service IRI.
+func serviceHost(service string) string {
+	parsed, err := url.Parse(service)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// timeout returns the timeout of the query, which is the timeout of the
+// client extended by the timeouts of the services it calls. No timeout is set
+// if the client has none.
+func (r *Repository) timeout(query string) time.Duration {
+	if r.client.Timeout <= 0 {
+		return 0
+	}
+	timeout := r.client.Timeout
+	for _, service := range Services(query) {
+		timeout += config.CurrentSiteConfig.Services[serviceHost(service)].Timeout
+	}
+	return timeout
+}
+
+// queryFailed returns the error of a failed query. For federated queries it
+// names the service whose host the endpoint blamed, or else all services the
+// query calls as possible causes.
+func queryFailed(queryLocation string, query string, start time.Time, err error) error {
+	message := "Query " + queryLocation + " failed after " + time.Since(start).Round(time.Millisecond).String() + "."
+
+	services := Services(query)
+	if len(services) > 0 {
+		var statusErr statusError
+		blamed := ""
+		if errors.As(err, &statusErr) {
+			for _, service := range services {
+				if host := serviceHost(service); host != "" && strings.Contains(statusErr.Body, host) {
+					blamed = service
+					break
+				}
+			}
+		}
+		if blamed != "" {
+			message += " The federated query to " + blamed + " failed."
+		} else {
+			message += " It calls the federated services " + strings.Join(services, ", ") + ", which might have caused the failure."
+		}
+	}
+	return utils.ErrorExit(message, err)
+}
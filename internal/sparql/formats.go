@@ -0,0 +1,275 @@
+package sparql
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// resultsFormats maps the results formats SPARQL clients can request to their
+// media types.
+var resultsFormats = map[string]string{
+	"json": resultsAccept,
+	"xml":  "application/sparql-results+xml",
+	"csv":  "text/csv",
+	"tsv":  "text/tab-separated-values",
+}
+
+// format returns the results format the response to the query is requested
+// in. ASK queries are always answered in JSON, as the other formats don't
+// agree on how to write their answer.
+func (r *Repository) format(query string) string {
+	if r.client.ResultsFormat == "" || QueryForm(query) == "ASK" {
+		return "json"
+	}
+	return r.client.ResultsFormat
+}
+
+// accept returns the Accept header of the request for the query.
+func (r *Repository) accept(query string) string {
+	if IsGraphQuery(query) {
+		return graphAccept
+	}
+	return resultsFormats[r.format(query)]
+}
+
+// parseResults parses the response to the query in the format it was
+// requested in and calls handle for each result row.
+func (r *Repository) parseResults(query string, reader io.Reader, handle func(map[string]rdf.Term) error) error {
+	switch r.format(query) {
+	case "xml":
+		return ParseSPARQLXMLStream(reader, handle)
+	case "csv":
+		return ParseSPARQLCSVStream(reader, handle)
+	case "tsv":
+		return ParseSPARQLTSVStream(reader, handle)
+	}
+	return ParseSPARQLJSONStream(reader, handle)
+}
+
+// parseRows works like parseResults but returns all rows, or none if the
+// response can't be parsed.
+func (r *Repository) parseRows(query string, reader io.Reader) []map[string]rdf.Term {
+	var rows []map[string]rdf.Term
+	err := r.parseResults(query, reader, func(row map[string]rdf.Term) error {
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return rows
+}
+
+type xmlLiteral struct {
+	Value    string `xml:",chardata"`
+	Lang     string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	DataType string `xml:"datatype,attr"`
+}
+
+type xmlResult struct {
+	Bindings []struct {
+		Name    string      `xml:"name,attr"`
+		URI     *string     `xml:"uri"`
+		BNode   *string     `xml:"bnode"`
+		Literal *xmlLiteral `xml:"literal"`
+	} `xml:"binding"`
+}
+
+// ParseSPARQLXMLStream parses SPARQL XML results incrementally and calls
+// handle for each result as soon as it has been read.
+func ParseSPARQLXMLStream(r io.Reader, handle func(map[string]rdf.Term) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		start, isStart := token.(xml.StartElement)
+		if !isStart || start.Name.Local != "result" {
+			continue
+		}
+		var result xmlResult
+		if err := decoder.DecodeElement(&result, &start); err != nil {
+			return err
+		}
+
+		bindings := make(map[string]binding)
+		for _, b := range result.Bindings {
+			switch {
+			case b.URI != nil:
+				bindings[b.Name] = binding{Type: "uri", Value: strings.TrimSpace(*b.URI)}
+			case b.BNode != nil:
+				bindings[b.Name] = binding{Type: "bnode", Value: strings.TrimSpace(*b.BNode)}
+			case b.Literal != nil:
+				bindings[b.Name] = binding{Type: "literal", Value: b.Literal.Value, Lang: b.Literal.Lang, DataType: b.Literal.DataType}
+			}
+		}
+		if err := handle(parseBinding(bindings)); err != nil {
+			return err
+		}
+	}
+}
+
+var absoluteIRI = regexp.MustCompile("^[A-Za-z][A-Za-z0-9+.-]*:[^\\s<>\"{}|\\\\^`]+$")
+
+// ParseSPARQLCSVStream parses SPARQL CSV results and calls handle for each
+// row. CSV doesn't keep the types of terms, so values looking like absolute
+// IRIs are read as IRIs, _: values as blank nodes and all others as plain
+// literals. Empty values are unbound.
+func ParseSPARQLCSVStream(r io.Reader, handle func(map[string]rdf.Term) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	names, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		bindings := make(map[string]binding)
+		for i, value := range record {
+			if i >= len(names) || value == "" {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(value, "_:"):
+				bindings[names[i]] = binding{Type: "bnode", Value: value[2:]}
+			case absoluteIRI.MatchString(value):
+				bindings[names[i]] = binding{Type: "uri", Value: value}
+			default:
+				bindings[names[i]] = binding{Type: "literal", Value: value}
+			}
+		}
+		if err := handle(parseBinding(bindings)); err != nil {
+			return err
+		}
+	}
+}
+
+const xsd = "http://www.w3.org/2001/XMLSchema#"
+
+var tsvLiteral = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)"(?:@([A-Za-z0-9-]+)|\^\^<([^<>]*)>)?$`)
+var tsvNumber = regexp.MustCompile(`^[+-]?(?:(\d+)|(\d*\.\d+)|((?:\d+\.?\d*|\.\d+)[eE][+-]?\d+))$`)
+
+// ParseSPARQLTSVStream parses SPARQL TSV results and calls handle for each
+// row. Terms are written as in Turtle, and empty values are unbound.
+func ParseSPARQLTSVStream(r io.Reader, handle func(map[string]rdf.Term) error) error {
+	reader := bufio.NewReader(r)
+	var names []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if names == nil {
+			for _, name := range strings.Split(line, "\t") {
+				names = append(names, strings.TrimLeft(name, "?$"))
+			}
+		} else if line != "" || err == nil {
+			bindings := make(map[string]binding)
+			for i, value := range strings.Split(line, "\t") {
+				if i >= len(names) || value == "" {
+					continue
+				}
+				term, parseErr := parseTSVTerm(value)
+				if parseErr != nil {
+					return parseErr
+				}
+				bindings[names[i]] = term
+			}
+			if err := handle(parseBinding(bindings)); err != nil {
+				return err
+			}
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// parseTSVTerm parses a term of SPARQL TSV results.
+func parseTSVTerm(value string) (binding, error) {
+	switch {
+	case strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">"):
+		return binding{Type: "uri", Value: value[1 : len(value)-1]}, nil
+	case strings.HasPrefix(value, "_:"):
+		return binding{Type: "bnode", Value: value[2:]}, nil
+	case value == "true" || value == "false":
+		return binding{Type: "literal", Value: value, DataType: xsd + "boolean"}, nil
+	}
+
+	if match := tsvLiteral.FindStringSubmatch(value); match != nil {
+		unescaped, err := unescapeTSV(match[1])
+		if err != nil {
+			return binding{}, err
+		}
+		return binding{Type: "literal", Value: unescaped, Lang: match[2], DataType: match[3]}, nil
+	}
+	if match := tsvNumber.FindStringSubmatch(value); match != nil {
+		datatype := "integer"
+		if match[2] != "" {
+			datatype = "decimal"
+		} else if match[3] != "" {
+			datatype = "double"
+		}
+		return binding{Type: "literal", Value: value, DataType: xsd + datatype}, nil
+	}
+	return binding{}, errors.New("Failed to parse the term " + value + " of SPARQL TSV results.")
+}
+
+var tsvEscapes = map[byte]string{'t': "\t", 'n': "\n", 'r': "\r", 'b': "\b", 'f': "\f", '"': "\"", '\'': "'", '\\': "\\"}
+
+// unescapeTSV replaces the escape sequences of a Turtle string.
+func unescapeTSV(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var unescaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i+1 == len(value) {
+			unescaped.WriteByte(value[i])
+			continue
+		}
+		i++
+		if replacement, exists := tsvEscapes[value[i]]; exists {
+			unescaped.WriteString(replacement)
+			continue
+		}
+		length := map[byte]int{'u': 4, 'U': 8}[value[i]]
+		if length == 0 || i+1+length > len(value) {
+			return "", errors.New("Invalid escape sequence in the string " + value + " of SPARQL TSV results.")
+		}
+		code, err := strconv.ParseUint(value[i+1:i+1+length], 16, 32)
+		if err != nil {
+			return "", errors.New("Invalid escape sequence in the string " + value + " of SPARQL TSV results.")
+		}
+		unescaped.WriteRune(rune(code))
+		i += length
+	}
+	return unescaped.String(), nil
+}
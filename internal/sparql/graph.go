@@ -0,0 +1,144 @@
+package sparql
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/knakk/rdf"
+	"github.com/spf13/cast"
+)
+
+var commentOrString = regexp.MustCompile(`(?m)"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|<[^<>\s]*>|#.*$`)
+var queryFormKeyword = regexp.MustCompile(`(?i)\b(SELECT|CONSTRUCT|DESCRIBE|ASK)\b`)
+
+// QueryForm returns the form of the given query in uppercase, for example
+// "SELECT" or "CONSTRUCT". An empty string is returned if it can't be found.
+func QueryForm(query string) string {
+	match := queryFormKeyword.FindString(stripQuery(query))
+	return strings.ToUpper(match)
+}
+
+// stripQuery removes IRIs, strings and comments from the query as they might
+// contain keywords.
+func stripQuery(query string) string {
+	return commentOrString.ReplaceAllStringFunc(query, func(match string) string {
+		if strings.HasPrefix(match, "<") {
+			return "<>"
+		}
+		return ""
+	})
+}
+
+// IsGraphQuery reports whether the given query returns an RDF graph rather
+// than a set of bindings.
+func IsGraphQuery(query string) bool {
+	form := QueryForm(query)
+	return form == "CONSTRUCT" || form == "DESCRIBE"
+}
+
+// Graph is the RDF graph returned by a CONSTRUCT or DESCRIBE query.
+type Graph struct {
+	Triples []rdf.Triple
+}
+
+func termKey(term interface{}) string {
+	return cast.ToString(term)
+}
+
+// Subjects returns the unique subjects of the graph in order of appearance.
+func (g Graph) Subjects() []rdf.Subject {
+	seen := make(map[string]bool)
+	var subjects []rdf.Subject
+	for _, triple := range g.Triples {
+		key := termKey(triple.Subj)
+		if !seen[key] {
+			seen[key] = true
+			subjects = append(subjects, triple.Subj)
+		}
+	}
+	return subjects
+}
+
+// Properties returns the unique predicates used with the given subject.
+func (g Graph) Properties(subject interface{}) []rdf.Predicate {
+	seen := make(map[string]bool)
+	var predicates []rdf.Predicate
+	for _, triple := range g.Triples {
+		key := termKey(triple.Pred)
+		if termKey(triple.Subj) == termKey(subject) && !seen[key] {
+			seen[key] = true
+			predicates = append(predicates, triple.Pred)
+		}
+	}
+	return predicates
+}
+
+// Objects returns all objects of the given subject and predicate.
+func (g Graph) Objects(subject interface{}, predicate interface{}) []rdf.Object {
+	var objects []rdf.Object
+	for _, triple := range g.Triples {
+		if termKey(triple.Subj) == termKey(subject) && termKey(triple.Pred) == termKey(predicate) {
+			objects = append(objects, triple.Obj)
+		}
+	}
+	return objects
+}
+
+// Object returns the first object of the given subject and predicate, or nil.
+func (g Graph) Object(subject interface{}, predicate interface{}) rdf.Object {
+	objects := g.Objects(subject, predicate)
+	if len(objects) == 0 {
+		return nil
+	}
+	return objects[0]
+}
+
+// ParseGraph parses an N-Triples or Turtle response into a graph.
+func ParseGraph(r io.Reader) (*Graph, error) {
+	var graph Graph
+	decoder := rdf.NewTripleDecoder(r, rdf.Turtle)
+	for {
+		triple, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		graph.Triples = append(graph.Triples, triple)
+	}
+	return &graph, nil
+}
+
+// QueryGraph issues the given CONSTRUCT or DESCRIBE query and returns the
+// resulting graph.
+func (r *Repository) QueryGraph(queryLocation string, arguments ...interface{}) (*Graph, error) {
+	query, err := r.prepareQuery(queryLocation, arguments...)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := r.getCache(queryLocation, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if file != nil {
+		defer file.Close()
+		return ParseGraph(file)
+	}
+
+	start := time.Now()
+	response, err := r.queryCall(query, graphAccept)
+	if err != nil {
+		return nil, queryFailed(queryLocation, query, start, err)
+	}
+
+	if err := r.CacheManager.SetCache(queryLocation, r.EndpointKey(), query, *response); err != nil {
+		return nil, err
+	}
+
+	return ParseGraph(strings.NewReader(*response))
+}
@@ -0,0 +1,91 @@
+package sparql
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+)
+
+var limitOrOffset = regexp.MustCompile(`(?i)\b(LIMIT|OFFSET)\s+\d+`)
+
+// HasLimit reports whether the query, or one of its subqueries, limits or
+// offsets its results.
+func HasLimit(query string) bool {
+	return limitOrOffset.MatchString(stripQuery(query))
+}
+
+// AddModifiers returns the query with the given solution modifiers, like
+// "LIMIT 1", added to its end. Queries ending with a VALUES clause get them
+// before the clause, as it has to follow the modifiers.
+func AddModifiers(query string, modifiers string) string {
+	// only the trailing VALUES clause is outside of all groups
+	if i := topLevelKeyword(query, "VALUES"); i >= 0 {
+		return query[:i] + modifiers + "\n" + query[i:]
+	}
+	return query + "\n" + modifiers
+}
+
+// HasOrderBy reports whether the results of the query are ordered, leaving
+// out the ordering of subqueries.
+func HasOrderBy(query string) bool {
+	return topLevelKeyword(query, "ORDER") >= 0
+}
+
+// topLevelKeyword returns the offset of the first occurrence of the keyword
+// outside of the groups of the query, or -1 if there is none.
+func topLevelKeyword(query string, keyword string) int {
+	// the IRIs, strings and comments are masked so that offsets still match
+	masked := commentOrString.ReplaceAllStringFunc(query, func(match string) string {
+		return strings.Repeat(" ", len(match))
+	})
+
+	depth := 0
+	for i := 0; i < len(masked); i++ {
+		switch masked[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		default:
+			end := i + len(keyword)
+			if depth == 0 && end <= len(masked) && strings.EqualFold(masked[i:end], keyword) && wordStart(masked, i) && !wordRune(masked, end) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// WithPageSize returns a copy of the repository which fetches the results of
+// SELECT queries in pages of the given size, by appending LIMIT and OFFSET,
+// until a page has fewer results. Queries which already have a LIMIT or
+// OFFSET are issued as they are.
+func (r *Repository) WithPageSize(size int) *Repository {
+	repo := *r
+	repo.pageSize = size
+	return &repo
+}
+
+// paged reports whether the given prepared query is fetched in pages.
+func (r *Repository) paged(query string) bool {
+	return r.pageSize > 0 && !IsGraphQuery(query) && !HasLimit(query)
+}
+
+// pageQuery returns the query fetching the page at the given offset.
+func (r *Repository) pageQuery(query string, offset int) string {
+	logger.Debug("Fetching " + strconv.Itoa(r.pageSize) + " results from offset " + strconv.Itoa(offset) + ".")
+	return AddModifiers(query, "LIMIT "+strconv.Itoa(r.pageSize)+"\nOFFSET "+strconv.Itoa(offset))
+}
+
+// lastPage reports whether a page with the given number of results is the
+// last one. More results than requested mean that the endpoint ignores the
+// LIMIT, which would otherwise fetch the same page forever.
+func (r *Repository) lastPage(results int) (bool, error) {
+	if results > r.pageSize {
+		return true, errors.New("The endpoint returned more results than the fetch_page_size of " + strconv.Itoa(r.pageSize) + ". It might not support LIMIT.")
+	}
+	return results < r.pageSize, nil
+}
@@ -0,0 +1,139 @@
+package sparql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+func TestHasLimit(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected bool
+	}{
+		{"SELECT * WHERE { ?s ?p ?o }", false},
+		{"SELECT * WHERE { ?s ?p ?o } LIMIT 10", true},
+		{"select * where { ?s ?p ?o } offset 5", true},
+		{"SELECT * WHERE { { SELECT ?s WHERE { ?s ?p ?o } LIMIT 1 } }", true},
+		{"SELECT * WHERE { ?s ?p \"LIMIT 10\" } # OFFSET 3", false},
+	}
+
+	for _, test := range tests {
+		if HasLimit(test.query) != test.expected {
+			t.Errorf("Expected HasLimit(%q) to be %v", test.query, test.expected)
+		}
+	}
+}
+
+func TestAddModifiers(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * WHERE { ?s ?p ?o }", "SELECT * WHERE { ?s ?p ?o }\nLIMIT 1"},
+		{"SELECT * WHERE { ?s ?p ?o } # done", "SELECT * WHERE { ?s ?p ?o } # done\nLIMIT 1"},
+		{"SELECT * WHERE { ?s ?p ?o } VALUES ?s { <http://ex.org/a> }", "SELECT * WHERE { ?s ?p ?o } LIMIT 1\nVALUES ?s { <http://ex.org/a> }"},
+		{"SELECT * WHERE { VALUES ?s { 1 } ?s ?p ?o }", "SELECT * WHERE { VALUES ?s { 1 } ?s ?p ?o }\nLIMIT 1"},
+		{"SELECT ?values WHERE { ?s ?p \"} VALUES\" }", "SELECT ?values WHERE { ?s ?p \"} VALUES\" }\nLIMIT 1"},
+	}
+
+	for _, test := range tests {
+		if got := AddModifiers(test.query, "LIMIT 1"); got != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, got)
+		}
+	}
+}
+
+func TestHasOrderBy(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected bool
+	}{
+		{"SELECT * WHERE { ?s ?p ?o } ORDER BY ?s", true},
+		{"select * where { ?s ?p ?o } order by desc(?o)", true},
+		{"SELECT * WHERE { ?s ?p ?o }", false},
+		{"SELECT * WHERE { { SELECT ?s WHERE { ?s ?p ?o } ORDER BY ?s } }", false},
+		{"SELECT * WHERE { ?s ?p \"ORDER BY\" } # ORDER BY ?s", false},
+	}
+
+	for _, test := range tests {
+		if HasOrderBy(test.query) != test.expected {
+			t.Errorf("Expected HasOrderBy(%q) to be %v", test.query, test.expected)
+		}
+	}
+}
+
+var pageParams = regexp.MustCompile(`LIMIT (\d+)\s+OFFSET (\d+)$`)
+
+// newPagingServer returns a server with the given number of results, which it
+// returns in pages according to the LIMIT and OFFSET of the query.
+func newPagingServer(total int, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		start, end := 0, total
+		if match := pageParams.FindStringSubmatch(r.FormValue("query")); match != nil {
+			limit, _ := strconv.Atoi(match[1])
+			start, _ = strconv.Atoi(match[2])
+			if start+limit < end {
+				end = start + limit
+			}
+		}
+
+		var bindings []string
+		for i := start; i < end; i++ {
+			bindings = append(bindings, fmt.Sprintf(`{"n": {"type": "literal", "value": "%d"}}`, i))
+		}
+		fmt.Fprintf(w, `{"head": {"vars": ["n"]}, "results": {"bindings": [%s]}}`, strings.Join(bindings, ","))
+	}))
+}
+
+func TestPagedQuery(t *testing.T) {
+	var tests = []struct {
+		query            string
+		pageSize         int
+		expectedRequests int
+	}{
+		{"SELECT ?n {}", 0, 1},
+		{"SELECT ?n {}", 2, 3},
+		// a full last page needs another request to find the end
+		{"SELECT ?n {}", 5, 2},
+		{"SELECT ?n {} LIMIT 10", 2, 1},
+	}
+
+	for _, test := range tests {
+		requests := 0
+		server := newPagingServer(5, &requests)
+
+		repo := Repository{
+			client:       config.ClientConfig{Endpoint: server.URL},
+			httpClient:   server.Client(),
+			CacheManager: &cache.CacheManager{CacheStrategy: "never"},
+			QueryIndex:   map[string]string{"test.rq": test.query},
+		}
+
+		var streamed []map[string]rdf.Term
+		err := repo.WithPageSize(test.pageSize).QueryStream("test.rq", func(row map[string]rdf.Term) error {
+			streamed = append(streamed, row)
+			return nil
+		})
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(streamed) != 5 || streamed[4]["n"].String() != "4" {
+			t.Errorf("Expected %q with page size %d to return all 5 results, got %v", test.query, test.pageSize, streamed)
+		}
+		if requests != test.expectedRequests {
+			t.Errorf("Expected %q with page size %d to take %d requests, took %d", test.query, test.pageSize, test.expectedRequests, requests)
+		}
+	}
+}
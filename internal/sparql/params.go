@@ -0,0 +1,133 @@
+package sparql
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+var paramPattern = regexp.MustCompile(`{{\s*([A-Za-z_][\w]*)\s*}}`)
+
+// WithParams returns a copy of the repository substituting the given
+// parameters, in addition to the query_params of the site configuration, into
+// its queries.
+func (r *Repository) WithParams(params map[string]interface{}) *Repository {
+	repo := *r
+	repo.params = params
+	return &repo
+}
+
+// FormatParam formats a parameter value as a SPARQL term. Strings wrapped in
+// angle brackets are IRIs, other strings are literals, numbers and booleans
+// are written as such and times as xsd:dateTime literals.
+func FormatParam(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return `"` + v.UTC().Format(time.RFC3339) + `"^^<http://www.w3.org/2001/XMLSchema#dateTime>`, nil
+	case string:
+		if strings.HasPrefix(v, "<") && strings.HasSuffix(v, ">") {
+			iri := v[1 : len(v)-1]
+			if strings.ContainsAny(iri, "<>\"{}|^`\\ \t\r\n") {
+				return "", errors.New("The IRI " + v + " contains characters that aren't allowed in IRIs.")
+			}
+			return v, nil
+		}
+		replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+		return `"` + replacer.Replace(v) + `"`, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", errors.New("Query parameters must be strings, numbers, booleans or times.")
+}
+
+// substituteParams replaces {{name}} placeholders in a query with the
+// formatted value of the parameter of that name.
+func substituteParams(query string, params map[string]interface{}) (string, error) {
+	var substitutionErr error
+	query = paramPattern.ReplaceAllStringFunc(query, func(placeholder string) string {
+		name := paramPattern.FindStringSubmatch(placeholder)[1]
+		value, exists := params[name]
+		if !exists {
+			if substitutionErr == nil {
+				substitutionErr = errors.New("The query parameter " + name + " isn't defined.")
+			}
+			return placeholder
+		}
+
+		term, err := FormatParam(value)
+		if err != nil && substitutionErr == nil {
+			substitutionErr = err
+		}
+		return term
+	})
+	return query, substitutionErr
+}
+
+var prefixDeclarationPattern = regexp.MustCompile(`(?i)\bPREFIX\s+([\w.-]*):`)
+
+// PrependPrefixes declares the given prefixes at the start of the query,
+// except those which the query declares itself.
+func PrependPrefixes(query string, prefixes map[string]string) string {
+	declared := make(map[string]bool)
+	for _, match := range prefixDeclarationPattern.FindAllStringSubmatch(query, -1) {
+		declared[match[1]] = true
+	}
+
+	names := make([]string, 0, len(prefixes))
+	for name := range prefixes {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var declarations strings.Builder
+	for _, name := range names {
+		declarations.WriteString("PREFIX " + name + ": <" + prefixes[name] + ">\n")
+	}
+	return declarations.String() + query
+}
+
+// SinceParam is the query parameter holding the time given with --since, or
+// the start of the Unix epoch, so that queries filtering by it return all
+// resources when it isn't given.
+const SinceParam = "since"
+
+// UsesParam reports whether the query has a placeholder for the parameter.
+func UsesParam(query string, name string) bool {
+	for _, match := range paramPattern.FindAllStringSubmatch(query, -1) {
+		if match[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// queryParams returns the site-wide query parameters overridden by those of
+// the repository, and those by the variables given on the command line.
+func (r *Repository) queryParams() map[string]interface{} {
+	params := make(map[string]interface{})
+	params[SinceParam] = time.Unix(0, 0).UTC()
+	if config.CurrentSiteConfig.Since != nil {
+		params[SinceParam] = *config.CurrentSiteConfig.Since
+	}
+	for name, value := range config.CurrentSiteConfig.QueryParams {
+		params[name] = value
+	}
+	for name, value := range r.params {
+		params[name] = value
+	}
+	for name, value := range config.CurrentSiteConfig.Variables {
+		params[name] = value
+	}
+	return params
+}
@@ -0,0 +1,58 @@
+package sparql
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+// querySlots limits the queries awaiting a response across all repositories
+// to sparql_max_concurrent. It's nil when there's no limit.
+var querySlots chan struct{}
+
+// newTransport returns the transport shared by the repositories of remote
+// endpoints, so that connections are pooled per host across views and
+// clients.
+func newTransport(siteConfig config.SiteConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	connections := siteConfig.Connections
+
+	transport.MaxIdleConnsPerHost = 16
+	if connections.MaxIdlePerHost > 0 {
+		transport.MaxIdleConnsPerHost = connections.MaxIdlePerHost
+	} else if siteConfig.MaxConcurrent > 0 {
+		transport.MaxIdleConnsPerHost = siteConfig.MaxConcurrent
+	}
+	if transport.MaxIdleConns < transport.MaxIdleConnsPerHost {
+		transport.MaxIdleConns = transport.MaxIdleConnsPerHost
+	}
+	if connections.IdleTimeout > 0 {
+		transport.IdleConnTimeout = connections.IdleTimeout
+	}
+	transport.DisableKeepAlives = connections.DisableKeepAlives
+	return transport
+}
+
+// acquireSlot waits until another query may be sent to the endpoint and
+// returns the function releasing the slot again. Queries of local files
+// aren't limited.
+func (r *Repository) acquireSlot() func() {
+	slots := querySlots
+	if slots == nil || r.store != nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// retryAfter returns the delay asked for by the Retry-After header of a
+// response, if it's given in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
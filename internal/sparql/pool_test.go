@@ -0,0 +1,149 @@
+package sparql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	var mutex sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		fmt.Fprint(w, `{"head": {"vars": []}, "results": {"bindings": []}}`)
+	}))
+	defer server.Close()
+
+	querySlots = make(chan struct{}, 2)
+	defer func() { querySlots = nil }()
+	repo := Repository{
+		client:     config.ClientConfig{Endpoint: server.URL},
+		httpClient: &http.Client{Transport: newTransport(config.SiteConfig{MaxConcurrent: 2})},
+	}
+
+	var queries sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+			if _, err := repo.QueryCall("SELECT * {}"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	queries.Wait()
+
+	if maxInFlight != 2 {
+		t.Errorf("Expected at most 2 queries at the same time, got %d", maxInFlight)
+	}
+}
+
+func TestQueryMethod(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("query") == "" {
+			t.Errorf("Expected a %s request with a query", r.Method)
+		}
+		methods = append(methods, r.Method)
+		fmt.Fprint(w, `{"head": {"vars": []}, "results": {"bindings": []}}`)
+	}))
+	defer server.Close()
+
+	for _, method := range []string{"post", "get"} {
+		repo := Repository{
+			client:     config.ClientConfig{Endpoint: server.URL, Method: method, MaxGetLength: 200},
+			httpClient: server.Client(),
+		}
+		for _, query := range []string{"SELECT * {}", "SELECT * {}" + strings.Repeat(" ", 200)} {
+			if _, err := repo.QueryCall(query); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if fmt.Sprint(methods) != "[POST POST GET POST]" {
+		t.Errorf("Unexpected methods %v", methods)
+	}
+}
+
+func TestQueryHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-Api-Key"); key != "secret" {
+			t.Errorf("Expected the X-Api-Key header secret, got %q", key)
+		}
+		if accept := r.Header.Get("Accept"); accept != "application/sparql-results+json;charset=utf-8" {
+			t.Errorf("Expected the Accept header to be overridden, got %q", accept)
+		}
+		fmt.Fprint(w, `{"head": {"vars": []}, "results": {"bindings": []}}`)
+	}))
+	defer server.Close()
+
+	repo := Repository{
+		client: config.ClientConfig{Endpoint: server.URL, Headers: map[string]string{
+			"X-Api-Key": "secret",
+			"Accept":    "application/sparql-results+json;charset=utf-8",
+		}},
+		httpClient: server.Client(),
+	}
+	if _, err := repo.QueryCall("SELECT * {}"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.FormValue("query"), "stall") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		// rows large enough not to be read in one go
+		padding := strings.Repeat("x", 1000)
+		var bindings []string
+		for i := 0; i < 200; i++ {
+			bindings = append(bindings, `{"n": {"type": "literal", "value": "`+padding+`"}}`)
+		}
+		fmt.Fprint(w, `{"head": {"vars": ["n"]}, "results": {"bindings": [`+strings.Join(bindings, ",")+`]}}`)
+	}))
+	defer server.Close()
+
+	repo := Repository{
+		client:       config.ClientConfig{Endpoint: server.URL, Timeout: 100 * time.Millisecond},
+		httpClient:   server.Client(),
+		CacheManager: &cache.CacheManager{CacheStrategy: "never"},
+		QueryIndex:   map[string]string{"slow.rq": "SELECT ?n {}", "stall.rq": "SELECT ?n { # stall\n}"},
+	}
+
+	// rendering the rows takes longer than the timeout, which only covers
+	// waiting for the endpoint
+	rows := 0
+	err := repo.QueryStream("slow.rq", func(row map[string]rdf.Term) error {
+		rows++
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err != nil || rows != 200 {
+		t.Errorf("Expected all 200 rows despite slow rendering, got %d and error %v", rows, err)
+	}
+
+	if err := repo.QueryStream("stall.rq", func(map[string]rdf.Term) error { return nil }); err == nil {
+		t.Error("Expected a stalling endpoint to time out")
+	}
+}
@@ -0,0 +1,124 @@
+package sparql
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/knakk/rdf"
+)
+
+// JSONTerm is a term in the SPARQL JSON results format.
+type JSONTerm struct {
+	Type     string `json:"type" yaml:"type"`
+	Value    string `json:"value" yaml:"value"`
+	Lang     string `json:"xml:lang,omitempty" yaml:"xml:lang,omitempty"`
+	DataType string `json:"datatype,omitempty" yaml:"datatype,omitempty"`
+}
+
+// ToJSONTerm converts a term to the SPARQL JSON results format.
+func ToJSONTerm(term rdf.Term) JSONTerm {
+	switch t := term.(type) {
+	case rdf.IRI:
+		return JSONTerm{Type: "uri", Value: t.String()}
+	case rdf.Blank:
+		return JSONTerm{Type: "bnode", Value: t.String()}
+	case rdf.Literal:
+		if t.Lang() != "" {
+			return JSONTerm{Type: "literal", Value: t.String(), Lang: t.Lang()}
+		}
+		return JSONTerm{Type: "literal", Value: t.String(), DataType: t.DataType.String()}
+	}
+	return JSONTerm{Type: "literal", Value: term.String()}
+}
+
+// ToJSONRow converts the bound terms of a result row to the SPARQL JSON
+// results format.
+func ToJSONRow(row map[string]rdf.Term) map[string]JSONTerm {
+	converted := make(map[string]JSONTerm, len(row))
+	for variable, term := range row {
+		if term != nil {
+			converted[variable] = ToJSONTerm(term)
+		}
+	}
+	return converted
+}
+
+// ToJSONRows converts result rows to the SPARQL JSON results format.
+func ToJSONRows(rows []map[string]rdf.Term) []map[string]JSONTerm {
+	converted := make([]map[string]JSONTerm, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, ToJSONRow(row))
+	}
+	return converted
+}
+
+// Variables returns the variables bound in any of the result rows, sorted.
+func Variables(rows []map[string]rdf.Term) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, row := range rows {
+		for name := range row {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteResultsJSON writes the result rows as SPARQL JSON results.
+func WriteResultsJSON(w io.Writer, rows []map[string]rdf.Term) error {
+	var results struct {
+		Head struct {
+			Vars []string `json:"vars"`
+		} `json:"head"`
+		Results struct {
+			Bindings []map[string]JSONTerm `json:"bindings"`
+		} `json:"results"`
+	}
+	results.Head.Vars = Variables(rows)
+	if results.Head.Vars == nil {
+		results.Head.Vars = []string{}
+	}
+	results.Results.Bindings = ToJSONRows(rows)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// WriteResultsCSV writes the result rows as SPARQL CSV results, which have a
+// header with the variables and the plain values of the terms.
+func WriteResultsCSV(w io.Writer, rows []map[string]rdf.Term) error {
+	return WriteResultsDelimited(w, rows, ',')
+}
+
+// WriteResultsDelimited works like WriteResultsCSV but separates the values
+// with the given delimiter. Values containing the delimiter, quotes or line
+// breaks are quoted.
+func WriteResultsDelimited(w io.Writer, rows []map[string]rdf.Term, delimiter rune) error {
+	names := Variables(rows)
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	if err := writer.Write(names); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		values := make([]string, len(names))
+		for i, name := range names {
+			if term, ok := row[name]; ok && term != nil {
+				values[i] = term.String()
+			}
+		}
+		if err := writer.Write(values); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
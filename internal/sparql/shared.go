@@ -0,0 +1,119 @@
+package sparql
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/knakk/rdf"
+)
+
+// sharedQuery is a query issued through SharedQuery. Requests for the same
+// query wait for done and read the results of the first one.
+type sharedQuery struct {
+	done    chan struct{}
+	results []map[string]rdf.Term
+	err     error
+}
+
+// sharedResults keeps the results of the queries issued through SharedQuery
+// for the duration of a build.
+type sharedResults struct {
+	sync.Mutex
+	queries      map[string]*sharedQuery
+	deduplicated int
+}
+
+// shared is nil unless results are shared, see ShareResults.
+var shared *sharedResults
+
+// ShareResults makes SharedQuery issue each distinct query to an endpoint only
+// once until the repositories are set up again.
+func ShareResults() {
+	shared = &sharedResults{queries: make(map[string]*sharedQuery)}
+}
+
+// Deduplicated returns the number of queries answered by SharedQuery with the
+// results of an identical query.
+func Deduplicated() int {
+	if shared == nil {
+		return 0
+	}
+	shared.Lock()
+	defer shared.Unlock()
+	return shared.deduplicated
+}
+
+// SharedQuery works like Query, but once results are shared it only issues
+// queries whose normalized text wasn't issued to the same endpoint before,
+// and returns the results of the earlier one otherwise.
+func (r *Repository) SharedQuery(queryLocation string) ([]map[string]rdf.Term, error) {
+	if shared == nil {
+		return r.Query(queryLocation)
+	}
+
+	query, err := r.prepareQuery(queryLocation)
+	if err != nil {
+		return nil, err
+	}
+	key := cache.ContentHash(r.EndpointKey(), normalizeQuery(query))
+
+	shared.Lock()
+	issued, exists := shared.queries[key]
+	if exists {
+		shared.deduplicated++
+		shared.Unlock()
+		<-issued.done
+		return issued.results, issued.err
+	}
+	issued = &sharedQuery{done: make(chan struct{})}
+	shared.queries[key] = issued
+	shared.Unlock()
+
+	issued.results, issued.err = r.queryPages(queryLocation, query)
+	close(issued.done)
+	return issued.results, issued.err
+}
+
+// normalizeQuery removes the comments of a query and collapses whitespace
+// outside of strings and IRIs, so that queries differing only in layout are
+// the same.
+func normalizeQuery(query string) string {
+	var normalized strings.Builder
+	space := false
+	for rest := query; rest != ""; {
+		r, size := utf8.DecodeRuneInString(rest)
+		token := rest[:size]
+		switch {
+		case r == '#':
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			rest = rest[end:]
+			space = true
+			continue
+		case unicode.IsSpace(r):
+			rest = rest[size:]
+			space = true
+			continue
+		case r == '"' || r == '\'':
+			if length, err := stringLength(rest); err == "" {
+				token = rest[:length]
+			}
+		case r == '<':
+			if match := iriRef.FindString(rest); match != "" {
+				token = match
+			}
+		}
+		if space && normalized.Len() > 0 {
+			normalized.WriteByte(' ')
+		}
+		space = false
+		normalized.WriteString(token)
+		rest = rest[len(token):]
+	}
+	return normalized.String()
+}
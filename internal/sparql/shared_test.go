@@ -0,0 +1,61 @@
+package sparql
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/cache"
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+func TestSharedQuery(t *testing.T) {
+	var mutex sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		mutex.Unlock()
+		fmt.Fprint(w, `{"head": {"vars": ["s"]}, "results": {"bindings": [{"s": {"type": "literal", "value": "a"}}]}}`)
+	}))
+	defer server.Close()
+
+	repo := Repository{
+		client:       config.ClientConfig{Endpoint: server.URL},
+		httpClient:   server.Client(),
+		CacheManager: &cache.CacheManager{CacheStrategy: "never"},
+		QueryIndex: map[string]string{
+			"a.rq": "SELECT ?s { ?s ?p ?o }",
+			"b.rq": "# the same query\nSELECT ?s {\n  ?s ?p ?o\n}\n",
+			"c.rq": "SELECT ?s { ?s ?p \"a  b\" }",
+			"d.rq": "SELECT ?s { ?s ?p \"a b\" }",
+		},
+	}
+
+	ShareResults()
+	defer func() { shared = nil }()
+
+	var queries sync.WaitGroup
+	for _, location := range []string{"a.rq", "b.rq", "a.rq", "c.rq", "d.rq"} {
+		queries.Add(1)
+		go func(location string) {
+			defer queries.Done()
+			results, err := repo.SharedQuery(location)
+			if err != nil {
+				t.Error(err)
+			} else if len(results) != 1 {
+				t.Errorf("Expected one result for %s, got %v", location, results)
+			}
+		}(location)
+	}
+	queries.Wait()
+
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	if deduplicated := Deduplicated(); deduplicated != 2 {
+		t.Errorf("Expected 2 deduplicated queries, got %d", deduplicated)
+	}
+}
@@ -2,18 +2,29 @@ package sparql
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/glaciers-in-archives/snowman/internal/cache"
 	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/localstore"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
 	"github.com/knakk/rdf"
 	"github.com/spf13/cast"
 )
@@ -21,78 +32,356 @@ import (
 type Repository struct {
 	client       config.ClientConfig
 	httpClient   *http.Client
-	verbose      bool
 	CacheManager *cache.CacheManager
 	QueryIndex   map[string]string
+	// params are substituted into queries, see WithParams
+	params map[string]interface{}
+	// results holds the results of queries issued through MemoizedQuery
+	results *resultMemo
+	// cacheTTL overrides the TTL of the cache manager, see WithCacheTTL
+	cacheTTL *time.Duration
+	// pageSize is the number of results fetched per request, see WithPageSize
+	pageSize int
+	// store answers the queries of file: endpoints
+	store *localstore.Store
 }
 
-var CurrentRepository Repository
+// EndpointKey identifies the endpoint in cache keys. For local files it
+// includes the hash of the data, so that changes to the file invalidate the
+// cache.
+func (r *Repository) EndpointKey() string {
+	if r.store != nil {
+		return r.client.Endpoint + "#" + r.store.Hash
+	}
+	// responses in other formats are cached apart from JSON ones
+	if r.client.ResultsFormat != "" && r.client.ResultsFormat != "json" {
+		return r.client.Endpoint + "#" + r.client.ResultsFormat
+	}
+	return r.client.Endpoint
+}
 
-func NewRepository(cacheStrategy string, queryIndex map[string]string, verbose bool) error {
-	repo := Repository{
-		client:     config.CurrentSiteConfig.Client,
-		QueryIndex: queryIndex,
-		verbose:    verbose,
+// WithCacheTTL returns a copy of the repository which considers cached
+// responses older than the given TTL stale.
+func (r *Repository) WithCacheTTL(ttl time.Duration) *Repository {
+	repo := *r
+	repo.cacheTTL = &ttl
+	return &repo
+}
+
+// getCache returns the cached response to the given query, if there's a fresh
+// one.
+func (r *Repository) getCache(queryLocation string, query string) (*os.File, error) {
+	if r.cacheTTL != nil {
+		return r.CacheManager.GetCacheWithTTL(queryLocation, r.EndpointKey(), query, *r.cacheTTL)
 	}
-	repo.httpClient = http.DefaultClient
+	return r.CacheManager.GetCache(queryLocation, r.EndpointKey(), query)
+}
+
+// resultMemo keeps query results in memory for the duration of a build.
+type resultMemo struct {
+	sync.Mutex
+	results map[string][]map[string]rdf.Term
+}
+
+// CurrentRepository is the repository of the default SPARQL client.
+var CurrentRepository Repository
+
+// Repositories holds a repository for each configured SPARQL client by name.
+var Repositories map[string]*Repository
 
-	cm, err := cache.NewCacheManager(cacheStrategy)
+// NewRepository sets up a repository for each configured SPARQL client. All
+// repositories share the same cache manager.
+func NewRepository(cacheStrategy string, queryIndex map[string]string) error {
+	cm, err := cache.NewCacheManager(cacheStrategy, config.CurrentSiteConfig.CacheTTL)
 	if err != nil {
 		return errors.New("Failed to initiate cache handler. " + " Error: " + err.Error())
 	}
 
-	repo.CacheManager = cm
+	querySlots = nil
+	shared = nil
+	if limit := config.CurrentSiteConfig.MaxConcurrent; limit > 0 {
+		querySlots = make(chan struct{}, limit)
+	}
 
-	CurrentRepository = repo
+	var transport *http.Transport
+	Repositories = make(map[string]*Repository)
+	for name, client := range config.CurrentSiteConfig.Clients {
+		repo := Repository{
+			client:       client,
+			QueryIndex:   queryIndex,
+			CacheManager: cm,
+			results:      &resultMemo{results: make(map[string][]map[string]rdf.Term)},
+		}
+		if path, isFile := config.LocalFile(client.Endpoint); isFile {
+			store, err := localstore.Load(path)
+			if err != nil {
+				return errors.New("Failed to load the RDF file of SPARQL client " + name + ". Error: " + err.Error())
+			}
+			logger.Debug("Loaded " + strconv.Itoa(store.Len()) + " triples from " + path + ".")
+			repo.store = store
+			repo.httpClient = &http.Client{Transport: localstore.Transport{Store: store}}
+		} else {
+			if transport == nil {
+				transport = newTransport(config.CurrentSiteConfig)
+			}
+			repo.httpClient = &http.Client{Transport: transport}
+		}
+		Repositories[name] = &repo
+	}
+
+	CurrentRepository = *Repositories[config.CurrentSiteConfig.DefaultClient]
 
 	return nil
 }
 
-func (r *Repository) QueryCall(query string) (*string, error) {
-	form := url.Values{}
-	form.Set("query", query)
-	b := form.Encode()
+// GetRepository returns the repository of the named SPARQL client, or the
+// default repository if no name is given.
+func GetRepository(name string) (*Repository, error) {
+	if name == "" {
+		return &CurrentRepository, nil
+	}
+
+	repo, exists := Repositories[name]
+	if !exists {
+		return nil, errors.New("No SPARQL client named " + name + " has been configured.")
+	}
+	return repo, nil
+}
+
+const resultsAccept = "application/sparql-results+json"
+const graphAccept = "application/n-triples, text/turtle;q=0.9"
+
+// getURL returns the URL of the query as a GET request, or "" if it's to be
+// sent as a POST request.
+func (r *Repository) getURL(body string) string {
+	if r.client.Method != "get" {
+		return ""
+	}
+	separator := "?"
+	if strings.Contains(r.client.Endpoint, "?") {
+		separator = "&"
+	}
+	queryURL := r.client.Endpoint + separator + body
+	if len(queryURL) > r.client.MaxGetLength {
+		return ""
+	}
+	return queryURL
+}
 
-	req, err := http.NewRequest("POST", r.client.Endpoint, bytes.NewBufferString(b))
+func (r *Repository) newQueryRequest(ctx context.Context, body string, accept string) (*http.Request, error) {
+	var req *http.Request
+	var err error
+	if queryURL := r.getURL(body); queryURL != "" {
+		req, err = http.NewRequestWithContext(ctx, "GET", queryURL, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "POST", r.client.Endpoint, bytes.NewBufferString(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Content-Length", strconv.Itoa(len(b)))
-	req.Header.Set("Accept", "application/sparql-results+json")
+	req.Header.Set("Accept", accept)
 
 	for header, content := range r.client.Headers {
 		req.Header.Set(header, content)
 	}
 
-	resp, err := r.httpClient.Do(req)
+	if r.client.Username != "" || r.client.Password != "" {
+		req.SetBasicAuth(r.client.Username, r.client.Password)
+	} else if r.client.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.client.Token)
+	}
+
+	return req, nil
+}
+
+// statusError is returned when the endpoint responds with a non-OK status.
+type statusError struct {
+	StatusCode int
+	// RetryAfter is the delay the endpoint asked for before retrying
+	RetryAfter time.Duration
+	// Body is the response, which often explains the error
+	Body string
+}
+
+func (e statusError) Error() string {
+	return "Received bad response(HTTP: " + strconv.Itoa(e.StatusCode) + ") from SPARQL endpoint."
+}
+
+// isRetryable reports whether a failed query call might succeed if retried.
+func isRetryable(err error) bool {
+	var statusErr statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// queryContext returns the context for a single query call, which is cancelled
+// if the given timeout is exceeded.
+func (r *Repository) queryContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// staleConnection reports whether a request failed because the endpoint
+// closed the reused keep-alive connection it was sent on, in which case it can
+// be sent again on a new one. Other failures, like unknown hosts, refused
+// connections or timeouts, are left to the retries.
+func staleConnection(err error, reused bool) bool {
+	return reused && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE))
+}
+
+// openQueryCall issues a single query request and returns the response body
+// if the endpoint responded with an OK status.
+func (r *Repository) openQueryCall(ctx context.Context, body string, accept string) (io.ReadCloser, error) {
+	var reused bool
+	trace := httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+	})
+	req, err := r.newQueryRequest(trace, body, accept)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	resp, err := r.httpClient.Do(req)
+	if err != nil && ctx.Err() == nil && staleConnection(err, reused) {
+		// reconnect once and retry
+		r.httpClient.CloseIdleConnections()
+		req, err = r.newQueryRequest(ctx, body, accept)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err = r.httpClient.Do(req)
+	}
 	if err != nil {
 		return nil, err
 	}
-	responseString := string(bodyBytes)
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Received bad(HTTP: " + resp.Status + ") response from SPARQL endpoint:")
-		fmt.Println(responseString)
-		return nil, errors.New("Received bad response from SPARQL endpoint")
+		defer resp.Body.Close()
+		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Error("Received bad(HTTP: " + resp.Status + ") response from SPARQL endpoint:")
+		logger.Error(string(bodyBytes))
+		return nil, statusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp), Body: string(bodyBytes)}
+	}
+
+	return resp.Body, nil
+}
+
+func (r *Repository) queryCallOnce(body string, accept string, timeout time.Duration) (*string, error) {
+	// the timeout starts once the query is sent
+	release := r.acquireSlot()
+	defer release()
+	ctx, cancel := r.queryContext(timeout)
+	defer cancel()
+
+	responseBody, err := r.openQueryCall(ctx, body, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer responseBody.Close()
+
+	bodyBytes, err := ioutil.ReadAll(responseBody)
+	if err != nil {
+		return nil, err
 	}
+	responseString := string(bodyBytes)
 
 	return &responseString, nil
 }
 
-func (r *Repository) Query(queryLocation string, arguments ...interface{}) ([]map[string]rdf.Term, error) {
+// Interrupted is cancelled when the build is interrupted, which stops the
+// waits before retrying failed queries.
+var Interrupted = context.Background()
+
+// withRetries calls the given function until it succeeds, retrying failed
+// calls, with exponential backoff, as many times as configured for the client.
+// Endpoints can ask for a longer delay using the Retry-After header. Waiting
+// for a retry ends with the error of Interrupted when the build is
+// interrupted.
+func (r *Repository) withRetries(call func() error) error {
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err := call()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= r.client.Retries || !isRetryable(err) {
+			return err
+		}
+
+		wait := delay
+		var statusErr statusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+		logger.Warn("Query failed, retrying in " + wait.String() + ". Error: " + err.Error())
+		select {
+		case <-time.After(wait):
+		case <-Interrupted.Done():
+			return Interrupted.Err()
+		}
+		delay *= 2
+	}
+}
+
+func encodeQuery(query string) string {
+	form := url.Values{}
+	form.Set("query", query)
+	return form.Encode()
+}
+
+func (r *Repository) queryCall(query string, accept string) (*string, error) {
+	b := encodeQuery(query)
+	timeout := r.timeout(query)
+
+	var response *string
+	err := r.withRetries(func() error {
+		var err error
+		response, err = r.queryCallOnce(b, accept, timeout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// QueryCall issues the given query and returns the raw SPARQL JSON response.
+func (r *Repository) QueryCall(query string) (*string, error) {
+	return r.queryCall(query, resultsAccept)
+}
+
+// prepareQuery looks up the given query and injects its arguments.
+func (r *Repository) prepareQuery(queryLocation string, arguments ...interface{}) (string, error) {
 	query, exists := r.QueryIndex[queryLocation] // QueryIndex includes query/, wanted or not? not?
 	if !exists {
-		return nil, errors.New("The given query could not be found. " + queryLocation)
+		return "", errors.New("The given query could not be found. " + queryLocation)
+	}
+	if err := CheckSyntax(query); err != nil {
+		return "", utils.ErrorExit("The query "+queryLocation+" has a syntax error.", err)
 	}
 
+	query, err := substituteParams(query, r.queryParams())
+	if err != nil {
+		return "", utils.ErrorExit("Failed to prepare query "+queryLocation+".", err)
+	}
+	query = PrependPrefixes(query, config.CurrentSiteConfig.Prefixes)
+
 	if len(arguments) > 0 {
 		for _, argument := range arguments {
 			argument := cast.ToString(argument)
@@ -100,56 +389,298 @@ func (r *Repository) Query(queryLocation string, arguments ...interface{}) ([]ma
 		}
 	}
 
-	if r.verbose {
-		if len(arguments) > 0 {
-			promt := fmt.Sprintf("Issuing parameterized query %v with arguments: %v.", queryLocation, arguments)
-			fmt.Println(promt)
-		} else {
-			fmt.Println("Issuing query: " + queryLocation)
-		}
+	if len(arguments) > 0 {
+		logger.Debug(fmt.Sprintf("Issuing parameterized query %v with arguments: %v.", queryLocation, arguments))
+	} else {
+		logger.Debug("Issuing query: " + queryLocation)
 	}
 
-	file, err := r.CacheManager.GetCache(queryLocation, query)
+	return query, nil
+}
+
+func (r *Repository) Query(queryLocation string, arguments ...interface{}) ([]map[string]rdf.Term, error) {
+	query, err := r.prepareQuery(queryLocation, arguments...)
 	if err != nil {
 		return nil, err
 	}
+	return r.queryPages(queryLocation, query)
+}
 
-	var parsedResponse []map[string]rdf.Term
-	if file != nil {
-		parsedResponse := ParseSPARQLJSON(file)
+// queryPages issues the given prepared query, fetching its results in pages
+// if a page size is set.
+func (r *Repository) queryPages(queryLocation string, query string) ([]map[string]rdf.Term, error) {
+	if !r.paged(query) {
+		return r.queryPrepared(queryLocation, query)
+	}
+
+	results := make([]map[string]rdf.Term, 0)
+	for offset := 0; ; offset += r.pageSize {
+		page, err := r.queryPrepared(queryLocation, r.pageQuery(query, offset))
 		if err != nil {
 			return nil, err
 		}
+		results = append(results, page...)
+		if last, err := r.lastPage(len(page)); last || err != nil {
+			return results, err
+		}
+	}
+}
 
-		file.Close()
-		return parsedResponse, nil
+// queryPrepared issues the given prepared query, or reads its response from
+// the cache.
+func (r *Repository) queryPrepared(queryLocation string, query string) ([]map[string]rdf.Term, error) {
+	file, err := r.getCache(queryLocation, query)
+	if err != nil {
+		return nil, err
 	}
 
-	jsonString, err := r.QueryCall(query)
+	if file != nil {
+		defer file.Close()
+		return r.parseRows(query, file), nil
+	}
+
+	start := time.Now()
+	response, err := r.queryCall(query, r.accept(query))
 	if err != nil {
+		return nil, queryFailed(queryLocation, query, start, err)
+	}
+
+	if err := r.CacheManager.SetCache(queryLocation, r.EndpointKey(), query, *response); err != nil {
 		return nil, err
 	}
 
-	if err := r.CacheManager.SetCache(queryLocation, query, *jsonString); err != nil {
+	return r.parseRows(query, strings.NewReader(*response)), nil
+}
+
+// MemoizedQuery works like Query but keeps the results in memory, so that
+// issuing the same query with the same arguments again during the build
+// doesn't require another request or reading and parsing the cache.
+func (r *Repository) MemoizedQuery(queryLocation string, arguments ...interface{}) ([]map[string]rdf.Term, error) {
+	query, err := substituteParams(r.QueryIndex[queryLocation], r.queryParams())
+	if err != nil {
 		return nil, err
 	}
+	query = PrependPrefixes(query, config.CurrentSiteConfig.Prefixes)
+	key := cache.ContentHash(r.EndpointKey(), query)
+	for _, argument := range arguments {
+		key += "\x00" + cast.ToString(argument)
+	}
+
+	r.results.Lock()
+	results, exists := r.results.results[key]
+	r.results.Unlock()
+	if exists {
+		return results, nil
+	}
 
-	var resultReader = strings.NewReader(*jsonString)
-	parsedResponse = ParseSPARQLJSON(resultReader)
+	results, err = r.Query(queryLocation, arguments...)
 	if err != nil {
 		return nil, err
 	}
 
-	return parsedResponse, nil
+	r.results.Lock()
+	r.results.results[key] = results
+	r.results.Unlock()
+	return results, nil
 }
 
-type Results struct {
-	Variables []string
-	Results   results
+// QueryStream issues the given query and calls handle for each result row as
+// the response is being parsed, rather than keeping all rows in memory. The
+// response is written to the cache while it's being read.
+func (r *Repository) QueryStream(queryLocation string, handle func(map[string]rdf.Term) error, arguments ...interface{}) error {
+	query, err := r.prepareQuery(queryLocation, arguments...)
+	if err != nil {
+		return err
+	}
+
+	if !r.paged(query) {
+		return r.streamPrepared(queryLocation, query, handle)
+	}
+
+	for offset := 0; ; offset += r.pageSize {
+		rows := 0
+		err := r.streamPrepared(queryLocation, r.pageQuery(query, offset), func(row map[string]rdf.Term) error {
+			rows++
+			return handle(row)
+		})
+		if err != nil {
+			return err
+		}
+		if last, err := r.lastPage(rows); last || err != nil {
+			return err
+		}
+	}
+}
+
+// idleReader cancels a streamed response when a read waits longer than the
+// timeout for data.
+type idleReader struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.timer.Reset(r.timeout)
+	n, err := r.ReadCloser.Read(p)
+	r.timer.Stop()
+	return n, err
+}
+
+// openResponse issues the given prepared query and returns the body of its
+// response, which is canceled by calling the returned function.
+func (r *Repository) openResponse(query string) (io.ReadCloser, func(), error) {
+	b := encodeQuery(query)
+	timeout := r.timeout(query)
+
+	var responseBody io.ReadCloser
+	cancel := func() {}
+	err := r.withRetries(func() error {
+		// the slot is only held until the response starts, as rendering the
+		// streamed rows may issue queries of its own
+		release := r.acquireSlot()
+		defer release()
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		// the timeout covers the response headers and then each wait for
+		// more of the body, but not the rendering of the rows in between
+		var timer *time.Timer
+		if timeout > 0 {
+			timer = time.AfterFunc(timeout, cancel)
+		}
+		var err error
+		responseBody, err = r.openQueryCall(ctx, b, r.accept(query))
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			cancel()
+			return err
+		}
+		if timer != nil {
+			responseBody = &idleReader{ReadCloser: responseBody, timer: timer, timeout: timeout}
+		}
+		return nil
+	})
+	return responseBody, cancel, err
+}
+
+// streamPrepared issues the given prepared query, or reads its response from
+// the cache, and calls handle for each result row.
+func (r *Repository) streamPrepared(queryLocation string, query string, handle func(map[string]rdf.Term) error) error {
+	file, err := r.getCache(queryLocation, query)
+	if err != nil {
+		return err
+	}
+
+	if file != nil {
+		defer file.Close()
+		return r.parseResults(query, file, handle)
+	}
+
+	start := time.Now()
+	responseBody, cancel, err := r.openResponse(query)
+	if err != nil {
+		return queryFailed(queryLocation, query, start, err)
+	}
+	defer cancel()
+	defer responseBody.Close()
+
+	cacheWriter, err := r.CacheManager.NewCacheWriter(queryLocation, r.EndpointKey(), query)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = responseBody
+	if cacheWriter != nil {
+		reader = io.TeeReader(responseBody, cacheWriter)
+	}
+
+	if err := r.parseResults(query, reader, handle); err != nil {
+		if cacheWriter != nil {
+			cacheWriter.Abort()
+		}
+		return err
+	}
+
+	if cacheWriter != nil {
+		// read what might remain after the bindings so that the cache is complete
+		if _, err := io.Copy(io.Discard, reader); err != nil {
+			cacheWriter.Abort()
+			return err
+		}
+		return cacheWriter.Commit()
+	}
+
+	return nil
 }
 
-type results struct {
-	Bindings []map[string]binding
+// ResponseHash returns a hash of the response to the given query, making sure
+// the response is cached so that rendering the view doesn't query again.
+func (r *Repository) ResponseHash(queryLocation string, arguments ...interface{}) (string, error) {
+	query, err := r.prepareQuery(queryLocation, arguments...)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	if !r.paged(query) {
+		if err := r.writeResponse(hash, queryLocation, query); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	for offset := 0; ; offset += r.pageSize {
+		var page bytes.Buffer
+		if err := r.writeResponse(&page, queryLocation, r.pageQuery(query, offset)); err != nil {
+			return "", err
+		}
+		hash.Write(page.Bytes())
+		if last, err := r.lastPage(len(r.parseRows(query, &page))); last || err != nil {
+			return hex.EncodeToString(hash.Sum(nil)), err
+		}
+	}
+}
+
+// writeResponse writes the response to the given prepared query to w, making
+// sure the response is cached. Responses are streamed rather than held in
+// memory.
+func (r *Repository) writeResponse(w io.Writer, queryLocation string, query string) error {
+	file, err := r.getCache(queryLocation, query)
+	if err != nil {
+		return err
+	}
+
+	if file != nil {
+		defer file.Close()
+		_, err := io.Copy(w, file)
+		return err
+	}
+
+	start := time.Now()
+	responseBody, cancel, err := r.openResponse(query)
+	if err != nil {
+		return queryFailed(queryLocation, query, start, err)
+	}
+	defer cancel()
+	defer responseBody.Close()
+
+	cacheWriter, err := r.CacheManager.NewCacheWriter(queryLocation, r.EndpointKey(), query)
+	if err != nil {
+		return err
+	}
+	if cacheWriter == nil {
+		_, err := io.Copy(w, responseBody)
+		return err
+	}
+
+	// the response is streamed into the cache while it's written to w
+	if _, err := io.Copy(io.MultiWriter(w, cacheWriter), responseBody); err != nil {
+		cacheWriter.Abort()
+		return err
+	}
+	return cacheWriter.Commit()
 }
 
 type binding struct {
@@ -161,48 +692,140 @@ type binding struct {
 
 var xsdString, _ = rdf.NewIRI("http://www.w3.org/2001/XMLSchema#string")
 
-func ParseSPARQLJSON(r io.Reader) []map[string]rdf.Term {
-	var results Results
-	err := json.NewDecoder(r).Decode(&results)
+func parseBinding(binding map[string]binding) map[string]rdf.Term {
+	parsedBinding := make(map[string]rdf.Term)
+	for key, value := range binding {
+		var term rdf.Term
+		var err error
+		switch value.Type {
+		case "bnode":
+			term, err = rdf.NewBlank(value.Value)
+		case "uri":
+			term, err = rdf.NewIRI(value.Value)
+		case "literal":
+			if value.Lang != "" {
+				if term, err = rdf.NewLangLiteral(value.Value, value.Lang); err == nil {
+					break
+				}
+				// tags with several subtags aren't supported and are dropped
+				err = nil
+			}
+			if value.DataType != "" {
+				if iri, err := rdf.NewIRI(value.DataType); err == nil {
+					term = rdf.NewTypedLiteral(value.Value, iri)
+					break
+				}
+			}
+			// Untyped literals are typed as xsd:string
+			term = rdf.NewTypedLiteral(value.Value, xsdString)
+		case "typed-literal":
+			iri, err := rdf.NewIRI(value.DataType)
+			term = rdf.NewTypedLiteral(value.Value, iri)
+			if err != nil {
+				term = nil
+				err = nil
+			}
+		default:
+			term = nil
+			err = errors.New("Unknown RDF type")
+		}
 
+		if err == nil {
+			parsedBinding[key] = term
+		}
+	}
+	return parsedBinding
+}
+
+// expectDelim reads the next token and returns an error if it isn't the given
+// delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
 	if err != nil {
-		return nil
+		return err
+	}
+	if token != delim {
+		return errors.New("Unexpected token " + fmt.Sprint(token) + " in SPARQL JSON response.")
 	}
+	return nil
+}
 
-	var parsedResults []map[string]rdf.Term
-	for _, binding := range results.Results.Bindings {
-		parsedBinding := make(map[string]rdf.Term)
-		for key, value := range binding {
-			var term rdf.Term
-			var err error
-			switch value.Type {
-			case "bnode":
-				term, err = rdf.NewBlank(value.Value)
-			case "uri":
-				term, err = rdf.NewIRI(value.Value)
-			case "literal":
-				// Untyped literals are typed as xsd:string
-				if value.Lang != "" {
-					term, err = rdf.NewLangLiteral(value.Value, value.Lang)
+// ParseSPARQLJSONStream parses SPARQL JSON results incrementally and calls
+// handle for each binding as soon as it has been read.
+func ParseSPARQLJSONStream(r io.Reader, handle func(map[string]rdf.Term) error) error {
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		if key != "results" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := expectDelim(decoder, '{'); err != nil {
+			return err
+		}
+
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			if key != "bindings" {
+				var skipped json.RawMessage
+				if err := decoder.Decode(&skipped); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := expectDelim(decoder, '['); err != nil {
+				return err
+			}
+
+			for decoder.More() {
+				var b map[string]binding
+				if err := decoder.Decode(&b); err != nil {
+					return err
 				}
-				term = rdf.NewTypedLiteral(value.Value, xsdString)
-			case "typed-literal":
-				iri, err := rdf.NewIRI(value.DataType)
-				term = rdf.NewTypedLiteral(value.Value, iri)
-				if err != nil {
-					term = nil
-					err = nil
+				if err := handle(parseBinding(b)); err != nil {
+					return err
 				}
-			default:
-				term = nil
-				err = errors.New("Unknown RDF type")
 			}
 
-			if err == nil {
-				parsedBinding[key] = term
+			if err := expectDelim(decoder, ']'); err != nil {
+				return err
 			}
 		}
-		parsedResults = append(parsedResults, parsedBinding)
+
+		if err := expectDelim(decoder, '}'); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+func ParseSPARQLJSON(r io.Reader) []map[string]rdf.Term {
+	var parsedResults []map[string]rdf.Term
+	err := ParseSPARQLJSONStream(r, func(row map[string]rdf.Term) error {
+		parsedResults = append(parsedResults, row)
+		return nil
+	})
+
+	if err != nil {
+		return nil
 	}
 
 	return parsedResults
@@ -0,0 +1,367 @@
+package sparql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+var parseSPARQLJSONStreamTests = []struct {
+	response     string
+	expectedRows int
+}{
+	{`{"head": {"vars": ["a"]}, "results": {"bindings": [{"a": {"type": "uri", "value": "http://example.org/1"}}, {"a": {"type": "literal", "value": "2"}}]}}`, 2},
+	// the bindings don't have to come after the head
+	{`{"results": {"distinct": false, "bindings": [{"a": {"type": "bnode", "value": "b0"}}]}, "head": {"vars": ["a"]}}`, 1},
+	{`{"head": {"vars": ["a"]}, "results": {"bindings": []}}`, 0},
+	// ASK responses have no bindings
+	{`{"head": {}, "boolean": true}`, 0},
+}
+
+func TestParseSPARQLJSONStream(t *testing.T) {
+	for _, test := range parseSPARQLJSONStreamTests {
+		rows := 0
+		err := ParseSPARQLJSONStream(strings.NewReader(test.response), func(row map[string]rdf.Term) error {
+			if _, exists := row["a"]; !exists {
+				t.Errorf("Expected row to contain variable \"a\" in response %s", test.response)
+			}
+			rows++
+			return nil
+		})
+
+		if err != nil {
+			t.Errorf("Failed to parse response %s, got error: %v", test.response, err)
+		}
+		if rows != test.expectedRows {
+			t.Errorf("Expected %d rows but got %d for response %s", test.expectedRows, rows, test.response)
+		}
+	}
+}
+
+func TestParseSPARQLJSONStreamWithError(t *testing.T) {
+	responses := []string{
+		`[]`,
+		`{"results": {"bindings": [{"a": {"type": "uri", "value": "http://example.org/1"}}`,
+		`{"results": {"bindings": {}}}`,
+	}
+
+	for _, response := range responses {
+		err := ParseSPARQLJSONStream(strings.NewReader(response), func(row map[string]rdf.Term) error {
+			return nil
+		})
+		if err == nil {
+			t.Errorf("Expected response %s to be invalid, but got nil", response)
+		}
+	}
+}
+
+var queryFormTests = []struct {
+	query string
+	want  string
+}{
+	{"SELECT * WHERE { ?s ?p ?o }", "SELECT"},
+	{"PREFIX schema: <http://schema.org/>\nconstruct { ?s ?p ?o } WHERE { ?s ?p ?o }", "CONSTRUCT"},
+	{"# SELECT is mentioned in this comment\nDESCRIBE <http://example.org/a>", "DESCRIBE"},
+	{"PREFIX ex: <http://example.org/select/>\nASK { ?s ex:p \"SELECT\" }", "ASK"},
+	{"", ""},
+}
+
+func TestParseAskResult(t *testing.T) {
+	if answer, err := parseAskResult(strings.NewReader(`{"head": {}, "boolean": true}`)); err != nil || !answer {
+		t.Errorf("Expected true, got %v and %v", answer, err)
+	}
+	if _, err := parseAskResult(strings.NewReader(`{"head": {"vars": []}, "results": {"bindings": []}}`)); err == nil {
+		t.Error("Expected an error for a response without a boolean")
+	}
+}
+
+func TestCheckSyntax(t *testing.T) {
+	var tests = []struct {
+		query    string
+		expected string
+	}{
+		{"PREFIX schema: <http://schema.org/>\nSELECT * { ?s schema:name \"{\" . FILTER(?a < 3) }", ""},
+		{"SELECT * {\n  ?s <http://example.org/#a> '''}\n''' # }\n}", ""},
+		{"SELECT ?s WHERE { ?s ?p {{.}} }", ""},
+		{"SELECT * { <http://ex.org/{{.}}#this> ?p ?o }", ""},
+		{"SELECT * { <http://ex.org/{{ .id }}#this> ?p ?o }", ""},
+		{"SELECT * {\n  ?s ?p ?o\n", "Line 1, column 10: The { is never closed."},
+		{"SELECT * {\n  ?s ?p ?o ) }", "Line 2, column 12: The ) doesn't close any bracket."},
+		{"SELECT * {\n  ?s ?p \"é\n}", "Line 2, column 9: The string isn't closed on its line."},
+		{"PREFIX schema <http://schema.org/>\nSELECT * {}", "Line 1, column 1: PREFIX must be followed by a prefix name ending in : and an IRI."},
+		{"{ ?s ?p ?o }", "Line 1, column 1: The query has no SELECT, CONSTRUCT, DESCRIBE or ASK form."},
+	}
+	for _, test := range tests {
+		err := CheckSyntax(test.query)
+		if (err == nil && test.expected != "") || (err != nil && err.Error() != test.expected) {
+			t.Errorf("Expected %q for %q, got %v", test.expected, test.query, err)
+		}
+	}
+}
+
+func TestQueryForm(t *testing.T) {
+	for _, test := range queryFormTests {
+		if got := QueryForm(test.query); got != test.want {
+			t.Errorf("Expected query form %q but got %q for query %s", test.want, got, test.query)
+		}
+	}
+}
+
+func TestParseGraph(t *testing.T) {
+	response := `<http://example.org/a> <http://schema.org/name> "Alice"@en .
+<http://example.org/a> <http://schema.org/knows> <http://example.org/b> .
+<http://example.org/a> <http://schema.org/knows> <http://example.org/c> .
+<http://example.org/b> <http://schema.org/name> "Bob" .
+`
+	graph, err := ParseGraph(strings.NewReader(response))
+	if err != nil {
+		t.Fatalf("Failed to parse graph, got error: %v", err)
+	}
+
+	if len(graph.Triples) != 4 {
+		t.Errorf("Expected 4 triples but got %d", len(graph.Triples))
+	}
+	if len(graph.Subjects()) != 2 {
+		t.Errorf("Expected 2 subjects but got %d", len(graph.Subjects()))
+	}
+	if len(graph.Properties("http://example.org/a")) != 2 {
+		t.Errorf("Expected 2 properties but got %d", len(graph.Properties("http://example.org/a")))
+	}
+	if len(graph.Objects("http://example.org/a", "http://schema.org/knows")) != 2 {
+		t.Errorf("Expected 2 objects but got %d", len(graph.Objects("http://example.org/a", "http://schema.org/knows")))
+	}
+	if got := graph.Object("http://example.org/b", "http://schema.org/name"); got == nil || got.String() != "Bob" {
+		t.Errorf("Expected object \"Bob\" but got %v", got)
+	}
+	if got := graph.Object("http://example.org/b", "http://schema.org/knows"); got != nil {
+		t.Errorf("Expected no object but got %v", got)
+	}
+}
+
+func TestSubstituteParams(t *testing.T) {
+	params := map[string]interface{}{
+		"class": "<http://schema.org/Book>",
+		"lang":  "en",
+		"title": "A \"quoted\" }title",
+		"limit": 10,
+	}
+
+	query, err := substituteParams(`SELECT * { ?s a {{class}} ; ?p ?o FILTER(lang(?o) = {{ lang }} || ?o = {{title}}) } LIMIT {{limit}} # {{.}}`, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `SELECT * { ?s a <http://schema.org/Book> ; ?p ?o FILTER(lang(?o) = "en" || ?o = "A \"quoted\" }title") } LIMIT 10 # {{.}}`
+	if query != expected {
+		t.Errorf("Expected %s, got %s", expected, query)
+	}
+
+	if _, err := substituteParams("SELECT * { ?s a {{missing}} }", params); err == nil {
+		t.Error("Expected an error for an undefined parameter")
+	}
+
+	if _, err := substituteParams("SELECT * { ?s a {{class}} }", map[string]interface{}{"class": "<http://example.org/> } DROP ALL { <x>"}); err == nil {
+		t.Error("Expected an error for an IRI with illegal characters")
+	}
+}
+
+func TestQueryParamsPrecedence(t *testing.T) {
+	defer func(siteConfig config.SiteConfig) {
+		config.CurrentSiteConfig = siteConfig
+	}(config.CurrentSiteConfig)
+	config.CurrentSiteConfig.QueryParams = map[string]interface{}{"site": 1, "view": 1, "cli": 1}
+	config.CurrentSiteConfig.Variables = map[string]interface{}{"cli": 3}
+
+	repo := (&Repository{}).WithParams(map[string]interface{}{"view": 2, "cli": 2})
+	if params := fmt.Sprint(repo.queryParams()); params != "map[cli:3 since:1970-01-01 00:00:00 +0000 UTC site:1 view:2]" {
+		t.Errorf("Unexpected query parameters %s", params)
+	}
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	config.CurrentSiteConfig.Since = &since
+	query, err := substituteParams("FILTER (?modified > {{since}})", repo.queryParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != `FILTER (?modified > "2024-05-01T12:00:00Z"^^<http://www.w3.org/2001/XMLSchema#dateTime>)` {
+		t.Errorf("Unexpected query %s", query)
+	}
+	if !UsesParam("FILTER (?modified > {{ since }})", SinceParam) || UsesParam("SELECT * {}", SinceParam) {
+		t.Error("Expected only the first query to use the since parameter")
+	}
+}
+
+func TestPrependPrefixes(t *testing.T) {
+	prefixes := map[string]string{
+		"schema": "http://schema.org/",
+		"dc":     "http://purl.org/dc/terms/",
+		"":       "http://example.org/",
+	}
+
+	query := PrependPrefixes("prefix dc: <http://purl.org/dc/elements/1.1/>\nSELECT * { ?s dc:title ?t }", prefixes)
+
+	// the query's own declaration of dc wins
+	expected := "PREFIX : <http://example.org/>\nPREFIX schema: <http://schema.org/>\nprefix dc: <http://purl.org/dc/elements/1.1/>\nSELECT * { ?s dc:title ?t }"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	response := `{"head": {"vars": ["s", "label"]}, "results": {"bindings": [{"s": {"type": "uri", "value": "http://example.org/1"}, "label": {"type": "literal", "value": "One, \"first\"", "xml:lang": "en"}}, {"s": {"type": "uri", "value": "http://example.org/2"}}]}}`
+	rows := ParseSPARQLJSON(strings.NewReader(response))
+
+	var csv strings.Builder
+	if err := WriteResultsCSV(&csv, rows); err != nil {
+		t.Fatal(err)
+	}
+	expected := "label,s\n\"One, \"\"first\"\"\",http://example.org/1\n,http://example.org/2\n"
+	if csv.String() != expected {
+		t.Errorf("Expected CSV %q, got %q", expected, csv.String())
+	}
+
+	var json strings.Builder
+	if err := WriteResultsJSON(&json, rows); err != nil {
+		t.Fatal(err)
+	}
+	again := ParseSPARQLJSON(strings.NewReader(json.String()))
+	if len(again) != 2 || again[0]["label"].String() != "One, \"first\"" || again[1]["s"].String() != "http://example.org/2" {
+		t.Errorf("Expected the JSON results to parse back to the same rows, got %s", json.String())
+	}
+}
+
+func TestFederation(t *testing.T) {
+	query := `SELECT * {
+  SERVICE <https://query.wikidata.org/sparql> { ?s ?p ?o }
+  SERVICE SILENT <https://example.org/sparql> { ?s ?p ?o }
+  service <https://query.wikidata.org/sparql> { ?o ?p ?s }
+  SERVICE ?endpoint { ?s ?p ?o }
+}`
+	services := Services(query)
+	if fmt.Sprint(services) != "[https://query.wikidata.org/sparql https://example.org/sparql]" {
+		t.Errorf("Unexpected services %v", services)
+	}
+
+	defer func(services map[string]config.ServiceConfig) { config.CurrentSiteConfig.Services = services }(config.CurrentSiteConfig.Services)
+	config.CurrentSiteConfig.Services = map[string]config.ServiceConfig{"query.wikidata.org": {Timeout: time.Minute}}
+	repo := Repository{client: config.ClientConfig{Timeout: 30 * time.Second}}
+	if timeout := repo.timeout(query); timeout != 90*time.Second {
+		t.Errorf("Expected the timeout of the service to be added, got %v", timeout)
+	}
+	if timeout := repo.timeout("SELECT * {}"); timeout != 30*time.Second {
+		t.Errorf("Expected the timeout of the client, got %v", timeout)
+	}
+
+	err := queryFailed("index.rq", query, time.Now(), statusError{StatusCode: 500, Body: "Remote endpoint example.org timed out"})
+	if !strings.Contains(err.Error(), "The federated query to https://example.org/sparql failed.") {
+		t.Errorf("Expected the failing service to be named, got %s", err)
+	}
+	err = queryFailed("index.rq", query, time.Now(), statusError{StatusCode: 500})
+	if !strings.Contains(err.Error(), "It calls the federated services https://query.wikidata.org/sparql, https://example.org/sparql") {
+		t.Errorf("Expected the services to be listed, got %s", err)
+	}
+}
+
+func TestResultsFormats(t *testing.T) {
+	json := `{"head": {"vars": ["s", "label", "n", "b"]}, "results": {"bindings": [
+		{"s": {"type": "uri", "value": "http://example.org/1"}, "label": {"type": "literal", "value": "Tab\there", "xml:lang": "en"}, "n": {"type": "literal", "value": "2", "datatype": "http://www.w3.org/2001/XMLSchema#integer"}, "b": {"type": "bnode", "value": "b0"}},
+		{"s": {"type": "uri", "value": "http://example.org/2"}, "label": {"type": "literal", "value": "Say \"hi\""}}]}}`
+	xml := `<?xml version="1.0"?>
+<sparql xmlns="http://www.w3.org/2005/sparql-results#">
+  <head><variable name="s"/><variable name="label"/><variable name="n"/><variable name="b"/></head>
+  <results>
+    <result>
+      <binding name="s"><uri>http://example.org/1</uri></binding>
+      <binding name="label"><literal xml:lang="en">Tab&#9;here</literal></binding>
+      <binding name="n"><literal datatype="http://www.w3.org/2001/XMLSchema#integer">2</literal></binding>
+      <binding name="b"><bnode>b0</bnode></binding>
+    </result>
+    <result>
+      <binding name="s"><uri>http://example.org/2</uri></binding>
+      <binding name="label"><literal>Say "hi"</literal></binding>
+    </result>
+  </results>
+</sparql>`
+	tsv := "?s\t?label\t?n\t?b\n" +
+		"<http://example.org/1>\t\"Tab\\there\"@en\t2\t_:b0\n" +
+		"<http://example.org/2>\t\"Say \\\"hi\\\"\"\t\t\n"
+
+	expected := fmt.Sprint(ParseSPARQLJSON(strings.NewReader(json)))
+	if !strings.Contains(expected, "Tab\there") {
+		t.Fatalf("Unexpected JSON results %s", expected)
+	}
+	for format, response := range map[string]string{"xml": xml, "tsv": tsv} {
+		repo := Repository{client: config.ClientConfig{ResultsFormat: format}}
+		rows := repo.parseRows("SELECT * {}", strings.NewReader(response))
+		if actual := fmt.Sprint(rows); actual != expected {
+			t.Errorf("Expected the %s results to be parsed as\n%s\ngot\n%s", format, expected, actual)
+		}
+	}
+
+	// CSV results don't keep the types of literals
+	csv := "s,label,b\r\nhttp://example.org/1,\"Tab\there\",_:b0\r\nhttp://example.org/2,\"Say \"\"hi\"\"\",\r\n"
+	repo := Repository{client: config.ClientConfig{ResultsFormat: "csv"}}
+	rows := repo.parseRows("SELECT * {}", strings.NewReader(csv))
+	expectedCSV := "[map[b:b0 label:Tab\there s:http://example.org/1] map[label:Say \"hi\" s:http://example.org/2]]"
+	if actual := fmt.Sprint(rows); actual != expectedCSV {
+		t.Errorf("Expected the csv results to be parsed as\n%s\ngot\n%s", expectedCSV, actual)
+	}
+	if _, isIRI := rows[0]["s"].(rdf.IRI); !isIRI {
+		t.Errorf("Expected an IRI, got %T", rows[0]["s"])
+	}
+
+	// ASK queries are always answered in JSON
+	if accept := repo.accept("ASK {}"); accept != resultsAccept {
+		t.Errorf("Expected ASK queries to accept JSON, got %s", accept)
+	}
+}
+
+func TestRetriesInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func(previous context.Context) { Interrupted = previous }(Interrupted)
+	Interrupted = ctx
+	cancel()
+
+	r := Repository{client: config.ClientConfig{Retries: 3}}
+	calls := 0
+	start := time.Now()
+	err := r.withRetries(func() error {
+		calls++
+		return statusError{StatusCode: 503, RetryAfter: time.Hour}
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected the interrupt to stop the retries, got %v after %d calls", err, calls)
+	}
+}
+
+func TestStaleConnection(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	var tests = []struct {
+		err      error
+		reused   bool
+		expected bool
+	}{
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: io.EOF}, true, true},
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}}, true, true},
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: io.EOF}, false, false},
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: refused}, true, false},
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: &net.DNSError{Err: "no such host", Name: "example.org"}}, false, false},
+		{&url.Error{Op: "Post", URL: "http://example.org/sparql", Err: context.DeadlineExceeded}, true, false},
+	}
+	for _, test := range tests {
+		if stale := staleConnection(test.err, test.reused); stale != test.expected {
+			t.Errorf("Expected %v for %v on a reused connection %v, got %v", test.expected, test.err, test.reused, stale)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sparql
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError is a problem found in a query before it's sent, at a line and
+// column counted from 1.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	return "Line " + strconv.Itoa(e.Line) + ", column " + strconv.Itoa(e.Column) + ": " + e.Message
+}
+
+var closingBrackets = map[rune]rune{'{': '}', '(': ')', '[': ']'}
+var prefixDeclaration = regexp.MustCompile(`(?i)^PREFIX\s+([A-Za-z][\w.-]*)?:\s*<[^<>\s]*>`)
+
+// iriRef matches an IRI, which can contain the placeholders of query templates
+// like <http://example.org/{{.}}#this>.
+var iriRef = regexp.MustCompile(`^<(?:[^<>"{}|^` + "`" + `\\\s]|\{\{.*?\}\})*>`)
+
+// CheckSyntax checks the structure of a query: that it has a query form, that
+// its strings end and its brackets are balanced, and that its PREFIX
+// declarations are complete. It doesn't parse the full SPARQL grammar, so
+// queries passing it can still be rejected by the endpoint.
+func CheckSyntax(query string) error {
+	type opening struct {
+		bracket      rune
+		line, column int
+	}
+	var stack []opening
+
+	line, column := 1, 1
+	advance := func(text string) {
+		for _, r := range text {
+			if r == '\n' {
+				line, column = line+1, 1
+			} else {
+				column++
+			}
+		}
+	}
+	fail := func(message string) error {
+		return SyntaxError{Line: line, Column: column, Message: message}
+	}
+
+	for rest := query; rest != ""; {
+		r, size := utf8.DecodeRuneInString(rest)
+		switch {
+		case r == '#':
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			advance(rest[:end])
+			rest = rest[end:]
+			continue
+
+		case r == '"' || r == '\'':
+			length, err := stringLength(rest)
+			if err != "" {
+				return fail(err)
+			}
+			advance(rest[:length])
+			rest = rest[length:]
+			continue
+
+		case r == '<':
+			if match := iriRef.FindString(rest); match != "" {
+				advance(match)
+				rest = rest[len(match):]
+				continue
+			}
+
+		case r == '{' || r == '(' || r == '[':
+			stack = append(stack, opening{r, line, column})
+
+		case r == '}' || r == ')' || r == ']':
+			if len(stack) == 0 || closingBrackets[stack[len(stack)-1].bracket] != r {
+				return fail("The " + string(r) + " doesn't close any bracket.")
+			}
+			stack = stack[:len(stack)-1]
+
+		case r == 'P' || r == 'p':
+			if len(rest) >= 6 && strings.EqualFold(rest[:6], "PREFIX") && wordStart(query, len(query)-len(rest)) && !wordRune(rest, 6) {
+				match := prefixDeclaration.FindString(rest)
+				if match == "" {
+					return fail("PREFIX must be followed by a prefix name ending in : and an IRI.")
+				}
+				advance(match)
+				rest = rest[len(match):]
+				continue
+			}
+		}
+		advance(rest[:size])
+		rest = rest[size:]
+	}
+
+	if len(stack) > 0 {
+		unclosed := stack[len(stack)-1]
+		return SyntaxError{Line: unclosed.line, Column: unclosed.column, Message: "The " + string(unclosed.bracket) + " is never closed."}
+	}
+	if QueryForm(query) == "" {
+		return SyntaxError{Line: 1, Column: 1, Message: "The query has no SELECT, CONSTRUCT, DESCRIBE or ASK form."}
+	}
+	return nil
+}
+
+// stringLength returns the length of the string literal the text starts
+// with, or a message if it doesn't end. Short strings end on the same line,
+// long ones in triple quotes can span lines.
+func stringLength(text string) (int, string) {
+	quote := text[:1]
+	if strings.HasPrefix(text, strings.Repeat(quote, 3)) {
+		for i := 3; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+			} else if strings.HasPrefix(text[i:], strings.Repeat(quote, 3)) {
+				return i + 3, ""
+			}
+		}
+		return 0, "The string is never closed."
+	}
+
+	for i := 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '\n', '\r':
+			return 0, "The string isn't closed on its line."
+		case quote[0]:
+			return i + 1, ""
+		}
+	}
+	return 0, "The string is never closed."
+}
+
+// wordStart reports whether the byte at the offset doesn't continue a word,
+// variable or prefixed name.
+func wordStart(text string, offset int) bool {
+	if offset == 0 {
+		return true
+	}
+	return !wordRune(text, offset-1) && text[offset-1] != '?' && text[offset-1] != '$' && text[offset-1] != ':'
+}
+
+func wordRune(text string, offset int) bool {
+	if offset >= len(text) {
+		return false
+	}
+	c := text[offset]
+	return c == '_' || c == '-' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
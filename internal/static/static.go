@@ -1,11 +1,19 @@
 package static
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
 	"github.com/glaciers-in-archives/snowman/internal/utils"
 )
 
@@ -16,7 +24,7 @@ func ClearStatic() error {
 	}
 
 	for _, path := range lastStaticFiles {
-		fmt.Println("Removing: " + path)
+		logger.Info("Removing: " + path)
 		if err := os.Remove(path); err != nil {
 			return err
 		}
@@ -24,25 +32,256 @@ func ClearStatic() error {
 	return err
 }
 
-func CopyIn() error {
-	var writtenFiles []string
-	// This does not include checking if the "from" directory exists
-	err := filepath.Walk("static", func(path string, info os.FileInfo, err error) error {
-		if info.Mode().IsRegular() {
-			newPath := strings.Replace(path, "static/", "site/", 1)
-			if err := os.MkdirAll(filepath.Dir(newPath), 0770); err != nil {
-				return err
-			}
+// ManifestFile is the name of the file mapping fingerprinted static files to
+// their hashed names, written to the site directory.
+const ManifestFile = "asset-manifest.json"
+
+// Assets maps the paths of fingerprinted static files, relative to the static
+// directory, to their hashed paths. It's populated by CopyIn.
+var Assets = map[string]string{}
+
+// shouldFingerprint reports whether the static file at the given relative path
+// matches any of the configured fingerprint patterns.
+func shouldFingerprint(relativePath string) (bool, error) {
+	for _, pattern := range config.CurrentSiteConfig.Fingerprint {
+		matched, err := filepath.Match(pattern, filepath.ToSlash(relativePath))
+		if err != nil {
+			return false, err
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fingerprintedPath inserts a short content hash of the file before its
+// extension, turning css/app.css into css/app.a1b2c3d4.css.
+func fingerprintedPath(relativePath string, source string) (string, error) {
+	content, err := ioutil.ReadFile(source)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	extension := filepath.Ext(relativePath)
+	return strings.TrimSuffix(relativePath, extension) + "." + hex.EncodeToString(hash[:])[:8] + extension, nil
+}
+
+// copier copies the static directory into the site directory. Directories
+// and symlinks are handled while walking the static directory, the files are
+// collected and copied in parallel afterwards.
+type copier struct {
+	writtenFiles []string
+	writtenBytes int64
+	assets       map[string]string
+	// visited holds the resolved directories followed through symlinks to
+	// avoid copying cycles forever
+	visited map[string]bool
+	files   []staticFile
+}
+
+// staticFile is a regular file to copy from path to relativePath in the site
+// directory.
+type staticFile struct {
+	path         string
+	relativePath string
+	size         int64
+}
+
+// copyDir copies the contents of dir, which is found at relativeDir within the
+// static directory.
+func (c *copier) copyDir(dir string, relativeDir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 && config.CurrentSiteConfig.StaticCopy.EmptyDirs {
+		return os.MkdirAll(filepath.Join(config.CurrentSiteConfig.OutputDir, relativeDir), 0770)
+	}
+
+	for _, entry := range entries {
+		if err := c.copyEntry(filepath.Join(dir, entry.Name()), filepath.Join(relativeDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *copier) copyEntry(path string, relativePath string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if !config.CurrentSiteConfig.StaticCopy.FollowSymlinks {
+			return c.copySymlink(path, relativePath)
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if info, err = os.Stat(resolved); err != nil {
+			return err
+		}
+		path = resolved
+	}
+
+	if info.IsDir() {
+		resolved, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if c.visited[resolved] {
+			return errors.New("The static directory " + relativePath + " is part of a symlink cycle.")
+		}
+		c.visited[resolved] = true
+		defer delete(c.visited, resolved)
 
-			err := utils.CopyFile(path, newPath)
-			if err != nil {
-				return err
+		return c.copyDir(path, relativePath)
+	}
+
+	if info.Mode().IsRegular() {
+		c.files = append(c.files, staticFile{path: path, relativePath: relativePath, size: info.Size()})
+	}
+	return nil
+}
+
+// copyFile copies a static file, fingerprinting it if configured, and returns
+// its path in the site directory together with its hashed relative path, or
+// "" if it isn't fingerprinted.
+func copyFile(file staticFile) (string, string, error) {
+	relativePath := file.relativePath
+	fingerprint, err := shouldFingerprint(relativePath)
+	if err != nil {
+		return "", "", err
+	}
+	var hashedPath string
+	if fingerprint {
+		if hashedPath, err = fingerprintedPath(relativePath, file.path); err != nil {
+			return "", "", err
+		}
+		relativePath = hashedPath
+	}
+
+	// MkdirAll succeeds when another worker creates the directory first
+	newPath := filepath.Join(config.CurrentSiteConfig.OutputDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0770); err != nil {
+		return "", "", err
+	}
+	return newPath, hashedPath, utils.CopyFile(file.path, newPath)
+}
+
+// copyFiles copies the collected files with the given number of workers. No
+// more files are copied after the first error, which is returned.
+func (c *copier) copyFiles(jobs int) error {
+	newPaths := make([]string, len(c.files))
+	hashedPaths := make([]string, len(c.files))
+
+	var mutex sync.Mutex
+	var firstErr error
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range queue {
+				mutex.Lock()
+				failed := firstErr != nil
+				mutex.Unlock()
+				if failed {
+					continue
+				}
+
+				newPath, hashedPath, err := copyFile(c.files[index])
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mutex.Unlock()
+				newPaths[index], hashedPaths[index] = newPath, hashedPath
 			}
-			writtenFiles = append(writtenFiles, newPath)
+		}()
+	}
+	for index := range c.files {
+		queue <- index
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// the results are collected in the order of the walk to stay reproducible
+	for index, file := range c.files {
+		if hashedPaths[index] != "" {
+			c.assets[filepath.ToSlash(file.relativePath)] = filepath.ToSlash(hashedPaths[index])
 		}
+		c.writtenFiles = append(c.writtenFiles, newPaths[index])
+		c.writtenBytes += file.size
+	}
+	return nil
+}
+
+// copySymlink recreates the symlink at path in the site directory, pointing
+// to the same target.
+func (c *copier) copySymlink(path string, relativePath string) error {
+	target, err := os.Readlink(path)
+	if err != nil {
 		return err
-	})
+	}
 
-	err = utils.WriteLineSeperatedFile(writtenFiles, ".snowman/static_history.txt")
-	return err
+	newPath := filepath.Join(config.CurrentSiteConfig.OutputDir, relativePath)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0770); err != nil {
+		return err
+	}
+	if err := os.Remove(newPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Symlink(target, newPath); err != nil {
+		return err
+	}
+	c.writtenFiles = append(c.writtenFiles, newPath)
+	return nil
+}
+
+// CopyIn copies all static files into the site directory, with the given
+// number of files copied in parallel, and returns the sorted paths of the
+// files and the number of bytes copied. File modes are preserved, and symlinks are recreated or
+// followed depending on the configuration.
+func CopyIn(jobs int) ([]string, int64, error) {
+	c := copier{assets: map[string]string{}, visited: map[string]bool{}}
+	if root, err := filepath.Abs(config.CurrentSiteConfig.StaticDir); err == nil {
+		c.visited[root] = true
+	}
+	// This does not include checking if the "from" directory exists
+	if err := c.copyDir(config.CurrentSiteConfig.StaticDir, "."); err != nil {
+		return nil, 0, err
+	}
+	if err := c.copyFiles(jobs); err != nil {
+		return nil, 0, err
+	}
+	Assets = c.assets
+	writtenFiles := c.writtenFiles
+
+	if len(c.assets) > 0 {
+		manifestPath := filepath.Join(config.CurrentSiteConfig.OutputDir, ManifestFile)
+		manifest, err := json.MarshalIndent(c.assets, "", "  ")
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := ioutil.WriteFile(manifestPath, manifest, 0666); err != nil {
+			return nil, 0, err
+		}
+		writtenFiles = append(writtenFiles, manifestPath)
+	}
+
+	err := utils.WriteLineSeperatedFile(writtenFiles, ".snowman/static_history.txt")
+	sort.Strings(writtenFiles)
+	return writtenFiles, c.writtenBytes, err
 }
@@ -0,0 +1,147 @@
+//go:build !windows
+
+package static
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+// setup creates a project with static files in a temporary directory and
+// makes it the working directory.
+func setup(t *testing.T, copyConfig config.StaticCopyConfig) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	previous := config.CurrentSiteConfig
+	config.CurrentSiteConfig.StaticDir = "static"
+	config.CurrentSiteConfig.OutputDir = "site"
+	config.CurrentSiteConfig.Fingerprint = nil
+	config.CurrentSiteConfig.StaticCopy = copyConfig
+	t.Cleanup(func() { config.CurrentSiteConfig = previous })
+
+	for _, dir := range []string{"static/scripts", "static/empty", "site", ".snowman"} {
+		if err := os.MkdirAll(dir, 0770); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile("static/scripts/run.sh", []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("scripts/run.sh", "static/run.sh"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyInPreservesMode(t *testing.T) {
+	setup(t, config.StaticCopyConfig{})
+	if _, _, err := CopyIn(1); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat("site/scripts/run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %o", info.Mode().Perm())
+	}
+}
+
+func TestCopyInSymlinks(t *testing.T) {
+	setup(t, config.StaticCopyConfig{})
+	if _, _, err := CopyIn(1); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := os.Readlink("site/run.sh")
+	if err != nil {
+		t.Fatalf("Expected a symlink: %v", err)
+	}
+	if target != "scripts/run.sh" {
+		t.Errorf("Expected the symlink to point to scripts/run.sh, got %s", target)
+	}
+	if _, err := os.Stat("site/empty"); err == nil {
+		t.Error("Expected empty directories not to be copied")
+	}
+}
+
+func TestCopyInFollowSymlinks(t *testing.T) {
+	setup(t, config.StaticCopyConfig{FollowSymlinks: true, EmptyDirs: true})
+	if err := os.Symlink("..", filepath.Join("static", "scripts", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := CopyIn(1); err == nil {
+		t.Fatal("Expected a symlink cycle to be an error")
+	}
+	if err := os.Remove(filepath.Join("static", "scripts", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := CopyIn(1); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Lstat("site/run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Error("Expected the symlink target to be copied as a file")
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %o", info.Mode().Perm())
+	}
+	if info, err := os.Stat("site/empty"); err != nil || !info.IsDir() {
+		t.Error("Expected the empty directory to be created")
+	}
+}
+
+func TestCopyInParallel(t *testing.T) {
+	setup(t, config.StaticCopyConfig{})
+	config.CurrentSiteConfig.Fingerprint = []string{"css/*.css"}
+	for i := 0; i < 50; i++ {
+		dir := filepath.Join("static", "images", strconv.Itoa(i%5))
+		if err := os.MkdirAll(dir, 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(i)+".png"), []byte{byte(i)}, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll("static/css", 0770)
+	os.WriteFile("static/css/app.css", []byte("body {}"), 0666)
+
+	files, bytes, err := CopyIn(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the images, the stylesheet, the script, its symlink and the manifest
+	if len(files) != 54 || bytes != 50+7+10 {
+		t.Errorf("Unexpected %d files and %d bytes", len(files), bytes)
+	}
+	if _, err := os.Stat(filepath.Join("site", Assets["css/app.css"])); err != nil {
+		t.Errorf("Expected the fingerprinted stylesheet: %v", err)
+	}
+
+	// a directory in the way of a file can't be overwritten
+	os.Remove("site/images/3/13.png")
+	if err := os.MkdirAll("site/images/3/13.png", 0770); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CopyIn(8); err == nil {
+		t.Error("Expected an error for a file that can't be written")
+	}
+}
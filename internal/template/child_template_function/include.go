@@ -8,11 +8,12 @@ import (
 	"path/filepath"
 	text_template "text/template"
 
+	"github.com/glaciers-in-archives/snowman/internal/config"
 	"github.com/glaciers-in-archives/snowman/internal/template/function_loader"
 )
 
 func include(templatePath string, arguments ...interface{}) (html_template.HTML, error) {
-	templatePath = "templates/" + templatePath
+	templatePath = config.CurrentSiteConfig.TemplatesDir + "/" + templatePath
 	if _, err := os.Stat(templatePath); err != nil {
 		return "", errors.New("Unable to find the template file " + templatePath)
 	}
@@ -42,7 +43,7 @@ func include(templatePath string, arguments ...interface{}) (html_template.HTML,
 }
 
 func include_text(templatePath string, arguments ...interface{}) (string, error) {
-	templatePath = "templates/" + templatePath
+	templatePath = config.CurrentSiteConfig.TemplatesDir + "/" + templatePath
 	if _, err := os.Stat(templatePath); err != nil {
 		return "", errors.New("Unable to find the template file " + templatePath)
 	}
@@ -0,0 +1,19 @@
+package function
+
+import (
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/static"
+)
+
+// Asset returns the fingerprinted path of a static file, or the given path if
+// the file isn't fingerprinted. A leading "/" is kept.
+func Asset(path string) string {
+	if hashed, ok := static.Assets[strings.TrimPrefix(path, "/")]; ok {
+		if strings.HasPrefix(path, "/") {
+			return "/" + hashed
+		}
+		return hashed
+	}
+	return path
+}
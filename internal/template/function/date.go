@@ -0,0 +1,107 @@
+package function
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/knakk/rdf"
+	"github.com/spf13/cast"
+)
+
+// dateLayouts are the lexical forms of xsd:dateTime, xsd:date, xsd:gYearMonth
+// and xsd:gYear, with and without a timezone. Fractional seconds are accepted
+// by the layouts with seconds.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02Z07:00",
+	"2006-01-02",
+	"2006-01Z07:00",
+	"2006-01",
+	"2006Z07:00",
+	"2006",
+}
+
+// ParseDate parses an xsd:dateTime, xsd:date, xsd:gYearMonth or xsd:gYear
+// literal. Values without a timezone are taken to be in UTC.
+func ParseDate(value interface{}) (time.Time, error) {
+	var lexical string
+	switch value := value.(type) {
+	case time.Time:
+		return value, nil
+	case rdf.Term:
+		lexical = value.String()
+	default:
+		lexical = cast.ToString(value)
+	}
+
+	lexical = strings.TrimSpace(lexical)
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, lexical); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New("The value " + lexical + " isn't a valid xsd:dateTime, xsd:date, xsd:gYearMonth or xsd:gYear.")
+}
+
+// FormatDate formats a date literal using a Go time layout, like "2 January
+// 2006". It takes the layout first so that values can be piped into it.
+func FormatDate(layout string, value interface{}) (string, error) {
+	date, err := ParseDate(value)
+	if err != nil {
+		return "", err
+	}
+	return date.Format(layout), nil
+}
+
+func DateBefore(a interface{}, b interface{}) (bool, error) {
+	first, second, err := parseDates(a, b)
+	return first.Before(second), err
+}
+
+func DateAfter(a interface{}, b interface{}) (bool, error) {
+	first, second, err := parseDates(a, b)
+	return first.After(second), err
+}
+
+func parseDates(a interface{}, b interface{}) (time.Time, time.Time, error) {
+	first, err := ParseDate(a)
+	if err != nil {
+		return first, first, err
+	}
+	second, err := ParseDate(b)
+	return first, second, err
+}
+
+// SortByDate returns the rows sorted by the date bound to the variable, oldest
+// first. Rows keep their order if their dates are the same.
+func SortByDate(rows []map[string]rdf.Term, variable string) ([]map[string]rdf.Term, error) {
+	dates := make([]time.Time, len(rows))
+	for i, row := range rows {
+		term, bound := row[variable]
+		if !bound || term == nil {
+			return nil, errors.New("The variable " + variable + " isn't bound in every row to sort by.")
+		}
+		date, err := ParseDate(term)
+		if err != nil {
+			return nil, err
+		}
+		dates[i] = date
+	}
+
+	indices := make([]int, len(rows))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return dates[indices[i]].Before(dates[indices[j]])
+	})
+
+	sorted := make([]map[string]rdf.Term, len(rows))
+	for i, index := range indices {
+		sorted[i] = rows[index]
+	}
+	return sorted, nil
+}
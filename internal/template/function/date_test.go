@@ -0,0 +1,60 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/knakk/rdf"
+)
+
+func TestFormatDate(t *testing.T) {
+	xsdDateTime, _ := rdf.NewIRI("http://www.w3.org/2001/XMLSchema#dateTime")
+
+	var tests = []struct {
+		value interface{}
+		want  string
+	}{
+		{"2021-03-04T05:06:07Z", "2021-03-04 05:06:07 +0000"},
+		{"2021-03-04T05:06:07.123+02:00", "2021-03-04 05:06:07 +0200"},
+		{"2021-03-04T05:06:07", "2021-03-04 05:06:07 +0000"},
+		{"2021-03-04", "2021-03-04 00:00:00 +0000"},
+		{"2021-03-04-05:00", "2021-03-04 00:00:00 -0500"},
+		{"2021-03", "2021-03-01 00:00:00 +0000"},
+		{"2021", "2021-01-01 00:00:00 +0000"},
+		{rdf.NewTypedLiteral("2021-03-04T05:06:07Z", xsdDateTime), "2021-03-04 05:06:07 +0000"},
+	}
+	for _, test := range tests {
+		got, err := FormatDate("2006-01-02 15:04:05 -0700", test.value)
+		if err != nil {
+			t.Errorf("Failed to format %v: %v", test.value, err)
+		} else if got != test.want {
+			t.Errorf("Expected %v to be formatted as %s, got %s", test.value, test.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "tomorrow", "2021-13-01", "04/03/2021"} {
+		if _, err := FormatDate("2006", invalid); err == nil {
+			t.Errorf("Expected %q to be an invalid date", invalid)
+		}
+	}
+}
+
+func TestSortByDate(t *testing.T) {
+	row := func(date string) map[string]rdf.Term {
+		return map[string]rdf.Term{"date": literal(date, "")}
+	}
+	sorted, err := SortByDate([]map[string]rdf.Term{row("2020-05-01"), row("1999"), row("2020-04-30T23:00:00-02:00")}, "date")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sorted[0]["date"].String() + " " + sorted[1]["date"].String() + " " + sorted[2]["date"].String(); got != "1999 2020-05-01 2020-04-30T23:00:00-02:00" {
+		t.Errorf("Expected the rows to be sorted by date, got %s", got)
+	}
+
+	if _, err := SortByDate([]map[string]rdf.Term{row("2020"), {}}, "date"); err == nil {
+		t.Error("Expected sorting rows without a date to fail")
+	}
+
+	if before, err := DateBefore("2020-01-01", "2020-01-01T00:00:01Z"); err != nil || !before {
+		t.Errorf("Expected the date to be before the dateTime, got %v, %v", before, err)
+	}
+}
@@ -0,0 +1,13 @@
+package function
+
+import (
+	"html/template"
+
+	"github.com/glaciers-in-archives/snowman/internal/images"
+)
+
+// Srcset returns a srcset of resized copies of a static image.
+func Srcset(path string) (template.Srcset, error) {
+	srcset, err := images.Srcset(path)
+	return template.Srcset(srcset), err
+}
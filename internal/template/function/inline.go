@@ -0,0 +1,52 @@
+package function
+
+import (
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+// Inline returns the contents of the static file at the given path, relative
+// to the static directory, for embedding it into a page. SVG images are
+// returned as markup, other files as a base64 encoded data URI to use in src
+// or href attributes. Files larger than the configured inline_max_size are an
+// error.
+func Inline(path string) (interface{}, error) {
+	relativePath := filepath.Clean(filepath.FromSlash(strings.TrimPrefix(path, "/")))
+	if relativePath == "." || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return nil, errors.New("The path " + path + " must point to a file in the static directory.")
+	}
+	file := filepath.Join(config.CurrentSiteConfig.StaticDir, relativePath)
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > config.CurrentSiteConfig.InlineMaxSize {
+		return nil, errors.New("The file " + path + " is " + strconv.FormatInt(info.Size(), 10) + " bytes, more than the inline_max_size of " + strconv.FormatInt(config.CurrentSiteConfig.InlineMaxSize, 10) + ".")
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(file)) == ".svg" {
+		return template.HTML(data), nil
+	}
+	mediaType := mime.TypeByExtension(filepath.Ext(file))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(data)
+	}
+	// the parameters of data URIs, like the charset, aren't separated by spaces
+	mediaType = strings.ReplaceAll(mediaType, " ", "")
+	return template.URL("data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
@@ -0,0 +1,35 @@
+package function
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+func TestInline(t *testing.T) {
+	dir := t.TempDir()
+	defer func(siteConfig config.SiteConfig) {
+		config.CurrentSiteConfig = siteConfig
+	}(config.CurrentSiteConfig)
+	config.CurrentSiteConfig.StaticDir = dir
+	config.CurrentSiteConfig.InlineMaxSize = 16
+
+	os.WriteFile(filepath.Join(dir, "icon.svg"), []byte("<svg></svg>"), 0666)
+	os.WriteFile(filepath.Join(dir, "dot.png"), []byte{0x89, 'P', 'N', 'G'}, 0666)
+	os.WriteFile(filepath.Join(dir, "large.svg"), make([]byte, 17), 0666)
+
+	if svg, err := Inline("/icon.svg"); err != nil || svg != template.HTML("<svg></svg>") {
+		t.Errorf("Unexpected %v and %v for the SVG", svg, err)
+	}
+	if png, err := Inline("dot.png"); err != nil || png != template.URL("data:image/png;base64,iVBORw==") {
+		t.Errorf("Unexpected %v and %v for the PNG", png, err)
+	}
+	for _, path := range []string{"large.svg", "../icon.svg", "missing.svg"} {
+		if _, err := Inline(path); err == nil {
+			t.Errorf("Expected an error for %s", path)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package function
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/knakk/rdf"
+)
+
+// languageTerms returns the terms of a list of terms, or the values the rows
+// bind to the variable.
+func languageTerms(values interface{}, variable []string) ([]rdf.Term, error) {
+	var terms []rdf.Term
+	switch values := values.(type) {
+	case []rdf.Term:
+		terms = values
+	case []interface{}:
+		for _, value := range values {
+			if term, ok := value.(rdf.Term); ok {
+				terms = append(terms, term)
+			}
+		}
+	case []map[string]rdf.Term:
+		if len(variable) != 1 {
+			return nil, errors.New("lang_pick needs the name of the variable to pick from the rows.")
+		}
+		for _, row := range values {
+			if term, ok := row[variable[0]]; ok && term != nil {
+				terms = append(terms, term)
+			}
+		}
+	default:
+		return nil, errors.New("lang_pick expects a list of terms or result rows.")
+	}
+	return terms, nil
+}
+
+// matchesLanguage reports whether the language tag is the given language or
+// one of its regional variants, so that "en" also matches "en-GB".
+func matchesLanguage(tag string, language string, exact bool) bool {
+	tag, language = strings.ToLower(tag), strings.ToLower(language)
+	if exact {
+		return tag == language
+	}
+	return strings.HasPrefix(tag, language+"-")
+}
+
+// LangPick returns the term in the first language of the fallback chain that
+// any of the values is in. Without a match it falls back to a literal without
+// a language tag and then to the first value. Language tags are compared
+// case-insensitively and regional variants match after exact tags.
+func LangPick(languages []string, values interface{}, variable ...string) (rdf.Term, error) {
+	terms, err := languageTerms(values, variable)
+	if err != nil || len(terms) == 0 {
+		return nil, err
+	}
+
+	for _, language := range languages {
+		for _, exact := range []bool{true, false} {
+			for _, term := range terms {
+				if literal, ok := term.(rdf.Literal); ok && matchesLanguage(literal.Lang(), language, exact) {
+					return term, nil
+				}
+			}
+		}
+	}
+	for _, term := range terms {
+		if literal, ok := term.(rdf.Literal); ok && literal.Lang() == "" {
+			return term, nil
+		}
+	}
+	return terms[0], nil
+}
@@ -0,0 +1,49 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/knakk/rdf"
+)
+
+func literal(value string, language string) rdf.Term {
+	if language == "" {
+		xsdString, _ := rdf.NewIRI("http://www.w3.org/2001/XMLSchema#string")
+		return rdf.NewTypedLiteral(value, xsdString)
+	}
+	term, _ := rdf.NewLangLiteral(value, language)
+	return term
+}
+
+func TestLangPick(t *testing.T) {
+	labels := []rdf.Term{literal("Haus", "de"), literal("house", "en-GB"), literal("huis", "nl"), literal("home", "")}
+
+	var tests = []struct {
+		languages []string
+		values    []rdf.Term
+		want      string
+	}{
+		{[]string{"nl", "en"}, labels, "huis"},
+		{[]string{"fr", "EN"}, labels, "house"},
+		{[]string{"fr"}, labels, "home"},
+		{[]string{"fr"}, labels[:2], "Haus"},
+		{nil, labels[:3], "Haus"},
+	}
+	for _, test := range tests {
+		got, err := LangPick(test.languages, test.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != test.want {
+			t.Errorf("Expected %v to pick %s, got %s", test.languages, test.want, got.String())
+		}
+	}
+
+	rows := []map[string]rdf.Term{{"label": labels[0]}, {"other": labels[2]}, {"label": labels[1]}}
+	if got, err := LangPick([]string{"nl", "en"}, rows, "label"); err != nil || got.String() != "house" {
+		t.Errorf("Expected to pick the English label of the rows, got %v, %v", got, err)
+	}
+	if _, err := LangPick([]string{"en"}, rows); err == nil {
+		t.Error("Expected picking from rows without a variable to fail")
+	}
+}
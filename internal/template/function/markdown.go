@@ -0,0 +1,35 @@
+package function
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/spf13/cast"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// newMarkdown returns a CommonMark converter configured by the markdown
+// options of the site configuration.
+func newMarkdown(options config.MarkdownConfig) goldmark.Markdown {
+	var markdownOptions []goldmark.Option
+	if options.Linkify {
+		markdownOptions = append(markdownOptions, goldmark.WithExtensions(extension.Linkify))
+	}
+	if options.Unsafe {
+		markdownOptions = append(markdownOptions, goldmark.WithRendererOptions(html.WithUnsafe()))
+	}
+	return goldmark.New(markdownOptions...)
+}
+
+// Markdown renders CommonMark to HTML. Raw HTML in the input is omitted unless
+// enabled in the site configuration.
+func Markdown(text interface{}) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := newMarkdown(config.CurrentSiteConfig.Markdown).Convert([]byte(cast.ToString(text)), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,41 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+var markdownTests = []struct {
+	options  config.MarkdownConfig
+	markdown string
+	want     string
+}{
+	{config.MarkdownConfig{}, "# Title", "<h1>Title</h1>\n"},
+	{config.MarkdownConfig{}, "[Snowman](https://example.org)", "<p><a href=\"https://example.org\">Snowman</a></p>\n"},
+	{config.MarkdownConfig{}, "```\n<b>code</b>\n```", "<pre><code>&lt;b&gt;code&lt;/b&gt;\n</code></pre>\n"},
+	{config.MarkdownConfig{}, "`x < y`", "<p><code>x &lt; y</code></p>\n"},
+
+	// raw HTML is only passed through when enabled
+	{config.MarkdownConfig{}, "<script>alert(1)</script>", "<!-- raw HTML omitted -->\n"},
+	{config.MarkdownConfig{Unsafe: true}, "<b>bold</b>", "<p><b>bold</b></p>\n"},
+
+	// bare URLs are only linked when enabled
+	{config.MarkdownConfig{}, "https://example.org", "<p>https://example.org</p>\n"},
+	{config.MarkdownConfig{Linkify: true}, "https://example.org", "<p><a href=\"https://example.org\">https://example.org</a></p>\n"},
+}
+
+func TestMarkdown(t *testing.T) {
+	defer func(previous config.MarkdownConfig) { config.CurrentSiteConfig.Markdown = previous }(config.CurrentSiteConfig.Markdown)
+
+	for _, test := range markdownTests {
+		config.CurrentSiteConfig.Markdown = test.options
+		got, err := Markdown(test.markdown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("Markdown of %q was %q, expected %q", test.markdown, got, test.want)
+		}
+	}
+}
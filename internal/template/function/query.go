@@ -5,10 +5,8 @@ import (
 	"github.com/knakk/rdf"
 )
 
+// Query issues a query during rendering. Results are kept in memory for the
+// rest of the build, so repeating a query with the same arguments is cheap.
 func Query(queryLocation string, arguments ...interface{}) ([]map[string]rdf.Term, error) {
-	if len(arguments) == 0 {
-		return sparql.CurrentRepository.Query(queryLocation)
-	}
-
-	return sparql.CurrentRepository.Query(queryLocation, arguments...)
+	return sparql.CurrentRepository.MemoizedQuery(queryLocation, arguments...)
 }
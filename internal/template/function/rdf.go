@@ -0,0 +1,49 @@
+package function
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/spf13/cast"
+)
+
+// splitURI splits a URI after its last "/" or "#".
+func splitURI(uri string) (string, string) {
+	i := strings.LastIndexAny(uri, "/#")
+	return uri[:i+1], uri[i+1:]
+}
+
+func LocalName(uri interface{}) string {
+	_, localName := splitURI(cast.ToString(uri))
+	return localName
+}
+
+func Namespace(uri interface{}) string {
+	namespace, _ := splitURI(cast.ToString(uri))
+	return namespace
+}
+
+// CURIE shortens a URI using the longest matching namespace from the prefixes
+// defined in the site configuration. URIs without a matching namespace are
+// returned as-is.
+func CURIE(uri interface{}) string {
+	preparedUri := cast.ToString(uri)
+
+	var prefix, namespace string
+	for p, ns := range config.CurrentSiteConfig.Prefixes {
+		if strings.HasPrefix(preparedUri, ns) && len(ns) > len(namespace) {
+			prefix, namespace = p, ns
+		}
+	}
+
+	if namespace == "" {
+		return preparedUri
+	}
+
+	return prefix + ":" + strings.TrimPrefix(preparedUri, namespace)
+}
+
+func URIEncode(value interface{}) string {
+	return url.PathEscape(cast.ToString(value))
+}
@@ -3,11 +3,11 @@ package function
 import (
 	"bytes"
 	"errors"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 
+	"github.com/glaciers-in-archives/snowman/internal/logger"
 	"github.com/spf13/cast"
 )
 
@@ -46,8 +46,8 @@ func GetRemote(uri interface{}, config map[interface{}]interface{}) (*string, er
 	responseString := string(bodyBytes)
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Println("Received bad(HTTP: " + resp.Status + ") response from " + preparedUri + ".")
-		fmt.Println(responseString)
+		logger.Warn("Received bad(HTTP: " + resp.Status + ") response from " + preparedUri + ".")
+		logger.Debug(responseString)
 		return nil, errors.New("Received bad response from remote resource.")
 	}
 
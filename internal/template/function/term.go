@@ -0,0 +1,108 @@
+package function
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/knakk/rdf"
+	"github.com/spf13/cast"
+)
+
+// TermType returns uri, literal or bnode for the kind of RDF term the value
+// is, or an empty string if it isn't a term.
+func TermType(value interface{}) string {
+	term, ok := value.(rdf.Term)
+	if !ok || term == nil {
+		return ""
+	}
+	switch term.Type() {
+	case rdf.TermIRI:
+		return "uri"
+	case rdf.TermBlank:
+		return "bnode"
+	}
+	return "literal"
+}
+
+func IsURI(value interface{}) bool {
+	return TermType(value) == "uri"
+}
+
+func IsLiteral(value interface{}) bool {
+	return TermType(value) == "literal"
+}
+
+func IsBlank(value interface{}) bool {
+	return TermType(value) == "bnode"
+}
+
+// Datatype returns the datatype IRI of a literal, which is rdf:langString for
+// literals with a language tag, or an empty string for other values.
+func Datatype(value interface{}) string {
+	if literal, ok := value.(rdf.Literal); ok {
+		return literal.DataType.String()
+	}
+	return ""
+}
+
+// TermLang returns the language tag of a literal, or an empty string for
+// literals without one and other values.
+func TermLang(value interface{}) string {
+	if literal, ok := value.(rdf.Literal); ok {
+		return literal.Lang()
+	}
+	return ""
+}
+
+// AsNumber returns the numeric value of a literal, or of any other value, as a
+// float64 so that numbers of any datatype can be compared with each other.
+func AsNumber(value interface{}) (float64, error) {
+	var lexical string
+	switch value := value.(type) {
+	case rdf.Literal:
+		lexical = value.String()
+	case rdf.Term:
+		return 0, errors.New("The term " + value.String() + " isn't a literal and has no numeric value.")
+	default:
+		return cast.ToFloat64E(value)
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(lexical), 64)
+	if err != nil {
+		return 0, errors.New("The literal " + lexical + " isn't a number.")
+	}
+	return number, nil
+}
+
+// SortByNumber returns the rows sorted by the numeric value of the variable,
+// smallest first. Rows with equal values keep their order.
+func SortByNumber(rows []map[string]rdf.Term, variable string) ([]map[string]rdf.Term, error) {
+	numbers := make([]float64, len(rows))
+	for i, row := range rows {
+		term, bound := row[variable]
+		if !bound || term == nil {
+			return nil, errors.New("The variable " + variable + " isn't bound in every row to sort by.")
+		}
+		number, err := AsNumber(term)
+		if err != nil {
+			return nil, err
+		}
+		numbers[i] = number
+	}
+
+	indices := make([]int, len(rows))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return numbers[indices[i]] < numbers[indices[j]]
+	})
+
+	sorted := make([]map[string]rdf.Term, len(rows))
+	for i, index := range indices {
+		sorted[i] = rows[index]
+	}
+	return sorted, nil
+}
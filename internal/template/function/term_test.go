@@ -0,0 +1,62 @@
+package function
+
+import (
+	"testing"
+
+	"github.com/knakk/rdf"
+)
+
+func TestTermType(t *testing.T) {
+	iri, _ := rdf.NewIRI("http://example.org/1")
+	blank, _ := rdf.NewBlank("b0")
+
+	var tests = []struct {
+		value    interface{}
+		expected string
+	}{
+		{iri, "uri"},
+		{blank, "bnode"},
+		{literal("house", "en"), "literal"},
+		{"http://example.org/1", ""},
+		{nil, ""},
+	}
+	for _, test := range tests {
+		if termType := TermType(test.value); termType != test.expected {
+			t.Errorf("Expected %q for %v, got %q", test.expected, test.value, termType)
+		}
+	}
+
+	if datatype := Datatype(literal("house", "")); datatype != "http://www.w3.org/2001/XMLSchema#string" {
+		t.Errorf("Unexpected datatype %s", datatype)
+	}
+	if lang := TermLang(literal("house", "en")); lang != "en" {
+		t.Errorf("Unexpected language %s", lang)
+	}
+}
+
+func TestSortByNumber(t *testing.T) {
+	xsdInteger, _ := rdf.NewIRI("http://www.w3.org/2001/XMLSchema#integer")
+	xsdDecimal, _ := rdf.NewIRI("http://www.w3.org/2001/XMLSchema#decimal")
+	rows := []map[string]rdf.Term{
+		{"n": rdf.NewTypedLiteral("10", xsdInteger)},
+		{"n": rdf.NewTypedLiteral("9", xsdInteger)},
+		{"n": rdf.NewTypedLiteral("9.5", xsdDecimal)},
+		{"n": rdf.NewTypedLiteral("-1", xsdInteger)},
+	}
+
+	sorted, err := SortByNumber(rows, "n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order string
+	for _, row := range sorted {
+		order += row["n"].String() + " "
+	}
+	if order != "-1 9 9.5 10 " {
+		t.Errorf("Unexpected order %s", order)
+	}
+
+	if _, err := SortByNumber(append(rows, map[string]rdf.Term{"n": literal("ten", "")}), "n"); err == nil {
+		t.Error("Expected an error for a literal that isn't a number")
+	}
+}
@@ -3,7 +3,9 @@ package function
 import (
 	"fmt"
 	"html/template"
+	"time"
 
+	"github.com/glaciers-in-archives/snowman/internal/basepath"
 	"github.com/glaciers-in-archives/snowman/internal/config"
 	"github.com/glaciers-in-archives/snowman/internal/version"
 	"github.com/knakk/rdf"
@@ -18,12 +20,58 @@ func URI(value string) (rdf.IRI, error) {
 	return rdf.NewIRI(value)
 }
 
+// URL returns the path of a page of the site under the site's base_path, for
+// sites served from a subdirectory. Other URLs are returned unchanged.
+func URL(target interface{}) string {
+	return basepath.Prefix(config.CurrentSiteConfig.BasePath, cast.ToString(target))
+}
+
 func Config() config.SiteConfig {
 	return config.CurrentSiteConfig
 }
 
+// SiteContext holds the site-wide values returned by the site function. All
+// fields of the configuration are available on it as well.
+type SiteContext struct {
+	config.SiteConfig
+	// Endpoint is the endpoint of the default SPARQL client.
+	Endpoint string
+	Version  string
+	// Snowman describes the executable building the site, for build stamps
+	Snowman version.Info
+	// Vars are the query_params overridden by the variables given with --var
+	Vars map[string]interface{}
+}
+
+func Site() SiteContext {
+	siteConfig := config.CurrentSiteConfig
+	vars := make(map[string]interface{})
+	for name, value := range siteConfig.QueryParams {
+		vars[name] = value
+	}
+	for name, value := range siteConfig.Variables {
+		vars[name] = value
+	}
+	return SiteContext{
+		SiteConfig: siteConfig,
+		Endpoint:   siteConfig.Clients[siteConfig.DefaultClient].Endpoint,
+		Version:    version.Build().Version,
+		Snowman:    version.Build(),
+		Vars:       vars,
+	}
+}
+
+// Now returns the current time, or the SOURCE_DATE_EPOCH of reproducible
+// builds.
+func Now() time.Time {
+	if sourceDate := config.CurrentSiteConfig.SourceDate; sourceDate != nil {
+		return *sourceDate
+	}
+	return time.Now()
+}
+
 func Version() string {
-	return version.CurrentVersion.String()
+	return version.Build().Version
 }
 
 func Type(variable interface{}) string {
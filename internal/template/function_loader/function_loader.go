@@ -3,7 +3,6 @@ package function_loader
 import (
 	"html/template"
 	"os"
-	"time"
 
 	"github.com/glaciers-in-archives/snowman/internal/template/function"
 )
@@ -19,6 +18,9 @@ func FunctionLoader() template.FuncMap {
 		"from_json": function.FromJSON,
 
 		"read_file": function.ReadFile,
+		"asset":     function.Asset,
+		"inline":    function.Inline,
+		"srcset":    function.Srcset,
 
 		"add1": function.Add1,
 		"add":  function.Add,
@@ -45,13 +47,35 @@ func FunctionLoader() template.FuncMap {
 		"trim":       function.Trim,
 		"contains":   function.Contains,
 
+		"local_name": function.LocalName,
+		"namespace":  function.Namespace,
+		"curie":      function.CURIE,
+		"uri_encode": function.URIEncode,
+
 		"safe_html": function.SafeHTML,
+		"markdown":  function.Markdown,
 		"uri":       function.URI,
+		"url":       function.URL,
 		"config":    function.Config,
+		"site":      function.Site,
 		"version":   function.Version,
 		"type":      function.Type,
-		"now":       time.Now,
+		"now":       function.Now,
 		"env":       os.Getenv,
+
+		"parse_date":   function.ParseDate,
+		"format_date":  function.FormatDate,
+		"date_before":  function.DateBefore,
+		"date_after":   function.DateAfter,
+		"sort_by_date": function.SortByDate,
+
+		"term_type":      function.TermType,
+		"is_uri":         function.IsURI,
+		"is_literal":     function.IsLiteral,
+		"is_blank":       function.IsBlank,
+		"datatype":       function.Datatype,
+		"as_number":      function.AsNumber,
+		"sort_by_number": function.SortByNumber,
 	}
 
 	return template.FuncMap(functions)
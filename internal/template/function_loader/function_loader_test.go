@@ -0,0 +1,59 @@
+package function_loader
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/knakk/rdf"
+)
+
+type renderTest struct {
+	template string
+	want     string
+}
+
+var rdfFunctionTests = []renderTest{
+	{`{{ local_name "http://example.org/people/alice" }}`, "alice"},
+	{`{{ local_name "http://example.org/vocab#Person" }}`, "Person"},
+	{`{{ local_name .iri }}`, "Work"},
+	{`{{ namespace "http://example.org/vocab#Person" }}`, "http://example.org/vocab#"},
+	{`{{ namespace .iri }}`, "http://schema.org/"},
+	{`{{ curie "http://schema.org/Person" }}`, "schema:Person"},
+	{`{{ curie .iri }}`, "schema:Work"},
+	{`{{ curie "http://www.wikidata.org/prop/direct/P31" }}`, "wdt:P31"},
+	{`{{ curie "http://example.org/unknown" }}`, "http://example.org/unknown"},
+	{`{{ uri_encode "Douglas Adams/Books" }}`, "Douglas%20Adams%2FBooks"},
+}
+
+func render(t *testing.T, tpl string, data interface{}) string {
+	parsed, err := template.New("").Funcs(FunctionLoader()).Parse(tpl)
+	if err != nil {
+		t.Fatalf("Failed to parse template %s: %v", tpl, err)
+	}
+
+	var rendered bytes.Buffer
+	if err := parsed.Execute(&rendered, data); err != nil {
+		t.Fatalf("Failed to render template %s: %v", tpl, err)
+	}
+	return rendered.String()
+}
+
+func TestRDFFunctions(t *testing.T) {
+	config.CurrentSiteConfig.Prefixes = map[string]string{
+		"schema": "http://schema.org/",
+		"wd":     "http://www.wikidata.org/",
+		"wdt":    "http://www.wikidata.org/prop/direct/",
+	}
+	defer func() { config.CurrentSiteConfig.Prefixes = nil }()
+
+	iri, _ := rdf.NewIRI("http://schema.org/Work")
+	data := map[string]rdf.Term{"iri": iri}
+
+	for _, test := range rdfFunctionTests {
+		if got := render(t, test.template, data); got != test.want {
+			t.Errorf("Template %s rendered %q, expected %q", test.template, got, test.want)
+		}
+	}
+}
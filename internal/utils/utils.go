@@ -9,32 +9,70 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
+// Error is an error with a message describing what failed and the error that
+// caused it.
+type Error struct {
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return e.Message + " Error: " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 func ErrorExit(message string, err error) error {
-	return errors.New(message + " Error: " + err.Error())
+	return &Error{Message: message, Err: err}
 }
 
+// ErrorMessages returns the messages of an error and the errors causing it,
+// outermost first.
+func ErrorMessages(err error) []string {
+	var messages []string
+	for err != nil {
+		var wrapped *Error
+		if !errors.As(err, &wrapped) || wrapped != err {
+			messages = append(messages, err.Error())
+			break
+		}
+		messages = append(messages, wrapped.Message)
+		err = wrapped.Err
+	}
+	return messages
+}
+
+// CopyFile copies the contents and permissions of a file.
 func CopyFile(srcFile, dstFile string) error {
-	out, err := os.Create(dstFile)
+	in, err := os.Open(srcFile)
 	if err != nil {
 		return err
 	}
-
-	defer out.Close()
-
-	in, err := os.Open(srcFile)
 	defer in.Close()
+
+	info, err := in.Stat()
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(out, in)
+	out, err := os.OpenFile(dstFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
+	defer out.Close()
 
-	return nil
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	// the mode given when opening is limited by the umask and doesn't apply to
+	// existing files
+	return out.Chmod(info.Mode().Perm())
 }
 
 func WriteLineSeperatedFile(data []string, path string) error {
@@ -99,7 +137,7 @@ func CountFilesRecursive(dir string) (int, error) {
 	return count, nil
 }
 
-var illegalPath = regexp.MustCompile(`[\~\:\*\?\"\<\>\|]`)
+var illegalPath = regexp.MustCompile(`[\~\:\*\?\"\<\>\|\\\x00-\x1f]`)
 var illegalNextToEachOther = regexp.MustCompile(`[\.\/]{2,}`)
 var illegalStartAndEnd = regexp.MustCompile(`^[\./]|[\./]$`)
 
@@ -126,3 +164,22 @@ func ValidatePathSection(path string) error {
 
 	return nil
 }
+
+// Slugify lowercases the value and replaces every run of characters other than
+// letters and digits with a single "-". Letters outside of ASCII are kept.
+func Slugify(value string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteRune('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return slug.String()
+}
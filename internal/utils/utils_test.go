@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -30,6 +32,11 @@ var validatePathSectionTests = []struct {
 	{"test./test", false},
 	{"test/.test", false},
 	{"test/./test", false},
+
+	// nor characters that are special on some file systems
+	{"a\\b", false},
+	{"a\x00b", false},
+	{"..\\..\\etc", false},
 }
 
 func TestValidatePathSection(t *testing.T) {
@@ -46,3 +53,35 @@ func TestValidatePathSection(t *testing.T) {
 		}
 	}
 }
+
+func TestSlugify(t *testing.T) {
+	var tests = []struct {
+		value string
+		want  string
+	}{
+		{"Mona Lisa", "mona-lisa"},
+		{"  AC/DC -- Live!  ", "ac-dc-live"},
+		{"Ängsö slott", "ängsö-slott"},
+		{"../../etc/passwd", "etc-passwd"},
+		{"...", ""},
+	}
+	for _, test := range tests {
+		if got := Slugify(test.value); got != test.want {
+			t.Errorf("Expected %q to be slugified as %q, got %q", test.value, test.want, got)
+		}
+	}
+}
+
+func TestErrorMessages(t *testing.T) {
+	err := ErrorExit("Failed to build view index.html.", ErrorExit("SPARQL query failed.", errors.New("connection refused")))
+
+	if err.Error() != "Failed to build view index.html. Error: SPARQL query failed. Error: connection refused" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+
+	messages := ErrorMessages(err)
+	expected := []string{"Failed to build view index.html.", "SPARQL query failed.", "connection refused"}
+	if strings.Join(messages, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected messages %v, got %v", expected, messages)
+	}
+}
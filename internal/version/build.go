@@ -0,0 +1,78 @@
+package version
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// These are set when building release executables, for example with
+// go build -ldflags "-X github.com/glaciers-in-archives/snowman/internal/version.commit=$(git rev-parse HEAD)".
+// Without them the commit and date are read from the version control
+// information Go embeds in executables built from a checkout.
+var (
+	// version overrides CurrentVersion, like "0.6.0" or "v0.6.0"
+	version string
+	commit  string
+	date    string
+)
+
+// Info describes the Snowman executable.
+type Info struct {
+	Version string
+	Commit  string
+	// Date is the time the executable was built or, without build flags,
+	// the time of its commit
+	Date string
+	// Modified is set for executables built from a checkout with changes
+	Modified bool
+}
+
+// Build returns the version, commit and build date of the executable. Unknown
+// values are empty.
+func Build() Info {
+	info := Info{Version: CurrentVersion.String(), Commit: commit, Date: date}
+	if version != "" {
+		info.Version = strings.TrimPrefix(version, "v")
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = setting.Value
+				}
+			case "vcs.modified":
+				info.Modified = commit == "" && setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// String formats the information like "0.6.0 (commit 1a2b3c4, built
+// 2024-01-02T15:04:05Z)", leaving out what's unknown.
+func (i Info) String() string {
+	var details []string
+	if i.Commit != "" {
+		short := i.Commit
+		if len(short) > 12 {
+			short = short[:12]
+		}
+		if i.Modified {
+			short += "-dirty"
+		}
+		details = append(details, "commit "+short)
+	}
+	if i.Date != "" {
+		details = append(details, "built "+i.Date)
+	}
+	if len(details) == 0 {
+		return i.Version
+	}
+	return i.Version + " (" + strings.Join(details, ", ") + ")"
+}
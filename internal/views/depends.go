@@ -0,0 +1,49 @@
+package views
+
+import (
+	"errors"
+	"strings"
+)
+
+// Levels groups the views by their dependencies. Each level only depends on
+// the levels before it, so the views of a level can be rendered in parallel
+// once those are done. Views keep their order within a level.
+func Levels(views []View) ([][]View, error) {
+	level := make(map[string]int, len(views))
+	remaining := views
+
+	var levels [][]View
+	for len(remaining) > 0 {
+		var current, next []View
+		for _, view := range remaining {
+			ready := true
+			for _, dependency := range view.ViewConfig.DependsOn {
+				if _, done := level[dependency]; !done {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				current = append(current, view)
+			} else {
+				next = append(next, view)
+			}
+		}
+
+		if len(current) == 0 {
+			var outputs []string
+			for _, view := range remaining {
+				outputs = append(outputs, view.ViewConfig.Output)
+			}
+			return nil, errors.New("The views " + strings.Join(outputs, ", ") + " can't be ordered because their dependencies form a cycle.")
+		}
+
+		for _, view := range current {
+			level[view.ViewConfig.Output] = len(levels)
+		}
+		levels = append(levels, current)
+		remaining = next
+	}
+	return levels, nil
+}
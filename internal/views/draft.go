@@ -0,0 +1,34 @@
+package views
+
+import "github.com/glaciers-in-archives/snowman/internal/logger"
+
+// SkipDrafts returns the views which aren't drafts. Views keep depending on
+// the other views, but no longer on the skipped drafts.
+func SkipDrafts(views []View) []View {
+	drafts := map[string]bool{}
+	for _, view := range views {
+		if view.ViewConfig.Draft {
+			logger.Debug("Skipping the draft view " + view.ViewConfig.Output)
+			drafts[view.ViewConfig.Output] = true
+		}
+	}
+	if len(drafts) == 0 {
+		return views
+	}
+
+	var published []View
+	for _, view := range views {
+		if view.ViewConfig.Draft {
+			continue
+		}
+		var dependencies []string
+		for _, dependency := range view.ViewConfig.DependsOn {
+			if !drafts[dependency] {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+		view.ViewConfig.DependsOn = dependencies
+		published = append(published, view)
+	}
+	return published
+}
@@ -0,0 +1,64 @@
+package views
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/knakk/rdf"
+)
+
+// exportConfig describes the delimited file the results of a view's query
+// are written to.
+type exportConfig struct {
+	// Output is the path of the file in the site directory
+	Output string `yaml:"output"`
+	// Delimiter defaults to a tab for .tsv files and a comma otherwise
+	Delimiter string `yaml:"delimiter"`
+}
+
+func (e *exportConfig) validate() error {
+	if e == nil {
+		return nil
+	}
+	if e.Output == "" || strings.Contains(e.Output, "{{") {
+		return errors.New("The export of a view needs an output path without variables.")
+	}
+	if e.Delimiter != "" && utf8.RuneCountInString(e.Delimiter) != 1 {
+		return errors.New("The delimiter of an export must be a single character.")
+	}
+	return nil
+}
+
+func (e *exportConfig) delimiter() rune {
+	if e.Delimiter != "" {
+		r, _ := utf8.DecodeRuneInString(e.Delimiter)
+		return r
+	}
+	if strings.EqualFold(filepath.Ext(e.Output), ".tsv") {
+		return '\t'
+	}
+	return ','
+}
+
+// WriteExport writes the result rows to the export file at the given path,
+// with a column per variable.
+func (v *View) WriteExport(path string, rows []map[string]rdf.Term) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := sparql.WriteResultsDelimited(f, rows, v.ViewConfig.Export.delimiter()); err != nil {
+		return err
+	}
+	return f.Close()
+}
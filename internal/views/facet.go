@@ -0,0 +1,51 @@
+package views
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cast"
+)
+
+// facetFuncs are the functions of output templates computing a path section
+// from a value, for bucketing the pages of multipage views into directories.
+var facetFuncs = map[string]interface{}{
+	// first_letter is the lowercased first letter or digit, or _ for values
+	// starting with anything else
+	"first_letter": func(value interface{}) string {
+		for _, r := range cast.ToString(value) {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return string(unicode.ToLower(r))
+			}
+			break
+		}
+		return "_"
+	},
+	// prefix is the lowercased first n characters
+	"prefix": func(value interface{}, n int) (string, error) {
+		if n < 1 {
+			return "", errors.New("The length of the prefix must be a positive number.")
+		}
+		runes := []rune(strings.ToLower(cast.ToString(value)))
+		if len(runes) > n {
+			runes = runes[:n]
+		}
+		return string(runes), nil
+	},
+	// hash is the first n hexadecimal characters of the SHA-256 hash, which
+	// spreads pages evenly over 16^n directories
+	"hash": func(value interface{}, n int) (string, error) {
+		if n < 1 {
+			return "", errors.New("The length of the hash must be a positive number.")
+		}
+		sum := sha256.Sum256([]byte(cast.ToString(value)))
+		hash := hex.EncodeToString(sum[:])
+		if n < len(hash) {
+			hash = hash[:n]
+		}
+		return hash, nil
+	},
+}
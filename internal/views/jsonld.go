@@ -0,0 +1,52 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/jsonld"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+)
+
+// newFramedView returns a view which writes the graph returned by its query
+// as JSON-LD shaped by the frame, rather than rendering a template.
+func newFramedView(viewConf viewConfig) (View, error) {
+	if viewConf.TemplateFile != "" || viewConf.Layout != "" {
+		return View{}, errors.New("A view can have either a template or a jsonld_frame, not both.")
+	}
+	if viewConf.Paginate > 0 || viewConf.Export != nil || viewConf.Sidecar != "" || strings.Contains(viewConf.Output, "{{") {
+		return View{}, errors.New("Views with a jsonld_frame write a single file and can't paginate, export or have sidecars or variables in the output.")
+	}
+
+	data, err := ioutil.ReadFile(viewConf.JSONLDFrame)
+	if err != nil {
+		return View{}, errors.New("Unable to read the JSON-LD frame " + viewConf.JSONLDFrame + ".")
+	}
+	frame, err := jsonld.ParseFrame(data)
+	if err != nil {
+		return View{}, errors.New("Invalid JSON-LD frame " + viewConf.JSONLDFrame + ". " + err.Error())
+	}
+
+	return View{ViewConfig: viewConf, Frame: frame, OutputPath: outputPath(viewConf)}, nil
+}
+
+// WriteJSONLD frames the graph and writes it to the given path.
+func (v *View) WriteJSONLD(path string, graph *sparql.Graph) error {
+	document, err := jsonld.Frame(graph.Triples, v.Frame)
+	if err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0666)
+}
@@ -0,0 +1,86 @@
+package views
+
+import (
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+// LangPlaceholder is replaced with each configured language in the output
+// path of localized views, which are rendered once per language.
+const LangPlaceholder = "{{lang}}"
+
+// languages returns the fallback chain of languages of the view.
+func languages(viewConf viewConfig) []string {
+	if len(viewConf.Language) > 0 {
+		return viewConf.Language
+	}
+	return config.CurrentSiteConfig.Language
+}
+
+// localize replaces each view with LangPlaceholder in its output by a view per
+// language of its fallback chain. The language of a localized view comes
+// first in its chain, followed by the others. Dependencies on localized views
+// are replaced by the view of the same language, or by all of them for views
+// which aren't localized themselves.
+func localize(configs []viewConfig, problems *ValidationError) []viewConfig {
+	expansions := map[string][]string{}
+	for _, viewConf := range configs {
+		if strings.Contains(viewConf.Output, LangPlaceholder) {
+			for _, language := range unique(languages(viewConf)) {
+				expansions[viewConf.Output] = append(expansions[viewConf.Output], strings.ReplaceAll(viewConf.Output, LangPlaceholder, language))
+			}
+		}
+	}
+
+	var localized []viewConfig
+	for _, viewConf := range configs {
+		if !strings.Contains(viewConf.Output, LangPlaceholder) {
+			viewConf.DependsOn = localizeDependencies(viewConf.DependsOn, "", expansions)
+			localized = append(localized, viewConf)
+			continue
+		}
+
+		chain := unique(languages(viewConf))
+		if len(chain) == 0 {
+			problems.add(viewConf, "The output contains "+LangPlaceholder+" but no language is configured for the view or the site.")
+			continue
+		}
+		for _, language := range chain {
+			localizedConf := viewConf
+			localizedConf.Output = strings.ReplaceAll(viewConf.Output, LangPlaceholder, language)
+			localizedConf.Language = append(config.Languages{language}, remove(chain, language)...)
+			localizedConf.DependsOn = localizeDependencies(viewConf.DependsOn, language, expansions)
+			localized = append(localized, localizedConf)
+		}
+	}
+	return localized
+}
+
+func localizeDependencies(dependencies []string, language string, expansions map[string][]string) []string {
+	var localized []string
+	for _, dependency := range dependencies {
+		switch {
+		case !strings.Contains(dependency, LangPlaceholder):
+			localized = append(localized, dependency)
+		case language != "":
+			localized = append(localized, strings.ReplaceAll(dependency, LangPlaceholder, language))
+		case len(expansions[dependency]) > 0:
+			localized = append(localized, expansions[dependency]...)
+		default:
+			// reported as depending on an unknown output
+			localized = append(localized, dependency)
+		}
+	}
+	return localized
+}
+
+func unique(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" && !contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
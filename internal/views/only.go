@@ -0,0 +1,78 @@
+package views
+
+import (
+	"errors"
+	"path"
+)
+
+// Only returns the views whose output or template matches one of the glob
+// patterns, in their original order. With dependencies the views they depend
+// on are kept too, otherwise the dependencies on the skipped views are
+// dropped like those on drafts.
+func Only(views []View, patterns []string, dependencies bool) ([]View, error) {
+	selected := map[string]bool{}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New("The pattern " + pattern + " is malformed.")
+		}
+		matched := false
+		for _, view := range views {
+			if matchView(view, pattern) {
+				selected[view.ViewConfig.Output] = true
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, errors.New("No view matches " + pattern + ".")
+		}
+	}
+
+	if dependencies {
+		byOutput := map[string]View{}
+		for _, view := range views {
+			byOutput[view.ViewConfig.Output] = view
+		}
+		var pending []string
+		for output := range selected {
+			pending = append(pending, output)
+		}
+		for len(pending) > 0 {
+			view := byOutput[pending[0]]
+			pending = pending[1:]
+			for _, dependency := range view.ViewConfig.DependsOn {
+				if _, exists := byOutput[dependency]; exists && !selected[dependency] {
+					selected[dependency] = true
+					pending = append(pending, dependency)
+				}
+			}
+		}
+	}
+
+	var only []View
+	for _, view := range views {
+		if !selected[view.ViewConfig.Output] {
+			continue
+		}
+		var kept []string
+		for _, dependency := range view.ViewConfig.DependsOn {
+			if selected[dependency] {
+				kept = append(kept, dependency)
+			}
+		}
+		view.ViewConfig.DependsOn = kept
+		only = append(only, view)
+	}
+	return only, nil
+}
+
+func matchView(view View, pattern string) bool {
+	for _, name := range []string{view.ViewConfig.Output, view.ViewConfig.TemplateFile} {
+		if name == pattern {
+			return true
+		}
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
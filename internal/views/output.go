@@ -0,0 +1,136 @@
+package views
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+	text_template "text/template"
+	"text/template/parse"
+
+	"github.com/glaciers-in-archives/snowman/internal/template/function_loader"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/knakk/rdf"
+)
+
+// templateAction matches an action of an output, which can contain dots in
+// output templates.
+var templateAction = regexp.MustCompile(`{{.*?}}`)
+
+// outputExtension returns the extension of an output, ignoring dots in its
+// actions.
+func outputExtension(output string) string {
+	return path.Ext(templateAction.ReplaceAllString(output, "_"))
+}
+
+// isOutputTemplate reports whether the output of a view is a template, which
+// is the case when it has actions other than variables.
+func isOutputTemplate(output string) bool {
+	return strings.Contains(multipageVariablePattern.ReplaceAllString(output, ""), "{{")
+}
+
+// parseOutputTemplate parses an output template and returns the variables it
+// uses, in the order they first appear.
+func parseOutputTemplate(output string) (*text_template.Template, []string, error) {
+	funcs := function_loader.FunctionLoader()
+	funcs["slugify"] = utils.Slugify
+	for name, function := range facetFuncs {
+		funcs[name] = function
+	}
+	tpl, err := text_template.New("output").Funcs(text_template.FuncMap(funcs)).Parse(output)
+	if err != nil {
+		return nil, nil, errors.New("The output is neither a path with variables like {{name}} nor a valid template. Error: " + err.Error())
+	}
+
+	var variables []string
+	templateVariables(tpl.Tree.Root, &variables)
+	if len(variables) == 0 {
+		return nil, nil, errors.New("The output template must use a variable of the results, like {{ .id.Value }}.")
+	}
+	return tpl, variables, nil
+}
+
+// templateVariables adds the variables a template node uses to variables.
+// The bodies of range and with actions are left out, as their dot isn't the
+// row.
+func templateVariables(node parse.Node, variables *[]string) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node != nil {
+			for _, child := range node.Nodes {
+				templateVariables(child, variables)
+			}
+		}
+	case *parse.ActionNode:
+		templateVariables(node.Pipe, variables)
+	case *parse.IfNode:
+		templateVariables(node.Pipe, variables)
+		templateVariables(node.List, variables)
+		templateVariables(node.ElseList, variables)
+	case *parse.RangeNode:
+		templateVariables(node.Pipe, variables)
+		templateVariables(node.ElseList, variables)
+	case *parse.WithNode:
+		templateVariables(node.Pipe, variables)
+		templateVariables(node.ElseList, variables)
+	case *parse.PipeNode:
+		if node != nil {
+			for _, command := range node.Cmds {
+				templateVariables(command, variables)
+			}
+		}
+	case *parse.CommandNode:
+		for _, argument := range node.Args {
+			templateVariables(argument, variables)
+		}
+	case *parse.ChainNode:
+		templateVariables(node.Node, variables)
+	case *parse.FieldNode:
+		if !contains(*variables, node.Ident[0]) {
+			*variables = append(*variables, node.Ident[0])
+		}
+	}
+}
+
+// outputTerm is a term of a row as seen by output templates, which also have
+// its value as a string.
+type outputTerm struct {
+	rdf.Term
+	Value string
+}
+
+// templateOutput returns the output path of the page rendered for the given
+// result row by executing the output template. Paths which are empty, lead
+// outside of the site directory or have empty sections are rejected.
+func (v *View) templateOutput(row map[string]rdf.Term) (string, error) {
+	data := make(map[string]interface{}, len(row))
+	for variable, term := range row {
+		if term != nil {
+			data[variable] = outputTerm{Term: term, Value: term.String()}
+		}
+	}
+
+	var rendered strings.Builder
+	err := v.outputTemplate.Execute(&rendered, data)
+	output := rendered.String()
+	if err != nil || strings.Contains(output, "<no value>") {
+		for _, variable := range v.MultipageVariables {
+			if data[variable] == nil {
+				return "", UnboundError{Variable: variable}
+			}
+		}
+	}
+	if err != nil {
+		return "", errors.New("Failed to render the output template. Error: " + err.Error())
+	}
+
+	if strings.TrimSpace(output) == "" {
+		return "", errors.New("The output template rendered an empty path.")
+	}
+	for _, section := range strings.Split(output, "/") {
+		if err := utils.ValidatePathSection(section); err != nil {
+			return "", utils.ErrorExit("The output path "+output+" leads outside of the site directory or has an invalid section.", err)
+		}
+	}
+	return output, nil
+}
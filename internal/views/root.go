@@ -0,0 +1,68 @@
+package views
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+)
+
+// rootFileConfigs returns a view configuration for every template in the
+// root_files directory. Each is rendered once, without a query, to the same
+// relative path in the output directory of root_files. Only .html templates
+// are escaped as HTML.
+func rootFileConfigs() ([]viewConfig, error) {
+	rootFiles := config.CurrentSiteConfig.RootFiles
+	if rootFiles.Dir == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Join(config.CurrentSiteConfig.TemplatesDir, rootFiles.Dir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, errors.New("The root_files directory " + dir + " doesn't exist.")
+	}
+
+	var configs []viewConfig
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		// root files are written to exactly the path they have
+		cleanURLs := false
+		extension := strings.ToLower(path.Ext(relativePath))
+		configs = append(configs, viewConfig{
+			Output:       path.Join(rootFiles.Output, relativePath),
+			TemplateFile: path.Join(rootFiles.Dir, relativePath),
+			Unsafe:       extension != ".html" && extension != ".htm",
+			CleanURLs:    &cleanURLs,
+		})
+		return nil
+	})
+	return configs, err
+}
+
+// notFoundConfig returns the view configuration of the page rendered from the
+// not_found_template, if there is one.
+func notFoundConfig() []viewConfig {
+	template := config.CurrentSiteConfig.NotFoundTemplate
+	if template == "" {
+		return nil
+	}
+
+	cleanURLs := false
+	return []viewConfig{{
+		Output:       config.NotFoundPage,
+		TemplateFile: filepath.ToSlash(template),
+		CleanURLs:    &cleanURLs,
+	}}
+}
@@ -0,0 +1,93 @@
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/knakk/rdf"
+	"gopkg.in/yaml.v2"
+)
+
+// sidecarData converts the data a page is rendered with to what's written to
+// its sidecar file.
+func sidecarData(data interface{}) interface{} {
+	switch d := data.(type) {
+	case map[string]rdf.Term:
+		return sparql.ToJSONRow(d)
+	case []map[string]rdf.Term:
+		return sparql.ToJSONRows(d)
+	case Pagination:
+		return map[string]interface{}{
+			"items":       sparql.ToJSONRows(d.Items),
+			"page_number": d.PageNumber,
+			"total_pages": d.TotalPages,
+			"prev":        d.Prev,
+			"next":        d.Next,
+		}
+	case Transformed:
+		items := make([]interface{}, len(d.Items))
+		for i, item := range d.Items {
+			items[i] = sidecarData(item)
+		}
+		return map[string]interface{}{"items": items, "rows": sparql.ToJSONRows(d.Rows)}
+	case map[string]interface{}:
+		// a group of rows
+		group := make(map[string]interface{}, len(d))
+		for key, value := range d {
+			switch value := value.(type) {
+			case rdf.Term:
+				group[key] = sparql.ToJSONTerm(value)
+			case []rdf.Term:
+				terms := make([]sparql.JSONTerm, len(value))
+				for i, term := range value {
+					terms[i] = sparql.ToJSONTerm(term)
+				}
+				group[key] = terms
+			default:
+				group[key] = sidecarData(value)
+			}
+		}
+		return group
+	case *sparql.Graph:
+		triples := make([]map[string]sparql.JSONTerm, 0, len(d.Triples))
+		for _, triple := range d.Triples {
+			triples = append(triples, map[string]sparql.JSONTerm{
+				"subject":   sparql.ToJSONTerm(triple.Subj),
+				"predicate": sparql.ToJSONTerm(triple.Pred),
+				"object":    sparql.ToJSONTerm(triple.Obj),
+			})
+		}
+		return triples
+	}
+	return data
+}
+
+// SidecarPath returns the path of the sidecar file of the page at the given
+// path, which replaces the page's extension with that of the sidecar format.
+func (v *View) SidecarPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + v.ViewConfig.Sidecar
+}
+
+// WriteSidecar writes the data of the page at the given path to its sidecar
+// file.
+func (v *View) WriteSidecar(path string, data interface{}) error {
+	var content []byte
+	var err error
+	switch v.ViewConfig.Sidecar {
+	case "json":
+		content, err = json.MarshalIndent(sidecarData(data), "", "  ")
+	case "yaml":
+		content, err = yaml.Marshal(sidecarData(data))
+	default:
+		return errors.New("Unknown sidecar format " + v.ViewConfig.Sidecar + ".")
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(v.SidecarPath(path), content, 0666)
+}
@@ -0,0 +1,129 @@
+package views
+
+import (
+	"errors"
+	html_template "html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	text_template "text/template"
+	"text/template/parse"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	function "github.com/glaciers-in-archives/snowman/internal/template/child_template_function"
+	"github.com/glaciers-in-archives/snowman/internal/template/function_loader"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+)
+
+// templateCache holds the layouts and includes shared by all views, parsed
+// once. Every view clones them and adds its own template on top.
+type templateCache struct {
+	files []string
+	text  *text_template.Template
+	html  *html_template.Template
+	// definitions are the files defining each shared template
+	definitions map[string]string
+	// broken are the errors of the files that failed to parse, which are
+	// reported on the views using them
+	broken map[string]error
+}
+
+// newTemplateCache parses the shared templates with the functions available to
+// views. Each file is parsed on its own so that errors name the file, and a
+// file failing to parse is left out and kept in broken. As later definitions
+// silently replace earlier ones, an include defining a template another layout
+// or include defines is reported. Layouts can share the names of their blocks,
+// since views only use one of them.
+func newTemplateCache(files []string) (*templateCache, error) {
+	cache := templateCache{
+		files:       files,
+		definitions: map[string]string{},
+		broken:      map[string]error{},
+		text:        text_template.New("").Funcs(getViewFuncs(viewConfig{})).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()),
+		html:        html_template.New("").Funcs(getViewFuncs(viewConfig{})).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()),
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, utils.ErrorExit("Failed to read the template "+file+".", err)
+		}
+		name := filepath.Base(file)
+		if _, err := cache.text.New(name).Parse(string(data)); err != nil {
+			cache.broken[file] = utils.ErrorExit("Failed to parse the template "+file+".", err)
+			continue
+		}
+		if _, err := cache.html.New(name).Parse(string(data)); err != nil {
+			cache.broken[file] = utils.ErrorExit("Failed to parse the template "+file+".", err)
+			continue
+		}
+
+		for _, defined := range definedTemplates(name, string(data)) {
+			if other, exists := cache.definitions[defined]; exists && other != file && !(isLayout(other) && isLayout(file)) {
+				return nil, errors.New("The templates " + other + " and " + file + " both define " + defined + ", so one would replace the other.")
+			}
+			cache.definitions[defined] = file
+		}
+	}
+	return &cache, nil
+}
+
+// shadowedInclude returns the name of a template of the include files that the
+// given template would replace, and the include defining it.
+func (c *templateCache) shadowedInclude(file string) (string, string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", utils.ErrorExit("Failed to read the template "+file+".", err)
+	}
+	for _, defined := range definedTemplates(filepath.Base(file), string(data)) {
+		if other, exists := c.definitions[defined]; exists && filepath.Clean(other) != filepath.Clean(file) && !isLayout(other) {
+			return defined, other, nil
+		}
+	}
+	return "", "", nil
+}
+
+// definedTemplates returns the names of the templates a file defines, starting
+// with its own. Files that fail to parse define none, as parsing them reports
+// the error.
+func definedTemplates(name string, data string) []string {
+	tree := parse.New(name)
+	tree.Mode = parse.SkipFuncCheck
+	trees := map[string]*parse.Tree{}
+	if _, err := tree.Parse(data, "", "", trees); err != nil {
+		return nil
+	}
+
+	names := []string{name}
+	for defined := range trees {
+		if defined != name {
+			names = append(names, defined)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// isLayout reports whether a shared template is a layout.
+func isLayout(file string) bool {
+	return strings.HasPrefix(file, filepath.Join(config.CurrentSiteConfig.TemplatesDir, "layouts")+string(filepath.Separator))
+}
+
+// textTemplate returns a copy of the shared templates with the functions of
+// the view and the given files parsed on top, in order.
+func (c *templateCache) textTemplate(viewConf viewConfig, files ...string) (*text_template.Template, error) {
+	tpl, err := c.text.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return tpl.Funcs(getViewFuncs(viewConf)).ParseFiles(files...)
+}
+
+// htmlTemplate is textTemplate for escaped views.
+func (c *templateCache) htmlTemplate(viewConf viewConfig, files ...string) (*html_template.Template, error) {
+	tpl, err := c.html.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return tpl.Funcs(html_template.FuncMap(getViewFuncs(viewConf))).ParseFiles(files...)
+}
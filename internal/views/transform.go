@@ -0,0 +1,159 @@
+package views
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	text_template "text/template"
+
+	function "github.com/glaciers-in-archives/snowman/internal/template/child_template_function"
+	"github.com/glaciers-in-archives/snowman/internal/template/function_loader"
+	"github.com/knakk/rdf"
+)
+
+// transformConfig reshapes the result rows of a view before they're rendered.
+type transformConfig struct {
+	// Fields are added to every row, rendered from the row by a template
+	Fields map[string]string `yaml:"fields"`
+	// GroupBy groups the rows by the value of a variable
+	GroupBy string `yaml:"group_by"`
+	// Collect lists the variables of which a group has all distinct values
+	Collect []string `yaml:"collect"`
+}
+
+// Transformed is the data rendered by views with a transform rendering a
+// single page.
+type Transformed struct {
+	// Items are the groups, or the rows with their fields if the rows aren't
+	// grouped
+	Items []interface{}
+	// Rows are the result rows as the query returned them
+	Rows []map[string]rdf.Term
+}
+
+// field is a field added to the rows of a view.
+type field struct {
+	name     string
+	template *text_template.Template
+}
+
+// parseFields parses the templates of the fields of a transform, in order of
+// their names.
+func parseFields(transform *transformConfig) ([]field, error) {
+	if transform == nil {
+		return nil, nil
+	}
+
+	var fields []field
+	for name, source := range transform.Fields {
+		if name == "" || name == transform.GroupBy {
+			return nil, errors.New("The fields of a transform need a name other than the group_by variable.")
+		}
+		tpl, err := text_template.New(name).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()).Parse(source)
+		if err != nil {
+			return nil, errors.New("Failed to parse the transform field " + name + ". Error: " + err.Error())
+		}
+		fields = append(fields, field{name: name, template: tpl})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].name < fields[j].name
+	})
+	return fields, nil
+}
+
+// AddFields returns a copy of the row with the fields of the transform added
+// as plain literals. Fields are rendered from the row as the query returned
+// it, so they can't use each other.
+func (v *View) AddFields(row map[string]rdf.Term) (map[string]rdf.Term, error) {
+	if len(v.fields) == 0 {
+		return row, nil
+	}
+
+	extended := make(map[string]rdf.Term, len(row)+len(v.fields))
+	for variable, term := range row {
+		extended[variable] = term
+	}
+	for _, f := range v.fields {
+		var value strings.Builder
+		if err := f.template.Execute(&value, row); err != nil {
+			return nil, errors.New("Failed to render the transform field " + f.name + ". Error: " + err.Error())
+		}
+		extended[f.name] = rdf.NewTypedLiteral(value.String(), xsdString)
+	}
+	return extended, nil
+}
+
+var xsdString, _ = rdf.NewIRI("http://www.w3.org/2001/XMLSchema#string")
+
+// Groups groups the rows by the value of the group_by variable, in order of
+// their first row. Each group holds the group_by value, a list of the distinct
+// values of each collected variable and the first value of every other
+// variable, along with the rows of the group as Rows. Rows not binding the
+// group_by variable form a group of their own.
+func (v *View) Groups(rows []map[string]rdf.Term) []map[string]interface{} {
+	transform := v.ViewConfig.Transform
+	var groups []map[string]interface{}
+	index := map[string]int{}
+	collected := map[string]bool{}
+
+	for _, row := range rows {
+		key := ""
+		if term := row[transform.GroupBy]; term != nil {
+			key = term.Serialize(rdf.NTriples)
+		}
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, map[string]interface{}{"Rows": []map[string]rdf.Term{}})
+			for _, variable := range transform.Collect {
+				groups[i][variable] = []rdf.Term{}
+			}
+		}
+		group := groups[i]
+		group["Rows"] = append(group["Rows"].([]map[string]rdf.Term), row)
+
+		for variable, term := range row {
+			if term == nil {
+				continue
+			}
+			if !contains(transform.Collect, variable) {
+				if _, set := group[variable]; !set {
+					group[variable] = term
+				}
+				continue
+			}
+			value := key + " " + variable + " " + term.Serialize(rdf.NTriples)
+			if !collected[value] {
+				collected[value] = true
+				group[variable] = append(group[variable].([]rdf.Term), term)
+			}
+		}
+	}
+	return groups
+}
+
+// Transform adds the fields of the transform to the rows and groups them if
+// the transform groups by a variable.
+func (v *View) Transform(rows []map[string]rdf.Term) (Transformed, error) {
+	transformed := Transformed{Rows: rows, Items: []interface{}{}}
+	extended := make([]map[string]rdf.Term, 0, len(rows))
+	for _, row := range rows {
+		row, err := v.AddFields(row)
+		if err != nil {
+			return transformed, err
+		}
+		extended = append(extended, row)
+	}
+
+	if v.ViewConfig.Transform.GroupBy == "" {
+		for _, row := range extended {
+			transformed.Items = append(transformed.Items, row)
+		}
+		return transformed, nil
+	}
+	for _, group := range v.Groups(extended) {
+		transformed.Items = append(transformed.Items, group)
+	}
+	return transformed, nil
+}
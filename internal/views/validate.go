@@ -0,0 +1,138 @@
+package views
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/glaciers-in-archives/snowman/internal/search"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+)
+
+// ValidationError holds all problems found in the view configurations.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) add(viewConf viewConfig, problem string) {
+	e.Problems = append(e.Problems, "view "+viewConf.Output+": "+problem)
+}
+
+func (e *ValidationError) Error() string {
+	count := strconv.Itoa(len(e.Problems)) + " problems"
+	if len(e.Problems) == 1 {
+		count = "1 problem"
+	}
+	return "Found " + count + " in views.yaml:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// validate checks that a view can be rendered with the given queries. It adds
+// problems that break the build to problems, and those that might not to
+// warnings.
+func validate(view View, queries map[string]string, problems *ValidationError, warnings *ValidationError) {
+	viewConf := view.ViewConfig
+
+	unmatched := multipageVariablePattern.ReplaceAllString(viewConf.Output, "")
+	if view.outputTemplate == nil && (strings.Contains(unmatched, "{{") || strings.Contains(unmatched, "}}")) {
+		problems.add(viewConf, "The output contains a malformed variable. Variables are written like {{name}}.")
+	}
+
+	if viewConf.Endpoint != "" {
+		if _, err := sparql.GetRepository(viewConf.Endpoint); err != nil {
+			problems.add(viewConf, err.Error())
+		}
+	}
+
+	if viewConf.FetchPageSize < 0 {
+		problems.add(viewConf, "The fetch_page_size can't be negative.")
+	}
+
+	if viewConf.QueryFile == "" {
+		if view.MultipageVariableHook != nil || viewConf.Paginate > 0 {
+			problems.add(viewConf, "Views rendering multiple pages must have a query.")
+		}
+		if viewConf.Export != nil {
+			problems.add(viewConf, "Views with an export must have a query.")
+		}
+		if viewConf.JSONLDFrame != "" {
+			problems.add(viewConf, "Views with a jsonld_frame must have a query.")
+		}
+		if viewConf.Transform != nil {
+			problems.add(viewConf, "Views with a transform must have a query.")
+		}
+		if len(viewConf.Search) > 0 {
+			problems.add(viewConf, "Views adding their pages to the search index must have a query.")
+		}
+		return
+	}
+
+	query, exists := queries[viewConf.QueryFile]
+	if !exists {
+		problems.add(viewConf, "The query file "+viewConf.QueryFile+" doesn't exist.")
+		return
+	}
+	if err := sparql.CheckSyntax(query); err != nil {
+		problems.add(viewConf, "The query "+viewConf.QueryFile+" has a syntax error. "+err.Error())
+		return
+	}
+
+	if viewConf.JSONLDFrame != "" && !sparql.IsGraphQuery(query) {
+		problems.add(viewConf, "Views with a jsonld_frame need a CONSTRUCT or DESCRIBE query.")
+	}
+	if viewConf.Transform != nil && sparql.IsGraphQuery(query) {
+		problems.add(viewConf, "Views with a transform need a SELECT query.")
+	}
+
+	if viewConf.FetchPageSize > 0 && (sparql.IsGraphQuery(query) || sparql.HasLimit(query)) {
+		warnings.add(viewConf, "The fetch_page_size is ignored as the query "+viewConf.QueryFile+" has a LIMIT or OFFSET or isn't a SELECT query.")
+	} else if viewConf.FetchPageSize > 0 && !sparql.HasOrderBy(query) {
+		warnings.add(viewConf, "The query "+viewConf.QueryFile+" has no ORDER BY, so the pages fetched with the fetch_page_size might overlap or miss results.")
+	}
+
+	for _, variable := range view.MultipageVariables {
+		if !isTransformField(viewConf, variable) && !queryMentions(query, variable) {
+			warnings.add(viewConf, "The variable "+variable+" used in the output doesn't appear in the query "+viewConf.QueryFile+".")
+		}
+	}
+
+	if len(viewConf.Search) > 0 {
+		if view.MultipageVariableHook == nil {
+			problems.add(viewConf, "Only views with variables in their output can add their pages to the search index.")
+		}
+		if _, exists := viewConf.Search[search.URLField]; exists {
+			problems.add(viewConf, "The "+search.URLField+" field of the search index is always the URL of the page.")
+		}
+		for _, field := range sortedKeys(viewConf.Search) {
+			variable := viewConf.Search[field]
+			if !isTransformField(viewConf, variable) && !queryMentions(query, variable) {
+				warnings.add(viewConf, "The variable "+variable+" of the search field "+field+" doesn't appear in the query "+viewConf.QueryFile+".")
+			}
+		}
+	}
+}
+
+// isTransformField reports whether the variable is a field the transform of
+// the view adds to the rows.
+func isTransformField(viewConf viewConfig, variable string) bool {
+	if viewConf.Transform == nil {
+		return false
+	}
+	_, isField := viewConf.Transform.Fields[variable]
+	return isField
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// queryMentions reports whether the query contains the variable as ?name or
+// $name.
+func queryMentions(query string, variable string) bool {
+	return regexp.MustCompile(`[?$]` + regexp.QuoteMeta(variable) + `\b`).MatchString(query)
+}
@@ -1,18 +1,28 @@
 package views
 
 import (
+	"bufio"
 	"errors"
-	"fmt"
 	html_template "html/template"
 	"io/ioutil"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
 	text_template "text/template"
+	"time"
 
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
 	function "github.com/glaciers-in-archives/snowman/internal/template/child_template_function"
+	template_function "github.com/glaciers-in-archives/snowman/internal/template/function"
 	"github.com/glaciers-in-archives/snowman/internal/template/function_loader"
+	"github.com/glaciers-in-archives/snowman/internal/utils"
+	"github.com/knakk/rdf"
 	"gopkg.in/yaml.v2"
 )
 
@@ -25,21 +35,274 @@ func (c *viewConfigList) Parse(data []byte) error {
 }
 
 type viewConfig struct {
-	Output       string `yaml:"output"`
-	QueryFile    string `yaml:"query"`
+	Output    string `yaml:"output"`
+	QueryFile string `yaml:"query"`
+	// Sparql is an inline query used instead of a query file
+	Sparql       string `yaml:"sparql"`
+	Endpoint     string `yaml:"endpoint"`
 	TemplateFile string `yaml:"template"`
 	Unsafe       bool   `yaml:"unsafe"`
+	// OutputType is html, xml, text or json. Only html is escaped; the
+	// others are rendered as text, like unsafe views
+	OutputType string `yaml:"output_type"`
+	// Params override the query_params of the site configuration
+	Params map[string]interface{} `yaml:"params"`
+	// Paginate splits the results into pages of this many rows
+	Paginate int `yaml:"paginate"`
+	// Siblings gives every page of a view rendering a page per result its
+	// position among the pages and the data of its neighbours
+	Siblings bool `yaml:"siblings"`
+	// FetchPageSize fetches the results in requests of this many rows
+	FetchPageSize int `yaml:"fetch_page_size"`
+	// SkipUnbound skips result rows not binding a variable of the output
+	// instead of failing the view
+	SkipUnbound bool `yaml:"skip_unbound"`
+	// CacheTTL overrides the cache_ttl of the site configuration
+	CacheTTL *time.Duration `yaml:"cache_ttl"`
+	// Sidecar is "json" or "yaml" to also write the data of each page
+	Sidecar string `yaml:"sidecar"`
+	// Export writes the results of the query to a delimited file
+	Export *exportConfig `yaml:"export"`
+	// Layout names a file in the layouts directory which is rendered with the
+	// blocks defined by the template
+	Layout string `yaml:"layout"`
+	// DependsOn lists the outputs of views which are rendered before this one
+	DependsOn []string `yaml:"depends_on"`
+	// CleanURLs overrides the clean_urls of the site configuration
+	CleanURLs *bool `yaml:"clean_urls"`
+	// Extension replaces the extension of the output, an empty one removes it
+	Extension *string `yaml:"extension"`
+	// JSONLDFrame is the path of a JSON-LD frame which the graph returned by
+	// the query is framed with and written as, instead of rendering a template
+	JSONLDFrame string `yaml:"jsonld_frame"`
+	// Language overrides the language fallback chain of the site
+	// configuration
+	Language config.Languages `yaml:"language"`
+	// Draft views are only rendered when drafts are included in the build
+	Draft bool `yaml:"draft"`
+	// PathEncoding overrides the path_encoding of the site configuration
+	PathEncoding string `yaml:"path_encoding"`
+	// Transform adds fields to and groups the result rows before rendering
+	Transform *transformConfig `yaml:"transform"`
+	// Search maps the fields of the search index to variables, adding every
+	// page of the view to the index
+	Search map[string]string `yaml:"search"`
+}
+
+// outputTypes maps the output types of views to the extension of outputs
+// without one.
+var outputTypes = map[string]string{
+	"html": ".html",
+	"xml":  ".xml",
+	"text": ".txt",
+	"json": ".json",
+}
+
+// outputPath applies the configured extension and clean URLs to the output of
+// a view. It's applied before variables are replaced so that dots in their
+// values aren't taken for extensions. Outputs without an extension get the
+// one of their output type.
+func outputPath(viewConf viewConfig) string {
+	output := viewConf.Output
+	if viewConf.OutputType != "" && viewConf.Extension == nil && outputExtension(output) == "" {
+		output += outputTypes[viewConf.OutputType]
+	}
+	if viewConf.Extension != nil {
+		output = strings.TrimSuffix(output, outputExtension(output))
+		if extension := strings.TrimPrefix(*viewConf.Extension, "."); extension != "" {
+			output += "." + extension
+		}
+	}
+
+	cleanURLs := config.CurrentSiteConfig.CleanURLs
+	if viewConf.CleanURLs != nil {
+		cleanURLs = *viewConf.CleanURLs
+	}
+	if cleanURLs && outputExtension(output) == ".html" && path.Base(output) != "index.html" {
+		output = strings.TrimSuffix(output, ".html") + "/index.html"
+	}
+	return output
+}
+
+// PagePlaceholder is replaced with the page number in the output path of
+// paginated views.
+const PagePlaceholder = "{{page}}"
+
+// Pagination is the data rendered by each page of a paginated view.
+type Pagination struct {
+	Items      []map[string]rdf.Term
+	PageNumber int
+	TotalPages int
+	// Prev and Next are the paths of the neighbouring pages relative to the
+	// site root, or empty on the first and last page
+	Prev string
+	Next string
+}
+
+// PageOutput returns the output path of the given page of a paginated view.
+func (v *View) PageOutput(page int) string {
+	return strings.ReplaceAll(v.OutputPath, PagePlaceholder, strconv.Itoa(page))
+}
+
+// Paginate splits the results into pages of the configured size. There's
+// always at least one, possibly empty, page.
+func (v *View) Paginate(results []map[string]rdf.Term) []Pagination {
+	size := v.ViewConfig.Paginate
+	total := (len(results) + size - 1) / size
+	if total == 0 {
+		total = 1
+	}
+
+	pages := make([]Pagination, total)
+	for i := range pages {
+		end := (i + 1) * size
+		if end > len(results) {
+			end = len(results)
+		}
+
+		pages[i] = Pagination{Items: results[i*size : end], PageNumber: i + 1, TotalPages: total}
+		if i > 0 {
+			pages[i].Prev = v.PageOutput(i)
+		}
+		if i < total-1 {
+			pages[i].Next = v.PageOutput(i + 2)
+		}
+	}
+	return pages
+}
+
+// Siblings adds the position of every page of a multipage view among all of
+// them to the data of the page: its Index counted from 1, the Total number of
+// pages and the data of the Prev and Next pages, which is nil on the first and
+// last page. Each page is a result row or a group of rows, and values of the
+// same names are replaced.
+func Siblings(pages []interface{}) []map[string]interface{} {
+	enriched := make([]map[string]interface{}, len(pages))
+	for i, page := range pages {
+		data := map[string]interface{}{}
+		switch page := page.(type) {
+		case map[string]rdf.Term:
+			for key, value := range page {
+				data[key] = value
+			}
+		case map[string]interface{}:
+			for key, value := range page {
+				data[key] = value
+			}
+		}
+
+		data["Index"] = i + 1
+		data["Total"] = len(pages)
+		data["Prev"] = nil
+		data["Next"] = nil
+		if i > 0 {
+			data["Prev"] = pages[i-1]
+		}
+		if i < len(pages)-1 {
+			data["Next"] = pages[i+1]
+		}
+		enriched[i] = data
+	}
+	return enriched
 }
 
 type View struct {
-	ViewConfig            viewConfig
-	TextTemplate          *text_template.Template
-	HTMLTemplate          *html_template.Template
-	TemplateName          string
+	ViewConfig   viewConfig
+	TextTemplate *text_template.Template
+	HTMLTemplate *html_template.Template
+	TemplateName string
+	// OutputPath is the output of the view with the configured extension and
+	// clean URLs applied
+	OutputPath            string
 	MultipageVariableHook *string
+	// MultipageVariables holds every variable referenced in the output path,
+	// the first of which is also the MultipageVariableHook
+	MultipageVariables []string
+	// Frame is the parsed JSON-LD frame of views with a jsonld_frame
+	Frame map[string]interface{}
+	// outputTemplate is the parsed output of views whose output is a
+	// template
+	outputTemplate *text_template.Template
+	// fields are the parsed fields of the transform
+	fields []field
 }
 
+var multipageVariablePattern = regexp.MustCompile(`{{([\w\d_]+)}}`)
+
+// UnboundError is returned for result rows which don't bind a variable used in
+// the output path, which is common for variables bound in an OPTIONAL clause.
+type UnboundError struct {
+	Variable string
+}
+
+func (e UnboundError) Error() string {
+	return "The variable " + e.Variable + " used in the output path is not bound in a result row."
+}
+
+// pathEncodings are the ways values are written into output paths. Raw values
+// are used as they are, so that they can contain directories.
+var pathEncodings = map[string]func(string) string{
+	"raw":       func(value string) string { return value },
+	"slugify":   utils.Slugify,
+	"urlencode": url.PathEscape,
+}
+
+// pathEncoding returns the path encoding of the view.
+func pathEncoding(viewConf viewConfig) string {
+	for _, encoding := range []string{viewConf.PathEncoding, config.CurrentSiteConfig.PathEncoding} {
+		if encoding != "" {
+			return encoding
+		}
+	}
+	return "raw"
+}
+
+// MultipageOutput returns the output path of the page rendered for the given
+// result row by replacing each variable in the output path with its encoded
+// value, or by executing the output template. Values which would lead outside
+// of the site directory are rejected.
+func (v *View) MultipageOutput(row map[string]rdf.Term) (string, error) {
+	if v.outputTemplate != nil {
+		return v.templateOutput(row)
+	}
+
+	encode, known := pathEncodings[pathEncoding(v.ViewConfig)]
+	if !known {
+		return "", errors.New("Unknown path encoding " + pathEncoding(v.ViewConfig) + ".")
+	}
+
+	output := v.OutputPath
+	for _, variable := range v.MultipageVariables {
+		term, ok := row[variable]
+		if !ok || term == nil {
+			return "", UnboundError{Variable: variable}
+		}
+
+		pathSection := encode(term.String())
+		if err := utils.ValidatePathSection(pathSection); err != nil {
+			return "", utils.ErrorExit("Failed to validate path section.", err)
+		}
+		output = strings.ReplaceAll(output, "{{"+variable+"}}", pathSection)
+	}
+
+	if cleaned := path.Clean(output); cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) {
+		return "", errors.New("The output path " + output + " leads outside of the site directory.")
+	}
+	return output, nil
+}
+
+// RenderPage renders the page with the given data to the file at path. The
+// template writes to the file as it's executed, so that large pages aren't
+// kept in memory, and the partial file is removed if rendering fails.
 func (v *View) RenderPage(path string, data interface{}) error {
+	if v.Frame != nil {
+		graph, ok := data.(*sparql.Graph)
+		if !ok {
+			return errors.New("Views with a jsonld_frame need a CONSTRUCT or DESCRIBE query.")
+		}
+		return v.WriteJSONLD(path, graph)
+	}
+
 	if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
 		return err
 	}
@@ -48,18 +311,23 @@ func (v *View) RenderPage(path string, data interface{}) error {
 	if err != nil {
 		return err
 	}
+
+	w := bufio.NewWriterSize(f, 64*1024)
 	if v.ViewConfig.Unsafe {
-		if err := v.TextTemplate.ExecuteTemplate(f, v.TemplateName, data); err != nil {
-			return err
-		}
+		err = v.TextTemplate.ExecuteTemplate(w, v.TemplateName, data)
 	} else {
-		if err := v.HTMLTemplate.ExecuteTemplate(f, v.TemplateName, data); err != nil {
-			return err
-		}
+		err = v.HTMLTemplate.ExecuteTemplate(w, v.TemplateName, data)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
 	}
-
-	f.Close()
-
 	return nil
 }
 
@@ -68,13 +336,52 @@ func getViewFuncs(currentViewConfig viewConfig) html_template.FuncMap {
 		"current_view": func() viewConfig {
 			return currentViewConfig
 		},
+		// lang returns the language tag of a literal, or without one the
+		// preferred language of the view, which is the one rendered by
+		// localized views
+		"lang": func(term ...interface{}) string {
+			if len(term) > 0 {
+				return template_function.TermLang(term[0])
+			}
+			if chain := languages(currentViewConfig); len(chain) > 0 {
+				return chain[0]
+			}
+			return ""
+		},
+		"lang_pick": func(values interface{}, variable ...string) (rdf.Term, error) {
+			return template_function.LangPick(languages(currentViewConfig), values, variable...)
+		},
 	}
 	return html_template.FuncMap(viewFuncs)
 }
 
-func DiscoverViews(layouts []string) ([]View, error) {
+// ParseTemplate checks that the template file at the given path parses with
+// the functions available to views.
+func ParseTemplate(path string) error {
+	_, err := text_template.New("").Funcs(getViewFuncs(viewConfig{})).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()).ParseFiles(path)
+	return err
+}
+
+// InlineQueryName returns the name under which the inline query of the view
+// with the given output is added to the queries.
+func InlineQueryName(output string) string {
+	return "views.yaml#" + output
+}
+
+// DiscoverViews parses the views in views.yaml and validates them against the
+// given queries. The layouts, along with the includes, are parsed once and
+// shared by every view. A layout failing to parse is reported on the views
+// using it. Inline queries of views are added to the queries. All problems are
+// reported together in a ValidationError. Problems that don't necessarily
+// break the build are logged as warnings unless strict is set.
+func DiscoverViews(layouts []string, queries map[string]string, strict bool) ([]View, error) {
 	var views []View
 
+	templates, err := newTemplateCache(layouts)
+	if err != nil {
+		return nil, err
+	}
+
 	data, err := ioutil.ReadFile("views.yaml")
 	if err != nil {
 		return nil, errors.New("Failed to read views.yaml")
@@ -85,44 +392,238 @@ func DiscoverViews(layouts []string) ([]View, error) {
 		return nil, errors.New("Failed to parse views.yaml")
 	}
 
-	fmt.Println("Building project with " + strconv.Itoa(len(vConfigs.Views)) + " views.")
+	rootFiles, err := rootFileConfigs()
+	if err != nil {
+		return nil, err
+	}
+	vConfigs.Views = append(vConfigs.Views, rootFiles...)
+	vConfigs.Views = append(vConfigs.Views, notFoundConfig()...)
+
+	// every view is discovered so that all problems are reported at once
+	var problems, warnings ValidationError
+	vConfigs.Views = localize(vConfigs.Views, &problems)
+	for _, viewConf := range vConfigs.Views {
+		if viewConf.Sparql != "" {
+			if viewConf.QueryFile != "" {
+				problems.add(viewConf, "A view can have either a query file or an inline sparql query, not both.")
+				continue
+			}
+			viewConf.QueryFile = InlineQueryName(viewConf.Output)
+			queries[viewConf.QueryFile] = viewConf.Sparql
+		}
+
+		view, err := newView(viewConf, templates)
+		if err != nil {
+			problems.add(viewConf, err.Error())
+			continue
+		}
+		validate(view, queries, &problems, &warnings)
+		views = append(views, view)
+	}
 
+	// broken includes can be used by any view, and broken layouts by none
+	used := map[string]bool{}
 	for _, viewConf := range vConfigs.Views {
-		var multipageVariableHook *string
-		re := regexp.MustCompile(`{{([\w\d_]+)}}`)
-		if re.Match([]byte(viewConf.Output)) {
-			multipageVariableHook = &re.FindAllStringSubmatch(viewConf.Output, 1)[0][1]
+		used[filepath.Join(config.CurrentSiteConfig.TemplatesDir, "layouts", viewConf.Layout)] = true
+	}
+	for _, file := range templates.files {
+		err := templates.broken[file]
+		switch {
+		case err == nil:
+		case !isLayout(file):
+			problems.Problems = append(problems.Problems, err.Error())
+		case !used[file]:
+			warnings.Problems = append(warnings.Problems, err.Error())
 		}
+	}
 
-		templatePath := "templates/" + viewConf.TemplateFile
-		if _, err := os.Stat(templatePath); err != nil {
-			return nil, errors.New("Unable to find the template file " + viewConf.TemplateFile)
+	outputs := map[string]bool{}
+	for _, viewConf := range vConfigs.Views {
+		outputs[viewConf.Output] = true
+	}
+	for _, viewConf := range vConfigs.Views {
+		for _, dependency := range viewConf.DependsOn {
+			if !outputs[dependency] {
+				problems.add(viewConf, "The view depends on "+dependency+", which isn't the output of any view.")
+			}
+		}
+	}
+	if len(problems.Problems) == 0 {
+		if _, err := Levels(views); err != nil {
+			problems.Problems = append(problems.Problems, err.Error())
 		}
+	}
 
-		_, file := filepath.Split(templatePath)
+	if strict {
+		problems.Problems = append(problems.Problems, warnings.Problems...)
+	} else {
+		for _, warning := range warnings.Problems {
+			logger.Warn(warning)
+		}
+	}
 
-		templates := append(layouts, templatePath)
+	if len(problems.Problems) > 0 {
+		return nil, &problems
+	}
+	return views, nil
+}
+
+func newView(viewConf viewConfig, templates *templateCache) (View, error) {
+	if viewConf.JSONLDFrame != "" {
+		return newFramedView(viewConf)
+	}
+
+	if viewConf.Sidecar != "" && viewConf.Sidecar != "json" && viewConf.Sidecar != "yaml" {
+		return View{}, errors.New("The sidecar format must be either json or yaml.")
+	}
+	if viewConf.Sidecar != "" && strings.EqualFold(outputExtension(outputPath(viewConf)), "."+viewConf.Sidecar) {
+		return View{}, errors.New("The sidecar format can't be the extension of the output, as the sidecar files would replace the pages.")
+	}
+
+	if _, known := outputTypes[viewConf.OutputType]; !known && viewConf.OutputType != "" {
+		return View{}, errors.New("The output type must be html, xml, text or json.")
+	}
+	if viewConf.OutputType == "html" && viewConf.Unsafe {
+		return View{}, errors.New("Views with the html output type are always escaped and can't be unsafe.")
+	}
+	if viewConf.OutputType != "" && viewConf.OutputType != "html" {
+		viewConf.Unsafe = true
+	}
+
+	if _, known := pathEncodings[pathEncoding(viewConf)]; !known {
+		return View{}, errors.New("The path encoding must be raw, slugify or urlencode.")
+	}
+
+	if viewConf.Paginate < 0 {
+		return View{}, errors.New("The view must paginate by a positive number of results.")
+	}
+	if viewConf.Paginate > 0 && !strings.Contains(viewConf.Output, PagePlaceholder) {
+		return View{}, errors.New("The output of a paginated view must contain " + PagePlaceholder + ".")
+	}
+
+	if err := viewConf.Export.validate(); err != nil {
+		return View{}, err
+	}
 
-		var TextTemplateA *text_template.Template
-		var HTMLTemplateA *html_template.Template
-		if viewConf.Unsafe {
-			TextTemplateA, err = text_template.New("").Funcs(getViewFuncs(viewConf)).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()).ParseFiles(templates...)
-		} else {
-			HTMLTemplateA, err = html_template.New("").Funcs(getViewFuncs(viewConf)).Funcs(function_loader.FunctionLoader()).Funcs(function.GetIncludeFuncs()).ParseFiles(templates...)
+	fields, err := parseFields(viewConf.Transform)
+	if err != nil {
+		return View{}, err
+	}
+	if viewConf.Transform != nil && viewConf.Transform.GroupBy == "" && len(viewConf.Transform.Collect) > 0 {
+		return View{}, errors.New("A transform can only collect the values of variables when it has a group_by.")
+	}
+	if viewConf.Transform != nil && viewConf.Transform.GroupBy != "" && viewConf.Paginate > 0 {
+		return View{}, errors.New("A transform with a group_by can't be combined with paginate.")
+	}
+
+	var multipageVariableHook *string
+	var multipageVariables []string
+	var outputTemplate *text_template.Template
+	if isOutputTemplate(viewConf.Output) {
+		if viewConf.Paginate > 0 {
+			return View{}, errors.New("The output of a paginated view can't contain variables other than " + PagePlaceholder + ".")
+		}
+		if outputTemplate, multipageVariables, err = parseOutputTemplate(outputPath(viewConf)); err != nil {
+			return View{}, err
+		}
+	}
+	for _, match := range multipageVariablePattern.FindAllStringSubmatch(viewConf.Output, -1) {
+		if outputTemplate != nil {
+			break
+		}
+		if viewConf.Paginate > 0 {
+			if "{{"+match[1]+"}}" != PagePlaceholder {
+				return View{}, errors.New("The output of a paginated view can't contain variables other than " + PagePlaceholder + ".")
+			}
+			continue
+		}
+		if !contains(multipageVariables, match[1]) {
+			multipageVariables = append(multipageVariables, match[1])
 		}
+	}
+	if len(multipageVariables) > 0 {
+		multipageVariableHook = &multipageVariables[0]
+	} else if viewConf.Siblings {
+		return View{}, errors.New("Only views rendering a page per result can have siblings.")
+	}
 
-		if err != nil {
-			return nil, err
+	templatePath := config.CurrentSiteConfig.TemplatesDir + "/" + viewConf.TemplateFile
+	if _, err := os.Stat(templatePath); err != nil {
+		return View{}, errors.New("Unable to find the template file " + viewConf.TemplateFile)
+	}
+
+	_, file := filepath.Split(templatePath)
+
+	// the template is parsed last so that its definitions override the blocks
+	// of the layouts
+	var files []string
+	if viewConf.Layout != "" {
+		layoutPath := filepath.Join(config.CurrentSiteConfig.TemplatesDir, "layouts", viewConf.Layout)
+		if !contains(templates.files, layoutPath) {
+			return View{}, errors.New("Unable to find the layout " + viewConf.Layout + " in the layouts directory.")
+		}
+		if err := templates.broken[layoutPath]; err != nil {
+			return View{}, err
+		}
+		if filepath.Base(layoutPath) == file {
+			return View{}, errors.New("The template can't have the same file name as its layout.")
+		}
+
+		// the selected layout is parsed again after the others so that its
+		// blocks are the defaults
+		files = append(files, layoutPath)
+		file = filepath.Base(layoutPath)
+	}
+	files = append(files, templatePath)
+
+	defined, include, err := templates.shadowedInclude(templatePath)
+	if err != nil {
+		return View{}, err
+	}
+	if defined != "" {
+		return View{}, errors.New("The template " + viewConf.TemplateFile + " defines " + defined + ", which would replace the one of the include " + include + ".")
+	}
+
+	var TextTemplateA *text_template.Template
+	var HTMLTemplateA *html_template.Template
+	if viewConf.Unsafe {
+		TextTemplateA, err = templates.textTemplate(viewConf, files...)
+	} else {
+		HTMLTemplateA, err = templates.htmlTemplate(viewConf, files...)
+	}
+
+	if err != nil {
+		return View{}, err
+	}
+
+	return View{
+		ViewConfig:            viewConf,
+		HTMLTemplate:          HTMLTemplateA,
+		TextTemplate:          TextTemplateA,
+		TemplateName:          file,
+		OutputPath:            outputPath(viewConf),
+		MultipageVariableHook: multipageVariableHook,
+		MultipageVariables:    multipageVariables,
+		outputTemplate:        outputTemplate,
+		fields:                fields,
+	}, nil
+}
+
+func remove(values []string, value string) []string {
+	var remaining []string
+	for _, v := range values {
+		if v != value {
+			remaining = append(remaining, v)
 		}
+	}
+	return remaining
+}
 
-		view := View{
-			ViewConfig:            viewConf,
-			HTMLTemplate:          HTMLTemplateA,
-			TextTemplate:          TextTemplateA,
-			TemplateName:          file,
-			MultipageVariableHook: multipageVariableHook,
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
 		}
-		views = append(views, view)
 	}
-	return views, nil
+	return false
 }
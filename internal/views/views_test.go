@@ -0,0 +1,642 @@
+package views
+
+import (
+	"errors"
+	"fmt"
+	html_template "html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	text_template "text/template"
+
+	"github.com/glaciers-in-archives/snowman/internal/config"
+	"github.com/glaciers-in-archives/snowman/internal/sparql"
+	"github.com/knakk/rdf"
+)
+
+func TestOutputPath(t *testing.T) {
+	yes, no := true, false
+	ttl, none := ".ttl", ""
+
+	var tests = []struct {
+		cleanURLs bool
+		viewConf  viewConfig
+		want      string
+	}{
+		{false, viewConfig{Output: "about.html"}, "about.html"},
+		{true, viewConfig{Output: "about.html"}, "about/index.html"},
+		{true, viewConfig{Output: "index.html"}, "index.html"},
+		{true, viewConfig{Output: "items/{{id}}.html"}, "items/{{id}}/index.html"},
+		{true, viewConfig{Output: "data.json"}, "data.json"},
+		{true, viewConfig{Output: "about.html", CleanURLs: &no}, "about.html"},
+		{false, viewConfig{Output: "about.html", CleanURLs: &yes}, "about/index.html"},
+		{false, viewConfig{Output: "items/{{id}}.html", Extension: &ttl}, "items/{{id}}.ttl"},
+		{false, viewConfig{Output: "items/{{id}}.html", Extension: &none}, "items/{{id}}"},
+		{true, viewConfig{Output: "robots", OutputType: "text"}, "robots.txt"},
+		{true, viewConfig{Output: "items/{{id}}", OutputType: "html"}, "items/{{id}}/index.html"},
+		{false, viewConfig{Output: "items/{{id}}.rdf", OutputType: "xml"}, "items/{{id}}.rdf"},
+		{false, viewConfig{Output: "items/{{id}}", OutputType: "json", Extension: &none}, "items/{{id}}"},
+	}
+
+	defer func(previous bool) { config.CurrentSiteConfig.CleanURLs = previous }(config.CurrentSiteConfig.CleanURLs)
+
+	for _, test := range tests {
+		config.CurrentSiteConfig.CleanURLs = test.cleanURLs
+		if got := outputPath(test.viewConf); got != test.want {
+			t.Errorf("Output path of %s was %s, expected %s", test.viewConf.Output, got, test.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	queries := map[string]string{
+		"works.rq":  "SELECT ?qid ?label WHERE { ?qid rdfs:label ?label }",
+		"sorted.rq": "SELECT ?qid ?label WHERE { ?qid rdfs:label ?label } ORDER BY ?qid",
+	}
+	hook := "qid"
+
+	var tests = []struct {
+		view     View
+		problems int
+		warnings int
+	}{
+		{View{ViewConfig: viewConfig{Output: "index.html", QueryFile: "works.rq"}}, 0, 0},
+		{View{ViewConfig: viewConfig{Output: "index.html"}}, 0, 0},
+		{View{ViewConfig: viewConfig{Output: "index.html", QueryFile: "missing.rq"}}, 1, 0},
+		{View{ViewConfig: viewConfig{Output: "works/{{ qid }}.html", QueryFile: "works.rq"}}, 1, 0},
+		{View{ViewConfig: viewConfig{Output: "works/{{qid}}.html", QueryFile: "works.rq"}, MultipageVariableHook: &hook, MultipageVariables: []string{"qid"}}, 0, 0},
+		{View{ViewConfig: viewConfig{Output: "works/{{qid}}.html"}, MultipageVariableHook: &hook, MultipageVariables: []string{"qid"}}, 1, 0},
+		{View{ViewConfig: viewConfig{Output: "works/{{year}}.html", QueryFile: "works.rq"}, MultipageVariableHook: &hook, MultipageVariables: []string{"year"}}, 0, 1},
+		{View{ViewConfig: viewConfig{Output: "works/{{qid}}.html", QueryFile: "works.rq", Search: map[string]string{"title": "label", "body": "abstract"}}, MultipageVariableHook: &hook, MultipageVariables: []string{"qid"}}, 0, 1},
+		{View{ViewConfig: viewConfig{Output: "index.html", QueryFile: "works.rq", Search: map[string]string{"url": "qid"}}}, 2, 0},
+		{View{ViewConfig: viewConfig{Output: "index.html", QueryFile: "works.rq", FetchPageSize: 100}}, 0, 1},
+		{View{ViewConfig: viewConfig{Output: "index.html", QueryFile: "sorted.rq", FetchPageSize: 100}}, 0, 0},
+	}
+
+	for _, test := range tests {
+		var problems, warnings ValidationError
+		validate(test.view, queries, &problems, &warnings)
+		if len(problems.Problems) != test.problems || len(warnings.Problems) != test.warnings {
+			t.Errorf("Validating %s found %v and warned %v", test.view.ViewConfig.Output, problems.Problems, warnings.Problems)
+		}
+	}
+}
+
+func TestLevels(t *testing.T) {
+	view := func(output string, dependsOn ...string) View {
+		return View{ViewConfig: viewConfig{Output: output, DependsOn: dependsOn}}
+	}
+
+	levels, err := Levels([]View{view("index.html", "data.json"), view("about.html"), view("data.json"), view("feed.xml", "index.html", "about.html")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, level := range levels {
+		var outputs []string
+		for _, v := range level {
+			outputs = append(outputs, v.ViewConfig.Output)
+		}
+		got = append(got, outputs)
+	}
+	expected := "[[about.html data.json] [index.html] [feed.xml]]"
+	if fmt.Sprint(got) != expected {
+		t.Errorf("Expected levels %s, got %v", expected, got)
+	}
+
+	if _, err := Levels([]View{view("a.html", "b.html"), view("b.html", "a.html"), view("c.html")}); err == nil {
+		t.Error("Expected a dependency cycle to be an error")
+	}
+}
+
+func TestSkipDrafts(t *testing.T) {
+	published := SkipDrafts([]View{
+		{ViewConfig: viewConfig{Output: "draft.html", Draft: true}},
+		{ViewConfig: viewConfig{Output: "index.html", DependsOn: []string{"draft.html", "data.json"}}},
+		{ViewConfig: viewConfig{Output: "data.json"}},
+	})
+	if len(published) != 2 || fmt.Sprint(published[0].ViewConfig.DependsOn) != "[data.json]" {
+		t.Errorf("Expected the draft and the dependency on it to be skipped, got %v", published)
+	}
+}
+
+func TestMultipageOutput(t *testing.T) {
+	view := View{OutputPath: "works/{{qid}}.html", MultipageVariables: []string{"qid"}}
+	label, _ := rdf.NewLiteral("Mona Lisa")
+	qid, _ := rdf.NewLiteral("Q12418")
+
+	output, err := view.MultipageOutput(map[string]rdf.Term{"qid": qid, "label": label})
+	if err != nil || output != "works/Q12418.html" {
+		t.Errorf("Expected works/Q12418.html, got %q and error %v", output, err)
+	}
+
+	// rows of OPTIONAL clauses might not bind the variable at all
+	_, err = view.MultipageOutput(map[string]rdf.Term{"label": label})
+	var unbound UnboundError
+	if !errors.As(err, &unbound) || unbound.Variable != "qid" {
+		t.Errorf("Expected an UnboundError for qid, got %v", err)
+	}
+}
+
+func TestMultipageOutputEncoding(t *testing.T) {
+	var tests = []struct {
+		encoding string
+		value    string
+		want     string
+	}{
+		{"", "paintings/Q12418", "works/paintings/Q12418.html"},
+		{"raw", "Mona Lisa", "works/Mona Lisa.html"},
+		{"raw", "../../etc/passwd", ""},
+		{"raw", "Q1\\..\\secret", ""},
+		{"slugify", "Mona Lisa / La Joconde", "works/mona-lisa-la-joconde.html"},
+		{"slugify", "Ängsö slott", "works/ängsö-slott.html"},
+		{"slugify", "../../etc/passwd", "works/etc-passwd.html"},
+		{"slugify", "..", ""},
+		{"urlencode", "AC/DC live", "works/AC%2FDC%20live.html"},
+		{"urlencode", "..", ""},
+	}
+	for _, test := range tests {
+		view := View{ViewConfig: viewConfig{PathEncoding: test.encoding}, OutputPath: "works/{{name}}.html", MultipageVariables: []string{"name"}}
+		value, _ := rdf.NewLiteral(test.value)
+		output, err := view.MultipageOutput(map[string]rdf.Term{"name": value})
+		if test.want == "" && err == nil {
+			t.Errorf("Expected %q to be rejected with the %s encoding, got %s", test.value, test.encoding, output)
+		} else if test.want != "" && output != test.want {
+			t.Errorf("Expected %q to give %s with the %s encoding, got %q and error %v", test.value, test.want, test.encoding, output, err)
+		}
+	}
+
+	view := View{OutputPath: "../{{name}}.html", MultipageVariables: []string{"name"}}
+	value, _ := rdf.NewLiteral("index")
+	if _, err := view.MultipageOutput(map[string]rdf.Term{"name": value}); err == nil {
+		t.Error("Expected an output outside of the site directory to be rejected")
+	}
+}
+
+func TestRenderPageWithUnboundVariable(t *testing.T) {
+	tpl, err := html_template.New("page.html").Parse(`<p>{{ .label }}</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := View{HTMLTemplate: tpl, TemplateName: "page.html"}
+
+	path := filepath.Join(t.TempDir(), "page.html")
+	qid, _ := rdf.NewLiteral("Q12418")
+	if err := view.RenderPage(path, map[string]rdf.Term{"qid": qid}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "<p></p>" {
+		t.Errorf("Expected the unbound variable to render empty, got %q", content)
+	}
+}
+
+func TestRenderPageRemovesPartialFile(t *testing.T) {
+	// the error comes after more output than is buffered
+	tpl, err := text_template.New("page.txt").Parse(`{{ range .items }}{{ . }}{{ end }}{{ index .items 100000 }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := View{TextTemplate: tpl, TemplateName: "page.txt", ViewConfig: viewConfig{Unsafe: true}}
+
+	items := make([]string, 10000)
+	for i := range items {
+		items[i] = "a line of the listing\n"
+	}
+	path := filepath.Join(t.TempDir(), "page.txt")
+	if err := view.RenderPage(path, map[string]interface{}{"items": items}); err == nil {
+		t.Fatal("Expected rendering to fail")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial page to be removed, got %v", err)
+	}
+}
+
+func TestRootFileConfigs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"root/robots.txt", "root/.htaccess", "root/errors/404.html"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{{ site.Title }}"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	defer func(previous config.SiteConfig) { config.CurrentSiteConfig = previous }(config.CurrentSiteConfig)
+	config.CurrentSiteConfig.TemplatesDir = dir
+	config.CurrentSiteConfig.RootFiles = config.RootFilesConfig{Dir: "root", Output: "."}
+
+	configs, err := rootFileConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]bool{}
+	for _, viewConf := range configs {
+		if viewConf.TemplateFile != "root/"+viewConf.Output {
+			t.Errorf("Expected %s to be written to the same relative path, got %s", viewConf.TemplateFile, viewConf.Output)
+		}
+		got[viewConf.Output] = viewConf.Unsafe
+	}
+	want := map[string]bool{"robots.txt": true, ".htaccess": true, "errors/404.html": false}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected root files %v, got %v", want, got)
+	}
+
+	config.CurrentSiteConfig.RootFiles.Dir = "missing"
+	if _, err := rootFileConfigs(); err == nil {
+		t.Error("Expected a missing root_files directory to be an error")
+	}
+}
+
+func TestFramedView(t *testing.T) {
+	dir := t.TempDir()
+	framePath := filepath.Join(dir, "frame.json")
+	if err := os.WriteFile(framePath, []byte(`{"@context": {"@vocab": "http://schema.org/"}, "@type": "Book"}`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newView(viewConfig{Output: "books.jsonld", TemplateFile: "books.html", JSONLDFrame: framePath}, nil); err == nil {
+		t.Error("Expected a view with both a template and a jsonld_frame to be invalid")
+	}
+
+	view, err := newView(viewConfig{Output: "books.jsonld", JSONLDFrame: framePath}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, _ := rdf.NewIRI("http://example.org/book")
+	typeIRI, _ := rdf.NewIRI("http://www.w3.org/1999/02/22-rdf-syntax-ns#type")
+	bookType, _ := rdf.NewIRI("http://schema.org/Book")
+	graph := &sparql.Graph{Triples: []rdf.Triple{{Subj: book, Pred: typeIRI, Obj: bookType}}}
+
+	path := filepath.Join(dir, "books.jsonld")
+	if err := view.RenderPage(path, graph); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "{\n  \"@context\": {\n    \"@vocab\": \"http://schema.org/\"\n  },\n  \"@graph\": [\n    {\n      \"@id\": \"http://example.org/book\",\n      \"@type\": \"Book\"\n    }\n  ]\n}"
+	if string(content) != expected {
+		t.Errorf("Expected the framed graph %s, got %s", expected, content)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	config.CurrentSiteConfig.Language = config.Languages{"nl", "en"}
+	defer func() { config.CurrentSiteConfig.Language = nil }()
+
+	var problems ValidationError
+	configs := localize([]viewConfig{
+		{Output: "{{lang}}/index.html"},
+		{Output: "{{lang}}/about.html", DependsOn: []string{"{{lang}}/index.html"}},
+		{Output: "sitemap.xml", DependsOn: []string{"{{lang}}/index.html"}},
+		{Output: "{{lang}}/de.html", Language: config.Languages{"de"}},
+	}, &problems)
+	if len(problems.Problems) > 0 {
+		t.Fatal(problems.Error())
+	}
+
+	var outputs []string
+	for _, viewConf := range configs {
+		outputs = append(outputs, fmt.Sprint(viewConf.Output, viewConf.Language, viewConf.DependsOn))
+	}
+	expected := "[nl/index.html[nl en] [] en/index.html[en nl] [] nl/about.html[nl en] [nl/index.html] en/about.html[en nl] [en/index.html] sitemap.xml[] [nl/index.html en/index.html] de/de.html[de] []]"
+	if actual := fmt.Sprint(outputs); actual != expected {
+		t.Errorf("Expected the localized views %s, got %s", expected, actual)
+	}
+
+	config.CurrentSiteConfig.Language = nil
+	localize([]viewConfig{{Output: "{{lang}}/index.html"}}, &problems)
+	if len(problems.Problems) != 1 {
+		t.Error("Expected a localized view without languages to be invalid")
+	}
+}
+
+func TestTransform(t *testing.T) {
+	transform := &transformConfig{
+		Fields:  map[string]string{"name": `{{ .first }} {{ .last }}`},
+		GroupBy: "person",
+		Collect: []string{"work"},
+	}
+	fields, err := parseFields(transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := View{ViewConfig: viewConfig{Transform: transform}, fields: fields}
+
+	term := func(value string) rdf.Term {
+		literal, _ := rdf.NewLiteral(value)
+		return literal
+	}
+	row := func(person, first, last, work string) map[string]rdf.Term {
+		return map[string]rdf.Term{"person": term(person), "first": term(first), "last": term(last), "work": term(work)}
+	}
+	rows := []map[string]rdf.Term{
+		row("p1", "Ada", "Lovelace", "Notes"),
+		row("p2", "Alan", "Turing", "On Computable Numbers"),
+		row("p1", "Ada", "Lovelace", "Sketch"),
+		row("p1", "Ada", "Lovelace", "Notes"),
+	}
+
+	transformed, err := view.Transform(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transformed.Rows) != 4 || len(transformed.Items) != 2 {
+		t.Fatalf("Expected the 4 rows to form 2 groups, got %v", transformed)
+	}
+	first := transformed.Items[0].(map[string]interface{})
+	if actual := fmt.Sprint(first["person"], " ", first["name"], " ", first["work"], " ", len(first["Rows"].([]map[string]rdf.Term))); actual != "p1 Ada Lovelace [Notes Sketch] 3" {
+		t.Errorf("Expected the group of p1 with its distinct works, got %s", actual)
+	}
+
+	if _, err := parseFields(&transformConfig{Fields: map[string]string{"broken": "{{ .first"}}); err == nil {
+		t.Error("Expected a field with a malformed template to be invalid")
+	}
+}
+
+func TestSiblings(t *testing.T) {
+	row := func(id string) map[string]rdf.Term {
+		literal, _ := rdf.NewLiteral(id)
+		return map[string]rdf.Term{"id": literal}
+	}
+	pages := Siblings([]interface{}{row("a"), row("b"), row("c")})
+	if len(pages) != 3 {
+		t.Fatalf("Expected 3 pages, got %d", len(pages))
+	}
+
+	middle := pages[1]
+	if actual := fmt.Sprint(middle["id"], " ", middle["Index"], " of ", middle["Total"], " ", middle["Prev"].(map[string]rdf.Term)["id"], " ", middle["Next"].(map[string]rdf.Term)["id"]); actual != "b 2 of 3 a c" {
+		t.Errorf("Expected the second page with its neighbours, got %s", actual)
+	}
+	if pages[0]["Prev"] != nil || pages[2]["Next"] != nil {
+		t.Error("Expected no previous page on the first and no next page on the last")
+	}
+}
+
+func TestSidecar(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous string) { config.CurrentSiteConfig.TemplatesDir = previous }(config.CurrentSiteConfig.TemplatesDir)
+	config.CurrentSiteConfig.TemplatesDir = dir
+	if err := os.WriteFile(filepath.Join(dir, "item.html"), []byte("{{ .id }}"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		viewConf viewConfig
+		want     string
+	}{
+		{viewConfig{Output: "items/{{id}}.html", Sidecar: "json"}, "site/items/1.json"},
+		{viewConfig{Output: "items/{{id}}.html", Sidecar: "yaml"}, "site/items/1.yaml"},
+		{viewConfig{Output: "items/{{id}}.json", Sidecar: "json"}, ""},
+		{viewConfig{Output: "items/{{id}}.YAML", Sidecar: "yaml"}, ""},
+		{viewConfig{Output: "items/{{id}}", OutputType: "json", Sidecar: "json"}, ""},
+	}
+
+	templates, err := newTemplateCache(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		test.viewConf.TemplateFile = "item.html"
+		view, err := newView(test.viewConf, templates)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("Expected the sidecar of %s to be rejected", test.viewConf.Output)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %v", test.viewConf.Output, err)
+			continue
+		}
+		if got := view.SidecarPath("site/items/1.html"); got != test.want {
+			t.Errorf("Sidecar path of %s was %s, expected %s", test.viewConf.Output, got, test.want)
+		}
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous string) { config.CurrentSiteConfig.TemplatesDir = previous }(config.CurrentSiteConfig.TemplatesDir)
+	config.CurrentSiteConfig.TemplatesDir = dir
+
+	if err := os.MkdirAll(filepath.Join(dir, "layouts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := filepath.Join(dir, "layouts", "base.html")
+	other := filepath.Join(dir, "layouts", "other.html")
+	broken := filepath.Join(dir, "layouts", "broken.html")
+	files := map[string]string{
+		base:                            `<main>{{ block "content" . }}base{{ end }}</main>`,
+		other:                           `<div>{{ block "content" . }}other{{ end }}</div>`,
+		broken:                          `{{ .Unclosed`,
+		filepath.Join(dir, "page.html"): `{{ define "content" }}{{ current_view.Output }}{{ end }}`,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	templates, err := newTemplateCache([]string{base, other, broken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, output := range []string{"a.html", "b.html"} {
+		view, err := newView(viewConfig{Output: output, TemplateFile: "page.html", Layout: "base.html"}, templates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, output)
+		if err := view.RenderPage(path, nil); err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := os.ReadFile(path); string(content) != "<main>"+output+"</main>" {
+			t.Errorf("Unexpected page %s", content)
+		}
+	}
+
+	// the broken layout only fails the views using it
+	if _, err := newView(viewConfig{Output: "c.html", TemplateFile: "page.html", Layout: "broken.html"}, templates); err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("Expected an error naming %s, got %v", broken, err)
+	}
+}
+
+func TestTemplateShadowing(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous string) { config.CurrentSiteConfig.TemplatesDir = previous }(config.CurrentSiteConfig.TemplatesDir)
+	config.CurrentSiteConfig.TemplatesDir = dir
+
+	files := map[string]string{
+		"layouts/base.html":  `<main>{{ block "content" . }}{{ end }}</main>`,
+		"layouts/other.html": `<div>{{ block "content" . }}{{ end }}</div>`,
+		"includes/card.html": `{{ define "card" }}{{ .label }}{{ end }}`,
+		"includes/menu.html": `{{ define "card" }}menu{{ end }}`,
+		"includes/body.html": `{{ define "content" }}body{{ end }}`,
+		"includes/nav.html":  `<nav></nav>`,
+		"page.html":          `{{ define "content" }}{{ template "card" . }}{{ end }}`,
+		"card.html":          `{{ define "card" }}page{{ end }}`,
+		"nav.html":           `<nav>{{ template "card" . }}</nav>`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	var tests = []struct {
+		shared []string
+		ok     bool
+	}{
+		{[]string{path("layouts/base.html"), path("layouts/other.html"), path("includes/card.html"), path("includes/nav.html")}, true},
+		{[]string{path("includes/card.html"), path("includes/menu.html")}, false},
+		{[]string{path("layouts/base.html"), path("includes/body.html")}, false},
+	}
+	for _, test := range tests {
+		if _, err := newTemplateCache(test.shared); (err == nil) != test.ok {
+			t.Errorf("Unexpected result for %v: %v", test.shared, err)
+		}
+	}
+
+	templates, err := newTemplateCache(tests[0].shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newView(viewConfig{Output: "index.html", TemplateFile: "page.html", Layout: "base.html"}, templates); err != nil {
+		t.Error(err)
+	}
+	for _, template := range []string{"card.html", "nav.html"} {
+		if _, err := newView(viewConfig{Output: "index.html", TemplateFile: template}, templates); err == nil {
+			t.Errorf("Expected %s to be reported for replacing an include", template)
+		}
+	}
+}
+
+func TestOnly(t *testing.T) {
+	all := []View{
+		{ViewConfig: viewConfig{Output: "index.html", TemplateFile: "index.html", DependsOn: []string{"data.json"}}},
+		{ViewConfig: viewConfig{Output: "items/{{id}}.html", TemplateFile: "item.html"}},
+		{ViewConfig: viewConfig{Output: "data.json", TemplateFile: "data.json"}},
+	}
+
+	var tests = []struct {
+		patterns     []string
+		dependencies bool
+		expected     string
+	}{
+		{[]string{"items/*"}, false, "[items/{{id}}.html]"},
+		{[]string{"item.html"}, false, "[items/{{id}}.html]"},
+		{[]string{"index.html"}, false, "[index.html]"},
+		{[]string{"index.html"}, true, "[index.html data.json]"},
+		{[]string{"index.*", "*.json"}, false, "[index.html data.json]"},
+	}
+	for _, test := range tests {
+		only, err := Only(all, test.patterns, test.dependencies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var outputs []string
+		for _, view := range only {
+			outputs = append(outputs, view.ViewConfig.Output)
+		}
+		if fmt.Sprint(outputs) != test.expected {
+			t.Errorf("Expected %s for %v, got %v", test.expected, test.patterns, outputs)
+		}
+	}
+
+	if only, _ := Only(all, []string{"index.html"}, false); len(only[0].ViewConfig.DependsOn) != 0 {
+		t.Error("Expected the dependency on the skipped view to be dropped")
+	}
+	if _, err := Only(all, []string{"missing/*"}, false); err == nil {
+		t.Error("Expected an error for a pattern matching no view")
+	}
+}
+
+func TestOutputFacets(t *testing.T) {
+	label, _ := rdf.NewLiteral("Mona Lisa")
+	item, _ := rdf.NewIRI("http://www.wikidata.org/entity/Q12418")
+	row := map[string]rdf.Term{"label": label, "item": item}
+
+	var tests = []struct {
+		output   string
+		expected string
+	}{
+		{"{{ first_letter .label }}/{{ .label.Value }}.html", "m/Mona Lisa.html"},
+		{"{{ prefix .label 2 }}/{{ local_name .item }}.html", "mo/Q12418.html"},
+		{"{{ hash .label.Value 2 }}/{{ lcase .label }}.html", "9c/mona lisa.html"},
+		{"{{ prefix .label 0 }}/{{ .label.Value }}.html", ""},
+	}
+	for _, test := range tests {
+		tpl, variables, err := parseOutputTemplate(test.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		view := View{OutputPath: test.output, MultipageVariables: variables, outputTemplate: tpl}
+		output, err := view.MultipageOutput(row)
+		if test.expected == "" && err == nil {
+			t.Errorf("Expected %s to be rejected, got %s", test.output, output)
+		} else if test.expected != "" && output != test.expected {
+			t.Errorf("Expected %s for %s, got %q and error %v", test.expected, test.output, output, err)
+		}
+	}
+}
+
+func TestOutputTemplate(t *testing.T) {
+	title, _ := rdf.NewLiteral("Mona Lisa")
+	year, _ := rdf.NewLiteral("1503")
+	dots, _ := rdf.NewLiteral("..")
+	row := map[string]rdf.Term{"title": title, "year": year}
+
+	var tests = []struct {
+		output   string
+		row      map[string]rdf.Term
+		expected string
+	}{
+		{"{{ .year.Value }}/{{ slugify .title.Value }}.html", row, "1503/mona-lisa.html"},
+		{"works/{{ with .id }}{{ .Value }}{{ else }}{{ .year }}{{ end }}.html", row, "works/1503.html"},
+		{"{{ .year.Value }}/{{ .missing.Value }}.html", row, ""},
+		{"{{ .title.Value }}/../../{{ .year.Value }}.html", row, ""},
+		{"{{ .title.Value }}", map[string]rdf.Term{"title": dots}, ""},
+	}
+	for _, test := range tests {
+		tpl, variables, err := parseOutputTemplate(test.output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		view := View{OutputPath: test.output, MultipageVariables: variables, outputTemplate: tpl}
+		output, err := view.MultipageOutput(test.row)
+		if test.expected == "" && err == nil {
+			t.Errorf("Expected %s to be rejected, got %s", test.output, output)
+		} else if test.expected != "" && output != test.expected {
+			t.Errorf("Expected %s for %s, got %q and error %v", test.expected, test.output, output, err)
+		}
+	}
+
+	_, variables, _ := parseOutputTemplate("{{ .year.Value }}/{{ with .id }}{{ .Value }}{{ end }}.html")
+	if fmt.Sprint(variables) != "[year id]" {
+		t.Errorf("Expected the variables year and id, got %v", variables)
+	}
+	if !isOutputTemplate("{{ .id.Value }}.html") || isOutputTemplate("works/{{year}}/{{id}}.html") {
+		t.Error("Expected only outputs with template actions to be output templates")
+	}
+	if _, _, err := parseOutputTemplate("{{ now }}.html"); err == nil {
+		t.Error("Expected an output template without variables to be rejected")
+	}
+}
@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/glaciers-in-archives/snowman/internal/logger"
+)
+
+// Watcher reports changes to a set of files and directories. Directories are
+// watched recursively and events are debounced so that a burst of changes,
+// like a save in an editor, results in a single notification.
+type Watcher struct {
+	fsWatcher *fsnotify.Watcher
+	debounce  time.Duration
+}
+
+func NewWatcher(paths []string, debounce time.Duration) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := Watcher{fsWatcher: fsWatcher, debounce: debounce}
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+
+		if err := w.add(path); err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+	}
+
+	return &w, nil
+}
+
+func (w *Watcher) add(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path == root {
+			return w.fsWatcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// Run blocks and calls onChange with the sorted list of changed paths whenever
+// the watched files have been quiet for the debounce duration. Errors of the
+// file system notifications are logged without stopping the watching.
+func (w *Watcher) Run(onChange func(changed []string)) error {
+	changed := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+
+			// newly created directories need to be watched as well
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.add(event.Name); err != nil {
+						logger.Warn("Failed to watch " + event.Name + ". Error: " + err.Error())
+					}
+				}
+			}
+
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			changed[event.Name] = true
+			timer.Reset(w.debounce)
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("Failed to watch the project files. Error: " + err.Error())
+		case <-timer.C:
+			var paths []string
+			for path := range changed {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			changed = make(map[string]bool)
+			onChange(paths)
+		}
+	}
+}
+
+func (w *Watcher) Close() error {
+	return w.fsWatcher.Close()
+}
@@ -5,6 +5,9 @@ platforms=("windows/amd64" "linux/386" "linux/amd64" "linux/arm64" "darwin/amd64
 echo -n "Please enter the path prefix to trim from the executables: "
 read trimpath
 
+package='github.com/glaciers-in-archives/snowman/internal/version'
+ldflags="-X $package.commit=$(git rev-parse HEAD) -X $package.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+
 for platform in "${platforms[@]}"
 do
     platform_split=(${platform//\// })
@@ -15,7 +18,7 @@ do
         output_name+='.exe'
     fi
 
-    env GOOS=$GOOS GOARCH=$GOARCH go build -gcflags=-trimpath=$trimpath -asmflags=-trimpath=$trimpath -o $output_name main.go
+    env GOOS=$GOOS GOARCH=$GOARCH go build -gcflags=-trimpath=$trimpath -asmflags=-trimpath=$trimpath -ldflags="$ldflags" -o $output_name main.go
     if [ $? -ne 0 ]; then
         echo 'An error has occurred! Aborting the script execution...'
         exit 1